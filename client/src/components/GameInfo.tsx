@@ -14,6 +14,7 @@ import {
 } from '@mui/material';
 import ContentCopyIcon from '@mui/icons-material/ContentCopy';
 import {
+   type CharacterView,
    type GameStateView,
    type ItemView,
    type RoomView,
@@ -54,6 +55,17 @@ const SLOT_LABELS: Record<string, string> = {
 
 const SLOT_ORDER = ['head', 'chest', 'legs', 'hands', 'feet', 'back'] as const;
 
+/** HP label for a party member, showing the death save tally while they are down. */
+function hpLabel(c: CharacterView): string {
+   if (!c.alive) return 'DEAD';
+   const saves = c.death_saves
+      ? ` (${c.death_saves.successes}✓ ${c.death_saves.failures}✗)`
+      : '';
+   if (c.vital === 'dying') return `DYING${saves}`;
+   if (c.vital === 'stable') return `STABLE${saves}`;
+   return `${c.health}/${c.dnd?.max_hp ?? 100} HP`;
+}
+
 interface GameInfoProps {
    gameState: GameStateView | null;
    sendAction: (subAction: string, payload: string) => void;
@@ -596,9 +608,7 @@ export const GameInfo = ({
                                  : 'error.main',
                            }}
                         >
-                            {gameState.self.alive
-                               ? `${gameState.self.health}/${gameState.self.dnd?.max_hp ?? 100} HP`
-                               : 'DEAD'}
+                            {hpLabel(gameState.self)}
                          </Typography>
                       </Box>
                       <LinearProgress
@@ -645,7 +655,7 @@ export const GameInfo = ({
                                     : 'error.main',
                               }}
                            >
-                               {member.alive ? `${member.health}/${member.dnd?.max_hp ?? 100} HP` : 'DEAD'}
+                               {hpLabel(member)}
                             </Typography>
                          </Box>
                          <LinearProgress
@@ -891,6 +901,7 @@ export const GameInfo = ({
                                     { label: 'AC', value: dnd.ac },
                                     { label: 'Speed', value: `${dnd.speed} ft` },
                                     { label: 'Proficiency', value: `+${dnd.proficiency_bonus}` },
+                                    { label: 'XP', value: dnd.next_level_xp ? `${dnd.xp} / ${dnd.next_level_xp}` : dnd.xp },
                                  ].map(({ label, value }) => (
                                     <Box key={label} sx={{ display: 'flex', justifyContent: 'space-between', alignItems: 'center', px: 1, py: 0.5, borderRadius: 1, background: 'rgba(201,169,98,0.06)', border: '1px solid rgba(201,169,98,0.12)' }}>
                                        <Typography variant="caption" sx={{ color: 'text.secondary', textTransform: 'uppercase', letterSpacing: '0.05em', fontSize: '0.65rem' }}>
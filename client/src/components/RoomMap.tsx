@@ -781,7 +781,7 @@ const MapCanvas = ({
                                  <Text
                                     x={-52}
                                     y={4}
-                                    text={room.name}
+                                    text={room.unexplored ? '???' : room.name}
                                     fontSize={11}
                                     fill="#E8DCC4"
                                     fontFamily="Cinzel, Georgia, serif"
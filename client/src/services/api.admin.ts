@@ -1,4 +1,4 @@
-import { GET, PUT } from './api.service';
+import { DELETE, GET, PUT } from './api.service';
 
 export interface AdminUserView {
    user_id: string;
@@ -9,6 +9,7 @@ export interface AdminUserView {
    tokens_used: number;
    games_limit: number; // 0 = unlimited
    billing_mode: string;
+   ai_provider?: string; // absent = deployment default
    notes?: string;
    created_at: number;
 }
@@ -21,11 +22,17 @@ export interface AdminStats {
    total_tokens_used: number;
 }
 
+export interface ReleaseLeaseResponse {
+   status: 'ok';
+   released: boolean; // false when no turn held the session's lease
+}
+
 export interface UpdateUserRequest {
    role: 'admin' | 'user' | 'restricted';
    ai_enabled: boolean;
    token_limit: number;
    games_limit: number;
+   ai_provider?: string; // omit to keep the user's provider; '' = deployment default
    notes: string;
 }
 
@@ -45,3 +52,13 @@ export async function getAdminStats(): Promise<AdminStats> {
    const res = await GET<AdminStats>('api/admin/stats');
    return res.data;
 }
+
+/** Force-release a session's streaming lease when a narrator turn is stuck. */
+export async function releaseSessionLease(
+   sessionId: string,
+): Promise<ReleaseLeaseResponse> {
+   const res = await DELETE<ReleaseLeaseResponse>(
+      `api/admin/sessions/${sessionId}/lease`,
+   );
+   return res.data;
+}
@@ -1,5 +1,10 @@
 import { DELETE, GET, POST } from './api.service';
-import type { GameStateView, CharacterCreationData } from '../types/types';
+import type {
+   GameStateView,
+   CharacterCreationData,
+   LevelUpRequest,
+   LevelUpResult,
+} from '../types/types';
 
 export interface GameListItem {
    session_id: string;
@@ -10,6 +15,7 @@ export interface GameListItem {
    quest_goal?: string;
    conversation_count?: number;
    total_tokens?: number;
+   outcome?: 'cleared' | 'failed'; // set once the dungeon run has ended
 }
 
 export interface UserQuotaInfo {
@@ -83,3 +89,15 @@ export async function JoinCharacter(
    );
    return res.data;
 }
+
+/** Advance the caller's character one level once they have enough XP. */
+export async function LevelUp(
+   sessionId: string,
+   params: LevelUpRequest,
+): Promise<LevelUpResult> {
+   const res = await POST<LevelUpResult>(
+      `api/games/${sessionId}/level-up`,
+      params,
+   );
+   return res.data;
+}
@@ -15,9 +15,12 @@ export interface CharacterCreationData {
    backstory?: string; // optional 2-3 sentence character backstory
    race_id: string; // e.g. "dwarf"
    subrace_id?: string; // e.g. "hill-dwarf"
-   class_id: string; // "barbarian" | "fighter" | "monk"
+   class_id: string; // "barbarian" | "fighter" | "monk" | "cleric" | "wizard" | "warlock"
+   subclass_id?: string; // e.g. "life-domain"; defaulted for clerics
    ability_scores: Record<string, number>; // "str","dex","con","int","wis","cha" -> value
    selected_skills: string[];
+   selected_cantrips?: string[]; // casters only, e.g. "fire-bolt"
+   selected_spells?: string[]; // wizard spellbook / warlock known spells
    theme_hint?: string;
    preferences?: string[];
 }
@@ -32,6 +35,76 @@ export interface DnDStatsView {
    speed: number;
    proficiency_bonus: number;
    abilities: Record<string, number>; // "str","dex","con","int","wis","cha" -> score
+   xp: number;
+   next_level_xp?: number; // total XP for the next level; absent at level 20
+   can_level_up?: boolean;
+   spell_slots?: Record<number, SpellSlot>; // spell level -> slots; casters only
+   spells?: string[]; // known cantrips and spells; cast with the 'cast' sub_action
+}
+
+export interface SpellSlot {
+   max: number;
+   used: number;
+}
+
+// Level-up choices: 'level_up' sub_action payload and POST /api/games/{id}/level-up body
+export interface LevelUpRequest {
+   hp_method?: 'roll' | 'average'; // default average
+   ability_increases?: Record<string, number>; // ASI levels only: +2 to one or +1 to two
+   spells?: string[]; // wizard: 2, warlock: 1 new leveled spells
+}
+
+export interface LevelUpResult {
+   level: number;
+   hp_gained: number;
+   max_hp: number;
+   new_features?: string[];
+}
+
+// Rewind: POST /api/games/{id}/rewind body and response (owner only). Over
+// WebSocket the 'rewind' sub_action takes the turn number as its payload.
+export interface RewindRequest {
+   turn: number; // the session goes back to how it was before this narrator turn
+}
+
+export interface RewindResult {
+   session_id: string;
+   turn: number;
+   conversation_count: number;
+   version: number;
+}
+
+// Named checkpoints: GET/POST /api/games/{id}/checkpoints lists and creates
+// them; POST .../checkpoints/{checkpoint_id}/restore and .../fork (owner only)
+// go back to one or start a new session from it.
+export interface CheckpointView {
+   checkpoint_id: string;
+   name: string;
+   created_by: string; // userID
+   created_at: number; // Unix ms
+   conversation_count: number;
+}
+
+export interface ListCheckpointsResponse {
+   checkpoints: CheckpointView[]; // oldest first
+}
+
+export interface CreateCheckpointRequest {
+   name: string;
+}
+
+export interface RestoreCheckpointResult {
+   session_id: string;
+   checkpoint_id: string;
+   conversation_count: number;
+   version: number;
+}
+
+export interface ForkCheckpointResult {
+   session_id: string; // the new session
+   source_session_id: string;
+   checkpoint_id: string;
+   ready: boolean;
 }
 
 export interface Coordinates {
@@ -68,9 +141,16 @@ export interface CharacterView {
    friendly: boolean;
    inventory: ItemView[];
    equipment: EquipmentView;
+   vital?: 'conscious' | 'dying' | 'stable' | 'dead'; // D&D dying rules; absent for legacy characters
+   death_saves?: DeathSavesView; // set while at 0 HP and not dead
    dnd?: DnDStatsView; // present for v3+ characters
 }
 
+export interface DeathSavesView {
+   successes: number;
+   failures: number;
+}
+
 export type DungeonRoomType =
    | 'entrance'
    | 'chamber'
@@ -79,6 +159,24 @@ export type DungeonRoomType =
    | 'corridor'
    | 'junction';
 
+export type MonsterHPStatus =
+   | 'unhurt'
+   | 'wounded'
+   | 'bloodied'
+   | 'near_death'
+   | 'dead';
+
+export interface MonsterView {
+   id: string; // target for the "attack" game_action
+   name: string;
+   hp?: number; // omitted when the server hides exact HP
+   max_hp?: number;
+   hp_status: MonsterHPStatus;
+   ac?: number; // present once the party has attacked this monster
+   alive: boolean;
+   conditions?: string[];
+}
+
 export interface RoomView {
    id: string;
    name: string;
@@ -88,6 +186,8 @@ export interface RoomView {
    coordinates: Coordinates;
    items: ItemView[];
    occupants: CharacterView[];
+   monsters?: MonsterView[]; // omitted when the room has no monsters
+   unexplored?: boolean; // fog-of-war stub: known exit target, not yet visited (no name/description)
 }
 
 export interface GameStateView {
@@ -96,11 +196,65 @@ export interface GameStateView {
    self?: CharacterView; // calling user's own character (v2+)
    party?: CharacterView[]; // other party members (v2+)
    rooms: Record<string, RoomView>;
-   chat_history: ChatMessage[];
+   chat_history: ChatMessage[]; // the latest page only
+   older_chat?: number; // messages before chat_history; page back with GET /api/games/{id}/chat
+   turn?: TurnView; // present while a fight is in progress
+   grid?: GridView; // combat grid of the current room, once anyone is placed
+   action_round?: ActionRoundView; // present while round mode is on
+   ooc_chat?: OOCMessage[]; // recent out-of-character chat, oldest first; only GET /api/games/{id} fills it
+}
+
+// Hex position in offset coordinates; sent back as "x,y" with the 'step' sub_action
+export interface GridPos {
+   x: number;
+   y: number;
+}
+
+export interface GridView {
+   room_id: string;
+   width: number;
+   height: number;
+   positions: Record<string, GridPos>; // userID or monsterID → position
+}
+
+export interface InitiativeEntry {
+   combatant_id: string;
+   combatant_name: string;
+   roll: number;
+   is_player: boolean;
+}
+
+// Round mode (the owner sends the 'round_mode' sub_action with payload
+// "on"/"off"): each chat declares the sender's intent, and the narrator
+// resolves the whole round once everyone has declared or it times out.
+export interface IntentView {
+   user_id: string;
+   name: string; // the author's character name
+   content: string;
+}
+
+// Payload of the 'round_updated' frame and GameStateView.action_round
+export interface ActionRoundView {
+   intents: IntentView[];
+   waiting_on: string[]; // names of members yet to declare
+   deadline?: number; // unix ms when the server resolves the round anyway; absent before the first intent
+   timed_out?: boolean; // the round ran out of time and is resolved without waiting_on
+}
+
+// Payload of the 'turn_changed' frame; active=false once the fight is over
+export interface TurnView {
+   active: boolean;
+   room_id?: string;
+   round?: number;
+   combatant_id?: string; // userID for players, monsterID for monsters
+   combatant_name?: string;
+   is_player?: boolean;
+   deadline?: number; // unix ms after which the server skips the turn
+   order?: InitiativeEntry[];
 }
 
 export interface WorldEvent {
-   type: string; // "damage","heal","death","revive","item_gained","item_lost","item_appeared","character_arrived","character_departed"
+   type: string; // "damage","heal","death","revive","item_gained","item_lost","item_appeared","character_arrived","character_departed","ability_check"
    message: string; // human-readable, player's perspective
 }
 
@@ -108,10 +262,35 @@ export interface ChatMessage {
    type: 'player' | 'narrative';
    content: string;
    events?: WorldEvent[]; // non-empty on narrative messages when world events occurred this turn
+   private_to?: string; // userID of the only member who sees it: a whisper or the reply to one
    /** ISO timestamp when the message was committed (client-side). Added on receive; absent for messages loaded from chat_history. */
    timestamp?: string;
 }
 
+// One page of GET /api/games/{id}/chat?before=&limit=. Request
+// ?before=<start> for the page before; start 0 is the first message.
+export interface ChatPage {
+   messages: ChatMessage[];
+   start: number;
+   total: number;
+}
+
+// Out-of-character party chat: the 'ooc_message' frame payload. The narrator
+// never sees it.
+export interface OOCMessage {
+   user_id: string;
+   name: string; // the sender's character name when sent
+   content: string;
+   sent_at: number; // Unix seconds
+   seq: number; // position in the session log, increasing with every message
+}
+
+// Client → server 'ooc' frame; content is capped at 1000 characters.
+export interface OOCRequest {
+   action: 'ooc';
+   content: string;
+}
+
 // WebSocket frame types sent from server to client
 export type WsFrameType =
    | 'narrative_chunk'
@@ -121,7 +300,18 @@ export type WsFrameType =
    | 'error'
    | 'streaming_blocked'
    | 'world_gen_log'
-   | 'world_gen_ready';
+   | 'world_gen_ready'
+   | 'dungeon_ended'
+   | 'turn_changed'
+   | 'ooc_message'
+   | 'round_updated';
+
+// Payload of the 'dungeon_ended' frame. The epilogue is not part of it: it
+// follows as narrative chunks from a turn the server starts right away.
+export interface DungeonOutcome {
+   state: 'cleared' | 'failed';
+   message: string;
+}
 
 export interface WsFrame {
    type: WsFrameType;
@@ -130,6 +320,16 @@ export interface WsFrame {
 
 export interface NarrativeChunkPayload {
    content: string;
+   private?: boolean; // part of the narrator's reply to the caller's whisper
+}
+
+// Client → server 'chat' frame. A private chat is a whisper: the narrator
+// answers the sender alone and the rest of the party never sees either message.
+export interface ChatRequest {
+   action: 'chat';
+   content: string;
+   private?: boolean; // refused in round mode
+   resolve?: boolean; // round mode: resolve a timed-out round without an intent (content may be empty)
 }
 
 export interface WorldGenLogPayload {
@@ -143,6 +343,7 @@ export interface StateDelta {
    party?: CharacterView[]; // updated party member views
    updated_rooms?: Record<string, RoomView>;
    events?: WorldEvent[]; // player-visible world events this turn
+   monsters?: MonsterView[]; // monsters in the member's current room
    // new_message removed — narrative arrives via streaming frames, not state_delta
 }
 
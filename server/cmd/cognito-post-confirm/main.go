@@ -0,0 +1,12 @@
+// cognito-post-confirm is the Lambda entry point for package cognitopostconfirm, which holds the
+// handler so cmd/local-server can mount it too.
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/rrochlin/an-amazing-adventure/internal/handlers/cognitopostconfirm"
+)
+
+func main() {
+	lambda.Start(cognitopostconfirm.Handler)
+}
@@ -0,0 +1,12 @@
+// http-admin is the Lambda entry point for package httpadmin, which holds the
+// handler so cmd/local-server can mount it too.
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/rrochlin/an-amazing-adventure/internal/handlers/httpadmin"
+)
+
+func main() {
+	lambda.Start(httpadmin.Handler)
+}
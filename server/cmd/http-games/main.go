@@ -0,0 +1,12 @@
+// http-games is the Lambda entry point for package httpgames, which holds the
+// handler so cmd/local-server can mount it too.
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/rrochlin/an-amazing-adventure/internal/handlers/httpgames"
+)
+
+func main() {
+	lambda.Start(httpgames.Handler)
+}
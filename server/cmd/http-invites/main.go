@@ -0,0 +1,12 @@
+// http-invites is the Lambda entry point for package httpinvites, which holds the
+// handler so cmd/local-server can mount it too.
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/rrochlin/an-amazing-adventure/internal/handlers/httpinvites"
+)
+
+func main() {
+	lambda.Start(httpinvites.Handler)
+}
@@ -0,0 +1,12 @@
+// http-users is the Lambda entry point for package httpusers, which holds the
+// handler so cmd/local-server can mount it too.
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/rrochlin/an-amazing-adventure/internal/handlers/httpusers"
+)
+
+func main() {
+	lambda.Start(httpusers.Handler)
+}
@@ -0,0 +1,517 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/rrochlin/an-amazing-adventure/internal/auth"
+)
+
+// httpHandler is the signature of the API Gateway V2 HTTP Lambdas.
+type httpHandler func(context.Context, events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error)
+
+// wsHandler is the signature of the API Gateway WebSocket Lambdas.
+type wsHandler func(context.Context, events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// gateway stands in for the AWS services between the client and the
+// handlers: the HTTP API (routes, path parameters, JWT claims), the WebSocket
+// API and its Management API (@connections), and async Lambda invokes.
+type gateway struct {
+	mux *http.ServeMux
+
+	// WebSocket API: $connect / $disconnect and the message routes selected
+	// by $request.body.action.
+	connect    wsHandler
+	disconnect wsHandler
+	wsRoutes   map[string]wsHandler
+
+	// functions are the Lambdas reachable through the Invoke API, keyed by
+	// function name (see WORLD_GEN_ARN and GAME_ACTION_ARN).
+	functions map[string]lambda.Handler
+
+	// onUser, if set, is called with the caller's sub on every authenticated
+	// request before the handler runs.
+	onUser func(ctx context.Context, userID string)
+
+	upgrader websocket.Upgrader
+
+	mu    sync.Mutex
+	conns map[string]*wsConn // keyed by connection ID
+}
+
+// wsConn is a live client WebSocket. Writes are serialised: gorilla allows
+// one concurrent writer and handlers post to connections from goroutines.
+type wsConn struct {
+	mu          sync.Mutex
+	ws          *websocket.Conn
+	connectedAt time.Time
+	sourceIP    string
+	userAgent   string
+}
+
+func (c *wsConn) write(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ws.WriteMessage(websocket.TextMessage, data)
+}
+
+func newGateway() *gateway {
+	gw := &gateway{
+		mux:       http.NewServeMux(),
+		wsRoutes:  make(map[string]wsHandler),
+		functions: make(map[string]lambda.Handler),
+		conns:     make(map[string]*wsConn),
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(*http.Request) bool { return true }, // local dev only
+		},
+	}
+	gw.mux.HandleFunc("GET /ws", gw.serveWebSocket)
+	gw.mux.HandleFunc("/@connections/{id}", gw.serveConnection)
+	gw.mux.HandleFunc("POST /2015-03-31/functions/{name}/invocations", gw.serveInvoke)
+	return gw
+}
+
+// ServeHTTP adds permissive CORS so a client dev server on another port can
+// call the API, then dispatches to the routes.
+func (gw *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	gw.mux.ServeHTTP(w, r)
+}
+
+// -------------------------------------------------------------------
+// HTTP API
+// -------------------------------------------------------------------
+
+var pathParamRe = regexp.MustCompile(`\{(\w+)\}`)
+
+// route mounts h on an API Gateway route key such as
+// "GET /api/games/{uuid}". Routes with auth require a bearer token and pass
+// its claims on like the Cognito JWT authorizer does.
+func (gw *gateway) route(routeKey string, h httpHandler, auth bool) {
+	var params []string
+	for _, m := range pathParamRe.FindAllStringSubmatch(routeKey, -1) {
+		params = append(params, m[1])
+	}
+	gw.mux.HandleFunc(routeKey, func(w http.ResponseWriter, r *http.Request) {
+		var claims map[string]string
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			claims, _ = tokenClaims(token)
+		}
+		if auth && claims["sub"] == "" {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "Unauthorized"})
+			return
+		}
+		if claims["sub"] != "" && gw.onUser != nil {
+			gw.onUser(r.Context(), claims["sub"])
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Bad Request"})
+			return
+		}
+		req := httpRequest(r, routeKey, claims, string(body))
+		if len(params) > 0 {
+			req.PathParameters = make(map[string]string, len(params))
+			for _, p := range params {
+				req.PathParameters[p] = r.PathValue(p)
+			}
+		}
+
+		resp, err := h(r.Context(), req)
+		if err != nil {
+			log.Printf("local-server: %s: %v", routeKey, err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"message": "Internal Server Error"})
+			return
+		}
+		writeResponse(w, resp)
+	})
+}
+
+// httpRequest translates r into the API Gateway V2 (payload format 2.0)
+// event. Header names are lower-cased and repeated headers and query
+// parameters are comma-joined, as API Gateway does.
+func httpRequest(r *http.Request, routeKey string, claims map[string]string, body string) events.APIGatewayV2HTTPRequest {
+	headers := make(map[string]string, len(r.Header))
+	for k, v := range r.Header {
+		headers[strings.ToLower(k)] = strings.Join(v, ",")
+	}
+	var query map[string]string
+	if q := r.URL.Query(); len(q) > 0 {
+		query = make(map[string]string, len(q))
+		for k, v := range q {
+			query[k] = strings.Join(v, ",")
+		}
+	}
+	var cookies []string
+	for _, c := range r.Cookies() {
+		cookies = append(cookies, c.String())
+	}
+	now := time.Now()
+	return events.APIGatewayV2HTTPRequest{
+		Version:               "2.0",
+		RouteKey:              routeKey,
+		RawPath:               r.URL.Path,
+		RawQueryString:        r.URL.RawQuery,
+		Cookies:               cookies,
+		Headers:               headers,
+		QueryStringParameters: query,
+		Body:                  body,
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			RouteKey:   routeKey,
+			Stage:      "$default",
+			RequestID:  uuid.NewString(),
+			DomainName: r.Host,
+			Time:       now.UTC().Format("02/Jan/2006:15:04:05 -0700"),
+			TimeEpoch:  now.UnixMilli(),
+			Authorizer: &events.APIGatewayV2HTTPRequestContextAuthorizerDescription{
+				JWT: &events.APIGatewayV2HTTPRequestContextAuthorizerJWTDescription{Claims: claims},
+			},
+			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				Protocol:  r.Proto,
+				SourceIP:  sourceIP(r),
+				UserAgent: r.UserAgent(),
+			},
+		},
+	}
+}
+
+// writeResponse writes a Lambda proxy response back to the HTTP client.
+func writeResponse(w http.ResponseWriter, resp events.APIGatewayV2HTTPResponse) {
+	for k, v := range resp.Headers {
+		w.Header().Set(k, v)
+	}
+	for k, vs := range resp.MultiValueHeaders {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	for _, c := range resp.Cookies {
+		w.Header().Add("Set-Cookie", c)
+	}
+	body := []byte(resp.Body)
+	if resp.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(resp.Body)
+		if err != nil {
+			log.Printf("local-server: decode base64 response body: %v", err)
+		} else {
+			body = decoded
+		}
+	}
+	status := resp.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// tokenClaims decodes a JWT's claims into the string map the Cognito JWT
+// authorizer passes to handlers (arrays become "[a b]"). The signature is not
+// checked — this server is for local development only.
+func tokenClaims(token string) (map[string]string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed JWT")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("decode payload: %w", err)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("unmarshal claims: %w", err)
+	}
+	if exp, ok := raw["exp"].(float64); ok && time.Now().Unix() > int64(exp) {
+		return nil, fmt.Errorf("token expired")
+	}
+	claims := make(map[string]string, len(raw))
+	for k, v := range raw {
+		switch v := v.(type) {
+		case string:
+			claims[k] = v
+		case float64:
+			claims[k] = fmt.Sprintf("%.0f", v)
+		default:
+			claims[k] = fmt.Sprint(v)
+		}
+	}
+	return claims, nil
+}
+
+// unverifiedTokens stands in for the Cognito verifier when no user pool is
+// configured: it trusts whatever sub an unexpired token claims.
+type unverifiedTokens struct{}
+
+func (unverifiedTokens) Verify(_ context.Context, token string) (auth.Claims, error) {
+	claims, err := tokenClaims(token)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+	if claims["sub"] == "" {
+		return auth.Claims{}, fmt.Errorf("missing sub claim")
+	}
+	return auth.Claims{Sub: claims["sub"], TokenUse: claims["token_use"], ClientID: claims["client_id"]}, nil
+}
+
+// -------------------------------------------------------------------
+// WebSocket API
+// -------------------------------------------------------------------
+
+// serveWebSocket runs $connect, upgrades the connection if it succeeded,
+// routes every message by its "action" field, and runs $disconnect when the
+// client goes away.
+func (gw *gateway) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	connID := newConnectionID()
+	c := &wsConn{connectedAt: time.Now(), sourceIP: sourceIP(r), userAgent: r.UserAgent()}
+
+	if token := r.URL.Query().Get("token"); token != "" && gw.onUser != nil {
+		if claims, err := tokenClaims(token); err == nil && claims["sub"] != "" {
+			gw.onUser(r.Context(), claims["sub"])
+		}
+	}
+
+	req := gw.wsRequest(r.Host, c, connID, "$connect", "CONNECT", "")
+	req.Headers = make(map[string]string, len(r.Header))
+	for k, v := range r.Header {
+		req.Headers[k] = strings.Join(v, ",")
+	}
+	req.QueryStringParameters = make(map[string]string)
+	for k, v := range r.URL.Query() {
+		req.QueryStringParameters[k] = strings.Join(v, ",")
+	}
+	if gw.connect != nil {
+		resp, err := gw.connect(r.Context(), req)
+		if err != nil {
+			log.Printf("local-server: $connect %s: %v", connID, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if resp.StatusCode != 0 && resp.StatusCode != http.StatusOK {
+			w.WriteHeader(resp.StatusCode)
+			_, _ = io.WriteString(w, resp.Body)
+			return
+		}
+	}
+
+	ws, err := gw.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("local-server: upgrade %s: %v", connID, err)
+		return
+	}
+	c.ws = ws
+	gw.mu.Lock()
+	gw.conns[connID] = c
+	gw.mu.Unlock()
+	log.Printf("local-server: connected %s", connID)
+
+	host := r.Host
+	defer func() {
+		gw.mu.Lock()
+		delete(gw.conns, connID)
+		gw.mu.Unlock()
+		_ = ws.Close()
+		if gw.disconnect != nil {
+			invokeSafely("$disconnect", func() {
+				_, _ = gw.disconnect(context.Background(), gw.wsRequest(host, c, connID, "$disconnect", "DISCONNECT", ""))
+			})
+		}
+		log.Printf("local-server: disconnected %s", connID)
+	}()
+
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		// API Gateway invokes the route Lambda per message, concurrently.
+		go gw.routeMessage(host, c, connID, string(data))
+	}
+}
+
+// routeMessage runs the route selected by $request.body.action. Like API
+// Gateway with no $default route, unknown actions get a "Forbidden" frame.
+func (gw *gateway) routeMessage(host string, c *wsConn, connID, body string) {
+	var msg struct {
+		Action string `json:"action"`
+	}
+	_ = json.Unmarshal([]byte(body), &msg)
+	h, ok := gw.wsRoutes[msg.Action]
+	if !ok {
+		frame, _ := json.Marshal(map[string]string{
+			"message":      "Forbidden",
+			"connectionId": connID,
+			"requestId":    uuid.NewString(),
+		})
+		_ = c.write(frame)
+		return
+	}
+	invokeSafely(msg.Action, func() {
+		req := gw.wsRequest(host, c, connID, msg.Action, "MESSAGE", body)
+		if _, err := h(context.Background(), req); err != nil {
+			log.Printf("local-server: route %s on %s: %v", msg.Action, connID, err)
+		}
+	})
+}
+
+// wsRequest builds the API Gateway WebSocket proxy event for connID.
+func (gw *gateway) wsRequest(host string, c *wsConn, connID, routeKey, eventType, body string) events.APIGatewayWebsocketProxyRequest {
+	now := time.Now()
+	return events.APIGatewayWebsocketProxyRequest{
+		Body: body,
+		RequestContext: events.APIGatewayWebsocketProxyRequestContext{
+			Stage:             "local",
+			RequestID:         uuid.NewString(),
+			ExtendedRequestID: uuid.NewString(),
+			Identity:          events.APIGatewayRequestIdentity{SourceIP: c.sourceIP, UserAgent: c.userAgent},
+			ConnectedAt:       c.connectedAt.UnixMilli(),
+			ConnectionID:      connID,
+			DomainName:        host,
+			EventType:         eventType,
+			MessageDirection:  "IN",
+			RequestTime:       now.UTC().Format("02/Jan/2006:15:04:05 -0700"),
+			RequestTimeEpoch:  now.UnixMilli(),
+			RouteKey:          routeKey,
+		},
+	}
+}
+
+// serveConnection implements the API Gateway Management API the handlers
+// reach through wsutil: POST sends a frame, GET describes the connection and
+// DELETE closes it. Unknown connections answer 410 GoneException.
+func (gw *gateway) serveConnection(w http.ResponseWriter, r *http.Request) {
+	connID := r.PathValue("id")
+	gw.mu.Lock()
+	c, ok := gw.conns[connID]
+	gw.mu.Unlock()
+	if !ok {
+		w.Header().Set("X-Amzn-Errortype", "GoneException")
+		writeJSON(w, http.StatusGone, map[string]string{"message": "Gone"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+			return
+		}
+		if err := c.write(data); err != nil {
+			w.Header().Set("X-Amzn-Errortype", "GoneException")
+			writeJSON(w, http.StatusGone, map[string]string{"message": err.Error()})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]any{
+			"connectedAt":  c.connectedAt.UTC().Format(time.RFC3339),
+			"lastActiveAt": time.Now().UTC().Format(time.RFC3339),
+			"identity":     map[string]string{"sourceIp": c.sourceIP, "userAgent": c.userAgent},
+		})
+	case http.MethodDelete:
+		_ = c.ws.Close()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// -------------------------------------------------------------------
+// Lambda Invoke API
+// -------------------------------------------------------------------
+
+// serveInvoke implements Lambda Invoke for the registered functions. Event
+// (async) invocations run in a goroutine and return 202 straight away, the
+// way http-games fires world-gen.
+func (gw *gateway) serveInvoke(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	fn, ok := gw.functions[name]
+	if !ok {
+		w.Header().Set("X-Amzn-Errortype", "ResourceNotFoundException")
+		writeJSON(w, http.StatusNotFound, map[string]string{"Type": "User", "Message": "Function not found: " + name})
+		return
+	}
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return
+	}
+
+	if r.Header.Get("X-Amz-Invocation-Type") == "Event" {
+		go invokeSafely(name, func() {
+			if _, err := fn.Invoke(context.Background(), payload); err != nil {
+				log.Printf("local-server: %s: %v", name, err)
+			}
+		})
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	out, err := fn.Invoke(r.Context(), payload)
+	if err != nil {
+		w.Header().Set("X-Amz-Function-Error", "Unhandled")
+		writeJSON(w, http.StatusOK, map[string]string{"errorMessage": err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(out)
+}
+
+// -------------------------------------------------------------------
+// helpers
+// -------------------------------------------------------------------
+
+// invokeSafely runs fn, logging instead of crashing the server if the handler
+// panics (Lambda reports a panic as a failed invocation).
+func invokeSafely(name string, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("local-server: %s panicked: %v", name, r)
+		}
+	}()
+	fn()
+}
+
+// newConnectionID returns an API Gateway style connection ID.
+func newConnectionID() string {
+	b := make([]byte, 10)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func sourceIP(r *http.Request) string {
+	host := r.RemoteAddr
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	return strings.Trim(host, "[]")
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	apigateway_types "github.com/aws/aws-sdk-go-v2/service/apigatewaymanagementapi/types"
+	awslambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	awslambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/gorilla/websocket"
+	"github.com/rrochlin/an-amazing-adventure/internal/db"
+	"github.com/rrochlin/an-amazing-adventure/internal/wsutil"
+)
+
+// fakeToken builds an unsigned JWT carrying claims.
+func fakeToken(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"none"}`)) + "." + enc.EncodeToString(payload) + ".sig"
+}
+
+// localAWSEnv points the AWS SDK at srv with dummy credentials.
+func localAWSEnv(t *testing.T, srv *httptest.Server) {
+	t.Helper()
+	t.Setenv("AWS_REGION", "us-west-2")
+	t.Setenv("AWS_ACCESS_KEY_ID", "local")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "local")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	t.Setenv("WEBSOCKET_API_ENDPOINT", srv.URL)
+}
+
+// ---- HTTP API ----
+
+func TestRoute_TranslatesRequest(t *testing.T) {
+	gw := newGateway()
+	requests := make(chan events.APIGatewayV2HTTPRequest, 1)
+	gw.route("POST /api/games/{uuid}/level-up", func(_ context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+		requests <- req
+		return events.APIGatewayV2HTTPResponse{
+			StatusCode: 201,
+			Headers:    map[string]string{"Content-Type": "application/json"},
+			Body:       `{"ok":true}`,
+		}, nil
+	}, true)
+	provisioned := make(chan string, 1)
+	gw.onUser = func(_ context.Context, userID string) { provisioned <- userID }
+	srv := httptest.NewServer(gw)
+	defer srv.Close()
+
+	token := fakeToken(t, map[string]any{
+		"sub":            "user-1",
+		"cognito:groups": []string{"admin"},
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	})
+	req, _ := http.NewRequest("POST", srv.URL+"/api/games/game-9/level-up?hp=roll", strings.NewReader(`{"hp_method":"roll"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != 201 || string(body) != `{"ok":true}` {
+		t.Errorf("response = %d %s", resp.StatusCode, body)
+	}
+	got := <-requests
+	if got.RouteKey != "POST /api/games/{uuid}/level-up" || got.RequestContext.HTTP.Method != "POST" {
+		t.Errorf("route = %q method = %q", got.RouteKey, got.RequestContext.HTTP.Method)
+	}
+	if got.RequestContext.HTTP.Path != "/api/games/game-9/level-up" || got.PathParameters["uuid"] != "game-9" {
+		t.Errorf("path = %q params = %v", got.RequestContext.HTTP.Path, got.PathParameters)
+	}
+	if got.QueryStringParameters["hp"] != "roll" || got.Body != `{"hp_method":"roll"}` {
+		t.Errorf("query = %v body = %q", got.QueryStringParameters, got.Body)
+	}
+	claims := got.RequestContext.Authorizer.JWT.Claims
+	if claims["sub"] != "user-1" || claims["cognito:groups"] != "[admin]" {
+		t.Errorf("claims = %v", claims)
+	}
+	if userID := <-provisioned; userID != "user-1" {
+		t.Errorf("expected onUser for user-1, got %q", userID)
+	}
+}
+
+func TestRoute_RequiresToken(t *testing.T) {
+	gw := newGateway()
+	called := make(chan struct{}, 2)
+	ok := func(context.Context, events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+		called <- struct{}{}
+		return events.APIGatewayV2HTTPResponse{StatusCode: 200}, nil
+	}
+	gw.route("GET /api/games", ok, true)
+	gw.route("GET /api/invites/{code}", ok, false)
+	srv := httptest.NewServer(gw)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/games")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 401 || len(called) != 0 {
+		t.Errorf("expected 401 without calling the handler, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(srv.URL + "/api/invites/ABC123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 || len(called) != 1 {
+		t.Errorf("expected the public route to run, got %d", resp.StatusCode)
+	}
+}
+
+// ---- WebSocket API ----
+
+func TestWebSocket_RoutesMessagesAndPostsFrames(t *testing.T) {
+	gw := newGateway()
+	connectQuery := make(chan map[string]string, 1)
+	gw.connect = func(_ context.Context, req events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+		connectQuery <- req.QueryStringParameters
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	}
+	disconnected := make(chan string, 1)
+	gw.disconnect = func(_ context.Context, req events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+		disconnected <- req.RequestContext.ConnectionID
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	}
+	// chat echoes the message back through the Management API, as ws-chat does.
+	gw.wsRoutes["chat"] = func(ctx context.Context, req events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+		ws, err := wsutil.New(ctx)
+		if err != nil {
+			return events.APIGatewayProxyResponse{StatusCode: 500}, err
+		}
+		err = ws.SendNarrativeChunk(ctx, req.RequestContext.ConnectionID, req.RequestContext.RouteKey)
+		return events.APIGatewayProxyResponse{StatusCode: 200}, err
+	}
+	srv := httptest.NewServer(gw)
+	defer srv.Close()
+	localAWSEnv(t, srv)
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?token=abc&gameId=g1"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	if q := <-connectQuery; q["gameId"] != "g1" || q["token"] != "abc" {
+		t.Errorf("$connect query = %v", q)
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"action":"chat","content":"hi"}`)); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var frame wsutil.Frame
+	if err := conn.ReadJSON(&frame); err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	if frame.Type != wsutil.FrameNarrativeChunk {
+		t.Errorf("frame type = %q", frame.Type)
+	}
+	if payload, _ := frame.Payload.(map[string]any); payload["content"] != "chat" {
+		t.Errorf("frame payload = %v", frame.Payload)
+	}
+
+	conn.Close()
+	select {
+	case <-disconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected $disconnect after the client closed")
+	}
+}
+
+func TestWebSocket_ConnectRejected(t *testing.T) {
+	gw := newGateway()
+	gw.connect = func(context.Context, events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{StatusCode: 403, Body: "forbidden"}, nil
+	}
+	srv := httptest.NewServer(gw)
+	defer srv.Close()
+
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	if err == nil {
+		t.Fatal("expected the upgrade to fail")
+	}
+	if resp == nil || resp.StatusCode != 403 {
+		t.Errorf("expected 403, got %v", resp)
+	}
+}
+
+func TestConnections_UnknownIsGone(t *testing.T) {
+	srv := httptest.NewServer(newGateway())
+	defer srv.Close()
+	localAWSEnv(t, srv)
+
+	ws, err := wsutil.New(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ws.SendNarrativeEnd(context.Background(), "no-such-connection")
+	var gone *apigateway_types.GoneException
+	if !errors.As(err, &gone) {
+		t.Errorf("expected GoneException, got %v", err)
+	}
+}
+
+// ---- Lambda Invoke API ----
+
+func TestInvoke_EventRunsInBackground(t *testing.T) {
+	gw := newGateway()
+	got := make(chan map[string]string, 1)
+	gw.functions[worldGenFunction] = lambda.NewHandler(func(_ context.Context, evt map[string]string) error {
+		got <- evt
+		return nil
+	})
+	srv := httptest.NewServer(gw)
+	defer srv.Close()
+	localAWSEnv(t, srv)
+
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := awslambda.NewFromConfig(cfg, func(o *awslambda.Options) {
+		o.BaseEndpoint = aws.String(srv.URL)
+	})
+	out, err := client.Invoke(context.Background(), &awslambda.InvokeInput{
+		FunctionName:   aws.String(worldGenFunction),
+		InvocationType: awslambdatypes.InvocationTypeEvent,
+		Payload:        []byte(`{"session_id":"s1"}`),
+	})
+	if err != nil {
+		t.Fatalf("invoke: %v", err)
+	}
+	if out.StatusCode != 202 {
+		t.Errorf("status = %d, want 202", out.StatusCode)
+	}
+	select {
+	case evt := <-got:
+		if evt["session_id"] != "s1" {
+			t.Errorf("payload = %v", evt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("world-gen was not invoked")
+	}
+}
+
+func TestDialAddr(t *testing.T) {
+	cases := map[string]string{
+		":8080":          "127.0.0.1:8080",
+		"0.0.0.0:9000":   "127.0.0.1:9000",
+		"localhost:8080": "localhost:8080",
+	}
+	for in, want := range cases {
+		if got := dialAddr(in); got != want {
+			t.Errorf("dialAddr(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestProvisioner_GivesUnknownCallersItsRole(t *testing.T) {
+	store := db.NewMemory()
+	p := newProvisioner(store, "user")
+	p.ensure(context.Background(), "user-1")
+
+	rec, err := store.GetUser(context.Background(), "user-1")
+	if err != nil || rec == nil {
+		t.Fatalf("GetUser: %v %v", rec, err)
+	}
+	if rec.Role != "user" || !rec.AIEnabled {
+		t.Errorf("expected an AI-enabled non-admin record, got role=%q ai=%v", rec.Role, rec.AIEnabled)
+	}
+}
@@ -0,0 +1,228 @@
+// local-server runs every Lambda handler behind one HTTP + WebSocket listener
+// so a game can be played end to end on a laptop without deploying.
+//
+// It stands in for API Gateway and Lambda:
+//
+//	/api/...                 — the HTTP API routes, with JWT claims and path parameters
+//	/ws                      — the WebSocket API ($connect, chat, ooc, game_action, $disconnect)
+//	/@connections/{id}       — the Management API the handlers post frames to
+//	/2015-03-31/functions/…  — Lambda Invoke; world-gen and forwarded game actions run in a goroutine
+//
+// The handlers are the same code the Lambdas run, so they still use DynamoDB
+// and Bedrock through the usual AWS environment: set SESSIONS_TABLE,
+// SESSION_LOG_TABLE, CONNECTIONS_TABLE, LEASES_TABLE, MUTATIONS_TABLE, USERS_TABLE,
+// INVITES_TABLE and MEMBERSHIPS_TABLE (e.g. with doppler), and AWS_ENDPOINT_URL_DYNAMODB to use
+// DynamoDB Local. With -memory they share one in-process db.Memory instead
+// and no tables are needed; everything is lost on exit. WEBSOCKET_API_ENDPOINT,
+// WORLD_GEN_ARN, GAME_ACTION_ARN, CHAT_ARN and AWS_ENDPOINT_URL_LAMBDA are
+// pointed at this server.
+// AI_PROVIDER=fake swaps Bedrock for ai.FakeProvider's offline replies, and
+// AI_PROVIDER=openai for a self-hosted OpenAI-compatible server.
+//
+// Bearer tokens on HTTP routes are decoded but not verified, so the server
+// listens on loopback only unless -addr says otherwise. WebSocket tokens are
+// verified like ws-connect does when USER_POOL_ID and USER_POOL_CLIENT_ID
+// are set, and only decoded otherwise. With -provision (the default) the
+// first request from an unknown user creates an AI-enabled record with the
+// "user" role so the game is playable straight away; -provision-admin makes
+// it an admin record instead, for trying out the admin routes.
+//
+// Point the client at it with VITE_APP_URI=http://localhost:8080 and
+// VITE_WS_ENDPOINT=ws://localhost:8080/ws.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/rrochlin/an-amazing-adventure/internal/auth"
+	"github.com/rrochlin/an-amazing-adventure/internal/db"
+	"github.com/rrochlin/an-amazing-adventure/internal/handlers/httpadmin"
+	"github.com/rrochlin/an-amazing-adventure/internal/handlers/httpgames"
+	"github.com/rrochlin/an-amazing-adventure/internal/handlers/httpinvites"
+	"github.com/rrochlin/an-amazing-adventure/internal/handlers/httpusers"
+	"github.com/rrochlin/an-amazing-adventure/internal/handlers/worldgen"
+	"github.com/rrochlin/an-amazing-adventure/internal/handlers/wschat"
+	"github.com/rrochlin/an-amazing-adventure/internal/handlers/wsconnect"
+	"github.com/rrochlin/an-amazing-adventure/internal/handlers/wsdisconnect"
+	"github.com/rrochlin/an-amazing-adventure/internal/handlers/wsgameaction"
+	"github.com/rrochlin/an-amazing-adventure/internal/handlers/wsooc"
+)
+
+// worldGenFunction is the function name http-games invokes world-gen by.
+const worldGenFunction = "world-gen"
+
+// gameActionFunction is the function name ws-chat and ws-ooc forward
+// game actions by (see wsutil.Forward).
+const gameActionFunction = "ws-game-action"
+
+// chatFunction is the function name ws-ooc and ws-game-action forward the
+// resolve of a timed-out action round by.
+const chatFunction = "ws-chat"
+
+// requiredTables are the table env vars db.New reads.
+var requiredTables = []string{
+	"SESSIONS_TABLE", "SESSION_LOG_TABLE", "CONNECTIONS_TABLE", "LEASES_TABLE", "MUTATIONS_TABLE",
+	"USERS_TABLE", "INVITES_TABLE", "MEMBERSHIPS_TABLE",
+}
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8080", "listen address")
+	provision := flag.Bool("provision", true, "create an AI-enabled user record for unknown callers")
+	provisionAdmin := flag.Bool("provision-admin", false, "give provisioned users the admin role")
+	memory := flag.Bool("memory", false, "keep all state in memory instead of DynamoDB")
+	flag.Parse()
+
+	var store db.Store
+	if *memory {
+		store = db.NewMemory()
+	} else {
+		var missing []string
+		for _, name := range requiredTables {
+			if os.Getenv(name) == "" {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			log.Fatalf("local-server: missing env vars: %s (or run with -memory)", strings.Join(missing, ", "))
+		}
+		client, err := db.New(context.Background())
+		if err != nil {
+			log.Fatalf("local-server: db init: %v", err)
+		}
+		store = client
+	}
+
+	self := "http://" + dialAddr(*addr)
+	if os.Getenv("AWS_REGION") == "" {
+		os.Setenv("AWS_REGION", "us-west-2")
+	}
+	os.Setenv("WEBSOCKET_API_ENDPOINT", self)
+	os.Setenv("WORLD_GEN_ARN", worldGenFunction)
+	os.Setenv("GAME_ACTION_ARN", gameActionFunction)
+	os.Setenv("CHAT_ARN", chatFunction)
+	os.Setenv("AWS_ENDPOINT_URL_LAMBDA", self)
+
+	games := httpgames.New(store)
+	admin := httpadmin.New(store)
+	invites := httpinvites.New(store)
+
+	gw := newGateway()
+	gw.route("GET /api/games", games, true)
+	gw.route("POST /api/games", games, true)
+	gw.route("GET /api/games/{uuid}", games, true)
+	gw.route("DELETE /api/games/{uuid}", games, true)
+	gw.route("POST /api/games/{uuid}/join-character", games, true)
+	gw.route("POST /api/games/{uuid}/retry-world-gen", games, true)
+	gw.route("POST /api/games/{uuid}/level-up", games, true)
+	gw.route("POST /api/games/{uuid}/rewind", games, true)
+	gw.route("GET /api/games/{uuid}/chat", games, true)
+	gw.route("GET /api/games/{uuid}/checkpoints", games, true)
+	gw.route("POST /api/games/{uuid}/checkpoints", games, true)
+	gw.route("POST /api/games/{uuid}/checkpoints/{checkpoint_id}/restore", games, true)
+	gw.route("POST /api/games/{uuid}/checkpoints/{checkpoint_id}/fork", games, true)
+	gw.route("POST /api/users", httpusers.Handler, true)
+	gw.route("PUT /api/users", httpusers.Handler, true)
+	gw.route("GET /api/admin/users", admin, true)
+	gw.route("PUT /api/admin/users/{userId}", admin, true)
+	gw.route("GET /api/admin/stats", admin, true)
+	gw.route("DELETE /api/admin/sessions/{sessionId}/lease", admin, true)
+	gw.route("POST /api/invites", invites, true)
+	gw.route("GET /api/invites/{code}", invites, false)
+	gw.route("POST /api/invites/{code}/join", invites, true)
+
+	var tokens auth.TokenVerifier = unverifiedTokens{}
+	if v, err := auth.VerifierFromEnv(); err == nil {
+		tokens = v
+	} else {
+		log.Printf("local-server: WebSocket tokens will NOT be verified (%v)", err)
+	}
+	gw.connect = wsconnect.New(store, tokens)
+	gw.disconnect = wsdisconnect.New(store)
+	chat := wschat.New(store)
+	gw.wsRoutes["chat"] = chat
+	gw.wsRoutes["ooc"] = wsooc.New(store)
+	gameAction := wsgameaction.New(store)
+	gw.wsRoutes["game_action"] = gameAction
+
+	gw.functions[worldGenFunction] = lambda.NewHandler(worldgen.New(store))
+	gw.functions[gameActionFunction] = lambda.NewHandler(gameAction)
+	gw.functions[chatFunction] = lambda.NewHandler(chat)
+
+	if *provision {
+		role := "user"
+		if *provisionAdmin {
+			role = "admin"
+		}
+		gw.onUser = newProvisioner(store, role).ensure
+	}
+
+	log.Printf("local-server: listening on %s (api %s, ws %s/ws)", *addr, self, strings.Replace(self, "http", "ws", 1))
+	srv := &http.Server{Addr: *addr, Handler: gw, ReadHeaderTimeout: 10 * time.Second}
+	log.Fatal(srv.ListenAndServe())
+}
+
+// dialAddr turns a listen address into one the handlers can reach,
+// e.g. ":8080" → "127.0.0.1:8080".
+func dialAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// provisioner creates user records for callers who never went through the
+// Cognito post-confirmation trigger, since that does not run locally.
+type provisioner struct {
+	users db.UserStore
+	role  string   // "user" or "admin"
+	seen  sync.Map // userID → struct{}
+}
+
+func newProvisioner(users db.UserStore, role string) *provisioner {
+	return &provisioner{users: users, role: role}
+}
+
+// ensure gives userID an AI-enabled record with the provisioner's role unless
+// they already have one.
+// Failures are logged; the handlers then treat the user as restricted.
+func (p *provisioner) ensure(ctx context.Context, userID string) {
+	if _, ok := p.seen.Load(userID); ok {
+		return
+	}
+	rec, err := p.users.GetUser(ctx, userID)
+	if err != nil {
+		log.Printf("local-server: provision %s: %v", userID, err)
+		return
+	}
+	if rec == nil {
+		now := time.Now().UnixMilli()
+		err := p.users.PutUser(ctx, db.UserRecord{
+			UserID:      db.BinaryID(userID),
+			Role:        p.role,
+			AIEnabled:   true,
+			BillingMode: "admin_granted",
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			Notes:       "provisioned by local-server",
+		})
+		if err != nil {
+			log.Printf("local-server: provision %s: %v", userID, err)
+			return
+		}
+		log.Printf("local-server: provisioned %s user %s", p.role, userID)
+	}
+	p.seen.Store(userID, struct{}{})
+}
@@ -0,0 +1,12 @@
+// world-gen is the Lambda entry point for package worldgen, which holds the
+// handler so cmd/local-server can mount it too.
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/rrochlin/an-amazing-adventure/internal/handlers/worldgen"
+)
+
+func main() {
+	lambda.Start(worldgen.Handler)
+}
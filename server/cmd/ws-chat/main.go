@@ -0,0 +1,12 @@
+// ws-chat is the Lambda entry point for package wschat, which holds the
+// handler so cmd/local-server can mount it too.
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/rrochlin/an-amazing-adventure/internal/handlers/wschat"
+)
+
+func main() {
+	lambda.Start(wschat.Handler)
+}
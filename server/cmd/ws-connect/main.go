@@ -0,0 +1,12 @@
+// ws-connect is the Lambda entry point for package wsconnect, which holds the
+// handler so cmd/local-server can mount it too.
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/rrochlin/an-amazing-adventure/internal/handlers/wsconnect"
+)
+
+func main() {
+	lambda.Start(wsconnect.Handler)
+}
@@ -0,0 +1,12 @@
+// ws-disconnect is the Lambda entry point for package wsdisconnect, which holds the
+// handler so cmd/local-server can mount it too.
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/rrochlin/an-amazing-adventure/internal/handlers/wsdisconnect"
+)
+
+func main() {
+	lambda.Start(wsdisconnect.Handler)
+}
@@ -0,0 +1,12 @@
+// ws-game-action is the Lambda entry point for package wsgameaction, which holds the
+// handler so cmd/local-server can mount it too.
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/rrochlin/an-amazing-adventure/internal/handlers/wsgameaction"
+)
+
+func main() {
+	lambda.Start(wsgameaction.Handler)
+}
@@ -0,0 +1,12 @@
+// ws-ooc is the Lambda entry point for package wsooc, which holds the
+// handler so cmd/local-server can mount it too.
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/rrochlin/an-amazing-adventure/internal/handlers/wsooc"
+)
+
+func main() {
+	lambda.Start(wsooc.Handler)
+}
@@ -5,6 +5,12 @@ module github.com/rrochlin/an-amazing-adventure
 go 1.24.1
 
 require (
+	github.com/KirkDiggler/rpg-toolkit/core v0.10.0
+	github.com/KirkDiggler/rpg-toolkit/dice v0.3.2
+	github.com/KirkDiggler/rpg-toolkit/events v0.6.2
+	github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e v0.51.0
+	github.com/KirkDiggler/rpg-toolkit/tools/environments v0.4.2
+	github.com/KirkDiggler/rpg-toolkit/tools/spatial v0.4.0
 	github.com/aws/aws-lambda-go v1.53.0
 	github.com/aws/aws-sdk-go-v2 v1.41.3
 	github.com/aws/aws-sdk-go-v2/config v1.32.11
@@ -16,19 +22,14 @@ require (
 	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.56.1
 	github.com/aws/aws-sdk-go-v2/service/lambda v1.88.2
 	github.com/google/uuid v1.6.0
+	github.com/gorilla/websocket v1.5.3
 )
 
 require (
-	github.com/KirkDiggler/rpg-toolkit/core v0.10.0 // indirect
-	github.com/KirkDiggler/rpg-toolkit/dice v0.3.2 // indirect
-	github.com/KirkDiggler/rpg-toolkit/events v0.6.2 // indirect
 	github.com/KirkDiggler/rpg-toolkit/game v0.1.0 // indirect
 	github.com/KirkDiggler/rpg-toolkit/mechanics/resources v0.3.1 // indirect
 	github.com/KirkDiggler/rpg-toolkit/rpgerr v0.1.1 // indirect
-	github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e v0.51.0 // indirect
-	github.com/KirkDiggler/rpg-toolkit/tools/environments v0.4.2 // indirect
 	github.com/KirkDiggler/rpg-toolkit/tools/selectables v0.1.2 // indirect
-	github.com/KirkDiggler/rpg-toolkit/tools/spatial v0.4.0 // indirect
 	github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream v1.7.6 // indirect
 	github.com/aws/aws-sdk-go-v2/credentials v1.19.11 // indirect
 	github.com/aws/aws-sdk-go-v2/feature/ec2/imds v1.18.19 // indirect
@@ -78,6 +78,9 @@ module "lambdas" {
   users_table_name            = module.dynamodb.users_table_name
   memberships_table_name      = module.dynamodb.memberships_table_name
   invites_table_name          = module.dynamodb.invites_table_name
+  checkpoints_table_name      = module.dynamodb.checkpoints_table_name
+  session_log_table_name      = module.dynamodb.session_log_table_name
+  leases_table_name           = module.dynamodb.leases_table_name
   sessions_table_arn          = module.dynamodb.sessions_table_arn
   connections_table_arn       = module.dynamodb.connections_table_arn
   connections_table_index_arn = module.dynamodb.connections_table_index_arn
@@ -86,8 +89,12 @@ module "lambdas" {
   memberships_table_arn       = module.dynamodb.memberships_table_arn
   memberships_table_index_arn = module.dynamodb.memberships_table_index_arn
   invites_table_arn           = module.dynamodb.invites_table_arn
+  checkpoints_table_arn       = module.dynamodb.checkpoints_table_arn
+  session_log_table_arn       = module.dynamodb.session_log_table_arn
+  leases_table_arn            = module.dynamodb.leases_table_arn
   user_pool_id                = module.cognito.user_pool_id
   user_pool_arn               = module.cognito.user_pool_arn
+  user_pool_client_id         = module.cognito.user_pool_client_id
   websocket_api_execution_arn = module.api_gateway.websocket_api_execution_arn
   websocket_api_endpoint      = module.api_gateway.websocket_api_endpoint
 }
@@ -107,6 +114,7 @@ module "api_gateway" {
   ws_disconnect_invoke_arn     = module.lambdas.ws_disconnect_invoke_arn
   ws_chat_invoke_arn           = module.lambdas.ws_chat_invoke_arn
   ws_game_action_invoke_arn    = module.lambdas.ws_game_action_invoke_arn
+  ws_ooc_invoke_arn            = module.lambdas.ws_ooc_invoke_arn
   http_games_function_name     = module.lambdas.http_games_function_name
   http_users_function_name     = module.lambdas.http_users_function_name
   http_admin_function_name     = module.lambdas.http_admin_function_name
@@ -115,6 +123,7 @@ module "api_gateway" {
   ws_disconnect_function_name  = module.lambdas.ws_disconnect_function_name
   ws_chat_function_name        = module.lambdas.ws_chat_function_name
   ws_game_action_function_name = module.lambdas.ws_game_action_function_name
+  ws_ooc_function_name         = module.lambdas.ws_ooc_function_name
 }
 
 module "cloudfront" {
@@ -11,6 +11,7 @@ variable "ws_connect_invoke_arn" { type = string }
 variable "ws_disconnect_invoke_arn" { type = string }
 variable "ws_chat_invoke_arn" { type = string }
 variable "ws_game_action_invoke_arn" { type = string }
+variable "ws_ooc_invoke_arn" { type = string }
 variable "http_games_function_name" { type = string }
 variable "http_users_function_name" { type = string }
 variable "http_admin_function_name" { type = string }
@@ -19,6 +20,7 @@ variable "ws_connect_function_name" { type = string }
 variable "ws_disconnect_function_name" { type = string }
 variable "ws_chat_function_name" { type = string }
 variable "ws_game_action_function_name" { type = string }
+variable "ws_ooc_function_name" { type = string }
 
 data "aws_region" "current" {}
 
@@ -113,6 +115,55 @@ resource "aws_apigatewayv2_route" "delete_game" {
   authorizer_id      = local.jwt_auth.authorizer_id
   authorization_type = local.jwt_auth.authorization_type
 }
+resource "aws_apigatewayv2_route" "post_game_level_up" {
+  api_id             = aws_apigatewayv2_api.http.id
+  route_key          = "POST /api/games/{uuid}/level-up"
+  target             = local.games_target
+  authorizer_id      = local.jwt_auth.authorizer_id
+  authorization_type = local.jwt_auth.authorization_type
+}
+resource "aws_apigatewayv2_route" "post_game_rewind" {
+  api_id             = aws_apigatewayv2_api.http.id
+  route_key          = "POST /api/games/{uuid}/rewind"
+  target             = local.games_target
+  authorizer_id      = local.jwt_auth.authorizer_id
+  authorization_type = local.jwt_auth.authorization_type
+}
+resource "aws_apigatewayv2_route" "get_game_chat" {
+  api_id             = aws_apigatewayv2_api.http.id
+  route_key          = "GET /api/games/{uuid}/chat"
+  target             = local.games_target
+  authorizer_id      = local.jwt_auth.authorizer_id
+  authorization_type = local.jwt_auth.authorization_type
+}
+resource "aws_apigatewayv2_route" "get_game_checkpoints" {
+  api_id             = aws_apigatewayv2_api.http.id
+  route_key          = "GET /api/games/{uuid}/checkpoints"
+  target             = local.games_target
+  authorizer_id      = local.jwt_auth.authorizer_id
+  authorization_type = local.jwt_auth.authorization_type
+}
+resource "aws_apigatewayv2_route" "post_game_checkpoints" {
+  api_id             = aws_apigatewayv2_api.http.id
+  route_key          = "POST /api/games/{uuid}/checkpoints"
+  target             = local.games_target
+  authorizer_id      = local.jwt_auth.authorizer_id
+  authorization_type = local.jwt_auth.authorization_type
+}
+resource "aws_apigatewayv2_route" "post_checkpoint_restore" {
+  api_id             = aws_apigatewayv2_api.http.id
+  route_key          = "POST /api/games/{uuid}/checkpoints/{checkpoint_id}/restore"
+  target             = local.games_target
+  authorizer_id      = local.jwt_auth.authorizer_id
+  authorization_type = local.jwt_auth.authorization_type
+}
+resource "aws_apigatewayv2_route" "post_checkpoint_fork" {
+  api_id             = aws_apigatewayv2_api.http.id
+  route_key          = "POST /api/games/{uuid}/checkpoints/{checkpoint_id}/fork"
+  target             = local.games_target
+  authorizer_id      = local.jwt_auth.authorizer_id
+  authorization_type = local.jwt_auth.authorization_type
+}
 resource "aws_apigatewayv2_route" "post_users" {
   api_id             = aws_apigatewayv2_api.http.id
   route_key          = "POST /api/users"
@@ -149,6 +200,13 @@ resource "aws_apigatewayv2_route" "get_admin_stats" {
   authorizer_id      = local.jwt_auth.authorizer_id
   authorization_type = local.jwt_auth.authorization_type
 }
+resource "aws_apigatewayv2_route" "delete_admin_session_lease" {
+  api_id             = aws_apigatewayv2_api.http.id
+  route_key          = "DELETE /api/admin/sessions/{sessionId}/lease"
+  target             = local.admin_target
+  authorizer_id      = local.jwt_auth.authorizer_id
+  authorization_type = local.jwt_auth.authorization_type
+}
 
 # ── Invite routes ─────────────────────────────────────────────────────────────
 resource "aws_apigatewayv2_route" "post_invites" {
@@ -237,6 +295,11 @@ resource "aws_apigatewayv2_integration" "ws_game_action" {
   integration_type = "AWS_PROXY"
   integration_uri  = var.ws_game_action_invoke_arn
 }
+resource "aws_apigatewayv2_integration" "ws_ooc" {
+  api_id           = aws_apigatewayv2_api.websocket.id
+  integration_type = "AWS_PROXY"
+  integration_uri  = var.ws_ooc_invoke_arn
+}
 
 resource "aws_apigatewayv2_route" "ws_connect" {
   api_id             = aws_apigatewayv2_api.websocket.id
@@ -259,6 +322,11 @@ resource "aws_apigatewayv2_route" "ws_game_action" {
   route_key = "game_action"
   target    = "integrations/${aws_apigatewayv2_integration.ws_game_action.id}"
 }
+resource "aws_apigatewayv2_route" "ws_ooc" {
+  api_id    = aws_apigatewayv2_api.websocket.id
+  route_key = "ooc"
+  target    = "integrations/${aws_apigatewayv2_integration.ws_ooc.id}"
+}
 
 resource "aws_apigatewayv2_stage" "websocket" {
   api_id      = aws_apigatewayv2_api.websocket.id
@@ -295,6 +363,13 @@ resource "aws_lambda_permission" "ws_game_action" {
   principal     = "apigateway.amazonaws.com"
   source_arn    = "${aws_apigatewayv2_api.websocket.execution_arn}/*/*"
 }
+resource "aws_lambda_permission" "ws_ooc" {
+  statement_id  = "AllowAPIGatewayInvoke"
+  action        = "lambda:InvokeFunction"
+  function_name = var.ws_ooc_function_name
+  principal     = "apigateway.amazonaws.com"
+  source_arn    = "${aws_apigatewayv2_api.websocket.execution_arn}/*/*"
+}
 
 # ── Outputs ──────────────────────────────────────────────────────────────────
 # Strip https:// for use as CloudFront origin domain names
@@ -140,6 +140,69 @@ resource "aws_dynamodb_table" "invites" {
   tags = merge(var.common_tags, { Name = "Invites" })
 }
 
+# Named save points, kept apart from the live session item so restoring or
+# forking never races the session's optimistic lock.
+resource "aws_dynamodb_table" "checkpoints" {
+  name         = "${var.prefix}-checkpoints"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "session_id"
+  range_key    = "checkpoint_id"
+
+  attribute {
+    name = "session_id"
+    type = "B"
+  }
+  attribute {
+    name = "checkpoint_id"
+    type = "S"
+  }
+
+  tags = merge(var.common_tags, { Name = "Checkpoints" })
+}
+
+# Append-only chat history and narrative of each session (SchemaVersion 5+).
+# The session item only lists which entries make up its histories, so it no
+# longer grows with every turn. log_key is "<kind>#<zero-padded seq>".
+resource "aws_dynamodb_table" "session_log" {
+  name         = "${var.prefix}-session-log"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "session_id"
+  range_key    = "log_key"
+
+  attribute {
+    name = "session_id"
+    type = "B"
+  }
+  attribute {
+    name = "log_key"
+    type = "S"
+  }
+
+  tags = merge(var.common_tags, { Name = "SessionLog" })
+}
+
+# ── Streaming leases ─────────────────────────────────────────────────────────
+# One row per session while a narrator turn streams. Acquire and release are
+# conditional writes; a lease past expires_at is stale and taken over by the
+# next turn, and TTL sweeps up the ones nobody takes.
+resource "aws_dynamodb_table" "leases" {
+  name         = "${var.prefix}-leases"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "session_id"
+
+  attribute {
+    name = "session_id"
+    type = "B"
+  }
+
+  ttl {
+    attribute_name = "expires_at"
+    enabled        = true
+  }
+
+  tags = merge(var.common_tags, { Name = "Leases" })
+}
+
 output "sessions_table_name" { value = aws_dynamodb_table.sessions.name }
 output "sessions_table_arn" { value = aws_dynamodb_table.sessions.arn }
 output "connections_table_name" { value = aws_dynamodb_table.connections.name }
@@ -154,3 +217,9 @@ output "memberships_table_arn" { value = aws_dynamodb_table.memberships.arn }
 output "memberships_table_index_arn" { value = "${aws_dynamodb_table.memberships.arn}/index/*" }
 output "invites_table_name" { value = aws_dynamodb_table.invites.name }
 output "invites_table_arn" { value = aws_dynamodb_table.invites.arn }
+output "checkpoints_table_name" { value = aws_dynamodb_table.checkpoints.name }
+output "checkpoints_table_arn" { value = aws_dynamodb_table.checkpoints.arn }
+output "session_log_table_name" { value = aws_dynamodb_table.session_log.name }
+output "session_log_table_arn" { value = aws_dynamodb_table.session_log.arn }
+output "leases_table_name" { value = aws_dynamodb_table.leases.name }
+output "leases_table_arn" { value = aws_dynamodb_table.leases.arn }
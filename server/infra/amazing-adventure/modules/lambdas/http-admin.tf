@@ -1,5 +1,6 @@
 # ── http-admin ───────────────────────────────────────────────────────────────
-# Handles GET /api/admin/users, PUT /api/admin/users/{userId}, GET /api/admin/stats.
+# Handles GET /api/admin/users, PUT /api/admin/users/{userId}, GET /api/admin/stats,
+# DELETE /api/admin/sessions/{sessionId}/lease.
 # JWT authorizer + Lambda-level admin group check (defense in depth).
 resource "aws_iam_role" "http_admin" {
   name               = "${var.prefix}-http-admin"
@@ -21,6 +22,12 @@ resource "aws_iam_role_policy" "http_admin" {
         Action   = ["dynamodb:Scan", "dynamodb:GetItem", "dynamodb:PutItem", "dynamodb:UpdateItem"]
         Resource = var.users_table_arn
       },
+      {
+        # Force-release a stuck streaming lease
+        Effect   = "Allow"
+        Action   = ["dynamodb:GetItem", "dynamodb:DeleteItem"]
+        Resource = var.leases_table_arn
+      },
       {
         # Enrich user list with email, sync group membership on role changes
         Effect = "Allow"
@@ -53,6 +60,7 @@ resource "aws_lambda_function" "http_admin" {
   environment {
     variables = {
       USERS_TABLE  = var.users_table_name
+      LEASES_TABLE = var.leases_table_name
       USER_POOL_ID = var.user_pool_id
     }
   }
@@ -14,6 +14,35 @@ resource "aws_iam_role_policy" "http_games" {
   policy = jsonencode({
     Version = "2012-10-17"
     Statement = [
+      {
+        # Chat history and narrative: appended on save, read on load
+        Effect   = "Allow"
+        Action   = ["dynamodb:PutItem", "dynamodb:Query"]
+        Resource = var.session_log_table_arn
+      },
+      {
+        # Rewind and restore refuse while a narrator turn holds the streaming lease
+        Effect   = "Allow"
+        Action   = ["dynamodb:GetItem"]
+        Resource = var.leases_table_arn
+      },
+      {
+        # Typed state events — written after each successful save, read back to rewind
+        Effect   = "Allow"
+        Action   = ["dynamodb:PutItem", "dynamodb:Query"]
+        Resource = var.mutations_table_arn
+      },
+      {
+        # Rewind refuses mid-stream turns and pushes the restored state to the party
+        Effect   = "Allow"
+        Action   = ["dynamodb:Query", "dynamodb:DeleteItem"]
+        Resource = [var.connections_table_arn, var.connections_table_index_arn]
+      },
+      {
+        Effect   = "Allow"
+        Action   = ["execute-api:ManageConnections"]
+        Resource = "${var.websocket_api_execution_arn}/*/*/@connections/*"
+      },
       {
         Effect   = "Allow"
         Action   = ["dynamodb:GetItem", "dynamodb:PutItem", "dynamodb:DeleteItem", "dynamodb:Query"]
@@ -25,6 +54,13 @@ resource "aws_iam_role_policy" "http_games" {
         Action   = ["dynamodb:GetItem", "dynamodb:PutItem", "dynamodb:DeleteItem", "dynamodb:Query"]
         Resource = [var.memberships_table_arn, "${var.memberships_table_arn}/index/*"]
       },
+      {
+        # Named checkpoints: create, list, restore and fork. Creating and
+        # deleting also update the session's checkpoint count in a transaction
+        Effect   = "Allow"
+        Action   = ["dynamodb:GetItem", "dynamodb:PutItem", "dynamodb:UpdateItem", "dynamodb:DeleteItem", "dynamodb:Query"]
+        Resource = var.checkpoints_table_arn
+      },
       {
         # Read per-user RBAC record to enforce games limit
         Effect   = "Allow"
@@ -56,10 +92,16 @@ resource "aws_lambda_function" "http_games" {
   memory_size      = 128
   environment {
     variables = {
-      SESSIONS_TABLE    = var.sessions_table_name
-      USERS_TABLE       = var.users_table_name
-      MEMBERSHIPS_TABLE = var.memberships_table_name
-      WORLD_GEN_ARN     = aws_lambda_function.world_gen.arn
+      SESSIONS_TABLE         = var.sessions_table_name
+      SESSION_LOG_TABLE      = var.session_log_table_name
+      MUTATIONS_TABLE        = var.mutations_table_name
+      USERS_TABLE            = var.users_table_name
+      MEMBERSHIPS_TABLE      = var.memberships_table_name
+      CONNECTIONS_TABLE      = var.connections_table_name
+      CHECKPOINTS_TABLE      = var.checkpoints_table_name
+      LEASES_TABLE           = var.leases_table_name
+      WEBSOCKET_API_ENDPOINT = local.ws_endpoint_full
+      WORLD_GEN_ARN          = aws_lambda_function.world_gen.arn
     }
   }
   depends_on = [aws_cloudwatch_log_group.http_games]
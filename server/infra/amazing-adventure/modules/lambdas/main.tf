@@ -6,6 +6,9 @@ variable "mutations_table_name" { type = string }
 variable "users_table_name" { type = string }
 variable "memberships_table_name" { type = string }
 variable "invites_table_name" { type = string }
+variable "checkpoints_table_name" { type = string }
+variable "session_log_table_name" { type = string }
+variable "leases_table_name" { type = string }
 variable "sessions_table_arn" { type = string }
 variable "connections_table_arn" { type = string }
 variable "connections_table_index_arn" { type = string }
@@ -14,8 +17,12 @@ variable "users_table_arn" { type = string }
 variable "memberships_table_arn" { type = string }
 variable "memberships_table_index_arn" { type = string }
 variable "invites_table_arn" { type = string }
+variable "checkpoints_table_arn" { type = string }
+variable "session_log_table_arn" { type = string }
+variable "leases_table_arn" { type = string }
 variable "user_pool_id" { type = string }
 variable "user_pool_arn" { type = string }
+variable "user_pool_client_id" { type = string }
 variable "websocket_api_execution_arn" { type = string }
 variable "websocket_api_endpoint" { type = string }
 
@@ -375,6 +382,7 @@ resource "aws_lambda_function" "http_games" {
       MEMBERSHIPS_TABLE = var.memberships_table_name
       USERS_TABLE       = var.users_table_name
       WORLD_GEN_ARN     = aws_lambda_function.world_gen.arn
+      HIDE_MONSTER_HP   = "false"
     }
   }
   depends_on = [aws_cloudwatch_log_group.http_games]
@@ -7,6 +7,7 @@ output "ws_connect_invoke_arn" { value = aws_lambda_function.ws_connect.invoke_a
 output "ws_disconnect_invoke_arn" { value = aws_lambda_function.ws_disconnect.invoke_arn }
 output "ws_chat_invoke_arn" { value = aws_lambda_function.ws_chat.invoke_arn }
 output "ws_game_action_invoke_arn" { value = aws_lambda_function.ws_game_action.invoke_arn }
+output "ws_ooc_invoke_arn" { value = aws_lambda_function.ws_ooc.invoke_arn }
 output "world_gen_invoke_arn" { value = aws_lambda_function.world_gen.invoke_arn }
 output "cognito_post_confirm_invoke_arn" { value = aws_lambda_function.cognito_post_confirm.invoke_arn }
 output "cognito_post_confirm_function_arn" { value = aws_lambda_function.cognito_post_confirm.arn }
@@ -19,5 +20,6 @@ output "ws_connect_function_name" { value = aws_lambda_function.ws_connect.funct
 output "ws_disconnect_function_name" { value = aws_lambda_function.ws_disconnect.function_name }
 output "ws_chat_function_name" { value = aws_lambda_function.ws_chat.function_name }
 output "ws_game_action_function_name" { value = aws_lambda_function.ws_game_action.function_name }
+output "ws_ooc_function_name" { value = aws_lambda_function.ws_ooc.function_name }
 output "world_gen_function_name" { value = aws_lambda_function.world_gen.function_name }
 output "cognito_post_confirm_function_name" { value = aws_lambda_function.cognito_post_confirm.function_name }
@@ -14,6 +14,18 @@ resource "aws_iam_role_policy" "world_gen" {
   policy = jsonencode({
     Version = "2012-10-17"
     Statement = [
+      {
+        # Chat history and narrative: appended on save, read on load
+        Effect   = "Allow"
+        Action   = ["dynamodb:PutItem", "dynamodb:Query"]
+        Resource = var.session_log_table_arn
+      },
+      {
+        # Typed state events — written after each successful save
+        Effect   = "Allow"
+        Action   = ["dynamodb:PutItem"]
+        Resource = var.mutations_table_arn
+      },
       {
         Effect   = "Allow"
         Action   = ["dynamodb:GetItem", "dynamodb:PutItem", "dynamodb:UpdateItem"]
@@ -63,6 +75,8 @@ resource "aws_lambda_function" "world_gen" {
   environment {
     variables = {
       SESSIONS_TABLE         = var.sessions_table_name
+      SESSION_LOG_TABLE      = var.session_log_table_name
+      MUTATIONS_TABLE        = var.mutations_table_name
       CONNECTIONS_TABLE      = var.connections_table_name
       USERS_TABLE            = var.users_table_name
       WEBSOCKET_API_ENDPOINT = local.ws_endpoint_full
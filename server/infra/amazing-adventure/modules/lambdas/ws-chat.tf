@@ -14,6 +14,18 @@ resource "aws_iam_role_policy" "ws_chat" {
   policy = jsonencode({
     Version = "2012-10-17"
     Statement = [
+      {
+        # Chat history and narrative: appended on save, read on load
+        Effect   = "Allow"
+        Action   = ["dynamodb:PutItem", "dynamodb:Query"]
+        Resource = var.session_log_table_arn
+      },
+      {
+        # Streaming lease: one narrator turn per session
+        Effect   = "Allow"
+        Action   = ["dynamodb:PutItem", "dynamodb:DeleteItem"]
+        Resource = var.leases_table_arn
+      },
       {
         Effect   = "Allow"
         Action   = ["dynamodb:GetItem", "dynamodb:PutItem", "dynamodb:UpdateItem"]
@@ -35,6 +47,18 @@ resource "aws_iam_role_policy" "ws_chat" {
         Action   = ["bedrock:InvokeModelWithResponseStream", "bedrock:InvokeModel"]
         Resource = "*"
       },
+      {
+        # Hand a combat turn that has run past its deadline to ws-game-action
+        Effect   = "Allow"
+        Action   = ["lambda:InvokeFunction"]
+        Resource = aws_lambda_function.ws_game_action.arn
+      },
+      {
+        # Follow a turn that ended the run with its epilogue turn
+        Effect   = "Allow"
+        Action   = ["lambda:InvokeFunction"]
+        Resource = aws_lambda_function.ws_chat.arn
+      },
       {
         Effect   = "Allow"
         Action   = ["execute-api:ManageConnections"]
@@ -61,11 +85,16 @@ resource "aws_lambda_function" "ws_chat" {
   environment {
     variables = {
       SESSIONS_TABLE         = var.sessions_table_name
+      SESSION_LOG_TABLE      = var.session_log_table_name
       CONNECTIONS_TABLE      = var.connections_table_name
+      LEASES_TABLE           = var.leases_table_name
       MUTATIONS_TABLE        = var.mutations_table_name
       USERS_TABLE            = var.users_table_name
       WEBSOCKET_API_ENDPOINT = local.ws_endpoint_full
       BEDROCK_REGION         = "us-west-2"
+      GAME_ACTION_ARN        = aws_lambda_function.ws_game_action.arn
+      # By name: a function's env cannot reference its own ARN
+      CHAT_ARN               = "${var.prefix}-ws-chat"
     }
   }
   depends_on = [aws_cloudwatch_log_group.ws_chat]
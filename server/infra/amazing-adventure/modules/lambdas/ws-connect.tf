@@ -14,6 +14,12 @@ resource "aws_iam_role_policy" "ws_connect" {
   policy = jsonencode({
     Version = "2012-10-17"
     Statement = [
+      {
+        # Loading the session reads its narrative and chat from the session log
+        Effect   = "Allow"
+        Action   = ["dynamodb:Query"]
+        Resource = var.session_log_table_arn
+      },
       {
         Effect   = "Allow"
         Action   = ["dynamodb:PutItem", "dynamodb:DeleteItem", "dynamodb:Query"]
@@ -45,9 +51,11 @@ resource "aws_lambda_function" "ws_connect" {
   memory_size      = 128
   environment {
     variables = {
-      SESSIONS_TABLE    = var.sessions_table_name
-      CONNECTIONS_TABLE = var.connections_table_name
-      USER_POOL_ID      = var.user_pool_id
+      SESSIONS_TABLE      = var.sessions_table_name
+      SESSION_LOG_TABLE   = var.session_log_table_name
+      CONNECTIONS_TABLE   = var.connections_table_name
+      USER_POOL_ID        = var.user_pool_id
+      USER_POOL_CLIENT_ID = var.user_pool_client_id
     }
   }
   depends_on = [aws_cloudwatch_log_group.ws_connect]
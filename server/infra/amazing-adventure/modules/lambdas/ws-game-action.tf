@@ -14,11 +14,43 @@ resource "aws_iam_role_policy" "ws_game_action" {
   policy = jsonencode({
     Version = "2012-10-17"
     Statement = [
+      {
+        # Chat history and narrative: appended on save, read on load
+        Effect   = "Allow"
+        Action   = ["dynamodb:PutItem", "dynamodb:Query"]
+        Resource = var.session_log_table_arn
+      },
+      {
+        # Actions wait while a narrator turn holds the streaming lease, and
+        # world-changing ones hold it themselves until they have saved
+        Effect   = "Allow"
+        Action   = ["dynamodb:GetItem", "dynamodb:PutItem", "dynamodb:DeleteItem"]
+        Resource = var.leases_table_arn
+      },
+      {
+        # Typed state events — written after each successful save, read back to rewind
+        Effect   = "Allow"
+        Action   = ["dynamodb:PutItem", "dynamodb:Query"]
+        Resource = var.mutations_table_arn
+      },
+      {
+        # Find the party's connections to push state to, and drop gone ones
+        Effect   = "Allow"
+        Action   = ["dynamodb:Query", "dynamodb:DeleteItem"]
+        Resource = [var.connections_table_arn, var.connections_table_index_arn]
+      },
       {
         Effect   = "Allow"
         Action   = ["dynamodb:GetItem", "dynamodb:PutItem", "dynamodb:UpdateItem"]
         Resource = [var.sessions_table_arn, var.connections_table_arn]
       },
+      {
+        # Hand an action round that has run past its deadline, or the
+        # epilogue of a run an action ended, to ws-chat
+        Effect   = "Allow"
+        Action   = ["lambda:InvokeFunction"]
+        Resource = aws_lambda_function.ws_chat.arn
+      },
       {
         Effect   = "Allow"
         Action   = ["execute-api:ManageConnections"]
@@ -45,8 +77,13 @@ resource "aws_lambda_function" "ws_game_action" {
   environment {
     variables = {
       SESSIONS_TABLE         = var.sessions_table_name
+      SESSION_LOG_TABLE      = var.session_log_table_name
+      MUTATIONS_TABLE        = var.mutations_table_name
       CONNECTIONS_TABLE      = var.connections_table_name
+      LEASES_TABLE           = var.leases_table_name
       WEBSOCKET_API_ENDPOINT = local.ws_endpoint_full
+      # By name: ws-chat's env already references this function's ARN
+      CHAT_ARN               = "${var.prefix}-ws-chat"
     }
   }
   depends_on = [aws_cloudwatch_log_group.ws_game_action]
@@ -0,0 +1,82 @@
+# ── ws-ooc ───────────────────────────────────────────────────────────────────
+resource "aws_iam_role" "ws_ooc" {
+  name               = "${var.prefix}-ws-ooc"
+  assume_role_policy = data.aws_iam_policy_document.lambda_assume.json
+  tags               = var.common_tags
+}
+resource "aws_iam_role_policy_attachment" "ws_ooc_logs" {
+  role       = aws_iam_role.ws_ooc.name
+  policy_arn = aws_iam_policy.lambda_logs.arn
+}
+resource "aws_iam_role_policy" "ws_ooc" {
+  name = "ooc-permissions"
+  role = aws_iam_role.ws_ooc.id
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        # Out-of-character chat: appended and pruned here; the session's
+        # narrative is read along with it when loading the sender's name
+        Effect   = "Allow"
+        Action   = ["dynamodb:PutItem", "dynamodb:Query", "dynamodb:DeleteItem"]
+        Resource = var.session_log_table_arn
+      },
+      {
+        # Find the party's connections to relay to, and drop gone ones
+        Effect   = "Allow"
+        Action   = ["dynamodb:Query", "dynamodb:DeleteItem"]
+        Resource = [var.connections_table_arn, var.connections_table_index_arn]
+      },
+      {
+        Effect   = "Allow"
+        Action   = ["dynamodb:GetItem"]
+        Resource = [var.sessions_table_arn, var.connections_table_arn]
+      },
+      {
+        # Hand a combat turn that has run past its deadline to ws-game-action
+        Effect   = "Allow"
+        Action   = ["lambda:InvokeFunction"]
+        Resource = aws_lambda_function.ws_game_action.arn
+      },
+      {
+        # Hand an action round that has run past its deadline to ws-chat
+        Effect   = "Allow"
+        Action   = ["lambda:InvokeFunction"]
+        Resource = aws_lambda_function.ws_chat.arn
+      },
+      {
+        Effect   = "Allow"
+        Action   = ["execute-api:ManageConnections"]
+        Resource = "${var.websocket_api_execution_arn}/*/*/@connections/*"
+      }
+    ]
+  })
+}
+resource "aws_cloudwatch_log_group" "ws_ooc" {
+  name              = "/aws/lambda/${var.prefix}-ws-ooc"
+  retention_in_days = 7
+  tags              = var.common_tags
+}
+resource "aws_lambda_function" "ws_ooc" {
+  function_name    = "${var.prefix}-ws-ooc"
+  role             = aws_iam_role.ws_ooc.arn
+  runtime          = "provided.al2023"
+  architectures    = ["arm64"]
+  handler          = "bootstrap"
+  filename         = data.archive_file.placeholder.output_path
+  source_code_hash = data.archive_file.placeholder.output_base64sha256
+  timeout          = 10
+  memory_size      = 128
+  environment {
+    variables = {
+      SESSIONS_TABLE         = var.sessions_table_name
+      SESSION_LOG_TABLE      = var.session_log_table_name
+      CONNECTIONS_TABLE      = var.connections_table_name
+      WEBSOCKET_API_ENDPOINT = local.ws_endpoint_full
+      GAME_ACTION_ARN        = aws_lambda_function.ws_game_action.arn
+      CHAT_ARN               = aws_lambda_function.ws_chat.arn
+    }
+  }
+  depends_on = [aws_cloudwatch_log_group.ws_ooc]
+  tags       = var.common_tags
+}
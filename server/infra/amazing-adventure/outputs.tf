@@ -52,3 +52,18 @@ output "memberships_table_name" {
   description = "DynamoDB memberships table name (user ↔ session join)"
   value       = module.dynamodb.memberships_table_name
 }
+
+output "checkpoints_table_name" {
+  description = "DynamoDB checkpoints table name (named save points)"
+  value       = module.dynamodb.checkpoints_table_name
+}
+
+output "session_log_table_name" {
+  description = "DynamoDB session log table name (chat history and narrative)"
+  value       = module.dynamodb.session_log_table_name
+}
+
+output "leases_table_name" {
+  description = "DynamoDB leases table name (per-session streaming locks)"
+  value       = module.dynamodb.leases_table_name
+}
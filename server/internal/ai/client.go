@@ -0,0 +1,830 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rrochlin/an-amazing-adventure/internal/combat"
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+)
+
+// Client runs the game's AI Dungeon Master turns on a Provider.
+type Client struct {
+	provider Provider
+}
+
+// New creates a Client on the deployment's default provider (see NewProvider).
+func New(ctx context.Context) (*Client, error) {
+	return NewFor(ctx, "")
+}
+
+// NewFor creates a Client on the named provider, typically a user's
+// ai_provider override; "" falls back to the deployment default.
+func NewFor(ctx context.Context, provider string) (*Client, error) {
+	p, err := NewProvider(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(p), nil
+}
+
+// NewClient creates a Client on p.
+func NewClient(p Provider) *Client {
+	return &Client{provider: p}
+}
+
+// ---- Token usage ----
+
+// TokenUsage holds the token counts from a single model call. InputTokens
+// counts only the uncached part of the prompt; the prefixes read from and
+// written to the prompt cache are counted separately.
+type TokenUsage struct {
+	InputTokens      int
+	OutputTokens     int
+	CacheReadTokens  int
+	CacheWriteTokens int
+}
+
+// Total returns the tokens the call counts for against TotalTokens and user
+// quotas. Cached tokens are weighted the way Bedrock bills them: a cache read
+// costs a tenth of an input token and a cache write a quarter more.
+func (u TokenUsage) Total() int {
+	return u.InputTokens + u.OutputTokens + u.CacheReadTokens/10 + u.CacheWriteTokens*5/4
+}
+
+// add accumulates o into u.
+func (u *TokenUsage) add(o TokenUsage) {
+	u.InputTokens += o.InputTokens
+	u.OutputTokens += o.OutputTokens
+	u.CacheReadTokens += o.CacheReadTokens
+	u.CacheWriteTokens += o.CacheWriteTokens
+}
+
+// ---- Narrator (streaming chat) ----
+
+// NarratorResult holds everything that comes back from a streaming narrator turn.
+// The Narrator produces only prose — world mutations are handled by EngineerScan
+// after the narrative stream completes.
+type NarratorResult struct {
+	Narrative   string                  // accumulated text sent to the player
+	NewMessages []game.NarrativeMessage // updated history to persist
+	Tokens      TokenUsage              // token usage for this turn
+}
+
+// EngineerResult holds the world mutations the Engineer inferred from the narrative.
+type EngineerResult struct {
+	Events    []game.WorldEvent    // player-visible world events
+	Mutations []game.MutationEntry // audit log entries (also recorded on g.Events when attached)
+	Tokens    TokenUsage           // token usage for the Engineer call
+}
+
+// NarrateStream runs a single narrator turn with streaming.
+// The Narrator has NO tools — it produces pure immersive prose only.
+// World mutations are applied separately by EngineerScan after streaming completes.
+// speakerID is the party member whose input this is; the input is tagged with
+// their character's name in the history (see game.SpeakerInput). A private
+// turn is a whisper: the narrator answers the speaker alone, and the exchange
+// is kept in the speaker's private history, which only their later whispers
+// see (see game.NarrativeMessage.PrivateTo).
+// onChunk is called for each text delta so ws-chat can push narrative_chunk frames
+// immediately without buffering.
+func (c *Client) NarrateStream(
+	ctx context.Context,
+	g *game.Game,
+	speakerID string,
+	private bool,
+	history []game.NarrativeMessage,
+	playerInput string,
+	onChunk func(string),
+) (NarratorResult, error) {
+	// The new player turn is tagged with who is speaking
+	return c.narrate(ctx, g, []string{speakerID}, private, history, g.SpeakerInput(speakerID, playerInput, private), onChunk)
+}
+
+// NarrateRound resolves an action round in one narrator turn: every intent
+// goes to the narrator together, one tagged line per member (see
+// game.Game.RoundInput), and each author is marked as acting this turn.
+func (c *Client) NarrateRound(
+	ctx context.Context,
+	g *game.Game,
+	intents []game.Intent,
+	history []game.NarrativeMessage,
+	onChunk func(string),
+) (NarratorResult, error) {
+	acting := make([]string, 0, len(intents))
+	for _, in := range intents {
+		acting = append(acting, in.UserID)
+	}
+	return c.narrate(ctx, g, acting, false, history, g.RoundInput(intents), onChunk)
+}
+
+// epilogueInput stands in for a player's input on the epilogue turn.
+const epilogueInput = "[The adventure is over. Tell how the story ends.]"
+
+// NarrateEpilogue writes the epilogue of a cleared or failed run. No one is
+// acting; the turn context tells the narrator the run is over (see
+// narratorTurnContext).
+func (c *Client) NarrateEpilogue(
+	ctx context.Context,
+	g *game.Game,
+	history []game.NarrativeMessage,
+	onChunk func(string),
+) (NarratorResult, error) {
+	return c.narrate(ctx, g, nil, false, history, epilogueInput, onChunk)
+}
+
+// narrate streams the narrator's reply to playerInput, already tagged with
+// its speakers, and returns the history with the exchange appended. A public
+// turn sees only the shared story; a private one also sees the speaker's
+// earlier whispers.
+func (c *Client) narrate(
+	ctx context.Context,
+	g *game.Game,
+	acting []string,
+	private bool,
+	history []game.NarrativeMessage,
+	playerInput string,
+	onChunk func(string),
+) (NarratorResult, error) {
+	// Trim history if it has grown too long to avoid context window exhaustion.
+	trimmed, err := c.TrimHistory(ctx, history)
+	if err != nil {
+		log.Printf("NarrateStream: TrimHistory error (proceeding with full history): %v", err)
+		trimmed = history
+	}
+	privateTo := ""
+	if private && len(acting) > 0 {
+		privateTo = acting[0]
+	}
+	// The system prompt and the history so far are the same on the next turn,
+	// so both are cached; the turn context changes every turn and goes last.
+	messages := historyMessages(trimmed, privateTo)
+	if n := len(messages); n > 0 {
+		messages[n-1].Content = append(messages[n-1].Content, cachePoint)
+	}
+	messages = append(messages, Message{Role: "user", Content: []Block{
+		{Text: narratorTurnContext(g, acting, private)},
+		{Text: playerInput},
+	}})
+
+	// Single streaming call — Narrator never calls tools so there is no agentic loop.
+	resp, err := c.provider.StreamNarration(ctx, Request{
+		Tier:        TierNarrator,
+		System:      narratorSystemPrompt,
+		CacheSystem: true,
+		Messages:    messages,
+		// No Tools — Narrator is prose-only by construction.
+		MaxTokens:   4096,
+		Temperature: 0.7,
+	}, onChunk)
+	if err != nil {
+		return NarratorResult{}, err
+	}
+	narrative := resp.Message.Text()
+
+	// Append this exchange to history (user turn + assistant text turn)
+	newHistory := append(trimmed,
+		game.NarrativeMessage{
+			Role:      "user",
+			Content:   []game.NarrativeBlock{{Type: "text", Text: playerInput}},
+			PrivateTo: privateTo,
+		},
+		game.NarrativeMessage{
+			Role:      "assistant",
+			Content:   []game.NarrativeBlock{{Type: "text", Text: narrative}},
+			PrivateTo: privateTo,
+		},
+	)
+
+	return NarratorResult{
+		Narrative:   narrative,
+		NewMessages: newHistory,
+		Tokens:      resp.Usage,
+	}, nil
+}
+
+// engineerMaxRounds is the maximum number of agentic tool-call rounds the
+// Engineer is allowed before we stop regardless of stop reason. Each round is
+// one Converse call → dispatch tools → feed tool_results back. In practice
+// almost all turns complete in 1 round; the cap prevents infinite loops.
+const engineerMaxRounds = 5
+
+// EngineerScan reads the finished narrative and infers what world mutations it
+// implies, then executes them against g using the full tool set.
+// It runs synchronously after NarrateStream completes so the narrative stream
+// is not delayed. Runs on TierSubAgent — fast and cheap.
+//
+// The Engineer uses an agentic tool loop: after each Converse call it executes
+// the returned tool calls, sends tool_result blocks back to the model, and
+// continues until the model returns end_turn with no tools or the round cap is
+// reached. This gives the model visibility into tool failures so it can retry with
+// corrected arguments. speakerID is the party member whose input led to the
+// narrative.
+func (c *Client) EngineerScan(
+	ctx context.Context,
+	g *game.Game,
+	speakerID string,
+	narrative string,
+) (EngineerResult, error) {
+	systemPrompt := engineerSystemPrompt()
+	world := engineerWorldCatalogue(g)
+	userMsg := engineerUserMessage(g, speakerID, narrative)
+
+	log.Printf("[engineer] START turn=%d narrativeLen=%d", g.ConversationCount, len(narrative))
+	log.Printf("[engineer] user message:\n%s%s", world, userMsg)
+
+	// The world catalogue only changes when the Engineer adds to it, so it is
+	// cached along with the system prompt ahead of this turn's narrative.
+	messages := []Message{{Role: "user", Content: []Block{{Text: world}, cachePoint, {Text: userMsg}}}}
+
+	var result EngineerResult
+
+	for round := 0; round < engineerMaxRounds; round++ {
+		resp, err := c.provider.Converse(ctx, Request{
+			Tier:        TierSubAgent,
+			System:      systemPrompt,
+			CacheSystem: true,
+			Messages:    messages,
+			Tools:       NarratorTools(),
+			MaxTokens:   2048,
+			Temperature: 0.1, // low temperature — structured extraction task
+		})
+		if err != nil {
+			return result, fmt.Errorf("engineer scan round %d: %w", round, err)
+		}
+
+		result.Tokens.add(resp.Usage)
+
+		// Log the full assistant message content
+		for _, block := range resp.Message.Content {
+			switch {
+			case block.ToolCall != nil:
+				rawInput, _ := json.Marshal(block.ToolCall.Input)
+				log.Printf("[engineer] round=%d tool_use: name=%s input=%s",
+					round, block.ToolCall.Name, string(rawInput))
+			case block.Text != "":
+				log.Printf("[engineer] round=%d assistant text: %s", round, block.Text)
+			}
+		}
+
+		// Collect tool calls from this response
+		toolCalls := resp.Message.ToolCalls()
+
+		stopReason := resp.StopReason
+		log.Printf("[engineer] round=%d stopReason=%v toolCalls=%d",
+			round, stopReason, len(toolCalls))
+
+		// No tool calls → model is done
+		if len(toolCalls) == 0 {
+			break
+		}
+
+		// Execute each tool and collect tool results to feed back
+		var toolResults []Block
+		succeeded, failed := 0, 0
+		for _, call := range toolCalls {
+			toolResult, event, dispatchErr := DispatchTool(ctx, g, speakerID, call.Name, call.Input)
+			if dispatchErr != nil {
+				log.Printf("[engineer] round=%d tool=%s FAILED: %v", round, call.Name, dispatchErr)
+				toolResult = fmt.Sprintf("error: %v", dispatchErr)
+				failed++
+			} else {
+				log.Printf("[engineer] round=%d tool=%s OK: %s", round, call.Name, toolResult)
+				succeeded++
+			}
+
+			if event != nil {
+				result.Events = append(result.Events, *event)
+			}
+			g.RecordEvent(game.MutationEntry{
+				Type:   game.EventTool,
+				Tool:   call.Name,
+				Input:  call.Input,
+				Result: toolResult,
+			})
+			result.Mutations = append(result.Mutations, game.MutationEntry{
+				SessionID: g.ID,
+				Ts:        time.Now().UnixMilli(),
+				Turn:      g.ConversationCount,
+				Tool:      call.Name,
+				Input:     call.Input,
+				Result:    toolResult,
+			})
+
+			// Build a tool result so the model can see success/failure and retry
+			toolResults = append(toolResults, Block{ToolResult: &ToolResult{
+				ToolCallID: call.ID,
+				Text:       toolResult,
+			}})
+		}
+
+		log.Printf("[engineer] round=%d dispatch results: succeeded=%d failed=%d",
+			round, succeeded, failed)
+
+		// Append assistant turn + user turn with tool results to the conversation
+		messages = append(messages,
+			resp.Message,
+			Message{Role: "user", Content: toolResults},
+		)
+
+		// If the model signalled end_turn even with tools, stop after processing them
+		if stopReason == "end_turn" {
+			break
+		}
+	}
+
+	log.Printf("[engineer] DONE turn=%d mutations=%d events=%d tokens=in:%d+out:%d cache=read:%d+write:%d",
+		g.ConversationCount, len(result.Mutations), len(result.Events),
+		result.Tokens.InputTokens, result.Tokens.OutputTokens,
+		result.Tokens.CacheReadTokens, result.Tokens.CacheWriteTokens)
+
+	return result, nil
+}
+
+// engineerSystemPrompt returns the system instructions for the Engineer.
+func engineerSystemPrompt() string {
+	return `You are a game world engineer. Your job is to read a narrator's text and execute the world mutations it implies using the provided tools.
+
+Rules:
+- Call tools ONLY for mutations clearly and unambiguously implied by the narrative text.
+- Do NOT invent mutations not supported by the text.
+- Do NOT output any narrative text — only tool calls.
+- If the narrative implies no world changes, call no tools.
+- Prefer precision over completeness: it is better to miss a subtle mutation than to invent one.
+- Use only canonical entity names exactly as listed in the World and Current Game State sections.
+- Items change hands between specific party members: always pass character_name to give_item_to_player and take_item_from_player. "You" in the narrative usually means the acting player.
+- If a mutation references a room/entity that is uncertain, call get_room_info first, then mutate.
+- When the narrative leaves the outcome of a risky action open (sneaking, climbing, persuading, resisting a trap), call request_ability_check with the fitting skill and DC instead of deciding the outcome. Roll at most once per attempt.
+
+Examples of what to look for:
+- "The lever grinds and a hidden passage opens to the east" → create_room(name, description, connect_to_room_name, direction)
+- "You find a rusty key on the floor" → give_item_to_player(rusty key, character_name: the acting player) or place_item_in_room
+- "The merchant hands Ayla a healing potion" → give_item_to_player(healing potion, character_name: Ayla)
+- "Brom drops his torch" → take_item_from_player(torch, character_name: Brom)
+- "A warded chest materializes beside the altar" → create_item + place_item_in_room
+- "The bridge collapses, blocking the northern passage" → update_room(current room, updated description)
+- "A cloaked figure emerges from the shadows" → move_character or create_character if not yet present
+- "You press yourself to the wall and edge toward the sleeping guard..." → request_ability_check(skill: stealth, dc: 12)
+- "The floor gives way beneath Brom!" → request_ability_check(skill: dexterity, dc: 13, saving_throw: true, character_name: Brom)`
+}
+
+// engineerUserMessage builds the user-turn message for the Engineer.
+// It includes the narrative text plus a compact game state snapshot so the
+// Engineer knows who is involved: every party member with their location and
+// inventory, and the acting player's current room. The rest of the world is
+// listed ahead of it by engineerWorldCatalogue.
+func engineerUserMessage(g *game.Game, speakerID, narrative string) string {
+	var sb strings.Builder
+	sb.WriteString("## Narrative\n\n")
+	sb.WriteString(narrative)
+	sb.WriteString("\n\n## Current Game State\n\n")
+
+	speaker, ok := g.GetPlayerCharacter(speakerID)
+	if !ok {
+		speaker, _ = g.OwnerCharacter()
+	}
+	sb.WriteString(fmt.Sprintf("Acting player: %s\n", speaker.Name))
+
+	// Party — use DnD HP when available (authoritative), fall back to legacy stub
+	sb.WriteString("Party (use these exact names for character_name):\n")
+	for _, uid := range g.PartyOrder() {
+		member, _ := g.GetPlayerCharacter(uid)
+		hp, maxHP, alive := member.Health, 100, member.Alive
+		if dndChar, hasDnD := g.GetDnDCharacter(uid); hasDnD && dndChar != nil {
+			hp = dndChar.GetHitPoints()
+			alive = !combat.IsDead(dndChar)
+			maxHP = dndChar.ToData().MaxHitPoints
+		}
+		location := "not yet arrived"
+		if r, err := g.GetRoom(member.LocationID); err == nil {
+			location = r.Name
+		}
+		inventory := "empty"
+		if len(member.Inventory) > 0 {
+			names := make([]string, 0, len(member.Inventory))
+			for _, id := range member.Inventory {
+				if item, err := g.GetItem(id); err == nil {
+					names = append(names, item.Name)
+				}
+			}
+			inventory = strings.Join(names, ", ")
+		}
+		sb.WriteString(fmt.Sprintf("- %s (health %d/%d, alive %v, location: %s, inventory: %s)\n",
+			member.Name, hp, maxHP, alive, location, inventory))
+	}
+
+	// Acting player's room
+	if room, err := g.GetRoom(speaker.LocationID); err == nil {
+		sb.WriteString(fmt.Sprintf("Current room: %s\n", room.Name))
+		sb.WriteString("Current room exits: ")
+		if len(room.Connections) == 0 {
+			sb.WriteString("none")
+		} else {
+			exits := make([]string, 0, len(room.Connections))
+			for dir, destID := range room.Connections {
+				destName := "unknown"
+				if destRoom, err := g.GetRoom(destID); err == nil {
+					destName = destRoom.Name
+				}
+				exits = append(exits, fmt.Sprintf("%s -> %s", dir, destName))
+			}
+			sort.Strings(exits)
+			sb.WriteString(strings.Join(exits, "; "))
+		}
+		sb.WriteString("\n")
+		sb.WriteString("Room items: ")
+		if len(room.Items) == 0 {
+			sb.WriteString("none")
+		} else {
+			names := make([]string, 0, len(room.Items))
+			for _, id := range room.Items {
+				if item, err := g.GetItem(id); err == nil {
+					names = append(names, item.Name)
+				}
+			}
+			sb.WriteString(strings.Join(names, ", "))
+		}
+		sb.WriteString("\n")
+
+		// NPCs in current room
+		sb.WriteString("Room occupants: ")
+		if len(room.Occupants) == 0 {
+			sb.WriteString("none")
+		} else {
+			parts := make([]string, 0, len(room.Occupants))
+			for _, id := range room.Occupants {
+				if npc, err := g.GetNPC(id); err == nil {
+					parts = append(parts, fmt.Sprintf("%s (health %d, alive %v)", npc.Name, npc.Health, npc.Alive))
+				}
+			}
+			sb.WriteString(strings.Join(parts, "; "))
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("\n")
+	sb.WriteString("Execute all world mutations clearly implied by the narrative above.")
+	return sb.String()
+}
+
+// engineerWorldCatalogue lists every room with its exits, every item and every
+// NPC by canonical name, so the Engineer's tool arguments match existing
+// entities. It is sorted throughout so it reads the same on every call until
+// the world changes, which lets it be cached.
+func engineerWorldCatalogue(g *game.Game) string {
+	var sb strings.Builder
+	sb.WriteString("## World\n\n")
+
+	// Canonical room list and exits (for exact tool arguments)
+	sb.WriteString("Known rooms (use these exact names):\n")
+	roomNames := make([]string, 0, len(g.Rooms))
+	roomsByName := make(map[string]game.Area, len(g.Rooms))
+	for _, room := range g.Rooms {
+		roomNames = append(roomNames, room.Name)
+		roomsByName[room.Name] = room
+	}
+	sort.Strings(roomNames)
+	if len(roomNames) == 0 {
+		sb.WriteString("- none\n")
+	} else {
+		for _, roomName := range roomNames {
+			room := roomsByName[roomName]
+			exits := make([]string, 0, len(room.Connections))
+			for dir, destID := range room.Connections {
+				destName := "unknown"
+				if destRoom, err := g.GetRoom(destID); err == nil {
+					destName = destRoom.Name
+				}
+				exits = append(exits, fmt.Sprintf("%s -> %s", dir, destName))
+			}
+			sort.Strings(exits)
+			exitsStr := "none"
+			if len(exits) > 0 {
+				exitsStr = strings.Join(exits, ", ")
+			}
+			sb.WriteString(fmt.Sprintf("- %s (exits: %s)\n", roomName, exitsStr))
+		}
+	}
+
+	// Canonical item list
+	sb.WriteString("Known items (use these exact names): ")
+	itemNames := make([]string, 0, len(g.Items))
+	for _, item := range g.Items {
+		itemNames = append(itemNames, item.Name)
+	}
+	sort.Strings(itemNames)
+	if len(itemNames) == 0 {
+		sb.WriteString("none")
+	} else {
+		sb.WriteString(strings.Join(itemNames, ", "))
+	}
+	sb.WriteString("\n")
+
+	// All NPCs (for cross-room mutations)
+	sb.WriteString("All NPCs (use these exact names): ")
+	npcParts := make([]string, 0, len(g.NPCs))
+	for _, npc := range g.NPCs {
+		roomName := ""
+		if r, err := g.GetRoom(npc.LocationID); err == nil {
+			roomName = r.Name
+		}
+		npcParts = append(npcParts, fmt.Sprintf("%s (health %d, alive %v, location: %s)", npc.Name, npc.Health, npc.Alive, roomName))
+	}
+	sort.Strings(npcParts)
+	if len(npcParts) == 0 {
+		sb.WriteString("none")
+	} else {
+		sb.WriteString(strings.Join(npcParts, "; "))
+	}
+	sb.WriteString("\n\n")
+	return sb.String()
+}
+
+// ---- Context compression ----
+
+// maxHistoryMessages is the maximum number of NarrativeMessage entries to send
+// to the model before trimming. Each player turn + assistant response = 2 messages,
+// so this allows ~20 full exchanges before compression kicks in.
+const maxHistoryMessages = 40
+
+// TrimHistory reduces the narrative history if it exceeds maxHistoryMessages.
+// It summarises the dropped messages into a single synthetic assistant turn so
+// the model retains the plot context without the full token cost. The summary
+// is shared, so dropped whispers are left out of it; their players still
+// have them in the chat history.
+// The summary comes from Provider.Summarize (fast/cheap).
+func (c *Client) TrimHistory(ctx context.Context, history []game.NarrativeMessage) ([]game.NarrativeMessage, error) {
+	if len(history) <= maxHistoryMessages {
+		return history, nil
+	}
+
+	// Keep the most recent maxHistoryMessages entries; summarise the rest.
+	cutoff := len(history) - maxHistoryMessages
+	toSummarise := history[:cutoff]
+	kept := history[cutoff:]
+
+	// Build a plain-text digest of the dropped messages
+	var sb strings.Builder
+	for _, m := range toSummarise {
+		if m.PrivateTo != "" {
+			continue
+		}
+		for _, b := range m.Content {
+			if b.Type == "text" && b.Text != "" {
+				role := "Narrator"
+				if m.Role == "user" {
+					role = "Player"
+				}
+				sb.WriteString(role)
+				sb.WriteString(": ")
+				sb.WriteString(b.Text)
+				sb.WriteString("\n\n")
+			}
+		}
+	}
+
+	prompt := "Summarise the following adventure log in 3-5 concise paragraphs, " +
+		"preserving key plot points, character introductions, items found, and locations visited. " +
+		"Write in third person past tense.\n\n" + sb.String()
+
+	resp, err := c.provider.Summarize(ctx, prompt)
+	if err != nil {
+		// Non-fatal: log and return history untrimmed rather than breaking the game
+		log.Printf("TrimHistory: summarisation failed (returning untrimmed): %v", err)
+		return history, nil
+	}
+
+	log.Printf("TrimHistory: tokens — input: %d, output: %d",
+		resp.Usage.InputTokens, resp.Usage.OutputTokens)
+
+	summary := resp.Message.Text()
+	summaryMsg := game.NarrativeMessage{
+		Role: "assistant",
+		Content: []game.NarrativeBlock{{
+			Type: "text",
+			Text: "[Story so far] " + summary,
+		}},
+	}
+
+	log.Printf("TrimHistory: trimmed %d → %d messages (summary: %d chars)",
+		len(history), len(kept)+1, len(summary))
+
+	return append([]game.NarrativeMessage{summaryMsg}, kept...), nil
+}
+
+// NarrativeFraming holds the Claude-generated narrative wrapping for a
+// procedurally generated dungeon. It is the output of GenerateNarrativeFraming.
+type NarrativeFraming struct {
+	Title            string            `json:"title"`
+	Theme            string            `json:"theme"`
+	QuestGoal        string            `json:"quest_goal"`
+	OpeningScene     string            `json:"opening_scene"`
+	RoomNames        map[string]string `json:"room_names"`        // zoneID → display name
+	RoomDescriptions map[string]string `json:"room_descriptions"` // zoneID → 1-2 sentence atmospheric description
+}
+
+// GenerateNarrativeFraming sends a dungeon summary to the narrator model and gets
+// back the narrative framing (title, theme, quest, opening scene, room names).
+// This is a single non-streaming Converse call where Claude writes narrative
+// framing only; world layout is generated procedurally by rpg-toolkit.
+func (c *Client) GenerateNarrativeFraming(
+	ctx context.Context,
+	dungeonSummary string,
+	creationParams game.CharacterCreationData,
+) (NarrativeFraming, TokenUsage, error) {
+	themeHint := ""
+	if creationParams.ThemeHint != "" {
+		themeHint = fmt.Sprintf("\nDesired tone/theme: %s", creationParams.ThemeHint)
+	}
+	prefHint := ""
+	if len(creationParams.Preferences) > 0 {
+		prefHint = fmt.Sprintf("\nPreferred gameplay elements: %s", strings.Join(creationParams.Preferences, ", "))
+	}
+
+	prompt := fmt.Sprintf(`You are creating the narrative framing for a D&D 5e dungeon.
+Given this procedurally generated dungeon layout, write:
+1. An evocative dungeon TITLE (4-8 words)
+2. A dark fantasy THEME (1 sentence)
+3. A QUEST GOAL that fits the dungeon's structure (1-2 sentences)
+4. An OPENING SCENE narrative (2-3 paragraphs) that establishes the atmosphere and hooks the player
+5. A display NAME for each room (short, evocative, 2-5 words)
+6. A short DESCRIPTION for each room (1-2 sentences of atmospheric, sensory detail — what the player sees, hears, smells on first entry)
+
+Dungeon layout:
+%s
+
+Player character: %s the %s (race: %s)%s%s
+
+Respond in JSON only — no markdown fences, no commentary:
+{
+  "title": "...",
+  "theme": "...",
+  "quest_goal": "...",
+  "opening_scene": "...",
+  "room_names": {"<room_id>": "<display name>", ...},
+  "room_descriptions": {"<room_id>": "<1-2 sentence description>", ...}
+}`,
+		dungeonSummary,
+		creationParams.Name, creationParams.ClassID, creationParams.RaceID,
+		themeHint, prefHint,
+	)
+
+	resp, err := c.provider.Converse(ctx, Request{
+		Tier:        TierNarrator,
+		Messages:    []Message{userText(prompt)},
+		MaxTokens:   3000,
+		Temperature: 0.8,
+	})
+	if err != nil {
+		return NarrativeFraming{}, TokenUsage{}, fmt.Errorf("generate narrative framing: %w", err)
+	}
+	usage := resp.Usage
+
+	text := resp.Message.Text()
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimSuffix(text, "```")
+		text = strings.TrimSpace(text)
+	}
+
+	var framing NarrativeFraming
+	if err := json.Unmarshal([]byte(text), &framing); err != nil {
+		return NarrativeFraming{}, usage, fmt.Errorf("parse narrative framing JSON: %w (raw: %.200s)", err, text)
+	}
+	return framing, usage, nil
+}
+
+// ---- History conversion helpers ----
+
+// historyMessages converts our storage format to provider messages for a
+// turn private to privateTo ("" for a public turn), leaving out the whispers
+// that turn may not see. Only text blocks are stored, so only text is sent back.
+func historyMessages(history []game.NarrativeMessage, privateTo string) []Message {
+	msgs := make([]Message, 0, len(history))
+	for _, h := range history {
+		if !h.VisibleTo(privateTo) {
+			continue
+		}
+		role := "user"
+		if h.Role == "assistant" {
+			role = "assistant"
+		}
+		var blocks []Block
+		for _, b := range h.Content {
+			if b.Type == "text" && b.Text != "" {
+				blocks = append(blocks, Block{Text: b.Text})
+			}
+		}
+		if len(blocks) > 0 {
+			msgs = append(msgs, Message{Role: role, Content: blocks})
+		}
+	}
+	return msgs
+}
+
+// narratorSystemPrompt is the system instructions for the narrator.
+// The Narrator has NO tools — it must write pure prose only. The prompt is the
+// same on every turn so it can be cached; what changes from turn to turn comes
+// with the player's message (see narratorTurnContext).
+const narratorSystemPrompt = `You are an expert Dungeon Master narrating a D&D 5e text adventure game for a party of players.
+
+Each player turn opens with a [TURN CONTEXT] block written by the game, not by a player: who is acting, where every party member is with their D&D stats, and any dice results to narrate. It is always current — trust it over anything earlier in the conversation.
+
+Each player message starts with the speaking character's name in brackets, e.g. "[Mira] I look around".
+Respond to that character by name. Party members in other rooms do not see or hear what happens here.
+Messages tagged "privately", e.g. "[Mira, privately] ...", and your replies to them are secret to that character: never reveal them to the rest of the party.
+
+Your ONLY job is to write immersive, engaging narrative prose.
+Do NOT describe what you are about to do or what tools you might call.
+Do NOT say things like "I will now..." or "As the DM, I...".
+Write only what the party experiences — sights, sounds, dialogue, action.
+
+When narrating combat or physical feats, respect each character's D&D stats (HP, AC, ability scores).
+A Barbarian with high STR smashes through doors; a Monk with high DEX moves like water.
+
+DM Philosophy:
+- Say Yes or Roll the Dice: if nothing is at stake, say yes and move the story forward. If the outcome is uncertain, narrate the attempt up to the moment of truth and stop there — the dice are rolled for you and the result arrives in the next DICE LOG.
+- Fail Forward: failed attempts create complications and drama, never dead ends.
+- Pacing: cut to the next interesting scene when things drag; slow for dramatic moments.
+- Never list options — narrate the world and let the players decide what to do.
+- Be specific and sensory: name the smells, the sounds, the textures.
+
+Write 2-4 paragraphs of vivid prose. Do not break the fourth wall.`
+
+// narratorTurnContext returns the [TURN CONTEXT] block that opens the
+// player's message each turn. Every party member is described with their
+// location and, when they have one, their D&D stats; acting marks who is
+// acting this turn (more than one member in an action round), and private
+// that only they will read the reply.
+// When g.PendingCombatContext is non-empty it is injected as a [DICE LOG] block
+// so Claude narrates the mechanical results (combat and ability checks)
+// dramatically without inventing outcomes.
+func narratorTurnContext(g *game.Game, acting []string, private bool) string {
+	speakerID := ""
+	if len(acting) > 0 {
+		speakerID = acting[0]
+	}
+	speaker, ok := g.GetPlayerCharacter(speakerID)
+	if !ok {
+		speaker, _ = g.OwnerCharacter()
+	}
+	room, _ := g.GetRoom(speaker.LocationID)
+	actingLine := fmt.Sprintf("%q is acting this turn and is currently in %q.", speaker.Name, room.Name)
+
+	// One entry per party member: where they are and their D&D stats if available
+	var party strings.Builder
+	order := g.PartyOrder()
+	for _, uid := range order {
+		member, _ := g.GetPlayerCharacter(uid)
+		location := "not yet arrived"
+		if r, err := g.GetRoom(member.LocationID); err == nil {
+			location = fmt.Sprintf("in %q", r.Name)
+		}
+		fmt.Fprintf(&party, "\n- %s — %s", member.Name, location)
+		if slices.Contains(acting, uid) {
+			party.WriteString(" (acting this turn)")
+		}
+		if dndChar, ok := g.GetDnDCharacter(uid); ok && dndChar != nil {
+			party.WriteString("\n" + game.BuildCharacterContext(member.Name, dndChar.ToData()))
+		}
+	}
+
+	// Inject pending combat and check results so Claude narrates what actually happened
+	combatContext := ""
+	if g.PendingCombatContext != "" {
+		combatContext = fmt.Sprintf("\n\n[DICE LOG — narrate these mechanical results dramatically; do not change the outcomes:]\n%s", g.PendingCombatContext)
+		// Consume after injecting so it is not repeated on subsequent turns
+		g.PendingCombatContext = ""
+	}
+
+	// An action round resolves every declared intent in one reply.
+	if len(acting) > 1 {
+		names := make([]string, 0, len(acting))
+		for _, uid := range acting {
+			member, _ := g.GetPlayerCharacter(uid)
+			names = append(names, member.Name)
+		}
+		actingLine = fmt.Sprintf("This is an action round: %s each declared an action, one per line below. Resolve them together in one scene, in whatever order the fiction demands, and respond to every one of them.", strings.Join(names, ", "))
+	}
+
+	// A whisper is answered to the speaker alone.
+	if private {
+		combatContext += fmt.Sprintf("\n\n[PRIVATE ACTION — only %s will read this reply. Narrate what they alone do and notice; do not describe the rest of the party reacting to it.]", speaker.Name)
+	}
+
+	// Once the run has ended the narrator only writes the epilogue.
+	if g.DungeonOver() {
+		combatContext += fmt.Sprintf("\n\n[THE ADVENTURE HAS ENDED — %s\nWrite an epilogue that brings the story to a close. Do not introduce new threats, quests, rooms or items; the world no longer changes.]", g.Outcome().Message)
+	}
+
+	return fmt.Sprintf("[TURN CONTEXT]\nThis is a party of %d. %s\n\nTHE PARTY:%s%s\n[END TURN CONTEXT]\n\n",
+		len(order), actingLine, party.String(), combatContext)
+}
@@ -0,0 +1,490 @@
+package ai_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rrochlin/an-amazing-adventure/internal/ai"
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+)
+
+// newTestGame sets up a minimal game with a player and one room.
+func newTestGame(t *testing.T) *game.Game {
+	t.Helper()
+	g := game.NewGame("test-session", "test-user")
+	g.SetPlayerCharacter("test-user", game.NewCharacter("Hero", "The protagonist"))
+	room := game.NewArea("Tavern", "A smoky room")
+	if err := g.AddRoom(room); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.PlacePlayer(room.ID); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// makeHistory builds a slice of alternating user/assistant NarrativeMessages.
+func makeHistory(n int) []game.NarrativeMessage {
+	msgs := make([]game.NarrativeMessage, 0, n)
+	for i := range n {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		msgs = append(msgs, game.NarrativeMessage{
+			Role: role,
+			Content: []game.NarrativeBlock{{
+				Type: "text",
+				Text: "message " + string(rune('A'+i)),
+			}},
+		})
+	}
+	return msgs
+}
+
+func TestTrimHistory_BelowThreshold_Unchanged(t *testing.T) {
+	// History shorter than maxHistoryMessages must be returned as-is.
+	// We use an ai.Client that has no real Bedrock creds — TrimHistory should
+	// not call Bedrock at all when below the threshold.
+	t.Setenv("BEDROCK_REGION", "us-west-2")
+	c, err := ai.New(context.Background())
+	if err != nil {
+		t.Fatalf("ai.New: %v", err)
+	}
+
+	history := makeHistory(10)
+	got, err := c.TrimHistory(context.Background(), history)
+	if err != nil {
+		t.Fatalf("TrimHistory: %v", err)
+	}
+	if len(got) != len(history) {
+		t.Errorf("expected %d messages unchanged, got %d", len(history), len(got))
+	}
+}
+
+func TestTrimHistory_AtThreshold_Unchanged(t *testing.T) {
+	t.Setenv("BEDROCK_REGION", "us-west-2")
+	c, err := ai.New(context.Background())
+	if err != nil {
+		t.Fatalf("ai.New: %v", err)
+	}
+
+	history := makeHistory(40) // exactly maxHistoryMessages
+	got, err := c.TrimHistory(context.Background(), history)
+	if err != nil {
+		t.Fatalf("TrimHistory: %v", err)
+	}
+	if len(got) != 40 {
+		t.Errorf("expected 40 messages at threshold, got %d", len(got))
+	}
+}
+
+func TestTrimHistory_AboveThreshold_Trims(t *testing.T) {
+	// When above the threshold, TrimHistory must attempt summarisation.
+	// With no real Bedrock creds the call will fail — TrimHistory must
+	// return the original history rather than an error.
+	t.Setenv("BEDROCK_REGION", "us-west-2")
+	c, err := ai.New(context.Background())
+	if err != nil {
+		t.Fatalf("ai.New: %v", err)
+	}
+
+	history := makeHistory(50) // 10 over the limit
+	got, err := c.TrimHistory(context.Background(), history)
+	if err != nil {
+		t.Fatalf("TrimHistory must not return an error even on Bedrock failure: %v", err)
+	}
+	// On Bedrock failure (no creds in test), history is returned untrimmed
+	if len(got) == 0 {
+		t.Error("expected non-empty history back")
+	}
+}
+
+// ---- NarrateStream structural tests ----
+// These tests verify the call returns without error on the no-tool-config path.
+// With no real Bedrock creds, NarrateStream will fail at the API call — that is
+// expected and fine. We test the pre-call setup (history trimming, message building).
+
+func TestNarrateStream_ReturnsWithoutToolCallAttempt(t *testing.T) {
+	// NarrateStream must not panic on valid input even when Bedrock is unavailable.
+	t.Setenv("BEDROCK_REGION", "us-west-2")
+	c, err := ai.New(context.Background())
+	if err != nil {
+		t.Fatalf("ai.New: %v", err)
+	}
+	g := newTestGame(t)
+	_, streamErr := c.NarrateStream(context.Background(), g, "test-user", false, nil, "look around", nil)
+	// Expect a Bedrock connectivity/auth error, NOT a nil pointer or panic
+	if streamErr == nil {
+		t.Log("NarrateStream unexpectedly succeeded (real Bedrock available?)")
+	}
+	// The key assertion: no panic occurred. If we reach here the test passes.
+}
+
+func TestNarrateStream_EmptyHistoryHandled(t *testing.T) {
+	// nil history must not cause a nil pointer panic.
+	t.Setenv("BEDROCK_REGION", "us-west-2")
+	c, err := ai.New(context.Background())
+	if err != nil {
+		t.Fatalf("ai.New: %v", err)
+	}
+	g := newTestGame(t)
+	_, _ = c.NarrateStream(context.Background(), g, "test-user", false, nil, "hello", nil)
+	// Passes if no panic
+}
+
+// ---- EngineerScan structural tests ----
+
+func TestEngineerScan_ReturnsWithoutPanic(t *testing.T) {
+	t.Setenv("BEDROCK_REGION", "us-west-2")
+	c, err := ai.New(context.Background())
+	if err != nil {
+		t.Fatalf("ai.New: %v", err)
+	}
+	g := newTestGame(t)
+	result, scanErr := c.EngineerScan(context.Background(), g, "test-user", "The goblin appears from the shadows.")
+	if scanErr != nil {
+		// Expected — no real Bedrock creds in tests
+		t.Logf("EngineerScan returned expected error (no Bedrock creds): %v", scanErr)
+		return
+	}
+	// If somehow Bedrock is available, result must be valid
+	_ = result
+}
+
+func TestEngineerScan_EmptyNarrativeNoMutations(t *testing.T) {
+	// An empty narrative should yield no mutations even if Bedrock is available.
+	// Without Bedrock this just verifies no panic on empty input.
+	t.Setenv("BEDROCK_REGION", "us-west-2")
+	c, err := ai.New(context.Background())
+	if err != nil {
+		t.Fatalf("ai.New: %v", err)
+	}
+	g := newTestGame(t)
+	_, _ = c.EngineerScan(context.Background(), g, "test-user", "")
+	// Passes if no panic
+}
+
+func TestTrimHistory_SummaryPrefixed(t *testing.T) {
+	// Verify the summary message is prepended with "[Story so far]"
+	// by checking the first message of a trimmed result (when Bedrock succeeds).
+	// In unit tests Bedrock will fail, so we just verify the fallback is clean.
+	t.Setenv("BEDROCK_REGION", "us-west-2")
+	c, err := ai.New(context.Background())
+	if err != nil {
+		t.Fatalf("ai.New: %v", err)
+	}
+	history := makeHistory(50)
+	got, _ := c.TrimHistory(context.Background(), history)
+	// Either trimmed (first msg is summary) or untrimmed fallback — both valid
+	for _, m := range got {
+		for _, b := range m.Content {
+			if strings.HasPrefix(b.Text, "[Story so far]") {
+				return // found the summary prefix — test passes
+			}
+		}
+	}
+	// No summary found is fine — means Bedrock failed and we fell back
+	if len(got) != 50 {
+		t.Errorf("expected fallback to return all 50 messages, got %d", len(got))
+	}
+}
+
+// ---- Scripted provider ----
+
+func TestNarrateStream_FakeProviderStreamsAndRecordsHistory(t *testing.T) {
+	fake := ai.NewFakeProvider().ScriptNarration(ai.FakeTurn{
+		Text:  "The tavern door creaks open.",
+		Usage: ai.TokenUsage{InputTokens: 100, OutputTokens: 7},
+	})
+	c := ai.NewClient(fake)
+	g := newTestGame(t)
+
+	var chunks []string
+	res, err := c.NarrateStream(context.Background(), g, "test-user", false, makeHistory(2), "open the door", func(s string) {
+		chunks = append(chunks, s)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Narrative != "The tavern door creaks open." || strings.Join(chunks, "") != res.Narrative {
+		t.Errorf("narrative %q streamed as %q", res.Narrative, chunks)
+	}
+	if len(chunks) < 2 {
+		t.Errorf("expected several chunks, got %d", len(chunks))
+	}
+	if res.Tokens.Total() != 107 {
+		t.Errorf("tokens = %+v", res.Tokens)
+	}
+	if len(res.NewMessages) != 4 || res.NewMessages[2].Content[0].Text != "[Hero] open the door" {
+		t.Errorf("history = %+v", res.NewMessages)
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 1 || calls[0].Method != "StreamNarration" {
+		t.Fatalf("calls = %+v", calls)
+	}
+	req := calls[0].Request
+	if req.Tier != ai.TierNarrator || len(req.Tools) != 0 || !strings.Contains(turnContext(req), "Hero") {
+		t.Errorf("narrator request = tier %v, %d tools, turn context %.60q", req.Tier, len(req.Tools), turnContext(req))
+	}
+	if len(req.Messages) != 3 {
+		t.Errorf("expected 2 history messages plus the player turn, got %d", len(req.Messages))
+	}
+}
+
+// turnContext returns the [TURN CONTEXT] block opening a narrator request's
+// player turn.
+func turnContext(req ai.Request) string {
+	return req.Messages[len(req.Messages)-1].Content[0].Text
+}
+
+func TestNarrateStream_CachesStablePrefix(t *testing.T) {
+	fake := ai.NewFakeProvider().ScriptNarration(ai.FakeTurn{Text: "The fire crackles."})
+	c := ai.NewClient(fake)
+
+	if _, err := c.NarrateStream(context.Background(), newTestGame(t), "test-user", false, makeHistory(4), "warm my hands", nil); err != nil {
+		t.Fatal(err)
+	}
+	req := fake.Calls()[0].Request
+	if !req.CacheSystem || strings.Contains(req.System, "Hero") {
+		t.Errorf("system prompt should be cached and free of game state: cache=%v", req.CacheSystem)
+	}
+	// The cache point closes the history; the new turn comes after it.
+	history := req.Messages[len(req.Messages)-2].Content
+	if !history[len(history)-1].CachePoint {
+		t.Errorf("last history message should end with a cache point: %+v", history)
+	}
+	for _, b := range req.Messages[len(req.Messages)-1].Content {
+		if b.CachePoint {
+			t.Error("the player turn should not be cached")
+		}
+	}
+}
+
+func TestTokenUsage_TotalWeighsCachedTokens(t *testing.T) {
+	u := ai.TokenUsage{InputTokens: 100, OutputTokens: 50, CacheReadTokens: 2000, CacheWriteTokens: 400}
+	if got := u.Total(); got != 100+50+200+500 {
+		t.Errorf("Total = %d", got)
+	}
+}
+
+func TestNarrateStream_DescribesWholeParty(t *testing.T) {
+	fake := ai.NewFakeProvider().ScriptNarration(ai.FakeTurn{Text: "Ayla spots a glint."}).
+		ScriptConverse(ai.FakeTurn{Text: "Done."})
+	c := ai.NewClient(fake)
+	g := newTestGame(t)
+	cellar := game.NewArea("Cellar", "Damp and dark")
+	if err := g.AddRoom(cellar); err != nil {
+		t.Fatal(err)
+	}
+	g.SetPlayerCharacter("user-2", game.NewCharacter("Ayla", "A ranger"))
+	if err := g.PlaceCharacter("user-2", cellar.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := c.NarrateStream(context.Background(), g, "user-2", false, nil, "search the barrels", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.NewMessages[0].Content[0].Text; got != "[Ayla] search the barrels" {
+		t.Errorf("stored input = %q", got)
+	}
+	system := turnContext(fake.Calls()[0].Request)
+	for _, want := range []string{`Hero — in "Tavern"`, `Ayla — in "Cellar" (acting this turn)`, "party of 2"} {
+		if !strings.Contains(system, want) {
+			t.Errorf("turn context missing %q:\n%s", want, system)
+		}
+	}
+
+	if _, err := c.EngineerScan(context.Background(), g, "user-2", res.Narrative); err != nil {
+		t.Fatal(err)
+	}
+	engineer := fake.Calls()[1].Request.Messages[0].Text()
+	for _, want := range []string{"Acting player: Ayla", "- Hero (", "location: Cellar", "Current room: Cellar"} {
+		if !strings.Contains(engineer, want) {
+			t.Errorf("engineer message missing %q", want)
+		}
+	}
+}
+
+func TestNarrateRound_ResolvesEveryIntent(t *testing.T) {
+	fake := ai.NewFakeProvider().ScriptNarration(ai.FakeTurn{Text: "Steel rings as Ayla bars the door."})
+	c := ai.NewClient(fake)
+	g := newTestGame(t)
+	g.SetPlayerCharacter("user-2", game.NewCharacter("Ayla", "A ranger"))
+
+	intents := []game.Intent{{UserID: "test-user", Content: "open the chest"}, {UserID: "user-2", Content: "bar the door"}}
+	res, err := c.NarrateRound(context.Background(), g, intents, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.NewMessages[0].Content[0].Text; got != "[Hero] open the chest\n[Ayla] bar the door" {
+		t.Errorf("stored input = %q", got)
+	}
+	system := turnContext(fake.Calls()[0].Request)
+	for _, want := range []string{"This is an action round: Hero, Ayla", "Hero — in \"Tavern\" (acting this turn)", "Ayla — not yet arrived (acting this turn)"} {
+		if !strings.Contains(system, want) {
+			t.Errorf("turn context missing %q:\n%s", want, system)
+		}
+	}
+}
+
+func TestNarrateStream_PrivateTurn(t *testing.T) {
+	fake := ai.NewFakeProvider().ScriptNarration(ai.FakeTurn{Text: "The gem slips into your sleeve."})
+	c := ai.NewClient(fake)
+
+	res, err := c.NarrateStream(context.Background(), newTestGame(t), "test-user", true, nil, "I pocket the gem", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.NewMessages[0].Content[0].Text; got != "[Hero, privately] I pocket the gem" {
+		t.Errorf("stored input = %q", got)
+	}
+	if system := turnContext(fake.Calls()[0].Request); !strings.Contains(system, "PRIVATE ACTION — only Hero") {
+		t.Errorf("turn context should mark the turn private:\n%s", system)
+	}
+	for _, m := range res.NewMessages {
+		if m.PrivateTo != "test-user" {
+			t.Errorf("whisper stored with PrivateTo %q", m.PrivateTo)
+		}
+	}
+}
+
+func TestNarrateStream_WhispersStayOutOfPublicTurns(t *testing.T) {
+	fake := ai.NewFakeProvider().ScriptNarration(
+		ai.FakeTurn{Text: "The gem slips into your sleeve."},
+		ai.FakeTurn{Text: "The corridor stretches on."},
+		ai.FakeTurn{Text: "The gem is warm against your wrist."},
+	)
+	c := ai.NewClient(fake)
+	g := newTestGame(t)
+	g.SetPlayerCharacter("user-2", game.NewCharacter("Ayla", "A ranger"))
+
+	res, err := c.NarrateStream(context.Background(), g, "test-user", true, nil, "I pocket the gem", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = c.NarrateStream(context.Background(), g, "user-2", false, res.NewMessages, "we walk on", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.NarrateStream(context.Background(), g, "test-user", true, res.NewMessages, "I check the gem", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := func(i int) string {
+		var sb strings.Builder
+		for _, m := range fake.Calls()[i].Request.Messages {
+			sb.WriteString(m.Text())
+		}
+		return sb.String()
+	}
+	if public := sent(1); strings.Contains(public, "pocket the gem") || strings.Contains(public, "slips into your sleeve") {
+		t.Errorf("a public turn should not see the whisper:\n%s", public)
+	}
+	if private := sent(2); !strings.Contains(private, "pocket the gem") || !strings.Contains(private, "we walk on") {
+		t.Errorf("the whisperer's next private turn should see both histories:\n%s", private)
+	}
+}
+
+func TestEngineerScan_FakeProviderRunsToolLoop(t *testing.T) {
+	fake := ai.NewFakeProvider().ScriptConverse(
+		ai.FakeTurn{ToolCalls: []ai.ToolCall{
+			{Name: "create_item", Input: map[string]any{"name": "Rusty Key", "description": "Old and bent"}},
+			{Name: "summon_dragon", Input: map[string]any{}},
+		}},
+		ai.FakeTurn{Text: "Done."},
+	)
+	c := ai.NewClient(fake)
+	g := newTestGame(t)
+
+	res, err := c.EngineerScan(context.Background(), g, "test-user", "You find a rusty key.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Mutations) != 2 || res.Mutations[0].Tool != "create_item" {
+		t.Fatalf("mutations = %+v", res.Mutations)
+	}
+	if !strings.HasPrefix(res.Mutations[1].Result, "error:") {
+		t.Errorf("unknown tool should record an error result, got %q", res.Mutations[1].Result)
+	}
+	if _, err := g.GetItemByName("Rusty Key"); err != nil {
+		t.Errorf("create_item was not applied: %v", err)
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("expected a second round after tool results, got %d calls", len(calls))
+	}
+	second := calls[1].Request.Messages
+	// The world catalogue is cached ahead of the narrative on every round.
+	if first := second[0].Content; !calls[1].Request.CacheSystem || len(first) != 3 ||
+		!strings.HasPrefix(first[0].Text, "## World") || !first[1].CachePoint || !strings.HasPrefix(first[2].Text, "## Narrative") {
+		t.Errorf("engineer prompt should cache the world catalogue, got %+v", first)
+	}
+	results := second[len(second)-1]
+	if results.Role != "user" || len(results.Content) != 2 || results.Content[0].ToolResult == nil {
+		t.Fatalf("second round must end with tool results, got %+v", results)
+	}
+	if results.Content[0].ToolResult.ToolCallID != "fake-tool-1" {
+		t.Errorf("tool result id = %q", results.Content[0].ToolResult.ToolCallID)
+	}
+}
+
+func TestTrimHistory_FakeProviderSummarises(t *testing.T) {
+	fake := ai.NewFakeProvider().ScriptSummary(ai.FakeTurn{Text: "Hero drank ale."})
+	c := ai.NewClient(fake)
+
+	got, err := c.TrimHistory(context.Background(), makeHistory(50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 41 || got[0].Content[0].Text != "[Story so far] Hero drank ale." {
+		t.Errorf("trimmed to %d messages, first %+v", len(got), got[0])
+	}
+	calls := fake.Calls()
+	if len(calls) != 1 || calls[0].Method != "Summarize" || !strings.Contains(calls[0].Request.Messages[0].Text(), "message A") {
+		t.Errorf("summarise calls = %+v", calls)
+	}
+}
+
+func TestFakeProvider_DeploymentDefaultOnly(t *testing.T) {
+	t.Setenv("AI_PROVIDER", "")
+	if ai.KnownProvider(ai.ProviderFake) {
+		t.Error("the fake provider must not be stored as a user override")
+	}
+	if _, err := ai.NewFor(context.Background(), ai.ProviderFake); err == nil {
+		t.Error("expected a per-user fake override to be refused")
+	}
+}
+
+func TestFakeProvider_OfflineReplies(t *testing.T) {
+	t.Setenv("AI_PROVIDER", "fake")
+	c, err := ai.New(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := newTestGame(t)
+
+	res, err := c.NarrateStream(context.Background(), g, "test-user", false, nil, "look around", nil)
+	if err != nil || !strings.Contains(res.Narrative, "look around") {
+		t.Errorf("offline narration = %q, %v", res.Narrative, err)
+	}
+	eng, err := c.EngineerScan(context.Background(), g, "test-user", res.Narrative)
+	if err != nil || len(eng.Mutations) != 0 {
+		t.Errorf("offline engineer = %+v, %v", eng, err)
+	}
+	framing, _, err := c.GenerateNarrativeFraming(context.Background(), "one room", game.CharacterCreationData{Name: "Hero"})
+	if err != nil || framing.Title == "" || framing.OpeningScene == "" {
+		t.Errorf("offline framing = %+v, %v", framing, err)
+	}
+}
+
+func TestNewProvider_Unknown(t *testing.T) {
+	t.Setenv("AI_PROVIDER", "carrier-pigeon")
+	if _, err := ai.New(context.Background()); err == nil {
+		t.Error("expected an error for an unknown provider")
+	}
+}
@@ -0,0 +1,176 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// FakeTurn is one scripted model reply.
+type FakeTurn struct {
+	Text      string
+	ToolCalls []ToolCall
+	// StopReason defaults to "tool_use" when ToolCalls is set, else "end_turn".
+	StopReason string
+	Usage      TokenUsage
+	// Err, when set, is returned instead of a reply.
+	Err error
+}
+
+// FakeCall records one request a FakeProvider received.
+type FakeCall struct {
+	Method  string // "StreamNarration", "Converse" or "Summarize"
+	Request Request
+}
+
+// FakeProvider replays scripted replies: each method takes the next turn from
+// its own queue. Once a queue runs dry it answers offline instead — narration
+// echoes the player's action, the Engineer calls no tools, summaries are a
+// placeholder and narrative framing is a stub adventure — so a game can be
+// played end to end without a model.
+type FakeProvider struct {
+	mu        sync.Mutex
+	narration []FakeTurn
+	converse  []FakeTurn
+	summaries []FakeTurn
+	calls     []FakeCall
+}
+
+// NewFakeProvider returns a FakeProvider with empty scripts.
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{}
+}
+
+// ScriptNarration queues replies for StreamNarration.
+func (f *FakeProvider) ScriptNarration(turns ...FakeTurn) *FakeProvider {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.narration = append(f.narration, turns...)
+	return f
+}
+
+// ScriptConverse queues replies for Converse.
+func (f *FakeProvider) ScriptConverse(turns ...FakeTurn) *FakeProvider {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.converse = append(f.converse, turns...)
+	return f
+}
+
+// ScriptSummary queues replies for Summarize.
+func (f *FakeProvider) ScriptSummary(turns ...FakeTurn) *FakeProvider {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.summaries = append(f.summaries, turns...)
+	return f
+}
+
+// Calls returns the requests received so far, in order.
+func (f *FakeProvider) Calls() []FakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FakeCall(nil), f.calls...)
+}
+
+// StreamNarration implements Provider, streaming the reply a word at a time.
+func (f *FakeProvider) StreamNarration(_ context.Context, req Request, onChunk func(string)) (Response, error) {
+	turn, ok := f.next("StreamNarration", req, &f.narration)
+	if !ok {
+		turn = FakeTurn{Text: "(offline narrator) " + lastUserText(req.Messages)}
+	}
+	if turn.Err != nil {
+		return Response{}, turn.Err
+	}
+	if onChunk != nil {
+		for _, chunk := range strings.SplitAfter(turn.Text, " ") {
+			if chunk != "" {
+				onChunk(chunk)
+			}
+		}
+	}
+	return turn.response(), nil
+}
+
+// Converse implements Provider.
+func (f *FakeProvider) Converse(_ context.Context, req Request) (Response, error) {
+	turn, ok := f.next("Converse", req, &f.converse)
+	if !ok && len(req.Tools) == 0 {
+		turn = FakeTurn{Text: offlineFraming()}
+	}
+	if turn.Err != nil {
+		return Response{}, turn.Err
+	}
+	return turn.response(), nil
+}
+
+// Summarize implements Provider.
+func (f *FakeProvider) Summarize(_ context.Context, prompt string) (Response, error) {
+	turn, ok := f.next("Summarize", Request{Tier: TierSubAgent, Messages: []Message{userText(prompt)}}, &f.summaries)
+	if !ok {
+		turn = FakeTurn{Text: "The party's earlier adventures (offline summary)."}
+	}
+	if turn.Err != nil {
+		return Response{}, turn.Err
+	}
+	return turn.response(), nil
+}
+
+// next records the call and pops the head of queue, reporting whether there
+// was one.
+func (f *FakeProvider) next(method string, req Request, queue *[]FakeTurn) (FakeTurn, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, FakeCall{Method: method, Request: req})
+	if len(*queue) == 0 {
+		return FakeTurn{}, false
+	}
+	turn := (*queue)[0]
+	*queue = (*queue)[1:]
+	return turn, true
+}
+
+// response converts the turn into a Response, numbering unnamed tool calls.
+func (t FakeTurn) response() Response {
+	msg := Message{Role: "assistant"}
+	if t.Text != "" {
+		msg.Content = append(msg.Content, Block{Text: t.Text})
+	}
+	for i, call := range t.ToolCalls {
+		if call.ID == "" {
+			call.ID = fmt.Sprintf("fake-tool-%d", i+1)
+		}
+		msg.Content = append(msg.Content, Block{ToolCall: &call})
+	}
+	stop := t.StopReason
+	if stop == "" {
+		stop = "end_turn"
+		if len(t.ToolCalls) > 0 {
+			stop = "tool_use"
+		}
+	}
+	return Response{Message: msg, StopReason: stop, Usage: t.Usage}
+}
+
+// lastUserText returns the text of the last user message.
+func lastUserText(msgs []Message) string {
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == "user" {
+			return msgs[i].Text()
+		}
+	}
+	return ""
+}
+
+// offlineFraming is the narrative framing FakeProvider returns when world-gen
+// asks for one with no script; rooms keep their generated names.
+func offlineFraming() string {
+	b, _ := json.Marshal(NarrativeFraming{
+		Title:        "The Offline Depths",
+		Theme:        "A quiet dungeon waiting for its storyteller.",
+		QuestGoal:    "Find the way through the depths.",
+		OpeningScene: "You stand at the mouth of a dungeon. The narrator is offline, but the dice still roll.",
+	})
+	return string(b)
+}
@@ -0,0 +1,337 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// OpenAIProvider runs requests on an OpenAI-compatible Chat Completions API —
+// OpenAI itself, or a self-hosted model behind llama.cpp's server or vLLM.
+// Narration streams over server-sent events and the Engineer's tools are
+// offered as function calls.
+type OpenAIProvider struct {
+	// BaseURL is the API root, e.g. "http://localhost:8000/v1".
+	BaseURL string
+	// APIKey is sent as a bearer token when set; local servers usually need none.
+	APIKey string
+	// Models maps each tier to a model name as the server knows it.
+	Models map[ModelTier]string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// NewOpenAIProvider creates an OpenAIProvider from OPENAI_BASE_URL,
+// OPENAI_API_KEY, OPENAI_NARRATOR_MODEL and OPENAI_SUBAGENT_MODEL. The
+// sub-agent model defaults to the narrator model, since self-hosted setups
+// often serve a single model.
+func NewOpenAIProvider() (*OpenAIProvider, error) {
+	baseURL := os.Getenv("OPENAI_BASE_URL")
+	narrator := os.Getenv("OPENAI_NARRATOR_MODEL")
+	if baseURL == "" || narrator == "" {
+		return nil, fmt.Errorf("openai provider needs OPENAI_BASE_URL and OPENAI_NARRATOR_MODEL")
+	}
+	return &OpenAIProvider{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		APIKey:  os.Getenv("OPENAI_API_KEY"),
+		Models: map[ModelTier]string{
+			TierNarrator: narrator,
+			TierSubAgent: envOr("OPENAI_SUBAGENT_MODEL", narrator),
+		},
+	}, nil
+}
+
+// StreamNarration implements Provider with a streamed chat completion.
+func (p *OpenAIProvider) StreamNarration(ctx context.Context, req Request, onChunk func(string)) (Response, error) {
+	body := p.chatRequest(req)
+	body.Stream = true
+	body.StreamOptions = &oaStreamOptions{IncludeUsage: true}
+
+	resp, err := p.post(ctx, body)
+	if err != nil {
+		return Response{}, fmt.Errorf("chat completion stream: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var text strings.Builder
+	out := Response{StopReason: "end_turn"}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue // blank separators, comments and event: lines
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+		var chunk oaResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return Response{}, fmt.Errorf("decode stream chunk: %w", err)
+		}
+		for _, choice := range chunk.Choices {
+			if delta := choice.Delta.Content; delta != nil && *delta != "" {
+				text.WriteString(*delta)
+				if onChunk != nil {
+					onChunk(*delta)
+				}
+			}
+			if choice.FinishReason != "" {
+				out.StopReason = stopReason(choice.FinishReason)
+			}
+		}
+		if chunk.Usage != nil {
+			out.Usage = chunk.Usage.tokenUsage()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Response{}, fmt.Errorf("stream error: %w", err)
+	}
+	out.Message = Message{Role: "assistant", Content: []Block{{Text: text.String()}}}
+	return out, nil
+}
+
+// Converse implements Provider with a single chat completion.
+func (p *OpenAIProvider) Converse(ctx context.Context, req Request) (Response, error) {
+	resp, err := p.post(ctx, p.chatRequest(req))
+	if err != nil {
+		return Response{}, fmt.Errorf("chat completion: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var completion oaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+		return Response{}, fmt.Errorf("decode chat completion: %w", err)
+	}
+	if len(completion.Choices) == 0 {
+		return Response{}, fmt.Errorf("chat completion returned no choices")
+	}
+	choice := completion.Choices[0]
+	out := Response{
+		Message:    fromOpenAIMessage(choice.Message),
+		StopReason: stopReason(choice.FinishReason),
+	}
+	if completion.Usage != nil {
+		out.Usage = completion.Usage.tokenUsage()
+	}
+	return out, nil
+}
+
+// Summarize implements Provider on the sub-agent model.
+func (p *OpenAIProvider) Summarize(ctx context.Context, prompt string) (Response, error) {
+	return p.Converse(ctx, Request{
+		Tier:      TierSubAgent,
+		Messages:  []Message{userText(prompt)},
+		MaxTokens: 512,
+	})
+}
+
+// post sends a chat completion request, turning non-2xx replies into errors
+// that carry the server's message.
+func (p *OpenAIProvider) post(ctx context.Context, body oaRequest) (*http.Response, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+"/chat/completions", bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+
+	hc := p.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// chatRequest translates a Request into a Chat Completions request body.
+func (p *OpenAIProvider) chatRequest(req Request) oaRequest {
+	body := oaRequest{
+		Model:     p.Models[req.Tier],
+		Messages:  toOpenAIMessages(req.System, req.Messages),
+		MaxTokens: req.MaxTokens,
+	}
+	if req.Temperature > 0 {
+		t := req.Temperature
+		body.Temperature = &t
+	}
+	for _, t := range req.Tools {
+		body.Tools = append(body.Tools, toOpenAITool(t))
+	}
+	return body
+}
+
+// ---- Chat Completions wire types ----
+
+type oaRequest struct {
+	Model         string           `json:"model"`
+	Messages      []oaMessage      `json:"messages"`
+	Tools         []oaTool         `json:"tools,omitempty"`
+	MaxTokens     int              `json:"max_tokens,omitempty"`
+	Temperature   *float32         `json:"temperature,omitempty"`
+	Stream        bool             `json:"stream,omitempty"`
+	StreamOptions *oaStreamOptions `json:"stream_options,omitempty"`
+}
+
+type oaStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type oaMessage struct {
+	Role       string       `json:"role"` // "system" | "user" | "assistant" | "tool"
+	Content    *string      `json:"content"`
+	ToolCalls  []oaToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string       `json:"tool_call_id,omitempty"`
+}
+
+type oaToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"` // always "function"
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"` // JSON-encoded object
+	} `json:"function"`
+}
+
+type oaTool struct {
+	Type     string `json:"type"` // always "function"
+	Function struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description"`
+		Parameters  map[string]any `json:"parameters"`
+	} `json:"function"`
+}
+
+type oaResponse struct {
+	Choices []struct {
+		Message      oaMessage `json:"message"`
+		Delta        oaMessage `json:"delta"`
+		FinishReason string    `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *oaUsage `json:"usage"`
+}
+
+type oaUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+func (u oaUsage) tokenUsage() TokenUsage {
+	return TokenUsage{InputTokens: u.PromptTokens, OutputTokens: u.CompletionTokens}
+}
+
+// ---- Chat Completions conversion helpers ----
+
+// toOpenAITool converts a ToolSpec into a function tool. Some local servers
+// reject object schemas without a properties map, so one is always present.
+func toOpenAITool(t ToolSpec) oaTool {
+	params := make(map[string]any, len(t.InputSchema)+1)
+	for k, v := range t.InputSchema {
+		params[k] = v
+	}
+	if _, ok := params["properties"]; !ok {
+		params["properties"] = map[string]any{}
+	}
+	var out oaTool
+	out.Type = "function"
+	out.Function.Name = t.Name
+	out.Function.Description = t.Description
+	out.Function.Parameters = params
+	return out
+}
+
+// toOpenAIMessages converts provider-neutral messages to Chat Completions
+// messages. The system prompt becomes the first message, and each tool result
+// becomes its own "tool" message ahead of any text in the same turn.
+func toOpenAIMessages(system string, msgs []Message) []oaMessage {
+	var out []oaMessage
+	if system != "" {
+		out = append(out, oaMessage{Role: "system", Content: &system})
+	}
+	for _, m := range msgs {
+		if m.Role == "assistant" {
+			msg := oaMessage{Role: "assistant"}
+			if text := m.Text(); text != "" {
+				msg.Content = &text
+			}
+			for _, call := range m.ToolCalls() {
+				args, _ := json.Marshal(call.Input)
+				if call.Input == nil {
+					args = []byte("{}")
+				}
+				tc := oaToolCall{ID: call.ID, Type: "function"}
+				tc.Function.Name = call.Name
+				tc.Function.Arguments = string(args)
+				msg.ToolCalls = append(msg.ToolCalls, tc)
+			}
+			out = append(out, msg)
+			continue
+		}
+		for _, b := range m.Content {
+			if b.ToolResult != nil {
+				text := b.ToolResult.Text
+				out = append(out, oaMessage{Role: "tool", ToolCallID: b.ToolResult.ToolCallID, Content: &text})
+			}
+		}
+		if text := m.Text(); text != "" {
+			out = append(out, oaMessage{Role: "user", Content: &text})
+		}
+	}
+	return out
+}
+
+// fromOpenAIMessage converts an assistant message to a Message. Arguments
+// that are not a JSON object (small models get this wrong) become empty
+// input, so the tool call fails validation and the error is fed back.
+func fromOpenAIMessage(m oaMessage) Message {
+	out := Message{Role: "assistant"}
+	if m.Content != nil && *m.Content != "" {
+		out.Content = append(out.Content, Block{Text: *m.Content})
+	}
+	for _, tc := range m.ToolCalls {
+		input := map[string]any{}
+		_ = json.Unmarshal([]byte(tc.Function.Arguments), &input)
+		out.Content = append(out.Content, Block{ToolCall: &ToolCall{
+			ID:    tc.ID,
+			Name:  tc.Function.Name,
+			Input: input,
+		}})
+	}
+	return out
+}
+
+// stopReason maps a Chat Completions finish_reason onto the Bedrock-style
+// stop reasons Client checks.
+func stopReason(finish string) string {
+	switch finish {
+	case "stop":
+		return "end_turn"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	case "length":
+		return "max_tokens"
+	default:
+		return finish
+	}
+}
@@ -0,0 +1,224 @@
+package ai_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rrochlin/an-amazing-adventure/internal/ai"
+)
+
+// stubChatServer is an OpenAI-compatible /chat/completions endpoint that
+// records each request body and answers with the next scripted reply.
+type stubChatServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []map[string]any
+	auth     []string
+	replies  []func(w http.ResponseWriter)
+}
+
+func newStubChatServer(t *testing.T, replies ...func(w http.ResponseWriter)) *stubChatServer {
+	t.Helper()
+	s := &stubChatServer{replies: replies}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		s.mu.Lock()
+		s.requests = append(s.requests, body)
+		s.auth = append(s.auth, r.Header.Get("Authorization"))
+		n := len(s.requests)
+		s.mu.Unlock()
+		if n > len(s.replies) {
+			http.Error(w, `{"error":"unscripted request"}`, http.StatusInternalServerError)
+			return
+		}
+		s.replies[n-1](w)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *stubChatServer) provider() *ai.OpenAIProvider {
+	return &ai.OpenAIProvider{
+		BaseURL: s.URL + "/v1",
+		APIKey:  "sk-local",
+		Models:  map[ai.ModelTier]string{ai.TierNarrator: "big-model", ai.TierSubAgent: "small-model"},
+	}
+}
+
+func jsonReply(v string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, v)
+	}
+}
+
+func sseReply(events ...string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, e := range events {
+			fmt.Fprintf(w, "data: %s\n\n", e)
+			w.(http.Flusher).Flush()
+		}
+	}
+}
+
+// ---- Narrator streaming ----
+
+func TestOpenAI_NarrateStreamOverSSE(t *testing.T) {
+	srv := newStubChatServer(t, sseReply(
+		`{"choices":[{"delta":{"role":"assistant"}}]}`,
+		`{"choices":[{"delta":{"content":"The door "}}]}`,
+		`{"choices":[{"delta":{"content":"creaks."},"finish_reason":"stop"}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":120,"completion_tokens":4}}`,
+		`[DONE]`,
+	))
+	c := ai.NewClient(srv.provider())
+
+	var chunks []string
+	res, err := c.NarrateStream(context.Background(), newTestGame(t), "test-user", false, makeHistory(2), "open the door", func(s string) {
+		chunks = append(chunks, s)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Narrative != "The door creaks." || len(chunks) != 2 {
+		t.Errorf("narrative %q from chunks %q", res.Narrative, chunks)
+	}
+	if res.Tokens.InputTokens != 120 || res.Tokens.OutputTokens != 4 {
+		t.Errorf("tokens = %+v", res.Tokens)
+	}
+
+	req := srv.requests[0]
+	if req["model"] != "big-model" || req["stream"] != true || req["tools"] != nil {
+		t.Errorf("request model=%v stream=%v tools=%v", req["model"], req["stream"], req["tools"])
+	}
+	msgs := req["messages"].([]any)
+	first := msgs[0].(map[string]any)
+	last := msgs[len(msgs)-1].(map[string]any)
+	if first["role"] != "system" || !strings.Contains(first["content"].(string), "Dungeon Master") {
+		t.Errorf("first message should be the system prompt, got %v", first)
+	}
+	content, _ := last["content"].(string)
+	if last["role"] != "user" || !strings.HasPrefix(content, "[TURN CONTEXT]") || !strings.HasSuffix(content, "[END TURN CONTEXT]\n\n[Hero] open the door") || len(msgs) != 4 {
+		t.Errorf("messages = %v", msgs)
+	}
+	if srv.auth[0] != "Bearer sk-local" {
+		t.Errorf("Authorization = %q", srv.auth[0])
+	}
+}
+
+// ---- Engineer function calling ----
+
+func TestOpenAI_EngineerToolLoop(t *testing.T) {
+	srv := newStubChatServer(t,
+		jsonReply(`{
+			"choices":[{"finish_reason":"tool_calls","message":{"role":"assistant","content":null,"tool_calls":[
+				{"id":"call_1","type":"function","function":{"name":"create_item","arguments":"{\"name\":\"Rusty Key\",\"description\":\"Old\"}"}},
+				{"id":"call_2","type":"function","function":{"name":"create_item","arguments":"not json"}}
+			]}}],
+			"usage":{"prompt_tokens":300,"completion_tokens":40}}`),
+		jsonReply(`{"choices":[{"finish_reason":"stop","message":{"role":"assistant","content":"Done."}}],
+			"usage":{"prompt_tokens":350,"completion_tokens":2}}`),
+	)
+	c := ai.NewClient(srv.provider())
+	g := newTestGame(t)
+
+	res, err := c.EngineerScan(context.Background(), g, "test-user", "You find a rusty key.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.GetItemByName("Rusty Key"); err != nil {
+		t.Errorf("create_item was not applied: %v", err)
+	}
+	if len(res.Mutations) != 2 || !strings.HasPrefix(res.Mutations[1].Result, "error:") {
+		t.Errorf("mutations = %+v", res.Mutations)
+	}
+	if res.Tokens.Total() != 692 {
+		t.Errorf("tokens = %+v", res.Tokens)
+	}
+	if len(srv.requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(srv.requests))
+	}
+
+	first := srv.requests[0]
+	if first["model"] != "small-model" {
+		t.Errorf("engineer model = %v", first["model"])
+	}
+	tools := first["tools"].([]any)
+	if len(tools) != len(ai.NarratorTools()) {
+		t.Fatalf("sent %d tools, want %d", len(tools), len(ai.NarratorTools()))
+	}
+	for _, raw := range tools {
+		tool := raw.(map[string]any)
+		fn := tool["function"].(map[string]any)
+		params, _ := fn["parameters"].(map[string]any)
+		if tool["type"] != "function" || fn["name"] == "" || params["type"] != "object" || params["properties"] == nil {
+			t.Errorf("malformed tool %v", tool)
+		}
+	}
+
+	msgs := srv.requests[1]["messages"].([]any)
+	var assistant, results []map[string]any
+	for _, raw := range msgs {
+		m := raw.(map[string]any)
+		switch m["role"] {
+		case "assistant":
+			assistant = append(assistant, m)
+		case "tool":
+			results = append(results, m)
+		}
+	}
+	if len(assistant) != 1 || len(assistant[0]["tool_calls"].([]any)) != 2 {
+		t.Fatalf("second request should replay the assistant tool calls, got %v", assistant)
+	}
+	if len(results) != 2 || results[0]["tool_call_id"] != "call_1" || results[1]["tool_call_id"] != "call_2" {
+		t.Fatalf("tool results = %v", results)
+	}
+	if !strings.HasPrefix(results[1]["content"].(string), "error:") {
+		t.Errorf("bad arguments should come back as an error result, got %v", results[1]["content"])
+	}
+}
+
+// ---- Errors and selection ----
+
+func TestOpenAI_ErrorStatusSurfaces(t *testing.T) {
+	srv := newStubChatServer(t) // every request is unscripted → 500
+	_, err := ai.NewClient(srv.provider()).EngineerScan(context.Background(), newTestGame(t), "test-user", "Nothing happens.")
+	if err == nil || !strings.Contains(err.Error(), "unscripted request") {
+		t.Errorf("expected the server's error message, got %v", err)
+	}
+}
+
+func TestOpenAI_SelectedByName(t *testing.T) {
+	t.Setenv("AI_PROVIDER", "")
+	t.Setenv("OPENAI_BASE_URL", "")
+	if _, err := ai.NewFor(context.Background(), ai.ProviderOpenAI); err == nil {
+		t.Error("expected an error without OPENAI_BASE_URL")
+	}
+
+	srv := newStubChatServer(t, jsonReply(`{"choices":[{"finish_reason":"stop","message":{"role":"assistant","content":"Summary."}}]}`))
+	t.Setenv("OPENAI_BASE_URL", srv.URL+"/v1/")
+	t.Setenv("OPENAI_NARRATOR_MODEL", "llama")
+	t.Setenv("AI_PROVIDER", ai.ProviderOpenAI)
+	c, err := ai.New(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.TrimHistory(context.Background(), makeHistory(50)); err != nil {
+		t.Fatal(err)
+	}
+	if model := srv.requests[0]["model"]; model != "llama" {
+		t.Errorf("sub-agent model should default to the narrator model, got %v", model)
+	}
+}
@@ -0,0 +1,168 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ---- Provider interface ----
+
+// Provider is an LLM backend. Client builds the prompts and runs the game
+// logic (history trimming, the Engineer's tool loop); a Provider only turns a
+// Request into model output. BedrockProvider is the production backend,
+// OpenAIProvider serves self-hosted models and FakeProvider replays scripted
+// responses for tests and offline play.
+type Provider interface {
+	// StreamNarration runs a prose-only completion, calling onChunk with each
+	// text delta as it arrives. The returned Response holds the full text.
+	StreamNarration(ctx context.Context, req Request, onChunk func(string)) (Response, error)
+	// Converse runs a single non-streaming completion, which may request tool
+	// calls when req.Tools is set.
+	Converse(ctx context.Context, req Request) (Response, error)
+	// Summarize answers a summarisation prompt with the provider's cheap model.
+	Summarize(ctx context.Context, prompt string) (Response, error)
+}
+
+// ModelTier picks which class of model a Request runs on; each Provider maps
+// tiers to concrete model IDs.
+type ModelTier int
+
+const (
+	TierNarrator ModelTier = iota // heavy — narrator + narrative framing
+	TierSubAgent                  // light — Engineer, summaries, world-gen sub-agents
+)
+
+// Request is a provider-neutral model call.
+type Request struct {
+	Tier   ModelTier
+	System string
+	// CacheSystem marks System as a prefix that stays the same across calls,
+	// for providers that support prompt caching.
+	CacheSystem bool
+	Messages    []Message
+	Tools       []ToolSpec // nil for prose-only calls
+	MaxTokens   int
+	Temperature float32 // 0 uses the model default
+}
+
+// Response is a provider-neutral model reply.
+type Response struct {
+	Message    Message    // the assistant turn
+	StopReason string     // "end_turn", "tool_use", "max_tokens", …
+	Usage      TokenUsage // token usage for this call
+}
+
+// Message is one conversation turn.
+type Message struct {
+	Role    string // "user" | "assistant"
+	Content []Block
+}
+
+// Block is one piece of message content. Exactly one field is set.
+type Block struct {
+	Text       string
+	ToolCall   *ToolCall
+	ToolResult *ToolResult
+	// CachePoint ends a prompt prefix that stays the same across calls.
+	// Providers that support prompt caching cache everything before it;
+	// the others ignore it.
+	CachePoint bool
+}
+
+// ToolCall is a tool invocation requested by the model.
+type ToolCall struct {
+	ID    string
+	Name  string
+	Input map[string]any
+}
+
+// ToolResult answers a ToolCall.
+type ToolResult struct {
+	ToolCallID string
+	Text       string
+}
+
+// ToolSpec describes a tool the model may call. InputSchema is a JSON Schema
+// object.
+type ToolSpec struct {
+	Name        string
+	Description string
+	InputSchema map[string]any
+}
+
+// Text returns the concatenated text blocks of m.
+func (m Message) Text() string {
+	var sb strings.Builder
+	for _, b := range m.Content {
+		sb.WriteString(b.Text)
+	}
+	return sb.String()
+}
+
+// ToolCalls returns the tool calls in m, in order.
+func (m Message) ToolCalls() []ToolCall {
+	var calls []ToolCall
+	for _, b := range m.Content {
+		if b.ToolCall != nil {
+			calls = append(calls, *b.ToolCall)
+		}
+	}
+	return calls
+}
+
+// cachePoint is a Block marking the end of a cacheable prefix.
+var cachePoint = Block{CachePoint: true}
+
+// userText builds a user turn holding a single text block.
+func userText(text string) Message {
+	return Message{Role: "user", Content: []Block{{Text: text}}}
+}
+
+// ---- Provider selection ----
+
+// Provider names accepted by AI_PROVIDER. A user's ai_provider override may
+// name any of them but ProviderFake (see KnownProvider).
+const (
+	ProviderBedrock = "bedrock"
+	ProviderOpenAI  = "openai"
+	ProviderFake    = "fake"
+)
+
+// KnownProvider reports whether name may be stored as a user's ai_provider
+// override; "" means the deployment default. ProviderFake is left out: its
+// canned replies are for offline play and tests, so only the deployment's
+// AI_PROVIDER can select it.
+func KnownProvider(name string) bool {
+	switch name {
+	case "", ProviderBedrock, ProviderOpenAI:
+		return true
+	}
+	return false
+}
+
+// NewProvider returns the provider named by a user's override, or the
+// deployment default from AI_PROVIDER when name is empty. "bedrock" is the
+// default; "openai" talks to an OpenAI-compatible server (see
+// NewOpenAIProvider); "fake", as the deployment default only, answers every
+// call with FakeProvider's offline replies so the game can be played without
+// network access.
+func NewProvider(ctx context.Context, name string) (Provider, error) {
+	if !KnownProvider(name) {
+		return nil, fmt.Errorf("unknown AI provider %q", name)
+	}
+	if name == "" {
+		name = os.Getenv("AI_PROVIDER")
+	}
+	switch name {
+	case "", ProviderBedrock:
+		return NewBedrockProvider(ctx)
+	case ProviderOpenAI:
+		return NewOpenAIProvider()
+	case ProviderFake:
+		return NewFakeProvider(), nil
+	default:
+		return nil, fmt.Errorf("unknown AI provider %q", name)
+	}
+}
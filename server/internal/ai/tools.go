@@ -1,5 +1,5 @@
-// Package ai provides the Bedrock client, tool definitions, and tool dispatch
-// for the game's AI Dungeon Master.
+// Package ai provides the LLM client and providers, tool definitions, and tool
+// dispatch for the game's AI Dungeon Master.
 package ai
 
 import (
@@ -10,18 +10,16 @@ import (
 	"strings"
 	"unicode"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	brDocument "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
-	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/rrochlin/an-amazing-adventure/internal/combat"
 	"github.com/rrochlin/an-amazing-adventure/internal/game"
 )
 
-// ---- Tool definitions sent to Bedrock in toolConfig ----
+// ---- Tool definitions sent to the model ----
 
 // NarratorTools are the tools available to the Narrator during gameplay chat.
 // The AI calls these to mutate the game world in response to player actions.
-func NarratorTools() []types.Tool {
-	return []types.Tool{
+func NarratorTools() []ToolSpec {
+	return []ToolSpec{
 		tool("create_room",
 			"Create a new room connected to an existing room. Returns the new room's ID.",
 			props(
@@ -71,16 +69,18 @@ func NarratorTools() []types.Tool {
 			[]string{"character_name", "room_name"},
 		),
 		tool("give_item_to_player",
-			"Move an item from anywhere into the player's inventory.",
+			"Move an item from anywhere into a party member's inventory.",
 			props(
 				req("item_name", "string", "Name of the item to give"),
+				opt("character_name", "string", "Party member who receives it (default: the acting player)"),
 			),
 			[]string{"item_name"},
 		),
 		tool("take_item_from_player",
-			"Remove an item from the player's inventory and drop it in their current room.",
+			"Remove an item from a party member's inventory and drop it in their current room.",
 			props(
 				req("item_name", "string", "Name of the item to take"),
+				opt("character_name", "string", "Party member who loses it (default: the acting player)"),
 			),
 			[]string{"item_name"},
 		),
@@ -93,7 +93,7 @@ func NarratorTools() []types.Tool {
 			[]string{"item_name", "room_name"},
 		),
 		tool("trigger_short_rest",
-			"Trigger a short rest for all players. Restores Fighter Second Wind, Action Surge, Monk Ki. Does NOT restore hit points.",
+			"Trigger a short rest for all players. Restores Fighter Second Wind, Action Surge, Monk Ki and Warlock spell slots. Does NOT restore hit points.",
 			props(
 				req("reason", "string", "Brief in-world reason for the rest (e.g. 'The party finds a quiet alcove')"),
 			),
@@ -106,6 +106,17 @@ func NarratorTools() []types.Tool {
 			),
 			[]string{"reason"},
 		),
+		tool("request_ability_check",
+			"Roll an ability check or saving throw for a party member with their real modifiers and proficiencies. Use when the narrative leaves the outcome of an action uncertain. The result is shown to the players and narrated next turn.",
+			props(
+				req("skill", "string", "Skill (e.g. 'stealth', 'athletics', 'sleight of hand') or ability (e.g. 'dexterity'). Saving throws need an ability."),
+				req("dc", "integer", "Difficulty class: 5 very easy, 10 easy, 15 medium, 20 hard, 25 very hard"),
+				opt("character_name", "string", "Party member making the roll (default: the acting player)"),
+				opt("saving_throw", "boolean", "Roll a saving throw instead of an ability check (default false)"),
+				opt("reason", "string", "Brief in-world reason for the roll (e.g. 'sneaking past the sleeping ogre')"),
+			),
+			[]string{"skill", "dc"},
+		),
 		tool("get_room_info",
 			"Get full details about a room including items, occupants, and exits.",
 			props(
@@ -117,18 +128,20 @@ func NarratorTools() []types.Tool {
 }
 
 // WorldBuilderTools are the minimal set used during world generation.
-func WorldBuilderTools() []types.Tool {
+func WorldBuilderTools() []ToolSpec {
 	return NarratorTools() // world gen uses the same tool set
 }
 
 // ---- Tool dispatch ----
 
-// DispatchTool executes a single tool call against the game.
+// DispatchTool executes a single tool call against the game. speakerID is
+// the player whose turn is being scanned; tools that act on a party member
+// default to them when the model names no one ("" falls back to the owner).
 // Returns:
 //   - result: string to feed back to the model as a tool_result block
 //   - event: player-visible WorldEvent if the player can observe the mutation, otherwise nil
 //   - err: non-nil if the tool call failed
-func DispatchTool(ctx context.Context, g *game.Game, name string, input map[string]any) (result string, event *game.WorldEvent, err error) {
+func DispatchTool(ctx context.Context, g *game.Game, speakerID, name string, input map[string]any) (result string, event *game.WorldEvent, err error) {
 	switch name {
 	case "create_room":
 		result, event, err = execCreateRoom(g, input)
@@ -141,15 +154,17 @@ func DispatchTool(ctx context.Context, g *game.Game, name string, input map[stri
 	case "move_character":
 		result, event, err = execMoveCharacter(g, input)
 	case "give_item_to_player":
-		result, event, err = execGiveItemToPlayer(g, input)
+		result, event, err = execGiveItemToPlayer(g, speakerID, input)
 	case "take_item_from_player":
-		result, event, err = execTakeItemFromPlayer(g, input)
+		result, event, err = execTakeItemFromPlayer(g, speakerID, input)
 	case "place_item_in_room":
 		result, event, err = execPlaceItemInRoom(g, input)
 	case "trigger_short_rest":
 		result, event, err = execTriggerShortRest(ctx, g, input)
 	case "trigger_long_rest":
 		result, event, err = execTriggerLongRest(ctx, g, input)
+	case "request_ability_check":
+		result, event, err = execRequestAbilityCheck(ctx, g, speakerID, input)
 	case "get_room_info":
 		result, event, err = execGetRoomInfo(g, input)
 	default:
@@ -299,39 +314,78 @@ func execMoveCharacter(g *game.Game, in map[string]any) (string, *game.WorldEven
 	return fmt.Sprintf("Moved %q to %q", charName, roomName), ev, nil
 }
 
-func execGiveItemToPlayer(g *game.Game, in map[string]any) (string, *game.WorldEvent, error) {
+func execGiveItemToPlayer(g *game.Game, speakerID string, in map[string]any) (string, *game.WorldEvent, error) {
 	itemName := strArg(in, "item_name")
 	item, err := resolveItemByName(g, itemName)
 	if err != nil {
 		return "", nil, err
 	}
-	if err := g.GiveItemToPlayer(item.ID); err != nil {
+	userID, named, err := partyMemberArg(g, speakerID, in)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := g.GiveItemToCharacter(item.ID, userID); err != nil {
 		return "", nil, err
 	}
-	// Always visible — player receives item
+	// Always visible — a party member receives the item
+	if named != "" {
+		ev := &game.WorldEvent{Type: "item_gained", Message: fmt.Sprintf("%s receives %s.", named, itemName)}
+		return fmt.Sprintf("Gave %q to %s", itemName, named), ev, nil
+	}
 	ev := &game.WorldEvent{Type: "item_gained", Message: fmt.Sprintf("%s added to your inventory.", itemName)}
 	return fmt.Sprintf("Gave %q to player", itemName), ev, nil
 }
 
-func execTakeItemFromPlayer(g *game.Game, in map[string]any) (string, *game.WorldEvent, error) {
+func execTakeItemFromPlayer(g *game.Game, speakerID string, in map[string]any) (string, *game.WorldEvent, error) {
 	itemName := strArg(in, "item_name")
 	item, err := resolveItemByName(g, itemName)
 	if err != nil {
 		return "", nil, err
 	}
-	owner, _ := g.OwnerCharacter()
-	room, err := g.GetRoom(owner.LocationID)
+	userID, named, err := partyMemberArg(g, speakerID, in)
+	if err != nil {
+		return "", nil, err
+	}
+	player, _ := g.GetPlayerCharacter(userID)
+	room, err := g.GetRoom(player.LocationID)
 	if err != nil {
 		return "", nil, err
 	}
-	if err := g.TakeItemFromPlayer(item.ID, room.ID); err != nil {
+	if err := g.TakeItemFromCharacter(item.ID, userID, room.ID); err != nil {
 		return "", nil, err
 	}
-	// Always visible — item removed from player
+	// Always visible — item removed from a party member
+	if named != "" {
+		ev := &game.WorldEvent{Type: "item_lost", Message: fmt.Sprintf("%s loses %s.", named, itemName)}
+		return fmt.Sprintf("Took %q from %s", itemName, named), ev, nil
+	}
 	ev := &game.WorldEvent{Type: "item_lost", Message: fmt.Sprintf("%s removed from your inventory.", itemName)}
 	return fmt.Sprintf("Took %q from player", itemName), ev, nil
 }
 
+// partyMemberArg resolves the optional character_name argument to a party
+// member's user ID, defaulting to the acting player (speakerID), or the
+// owner when there is none. named is the character's name when one was
+// given, "" otherwise.
+func partyMemberArg(g *game.Game, speakerID string, in map[string]any) (userID, named string, err error) {
+	name := strArg(in, "character_name")
+	if name == "" {
+		if _, ok := g.GetPlayerCharacter(speakerID); speakerID != "" && ok {
+			return speakerID, "", nil
+		}
+		if _, ok := g.OwnerCharacter(); !ok {
+			return "", "", fmt.Errorf("owner character not found")
+		}
+		return g.OwnerID, "", nil
+	}
+	uid, ok := g.PartyMemberByName(name)
+	if !ok {
+		return "", "", fmt.Errorf("no party member named %q", name)
+	}
+	c, _ := g.GetPlayerCharacter(uid)
+	return uid, c.Name, nil
+}
+
 func execPlaceItemInRoom(g *game.Game, in map[string]any) (string, *game.WorldEvent, error) {
 	itemName := strArg(in, "item_name")
 	roomName := strArg(in, "room_name")
@@ -369,6 +423,7 @@ func execTriggerShortRest(ctx context.Context, g *game.Game, in map[string]any)
 			restored++
 		}
 	}
+	g.RecoverSpellSlots(false)
 	result := fmt.Sprintf("Short rest taken (%s). %d characters restored resources.", reason, restored)
 	if len(errs) > 0 {
 		result += " Errors: " + fmt.Sprint(errs)
@@ -385,12 +440,16 @@ func execTriggerLongRest(ctx context.Context, g *game.Game, in map[string]any) (
 		if dndChar == nil {
 			continue
 		}
+		if combat.IsDead(dndChar) {
+			continue // resting does not bring back the dead
+		}
 		if err := dndChar.LongRest(ctx); err != nil {
 			errs = append(errs, fmt.Sprintf("%s: %v", uid, err))
 		} else {
 			restored++
 		}
 	}
+	g.RecoverSpellSlots(true)
 	result := fmt.Sprintf("Long rest taken (%s). %d characters fully restored.", reason, restored)
 	if len(errs) > 0 {
 		result += " Errors: " + fmt.Sprint(errs)
@@ -399,6 +458,29 @@ func execTriggerLongRest(ctx context.Context, g *game.Game, in map[string]any) (
 	return result, ev, nil
 }
 
+func execRequestAbilityCheck(ctx context.Context, g *game.Game, speakerID string, in map[string]any) (string, *game.WorldEvent, error) {
+	req := game.CheckRequest{
+		Skill:  strArg(in, "skill"),
+		DC:     int(numArg(in, "dc")),
+		Reason: strArg(in, "reason"),
+	}
+	req.Save, _ = in["saving_throw"].(bool)
+	if req.Skill == "" || req.DC <= 0 {
+		return "", nil, fmt.Errorf("skill and a positive dc are required")
+	}
+	userID, _, err := partyMemberArg(g, speakerID, in)
+	if err != nil {
+		return "", nil, err
+	}
+	res, err := g.RollCheck(ctx, userID, req, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	// Rolls are always visible: the players see the dice before the narration.
+	ev := &game.WorldEvent{Type: "ability_check", Message: res.String()}
+	return res.String(), ev, nil
+}
+
 func execGetRoomInfo(g *game.Game, in map[string]any) (string, *game.WorldEvent, error) {
 	roomName := strArg(in, "room_name")
 	room, err := resolveRoomByName(g, roomName)
@@ -419,18 +501,10 @@ func execGetRoomInfo(g *game.Game, in map[string]any) (string, *game.WorldEvent,
 
 // ---- helpers for building tool definitions ----
 
-func tool(name, desc string, inputSchema map[string]any, required []string) types.Tool {
+func tool(name, desc string, inputSchema map[string]any, required []string) ToolSpec {
 	inputSchema["required"] = required
 	inputSchema["type"] = "object"
-	return &types.ToolMemberToolSpec{
-		Value: types.ToolSpecification{
-			Name:        aws.String(name),
-			Description: aws.String(desc),
-			InputSchema: &types.ToolInputSchemaMemberJson{
-				Value: brDocument.NewLazyDocument(inputSchema),
-			},
-		},
-	}
+	return ToolSpec{Name: name, Description: desc, InputSchema: inputSchema}
 }
 
 func props(fields ...map[string]any) map[string]any {
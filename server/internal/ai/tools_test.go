@@ -34,13 +34,13 @@ func newTestGameWithRooms(t *testing.T) (*game.Game, string, string) {
 
 // dispatch is a helper that calls DispatchTool and discards the WorldEvent return.
 func dispatch(g *game.Game, name string, args map[string]any) (string, error) {
-	result, _, err := ai.DispatchTool(context.Background(), g, name, args)
+	result, _, err := ai.DispatchTool(context.Background(), g, "", name, args)
 	return result, err
 }
 
 // dispatchWithEvent is a helper that returns the WorldEvent alongside result.
 func dispatchWithEvent(g *game.Game, name string, args map[string]any) (string, *game.WorldEvent, error) {
-	return ai.DispatchTool(context.Background(), g, name, args)
+	return ai.DispatchTool(context.Background(), g, "", name, args)
 }
 
 func TestDispatchCreateRoom(t *testing.T) {
@@ -287,14 +287,61 @@ func TestNarratorToolsDefined(t *testing.T) {
 	if len(tools) == 0 {
 		t.Error("expected non-empty narrator tools")
 	}
-	// Verify all tools have names
+	// Verify all tools have names and object schemas
 	for _, tool := range tools {
-		if tool == nil {
-			t.Error("got nil tool in NarratorTools")
+		if tool.Name == "" {
+			t.Error("got unnamed tool in NarratorTools")
+		}
+		if tool.InputSchema["type"] != "object" {
+			t.Errorf("%s: input schema type = %v, want object", tool.Name, tool.InputSchema["type"])
 		}
 	}
 }
 
+func TestDispatchRequestAbilityCheck(t *testing.T) {
+	g, _, _ := newTestGameWithRooms(t)
+	char, err := game.BuildDnDCharacter(context.Background(), game.CharacterCreationData{
+		Name:    "Hero",
+		RaceID:  "human",
+		ClassID: "fighter",
+		AbilityScores: map[string]int{
+			"strength": 16, "dexterity": 12, "constitution": 14,
+			"intelligence": 10, "wisdom": 10, "charisma": 8,
+		},
+		SelectedSkills: []string{"athletics", "intimidation"},
+	})
+	if err != nil {
+		t.Fatalf("BuildDnDCharacter: %v", err)
+	}
+	g.SetDnDCharacter("test-user", char)
+
+	result, ev, err := dispatchWithEvent(g, "request_ability_check", map[string]any{
+		"skill":          "athletics",
+		"dc":             float64(15),
+		"character_name": "hero",
+		"reason":         "climbing the crumbling wall",
+	})
+	if err != nil {
+		t.Fatalf("request_ability_check: %v", err)
+	}
+	if !strings.Contains(result, "Athletics check") || !strings.Contains(result, "vs DC 15") {
+		t.Errorf("unexpected result %q", result)
+	}
+	if ev == nil || ev.Type != "ability_check" {
+		t.Fatalf("expected an ability_check event, got %+v", ev)
+	}
+	// The roll is queued for the next narrator turn.
+	if !strings.Contains(g.PendingCombatContext, "climbing the crumbling wall") {
+		t.Errorf("expected the roll in PendingCombatContext, got %q", g.PendingCombatContext)
+	}
+
+	if _, err := dispatch(g, "request_ability_check", map[string]any{
+		"skill": "athletics", "dc": float64(15), "character_name": "Nobody",
+	}); err == nil {
+		t.Error("expected error for an unknown party member")
+	}
+}
+
 // ---- Visibility tests ----
 
 func TestGiveItemToPlayerAlwaysProducesEvent(t *testing.T) {
@@ -467,3 +514,69 @@ func TestCreateRoom_NoEvent(t *testing.T) {
 		t.Errorf("expected nil event for create_room, got %+v", ev)
 	}
 }
+
+func TestGiveAndTakeItem_NamedPartyMember(t *testing.T) {
+	g, startID, northID := newTestGameWithRooms(t)
+	g.SetPlayerCharacter("user-2", game.NewCharacter("Ayla", "A ranger"))
+	if err := g.PlaceCharacter("user-2", northID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dispatch(g, "create_item", map[string]any{"name": "Lantern", "description": "Brass", "place_in_room": "Tavern"}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, ev, err := ai.DispatchTool(context.Background(), g, "", "give_item_to_player", map[string]any{"item_name": "Lantern", "character_name": "ayla"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev == nil || ev.Message != "Ayla receives Lantern." {
+		t.Errorf("event = %+v", ev)
+	}
+	item, _ := g.GetItemByName("Lantern")
+	if ayla, _ := g.GetPlayerCharacter("user-2"); !ayla.HasItem(item.ID) {
+		t.Fatal("Ayla should hold the lantern")
+	}
+
+	if _, err := dispatch(g, "take_item_from_player", map[string]any{"item_name": "Lantern", "character_name": "Ayla"}); err != nil {
+		t.Fatal(err)
+	}
+	if north, _ := g.GetRoom(northID); !north.HasItem(item.ID) {
+		t.Error("the lantern should drop in Ayla's room, not the owner's")
+	}
+	if start, _ := g.GetRoom(startID); start.HasItem(item.ID) {
+		t.Error("the lantern should not be in the owner's room")
+	}
+	if _, err := dispatch(g, "give_item_to_player", map[string]any{"item_name": "Lantern", "character_name": "Nobody"}); err == nil {
+		t.Error("expected an error for an unknown party member")
+	}
+}
+
+func TestGiveAndTakeItem_DefaultsToActingPlayer(t *testing.T) {
+	g, _, northID := newTestGameWithRooms(t)
+	g.SetPlayerCharacter("user-2", game.NewCharacter("Ayla", "A ranger"))
+	if err := g.PlaceCharacter("user-2", northID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dispatch(g, "create_item", map[string]any{"name": "Lantern", "description": "Brass", "place_in_room": "Alley"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ayla's turn: the model omits character_name.
+	if _, _, err := ai.DispatchTool(context.Background(), g, "user-2", "give_item_to_player", map[string]any{"item_name": "Lantern"}); err != nil {
+		t.Fatal(err)
+	}
+	item, _ := g.GetItemByName("Lantern")
+	if ayla, _ := g.GetPlayerCharacter("user-2"); !ayla.HasItem(item.ID) {
+		t.Fatal("the acting player should receive the lantern")
+	}
+	if hero, _ := g.OwnerCharacter(); hero.HasItem(item.ID) {
+		t.Error("the owner should not receive the lantern")
+	}
+
+	if _, _, err := ai.DispatchTool(context.Background(), g, "user-2", "take_item_from_player", map[string]any{"item_name": "Lantern"}); err != nil {
+		t.Fatal(err)
+	}
+	if north, _ := g.GetRoom(northID); !north.HasItem(item.ID) {
+		t.Error("the lantern should drop in the acting player's room")
+	}
+}
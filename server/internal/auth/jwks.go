@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// KeySource resolves a JWT "kid" header to the RSA public key that signed it.
+type KeySource interface {
+	Key(ctx context.Context, kid string) (*rsa.PublicKey, error)
+}
+
+// StaticKeys is a fixed KeySource, for tests and local key sets.
+type StaticKeys map[string]*rsa.PublicKey
+
+// Key implements KeySource.
+func (s StaticKeys) Key(_ context.Context, kid string) (*rsa.PublicKey, error) {
+	if k, ok := s[kid]; ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// JWKS is a KeySource backed by a JSON Web Key Set URL, such as a Cognito
+// user pool's /.well-known/jwks.json.
+//
+// Keys are cached for TTL. A kid that is not in the cache triggers a refetch
+// so rotated-in keys are picked up straight away, but at most once per
+// MinRefresh so tokens with made-up kids cannot hammer the endpoint. When a
+// refetch fails the previous key set keeps serving.
+type JWKS struct {
+	URL        string
+	TTL        time.Duration // default 1h
+	MinRefresh time.Duration // default 1m
+	HTTPClient *http.Client  // default: 10s timeout
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+	triedAt   time.Time
+}
+
+// NewJWKS returns a JWKS for url with the default cache settings.
+func NewJWKS(url string) *JWKS {
+	return &JWKS{URL: url}
+}
+
+// Key implements KeySource.
+func (j *JWKS) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	ttl := j.TTL
+	if ttl == 0 {
+		ttl = time.Hour
+	}
+	minRefresh := j.MinRefresh
+	if minRefresh == 0 {
+		minRefresh = time.Minute
+	}
+
+	key, cached := j.keys[kid]
+	stale := j.keys == nil || now.Sub(j.fetchedAt) > ttl
+	if (stale || !cached) && now.Sub(j.triedAt) >= minRefresh {
+		j.triedAt = now
+		keys, err := j.fetch(ctx)
+		if err != nil && j.keys == nil {
+			return nil, err
+		}
+		if err == nil {
+			j.keys, j.fetchedAt = keys, now
+			key, cached = keys[kid]
+		}
+	}
+	if !cached {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+// fetch downloads and parses the key set. Callers must hold j.mu.
+func (j *JWKS) fetch(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("jwks request: %w", err)
+	}
+	hc := j.HTTPClient
+	if hc == nil {
+		hc = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("read jwks: %w", err)
+	}
+	return ParseJWKS(data)
+}
+
+// ParseJWKS decodes the RSA signing keys in a JSON Web Key Set, keyed by kid.
+// Keys of other types or uses are skipped.
+func ParseJWKS(data []byte) (map[string]*rsa.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("decode jwks: %w", err)
+	}
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("decode modulus of %q: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("decode exponent of %q: %w", k.Kid, err)
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("key %q has an unsupported exponent", k.Kid)
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}
+	}
+	return keys, nil
+}
+
+// EncodeJWKS renders keys as a JSON Web Key Set, the inverse of ParseJWKS.
+// Tests and local tooling use it to serve their own key sets.
+func EncodeJWKS(keys map[string]*rsa.PublicKey) []byte {
+	type jwk struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		Alg string `json:"alg"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	}
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{Keys: []jwk{}}
+	for kid, k := range keys {
+		set.Keys = append(set.Keys, jwk{
+			Kid: kid,
+			Kty: "RSA",
+			Alg: "RS256",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		})
+	}
+	data, _ := json.Marshal(set)
+	return data
+}
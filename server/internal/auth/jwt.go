@@ -0,0 +1,212 @@
+// Package auth verifies Cognito-issued JWTs for routes API Gateway cannot
+// authorize itself, such as the WebSocket $connect route.
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Claims are the verified claims of a Cognito access or ID token.
+type Claims struct {
+	Sub      string `json:"sub"`
+	Issuer   string `json:"iss"`
+	TokenUse string `json:"token_use"`           // "access" | "id"
+	ClientID string `json:"client_id,omitempty"` // access tokens
+	Audience string `json:"aud,omitempty"`       // ID tokens
+	Username string `json:"username,omitempty"`
+	Expires  int64  `json:"exp"`
+	IssuedAt int64  `json:"iat,omitempty"`
+}
+
+// TokenVerifier turns a bearer token into verified claims.
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (Claims, error)
+}
+
+// Verifier checks RS256 signatures against Keys, then the expiry, issuer,
+// token_use and audience. Access tokens carry their app client in client_id
+// and ID tokens in aud; either must be one of ClientIDs.
+type Verifier struct {
+	Issuer    string
+	ClientIDs []string
+	Keys      KeySource
+	// Leeway tolerates clock skew on exp; default 30s.
+	Leeway time.Duration
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+var _ TokenVerifier = (*Verifier)(nil)
+
+// CognitoIssuer returns the issuer URL of a user pool. The region is the
+// pool ID's prefix ("us-west-2_AbC123" → us-west-2).
+func CognitoIssuer(userPoolID string) (string, error) {
+	region, _, ok := strings.Cut(userPoolID, "_")
+	if !ok || region == "" {
+		return "", fmt.Errorf("malformed user pool id %q", userPoolID)
+	}
+	return fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", region, userPoolID), nil
+}
+
+// NewCognitoVerifier returns a Verifier for tokens from userPoolID issued to
+// any of clientIDs, fetching keys from jwksURL, or from the pool's own
+// /.well-known/jwks.json when jwksURL is empty.
+func NewCognitoVerifier(userPoolID string, clientIDs []string, jwksURL string) (*Verifier, error) {
+	issuer, err := CognitoIssuer(userPoolID)
+	if err != nil {
+		return nil, err
+	}
+	if len(clientIDs) == 0 {
+		return nil, fmt.Errorf("no app client ids")
+	}
+	if jwksURL == "" {
+		jwksURL = issuer + "/.well-known/jwks.json"
+	}
+	return &Verifier{Issuer: issuer, ClientIDs: clientIDs, Keys: NewJWKS(jwksURL)}, nil
+}
+
+var (
+	envVerifiersMu sync.Mutex
+	envVerifiers   = map[string]*Verifier{}
+)
+
+// VerifierFromEnv returns the Cognito verifier configured by USER_POOL_ID,
+// USER_POOL_CLIENT_ID (comma-separated) and, optionally, JWKS_URL. Verifiers
+// are shared per configuration so a warm Lambda keeps its cached keys across
+// invocations.
+func VerifierFromEnv() (*Verifier, error) {
+	poolID := os.Getenv("USER_POOL_ID")
+	clients := os.Getenv("USER_POOL_CLIENT_ID")
+	jwksURL := os.Getenv("JWKS_URL")
+	if poolID == "" || clients == "" {
+		return nil, fmt.Errorf("USER_POOL_ID and USER_POOL_CLIENT_ID must be set")
+	}
+
+	cacheKey := poolID + "|" + clients + "|" + jwksURL
+	envVerifiersMu.Lock()
+	defer envVerifiersMu.Unlock()
+	if v, ok := envVerifiers[cacheKey]; ok {
+		return v, nil
+	}
+	var ids []string
+	for _, id := range strings.Split(clients, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	v, err := NewCognitoVerifier(poolID, ids, jwksURL)
+	if err != nil {
+		return nil, err
+	}
+	envVerifiers[cacheKey] = v
+	return v, nil
+}
+
+// Verify checks token and returns its claims. Structural problems are
+// rejected before any key lookup, so garbage tokens never reach the JWKS
+// endpoint.
+func (v *Verifier) Verify(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, fmt.Errorf("malformed JWT")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Claims{}, fmt.Errorf("decode header: %w", err)
+	}
+	if header.Alg != "RS256" {
+		return Claims{}, fmt.Errorf("unsupported alg %q", header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, fmt.Errorf("decode signature: %w", err)
+	}
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Claims{}, fmt.Errorf("decode claims: %w", err)
+	}
+
+	key, err := v.Keys.Key(ctx, header.Kid)
+	if err != nil {
+		return Claims{}, fmt.Errorf("signing key: %w", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return Claims{}, fmt.Errorf("bad signature")
+	}
+
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+	leeway := v.Leeway
+	if leeway == 0 {
+		leeway = 30 * time.Second
+	}
+	if claims.Expires == 0 || now().Add(-leeway).Unix() > claims.Expires {
+		return Claims{}, fmt.Errorf("token expired")
+	}
+	if claims.Issuer != v.Issuer {
+		return Claims{}, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	var client string
+	switch claims.TokenUse {
+	case "access":
+		client = claims.ClientID
+	case "id":
+		client = claims.Audience
+	default:
+		return Claims{}, fmt.Errorf("unexpected token_use %q", claims.TokenUse)
+	}
+	if !slices.Contains(v.ClientIDs, client) {
+		return Claims{}, fmt.Errorf("token issued to unknown client %q", client)
+	}
+	if claims.Sub == "" {
+		return Claims{}, fmt.Errorf("missing sub claim")
+	}
+	return claims, nil
+}
+
+// decodeSegment base64url-decodes a JWT segment (padded or not) into v.
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(seg, "="))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// SignRS256 issues a JWT over claims signed by key, with kid in the header.
+// It exists for tests and local tooling that stand in for Cognito.
+func SignRS256(key *rsa.PrivateKey, kid string, claims any) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
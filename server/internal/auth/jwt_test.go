@@ -0,0 +1,269 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rrochlin/an-amazing-adventure/internal/auth"
+)
+
+const (
+	testPool   = "us-west-2_TestPool"
+	testIssuer = "https://cognito-idp.us-west-2.amazonaws.com/us-west-2_TestPool"
+	testClient = "spa-client"
+)
+
+func newKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func accessClaims(sub string) map[string]any {
+	return map[string]any{
+		"sub":       sub,
+		"iss":       testIssuer,
+		"token_use": "access",
+		"client_id": testClient,
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func sign(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	tok, err := auth.SignRS256(key, kid, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+func staticVerifier(key *rsa.PrivateKey) *auth.Verifier {
+	return &auth.Verifier{
+		Issuer:    testIssuer,
+		ClientIDs: []string{testClient},
+		Keys:      auth.StaticKeys{"k1": &key.PublicKey},
+	}
+}
+
+// ---- Verify ----
+
+func TestVerify_AcceptsAccessAndIDTokens(t *testing.T) {
+	key := newKey(t)
+	v := staticVerifier(key)
+
+	claims, err := v.Verify(context.Background(), sign(t, key, "k1", accessClaims("user-1")))
+	if err != nil || claims.Sub != "user-1" || claims.TokenUse != "access" {
+		t.Fatalf("access token: %+v, %v", claims, err)
+	}
+
+	id := accessClaims("user-2")
+	id["token_use"] = "id"
+	delete(id, "client_id")
+	id["aud"] = testClient
+	if claims, err := v.Verify(context.Background(), sign(t, key, "k1", id)); err != nil || claims.Sub != "user-2" {
+		t.Fatalf("id token: %+v, %v", claims, err)
+	}
+}
+
+func TestVerify_Rejects(t *testing.T) {
+	key := newKey(t)
+	v := staticVerifier(key)
+	good := sign(t, key, "k1", accessClaims("user-1"))
+	parts := strings.Split(good, ".")
+
+	with := func(k string, val any) string {
+		c := accessClaims("user-1")
+		if val == nil {
+			delete(c, k)
+		} else {
+			c[k] = val
+		}
+		return sign(t, key, "k1", c)
+	}
+	// An attacker's own key, advertised under the pool's kid.
+	forged := sign(t, newKey(t), "k1", accessClaims("user-1"))
+	// The pre-verification ws-connect accepted this: alg HS256, sub user-abc, far-future exp.
+	unsigned := "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9.eyJzdWIiOiJ1c2VyLWFiYyIsImV4cCI6OTk5OTk5OTk5OX0.signature"
+
+	cases := map[string]string{
+		"empty":             "",
+		"two parts":         "only.two",
+		"bad base64":        parts[0] + ".!!!not-base64!!!." + parts[2],
+		"unsigned":          unsigned,
+		"alg none":          "eyJhbGciOiJub25lIn0." + parts[1] + ".",
+		"forged signature":  forged,
+		"tampered payload":  parts[0] + "." + strings.Split(with("sub", "admin"), ".")[1] + "." + parts[2],
+		"unknown kid":       sign(t, key, "k2", accessClaims("user-1")),
+		"expired":           with("exp", time.Now().Add(-time.Hour).Unix()),
+		"no exp":            with("exp", nil),
+		"wrong issuer":      with("iss", "https://cognito-idp.us-west-2.amazonaws.com/us-west-2_Other"),
+		"wrong client":      with("client_id", "someone-elses-app"),
+		"wrong token_use":   with("token_use", "refresh"),
+		"id token aud miss": with("token_use", "id"),
+		"missing sub":       with("sub", nil),
+	}
+	for name, tok := range cases {
+		if _, err := v.Verify(context.Background(), tok); err == nil {
+			t.Errorf("%s: expected rejection", name)
+		}
+	}
+}
+
+func TestVerify_Leeway(t *testing.T) {
+	key := newKey(t)
+	v := staticVerifier(key)
+	c := accessClaims("user-1")
+	c["exp"] = time.Now().Add(-10 * time.Second).Unix()
+	if _, err := v.Verify(context.Background(), sign(t, key, "k1", c)); err != nil {
+		t.Errorf("10s of clock skew should be tolerated: %v", err)
+	}
+}
+
+// ---- JWKS ----
+
+// jwksServer serves whatever key set is current and counts fetches.
+type jwksServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fail    bool
+	fetches atomic.Int32
+}
+
+func newJWKSServer(t *testing.T, keys map[string]*rsa.PublicKey) *jwksServer {
+	s := &jwksServer{keys: keys}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.fail {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(auth.EncodeJWKS(s.keys))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) set(keys map[string]*rsa.PublicKey, fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys, s.fail = keys, fail
+}
+
+func TestJWKS_CachesAndPicksUpRotatedKeys(t *testing.T) {
+	oldKey, newKey := newKey(t), newKey(t)
+	srv := newJWKSServer(t, map[string]*rsa.PublicKey{"old": &oldKey.PublicKey})
+	jwks := &auth.JWKS{URL: srv.URL, MinRefresh: time.Nanosecond}
+	v := &auth.Verifier{Issuer: testIssuer, ClientIDs: []string{testClient}, Keys: jwks}
+	ctx := context.Background()
+
+	for range 3 {
+		if _, err := v.Verify(ctx, sign(t, oldKey, "old", accessClaims("u"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := srv.fetches.Load(); n != 1 {
+		t.Errorf("key set fetched %d times, want 1", n)
+	}
+
+	// The pool rotates in a new key alongside the old one.
+	srv.set(map[string]*rsa.PublicKey{"old": &oldKey.PublicKey, "new": &newKey.PublicKey}, false)
+	if _, err := v.Verify(ctx, sign(t, newKey, "new", accessClaims("u"))); err != nil {
+		t.Fatalf("rotated key not picked up: %v", err)
+	}
+	if n := srv.fetches.Load(); n != 2 {
+		t.Errorf("key set fetched %d times, want 2", n)
+	}
+}
+
+func TestJWKS_UnknownKidRefetchIsRateLimited(t *testing.T) {
+	key := newKey(t)
+	srv := newJWKSServer(t, map[string]*rsa.PublicKey{"k1": &key.PublicKey})
+	jwks := &auth.JWKS{URL: srv.URL, MinRefresh: time.Hour}
+	ctx := context.Background()
+
+	if _, err := jwks.Key(ctx, "k1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, kid := range []string{"made-up-1", "made-up-2", "made-up-3"} {
+		if _, err := jwks.Key(ctx, kid); err == nil {
+			t.Errorf("%s: expected an error", kid)
+		}
+	}
+	if n := srv.fetches.Load(); n != 1 {
+		t.Errorf("unknown kids caused %d fetches, want 1", n)
+	}
+}
+
+func TestJWKS_KeepsServingWhenRefreshFails(t *testing.T) {
+	key := newKey(t)
+	srv := newJWKSServer(t, map[string]*rsa.PublicKey{"k1": &key.PublicKey})
+	jwks := &auth.JWKS{URL: srv.URL, TTL: time.Nanosecond, MinRefresh: time.Nanosecond}
+	ctx := context.Background()
+
+	if _, err := jwks.Key(ctx, "k1"); err != nil {
+		t.Fatal(err)
+	}
+	srv.set(nil, true)
+	if _, err := jwks.Key(ctx, "k1"); err != nil {
+		t.Errorf("stale key should keep serving during an outage: %v", err)
+	}
+	if n := srv.fetches.Load(); n != 2 {
+		t.Errorf("expected a refresh attempt, got %d fetches", n)
+	}
+
+	cold := &auth.JWKS{URL: srv.URL}
+	if _, err := cold.Key(ctx, "k1"); err == nil {
+		t.Error("a cold cache with the endpoint down should fail")
+	}
+}
+
+// ---- Configuration ----
+
+func TestVerifierFromEnv(t *testing.T) {
+	t.Setenv("USER_POOL_ID", "")
+	t.Setenv("USER_POOL_CLIENT_ID", "")
+	if _, err := auth.VerifierFromEnv(); err == nil {
+		t.Error("expected an error without USER_POOL_ID")
+	}
+
+	key := newKey(t)
+	srv := newJWKSServer(t, map[string]*rsa.PublicKey{"k1": &key.PublicKey})
+	t.Setenv("USER_POOL_ID", testPool)
+	t.Setenv("USER_POOL_CLIENT_ID", "other-client, "+testClient)
+	t.Setenv("JWKS_URL", srv.URL)
+
+	v, err := auth.VerifierFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := auth.VerifierFromEnv(); again != v {
+		t.Error("verifiers should be shared per configuration")
+	}
+	if v.Issuer != testIssuer {
+		t.Errorf("issuer = %q", v.Issuer)
+	}
+	if _, err := v.Verify(context.Background(), sign(t, key, "k1", accessClaims("u"))); err != nil {
+		t.Errorf("token from the configured pool and client: %v", err)
+	}
+}
+
+func TestCognitoIssuer_Malformed(t *testing.T) {
+	if _, err := auth.CognitoIssuer("no-region"); err == nil {
+		t.Error("expected an error for a pool id without a region")
+	}
+}
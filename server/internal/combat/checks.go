@@ -0,0 +1,88 @@
+package combat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/KirkDiggler/rpg-toolkit/dice"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/abilities"
+	dnd5echar "github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/character"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/skills"
+)
+
+// CheckResult is the outcome of an ability check or saving throw.
+type CheckResult struct {
+	Character string // character name
+	Check     string // e.g. "Stealth check", "Dexterity saving throw"
+	Roll      int    // the d20
+	Modifier  int    // ability modifier plus any proficiency
+	Total     int
+	DC        int
+	Success   bool
+}
+
+// String formats the result as a combat log line, e.g.
+// "Brom — Stealth check: 14 + 2 = 16 vs DC 15 → SUCCESS".
+func (r CheckResult) String() string {
+	outcome := "FAILED"
+	if r.Success {
+		outcome = "SUCCESS"
+	}
+	return fmt.Sprintf("%s — %s: %d %s = %d vs DC %d → %s",
+		r.Character, r.Check, r.Roll, signed(r.Modifier), r.Total, r.DC, outcome)
+}
+
+// RollCheck rolls a d20 check for a character against dc. name is a skill
+// ("stealth", "sleight of hand") or an ability ("dex", "strength"); skill
+// checks add the character's proficiency or expertise. With save set it
+// rolls a saving throw instead, which needs an ability. roller is optional;
+// nil uses the crypto roller.
+//
+// As in the PHB, a natural 20 or 1 on a check or save has no special effect.
+func RollCheck(ctx context.Context, c *dnd5echar.Character, name string, dc int, save bool, roller dice.Roller) (CheckResult, error) {
+	key := strings.ReplaceAll(strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "-"), "_", "-")
+	var check string
+	var mod int
+	if skill, ok := skills.All[key]; ok && !save {
+		check = skills.Display(skill) + " check"
+		mod = c.GetSkillModifier(skill)
+	} else if ability, ok := abilities.All[key]; ok {
+		if save {
+			check = ability.Display() + " saving throw"
+			mod = c.GetSavingThrowModifier(ability)
+		} else {
+			check = ability.Display() + " check"
+			mod = c.GetAbilityModifier(ability)
+		}
+	} else if save {
+		return CheckResult{}, fmt.Errorf("unknown ability %q for a saving throw", name)
+	} else {
+		return CheckResult{}, fmt.Errorf("unknown skill or ability %q", name)
+	}
+
+	if roller == nil {
+		roller = dice.NewRoller()
+	}
+	d20, err := roller.Roll(ctx, 20)
+	if err != nil {
+		return CheckResult{}, fmt.Errorf("roll %s: %w", strings.ToLower(check), err)
+	}
+	return CheckResult{
+		Character: c.GetName(),
+		Check:     check,
+		Roll:      d20,
+		Modifier:  mod,
+		Total:     d20 + mod,
+		DC:        dc,
+		Success:   d20+mod >= dc,
+	}, nil
+}
+
+// signed formats a modifier with its operator, e.g. "+ 2" or "- 1".
+func signed(n int) string {
+	if n < 0 {
+		return fmt.Sprintf("- %d", -n)
+	}
+	return fmt.Sprintf("+ %d", n)
+}
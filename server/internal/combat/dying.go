@@ -0,0 +1,319 @@
+package combat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/KirkDiggler/rpg-toolkit/dice"
+	"github.com/KirkDiggler/rpg-toolkit/events"
+	dnd5echar "github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/character"
+	dnd5ecombat "github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/combat"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/damage"
+	dnd5eEvents "github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/events"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/monster"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/saves"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/skills"
+)
+
+// StabilizeDC is the Wisdom (Medicine) check DC to stabilize a dying creature.
+const StabilizeDC = 10
+
+// Vital states of a player character under the 5e dying rules.
+const (
+	VitalConscious = "conscious" // HP above 0
+	VitalDying     = "dying"     // 0 HP, making death saves
+	VitalStable    = "stable"    // 0 HP, no longer making death saves
+	VitalDead      = "dead"      // three failed death saves or massive damage
+)
+
+// Vital returns a character's state under the dying rules.
+func Vital(c *dnd5echar.Character) string {
+	state := c.GetDeathSaveState()
+	switch {
+	case state.Dead:
+		return VitalDead
+	case c.GetHitPoints() > 0:
+		return VitalConscious
+	case state.Stabilized:
+		return VitalStable
+	default:
+		return VitalDying
+	}
+}
+
+// IsDead reports whether a character has died.
+func IsDead(c *dnd5echar.Character) bool {
+	return c.GetDeathSaveState().Dead
+}
+
+// IsDying reports whether a character is at 0 HP and still making death saves.
+func IsDying(c *dnd5echar.Character) bool {
+	return Vital(c) == VitalDying
+}
+
+// liveDeathSaveState returns the character's own death save state so it can
+// be changed in place, attaching an empty one first if the character has none.
+// TODO(toolkit): GetDeathSaveState returns a detached value when the state is
+// unset, and there are no setters for stabilizing or instant death.
+func liveDeathSaveState(c *dnd5echar.Character) *saves.DeathSaveState {
+	if state := c.GetDeathSaveState(); *state != (saves.DeathSaveState{}) {
+		return state
+	}
+	c.ResetDeathSaveState()
+	return c.GetDeathSaveState()
+}
+
+// markDead kills a character outright.
+func markDead(c *dnd5echar.Character) {
+	liveDeathSaveState(c).Dead = true
+}
+
+// DamagePlayer applies damage to a party member under the dying rules and
+// returns the lines to add to the combat log:
+//   - damage that drops a character to 0 HP knocks them unconscious, or kills
+//     them outright if the damage left over equals their HP maximum;
+//   - damage at 0 HP is a failed death save (two on a critical hit), or
+//     instant death if it equals their HP maximum on its own.
+//
+// Damage is resolved through the damage chain on enc.PlayerBus (so features
+// such as rage resistance apply) when it is set, enc.Bus otherwise.
+func DamagePlayer(ctx context.Context, enc *Encounter, userID, sourceID string, amount int, damageType damage.Type, critical bool) (string, error) {
+	c, ok := enc.Players[userID]
+	if !ok {
+		return "", fmt.Errorf("player %s not found in encounter", userID)
+	}
+	if IsDead(c) || amount <= 0 {
+		return "", nil
+	}
+
+	var sb strings.Builder
+	maxHP := c.GetMaxHitPoints()
+	if c.GetHitPoints() == 0 {
+		if amount >= maxHP {
+			markDead(c)
+			sb.WriteString(fmt.Sprintf("%s takes %d damage while down and is killed outright.\n", c.GetName(), amount))
+			return sb.String(), nil
+		}
+		if _, err := c.TakeDamageWhileUnconscious(ctx, &dnd5echar.TakeDamageWhileUnconsciousInput{IsCritical: critical}); err != nil {
+			return "", fmt.Errorf("damage while unconscious: %w", err)
+		}
+		state := liveDeathSaveState(c)
+		state.Stabilized = false // taking damage starts the death saves again
+		failures := "a death save failure"
+		if critical {
+			failures = "two death save failures"
+		}
+		sb.WriteString(fmt.Sprintf("%s takes %d damage while down — %s %s.\n", c.GetName(), amount, failures, deathSaveTally(state.Successes, state.Failures)))
+		if state.Dead {
+			sb.WriteString(fmt.Sprintf("%s has died.\n", c.GetName()))
+		}
+		return sb.String(), nil
+	}
+
+	bus := enc.PlayerBus
+	if bus == nil {
+		bus = enc.Bus
+	}
+	before := c.GetHitPoints()
+	out, err := dnd5ecombat.DealDamage(ctx, &dnd5ecombat.DealDamageInput{
+		Target:     c,
+		AttackerID: sourceID,
+		Source:     dnd5ecombat.DamageSourceAttack,
+		Instances:  []dnd5ecombat.DamageInstanceInput{{Amount: amount, Type: damageType}},
+		IsCritical: critical,
+		EventBus:   bus,
+	})
+	if err != nil {
+		return "", fmt.Errorf("deal damage: %w", err)
+	}
+	sb.WriteString(fmt.Sprintf("%s HP: %d/%d\n", c.GetName(), out.CurrentHP, maxHP))
+	if !out.DroppedToZero {
+		return sb.String(), nil
+	}
+	if out.TotalDamage-before >= maxHP {
+		markDead(c)
+		sb.WriteString(fmt.Sprintf("%s is killed outright by massive damage.\n", c.GetName()))
+		return sb.String(), nil
+	}
+	c.ResetDeathSaveState()
+	sb.WriteString(fmt.Sprintf("%s falls unconscious and is dying.\n", c.GetName()))
+	return sb.String(), nil
+}
+
+// DeathSave rolls a dying character's death saving throw at the start of
+// their turn and returns a line for the combat log. A natural 20 restores
+// 1 HP, published as healing on bus (the bus the character was loaded on).
+// Conscious, stable and dead characters make no save.
+func DeathSave(ctx context.Context, c *dnd5echar.Character, bus events.EventBus, roller dice.Roller) (string, error) {
+	if !IsDying(c) {
+		return "", nil
+	}
+	if roller == nil {
+		roller = dice.NewRoller()
+	}
+	res, err := c.MakeDeathSave(ctx, &dnd5echar.MakeDeathSaveInput{Roller: roller})
+	if err != nil {
+		return "", fmt.Errorf("death save: %w", err)
+	}
+
+	name := c.GetName()
+	switch {
+	case res.RegainedConsciousness:
+		if err := dnd5eEvents.HealingReceivedTopic.On(bus).Publish(ctx, dnd5eEvents.HealingReceivedEvent{
+			TargetID: c.GetID(),
+			Amount:   res.HPRestored,
+			Roll:     res.Roll,
+			Source:   "death_save",
+		}); err != nil {
+			return "", fmt.Errorf("publish healing: %w", err)
+		}
+		c.ResetDeathSaveState()
+		return fmt.Sprintf("%s death save: 20 → %s regains consciousness with %d HP!\n", name, name, c.GetHitPoints()), nil
+	case res.State.Dead:
+		return fmt.Sprintf("%s death save: %d → failure %s. %s has died.\n", name, res.Roll, deathSaveTally(res.State.Successes, res.State.Failures), name), nil
+	case res.State.Stabilized:
+		return fmt.Sprintf("%s death save: %d → success %s. %s is stable.\n", name, res.Roll, deathSaveTally(res.State.Successes, res.State.Failures), name), nil
+	}
+	outcome := "success"
+	switch {
+	case res.IsCriticalFail:
+		outcome = "critical failure"
+	case res.Roll < 10:
+		outcome = "failure"
+	}
+	return fmt.Sprintf("%s death save: %d → %s %s.\n", name, res.Roll, outcome, deathSaveTally(res.State.Successes, res.State.Failures)), nil
+}
+
+// Stabilize has healer make a DC 10 Wisdom (Medicine) check to stabilize a
+// dying ally and returns a line for the combat log. The check fails (without
+// error) on a low roll; it is an error to stabilize someone who is not dying.
+func Stabilize(ctx context.Context, healer, patient *dnd5echar.Character, roller dice.Roller) (string, error) {
+	if !IsDying(patient) {
+		return "", fmt.Errorf("%s is %s, not dying", patient.GetName(), Vital(patient))
+	}
+	if roller == nil {
+		roller = dice.NewRoller()
+	}
+	d20, err := roller.Roll(ctx, 20)
+	if err != nil {
+		return "", fmt.Errorf("roll medicine: %w", err)
+	}
+	mod := healer.GetSkillModifier(skills.Medicine)
+	line := fmt.Sprintf("%s tends to %s. Medicine check: %d + %d = %d vs DC %d → ",
+		healer.GetName(), patient.GetName(), d20, mod, d20+mod, StabilizeDC)
+	if d20+mod < StabilizeDC {
+		return line + "FAILED\n", nil
+	}
+	liveDeathSaveState(patient).Stabilized = true
+	return line + fmt.Sprintf("SUCCESS. %s is stable.\n", patient.GetName()), nil
+}
+
+// deathSaveTally formats death save counts, e.g. "(2 successes, 1 failure)".
+func deathSaveTally(successes, failures int) string {
+	plural := func(n int, word string) string {
+		if n == 1 {
+			return fmt.Sprintf("%d %s", n, word)
+		}
+		return fmt.Sprintf("%d %ses", n, word)
+	}
+	return fmt.Sprintf("(%s, %s)", plural(successes, "success"), plural(min(failures, 3), "failure"))
+}
+
+// monsterAttackConfig is the part of a monster attack action's config needed
+// to resolve it. Melee, ranged, bite and scimitar actions all share it.
+type monsterAttackConfig struct {
+	AttackBonus int         `json:"attack_bonus"`
+	DamageDice  string      `json:"damage_dice"`
+	DamageBonus int         `json:"damage_bonus"`
+	DamageType  damage.Type `json:"damage_type"`
+}
+
+// monsterAttack looks up the attack action a monster used by its ID.
+func monsterAttack(m *monster.Monster, actionID string) (monsterAttackConfig, bool) {
+	for _, a := range m.Actions() {
+		if a.GetID() != actionID {
+			continue
+		}
+		var cfg monsterAttackConfig
+		if err := json.Unmarshal(a.ToData().Config, &cfg); err != nil || cfg.DamageDice == "" {
+			return cfg, false
+		}
+		if cfg.DamageType == "" {
+			cfg.DamageType = damage.Slashing
+		}
+		return cfg, true
+	}
+	return monsterAttackConfig{}, false
+}
+
+// resolveMonsterAttack rolls a monster's attack against a player and applies
+// the damage. The toolkit's monster actions only announce the attack.
+// TODO(toolkit): monster AttackEvents are not resolved by the rulebook.
+func resolveMonsterAttack(ctx context.Context, enc *Encounter, m *monster.Monster, ev dnd5eEvents.AttackEvent, roller dice.Roller) (string, error) {
+	var userID string
+	var target *dnd5echar.Character
+	for uid, p := range enc.Players {
+		if uid == ev.TargetID || p.GetID() == ev.TargetID {
+			userID, target = uid, p
+			break
+		}
+	}
+	if target == nil {
+		return "", nil
+	}
+	cfg, ok := monsterAttack(m, ev.WeaponRef)
+	if !ok {
+		return "", nil
+	}
+
+	d20, err := roller.Roll(ctx, 20)
+	if err != nil {
+		return "", fmt.Errorf("roll attack: %w", err)
+	}
+	critical := d20 == 20
+	total := d20 + cfg.AttackBonus
+	hit := d20 != 1 && (critical || total >= target.AC())
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("  %s vs %s: %d + %d = %d vs AC %d → ", ev.WeaponRef, target.GetName(), d20, cfg.AttackBonus, total, target.AC()))
+	switch {
+	case critical:
+		sb.WriteString("CRITICAL HIT!")
+	case hit:
+		sb.WriteString("HIT")
+	default:
+		sb.WriteString("MISS\n")
+		return sb.String(), nil
+	}
+
+	pool, err := dice.ParseNotation(cfg.DamageDice)
+	if err != nil {
+		return "", fmt.Errorf("damage dice %q: %w", cfg.DamageDice, err)
+	}
+	rolled := pool.RollContext(ctx, roller)
+	if rolled.Error() != nil {
+		return "", fmt.Errorf("roll damage: %w", rolled.Error())
+	}
+	amount := rolled.Total() + cfg.DamageBonus
+	if critical {
+		extra := pool.RollContext(ctx, roller)
+		if extra.Error() != nil {
+			return "", fmt.Errorf("roll damage: %w", extra.Error())
+		}
+		amount += extra.Total() - extra.Modifier()
+	}
+	amount = max(amount, 1)
+	sb.WriteString(fmt.Sprintf(", %d %s damage\n", amount, cfg.DamageType))
+
+	dmgLog, err := DamagePlayer(ctx, enc, userID, m.GetID(), amount, cfg.DamageType, critical)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.SplitAfter(dmgLog, "\n") {
+		if line != "" {
+			sb.WriteString("  " + line)
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,217 @@
+package combat
+
+import (
+	"fmt"
+
+	"github.com/KirkDiggler/rpg-toolkit/core"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/weapons"
+	"github.com/KirkDiggler/rpg-toolkit/tools/spatial"
+)
+
+// FeetPerHex is the D&D 5e grid scale: one hex is 5 feet across.
+const FeetPerHex = 5
+
+// PlayerSpeed is how many hexes a player may move on their turn (30 ft).
+const PlayerSpeed = 6
+
+// GridPos is a persisted hex position in offset coordinates (column X, row Y).
+// The toolkit's monster AI works in cube coordinates — use Cube to convert.
+type GridPos struct {
+	X int `json:"x" dynamodbav:"x"`
+	Y int `json:"y" dynamodbav:"y"`
+}
+
+func (p GridPos) offset() spatial.Position {
+	return spatial.Position{X: float64(p.X), Y: float64(p.Y)}
+}
+
+// Cube returns the position in cube coordinates.
+func (p GridPos) Cube() spatial.CubeCoordinate {
+	return spatial.OffsetCoordinateToCube(p.offset())
+}
+
+// Distance returns the number of hexes between two positions.
+func (p GridPos) Distance(other GridPos) int {
+	return p.Cube().Distance(other.Cube())
+}
+
+// String formats the position the way clients send it ("x,y").
+func (p GridPos) String() string {
+	return fmt.Sprintf("%d,%d", p.X, p.Y)
+}
+
+// ParseGridPos parses an "x,y" payload into a GridPos.
+func ParseGridPos(s string) (GridPos, error) {
+	var p GridPos
+	if _, err := fmt.Sscanf(s, "%d,%d", &p.X, &p.Y); err != nil {
+		return GridPos{}, fmt.Errorf("invalid position %q (want \"x,y\")", s)
+	}
+	return p, nil
+}
+
+func gridPosFromCube(c spatial.CubeCoordinate) GridPos {
+	o := c.ToOffsetCoordinate()
+	return GridPos{X: int(o.X), Y: int(o.Y)}
+}
+
+// gridEntity is the minimal core.Entity placed in the spatial room. Players
+// are keyed by userID and monsters by monster ID, matching the Encounter maps.
+type gridEntity struct {
+	id   string
+	kind core.EntityType
+}
+
+func (e gridEntity) GetID() string            { return e.id }
+func (e gridEntity) GetType() core.EntityType { return e.kind }
+
+const (
+	entityPlayer  core.EntityType = "player"
+	entityMonster core.EntityType = "monster"
+)
+
+// Grid is the hex combat grid for one room. It is rebuilt from persisted
+// positions on every Lambda invocation, like the Encounter that uses it.
+type Grid struct {
+	RoomID string
+	Width  int
+	Height int
+	hex    *spatial.HexGrid
+	room   *spatial.BasicRoom
+}
+
+// NewGrid creates an empty width × height hex grid for roomID.
+func NewGrid(roomID string, width, height int) *Grid {
+	hex := spatial.NewHexGrid(spatial.HexGridConfig{Width: float64(width), Height: float64(height)})
+	return &Grid{
+		RoomID: roomID,
+		Width:  width,
+		Height: height,
+		hex:    hex,
+		room:   spatial.NewBasicRoom(spatial.BasicRoomConfig{ID: roomID, Type: "combat", Grid: hex}),
+	}
+}
+
+// Contains reports whether p lies inside the grid.
+func (g *Grid) Contains(p GridPos) bool {
+	return g.hex.IsValidPosition(p.offset())
+}
+
+// Occupied reports whether anyone other than exceptID stands at p.
+func (g *Grid) Occupied(p GridPos, exceptID string) bool {
+	for _, e := range g.room.GetEntitiesAt(p.offset()) {
+		if e.GetID() != exceptID {
+			return true
+		}
+	}
+	return false
+}
+
+// PlacePlayer puts a player (keyed by userID) at p.
+func (g *Grid) PlacePlayer(userID string, p GridPos) error {
+	return g.place(gridEntity{id: userID, kind: entityPlayer}, p)
+}
+
+// PlaceMonster puts a monster at p.
+func (g *Grid) PlaceMonster(monsterID string, p GridPos) error {
+	return g.place(gridEntity{id: monsterID, kind: entityMonster}, p)
+}
+
+func (g *Grid) place(e gridEntity, p GridPos) error {
+	if !g.Contains(p) {
+		return fmt.Errorf("position %s is outside the room", p)
+	}
+	if g.Occupied(p, e.id) {
+		return fmt.Errorf("position %s is occupied", p)
+	}
+	return g.room.PlaceEntity(e, p.offset())
+}
+
+// Remove takes a combatant off the grid (e.g. when they leave the room).
+func (g *Grid) Remove(id string) {
+	_ = g.room.RemoveEntity(id)
+}
+
+// Position returns where a combatant stands.
+func (g *Grid) Position(id string) (GridPos, bool) {
+	pos, ok := g.room.GetEntityPosition(id)
+	if !ok {
+		return GridPos{}, false
+	}
+	return GridPos{X: int(pos.X), Y: int(pos.Y)}, true
+}
+
+// DistanceBetween returns the hex distance between two placed combatants.
+func (g *Grid) DistanceBetween(a, b string) (int, bool) {
+	pa, okA := g.Position(a)
+	pb, okB := g.Position(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	return pa.Distance(pb), true
+}
+
+// Positions returns every placed combatant's position, keyed by ID.
+func (g *Grid) Positions() map[string]GridPos {
+	out := make(map[string]GridPos)
+	for id := range g.room.GetAllEntities() {
+		if p, ok := g.Position(id); ok {
+			out[id] = p
+		}
+	}
+	return out
+}
+
+// NearestFree returns the closest unoccupied hex to p inside the grid,
+// searching outward ring by ring.
+func (g *Grid) NearestFree(p GridPos) (GridPos, bool) {
+	maxRadius := g.Width + g.Height
+	for r := 0; r <= maxRadius; r++ {
+		var ring []spatial.Position
+		if r == 0 {
+			ring = []spatial.Position{p.offset()}
+		} else {
+			ring = g.hex.GetHexRing(p.offset(), r)
+		}
+		for _, pos := range ring {
+			cand := GridPos{X: int(pos.X), Y: int(pos.Y)}
+			if g.Contains(cand) && !g.Occupied(cand, "") {
+				return cand, true
+			}
+		}
+	}
+	return GridPos{}, false
+}
+
+// -------------------------------------------------------------------
+// Reach
+// -------------------------------------------------------------------
+
+// WeaponReach returns how far a weapon can strike, in hexes. Melee weapons
+// reach 1 hex (2 with the reach property). Ranged and thrown weapons reach
+// their normal range and, at most, their long range.
+func WeaponReach(w *weapons.Weapon) (normal, long int) {
+	melee := 0
+	if w.Range == nil || w.IsMelee() || w.ID == "unarmed" {
+		melee = 1
+		if w.HasProperty(weapons.PropertyReach) {
+			melee = 2
+		}
+	}
+	normal, long = melee, melee
+	if w.Range != nil {
+		normal = max(melee, w.Range.Normal/FeetPerHex)
+		long = max(normal, w.Range.Long/FeetPerHex)
+	}
+	return normal, long
+}
+
+// CheckReach returns an error if a target distance hexes away is beyond the
+// weapon's long range.
+func CheckReach(w *weapons.Weapon, distance int) error {
+	_, long := WeaponReach(w)
+	if distance > long {
+		return fmt.Errorf("target is %d ft away — out of reach of %s (%d ft)",
+			distance*FeetPerHex, w.Name, long*FeetPerHex)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package combat
+
+import (
+	"slices"
+
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/classes"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/monster"
+)
+
+// MaxLevel is the highest character level.
+const MaxLevel = 20
+
+// xpThresholds[level-1] is the total XP needed to reach a level (PHB p. 15).
+var xpThresholds = [MaxLevel]int{
+	0, 300, 900, 2700, 6500, 14000, 23000, 34000, 48000, 64000,
+	85000, 100000, 120000, 140000, 165000, 195000, 225000, 265000, 305000, 355000,
+}
+
+// crXP is the XP awarded for defeating a monster of each challenge rating.
+var crXP = map[string]int{
+	"0": 10, "1/8": 25, "1/4": 50, "1/2": 100,
+	"1": 200, "2": 450, "3": 700, "4": 1100, "5": 1800,
+	"6": 2300, "7": 2900, "8": 3900, "9": 5000, "10": 5900,
+}
+
+// monsterCR is the challenge rating of every monster the toolkit can build,
+// keyed by its ref ID (the toolkit does not carry CR on monster data).
+var monsterCR = map[string]string{
+	"giant-rat":         "1/8",
+	"bandit":            "1/8",
+	"bandit-archer":     "1/8",
+	"goblin":            "1/4",
+	"skeleton":          "1/4",
+	"skeleton-archer":   "1/4",
+	"zombie":            "1/4",
+	"wolf":              "1/4",
+	"giant-wolf-spider": "1/4",
+	"thug":              "1/2",
+	"ghoul":             "1",
+	"brown-bear":        "1",
+	"giant-spider":      "1",
+	"skeleton-captain":  "2",
+	"bandit-captain":    "2",
+}
+
+// MonsterCR returns a monster's challenge rating. Monsters without a known
+// ref are treated as CR 0.
+func MonsterCR(m *monster.Data) string {
+	if m == nil || m.Ref == nil {
+		return "0"
+	}
+	if cr, ok := monsterCR[m.Ref.ID]; ok {
+		return cr
+	}
+	return "0"
+}
+
+// MonsterXP returns the XP awarded for defeating a monster.
+func MonsterXP(m *monster.Data) int {
+	return crXP[MonsterCR(m)]
+}
+
+// LevelForXP returns the character level a total XP amount qualifies for.
+func LevelForXP(xp int) int {
+	level := 1
+	for i, threshold := range xpThresholds {
+		if xp >= threshold {
+			level = i + 1
+		}
+	}
+	return level
+}
+
+// XPForLevel returns the total XP needed to reach level, or 0 past MaxLevel.
+func XPForLevel(level int) int {
+	if level < 1 || level > MaxLevel {
+		return 0
+	}
+	return xpThresholds[level-1]
+}
+
+// ProficiencyBonus returns the proficiency bonus at a character level.
+func ProficiencyBonus(level int) int {
+	return 2 + (max(1, level)-1)/4
+}
+
+// IsASILevel reports whether a class gains an Ability Score Improvement at
+// level. Fighters get two extra (6 and 14) and rogues one (10).
+func IsASILevel(class classes.Class, level int) bool {
+	if slices.Contains([]int{4, 8, 12, 16, 19}, level) {
+		return true
+	}
+	switch class {
+	case classes.Fighter:
+		return level == 6 || level == 14
+	case classes.Rogue:
+		return level == 10
+	}
+	return false
+}
+
+// RageCharges returns a barbarian's rages per long rest at level (20 is
+// unlimited in the PHB; capped here at 99).
+func RageCharges(level int) int {
+	switch {
+	case level >= 20:
+		return 99
+	case level >= 17:
+		return 6
+	case level >= 12:
+		return 5
+	case level >= 6:
+		return 4
+	case level >= 3:
+		return 3
+	default:
+		return 2
+	}
+}
+
+// SpellsLearnedPerLevel is how many leveled spells a class adds to its known
+// spells (or spellbook) on each level up. Clerics prepare from the full list.
+func SpellsLearnedPerLevel(class classes.Class) int {
+	switch class {
+	case classes.Wizard:
+		return 2
+	case classes.Warlock, classes.Sorcerer, classes.Bard:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// HighestSlotLevel returns the highest spell slot level a class has at level,
+// or 0 for non-casters.
+func HighestSlotLevel(class classes.Class, level int) int {
+	highest := 0
+	for lvl := range MaxSpellSlots(class, level) {
+		highest = max(highest, lvl)
+	}
+	return highest
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/abilities"
 	dnd5echar "github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/character"
 	dnd5ecombat "github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/combat"
+	dnd5eEvents "github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/events"
 	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/gamectx"
 	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/monster"
 	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/monster/actions"
@@ -30,6 +31,14 @@ type Encounter struct {
 	Registry *gamectx.CombatantRegistry
 	Players  map[string]*dnd5echar.Character // userID → character
 	Monsters map[string]*monster.Monster     // monsterID → monster
+	// Grid holds combatant positions in the room. Nil means positions are
+	// unknown and everyone is treated as adjacent (legacy behaviour).
+	Grid *Grid
+	// PlayerBus is the bus the player characters were loaded on (Bus only
+	// carries monsters). Healing spells publish there; nil disables them.
+	PlayerBus events.EventBus
+	// Roller rolls monster attacks; nil uses the crypto roller.
+	Roller dice.Roller
 }
 
 // NewEncounter builds an Encounter from loaded characters and monsters.
@@ -71,12 +80,11 @@ func NewEncounter(
 	}, nil
 }
 
-// Cleanup unsubscribes all character and monster event listeners.
-// Must be called at the end of every Lambda handler that creates an Encounter.
+// Cleanup unsubscribes the monsters' event listeners. Must be called at the
+// end of every Lambda handler that creates an Encounter. Player characters
+// belong to the caller (they stay subscribed to the bus they were loaded on,
+// and cleaning them up would strip their conditions before they are saved).
 func (e *Encounter) Cleanup(ctx context.Context) {
-	for _, p := range e.Players {
-		_ = p.Cleanup(ctx)
-	}
 	for _, m := range e.Monsters {
 		_ = m.Cleanup(ctx)
 	}
@@ -97,13 +105,13 @@ type AttackOutput struct {
 	TargetHP    int
 	TargetMaxHP int
 	TargetDied  bool
-	// CombatLog is the full formatted string for injection into the Narrator prompt.
-	// It includes both the player's attack and all subsequent monster turns.
+	// CombatLog is the formatted string for injection into the Narrator prompt.
+	// Monster turns are not included — they run at their own initiative slots
+	// via RunMonsterTurn.
 	CombatLog string
 }
 
-// ResolvePlayerAttack resolves a player's attack against a monster, then runs
-// monster turns for all live monsters in the encounter.
+// ResolvePlayerAttack resolves a player's attack against a monster.
 // Returns a structured result with a formatted combat log for the Narrator.
 func ResolvePlayerAttack(ctx context.Context, enc *Encounter, input AttackInput) (*AttackOutput, error) {
 	attacker, ok := enc.Players[input.AttackerID]
@@ -122,6 +130,15 @@ func ResolvePlayerAttack(ctx context.Context, enc *Encounter, input AttackInput)
 		return nil, fmt.Errorf("cannot determine weapon: %w", err)
 	}
 
+	// Enforce melee/ranged reach when positions are known.
+	if enc.Grid != nil {
+		if dist, placed := enc.Grid.DistanceBetween(input.AttackerID, input.TargetID); placed {
+			if err := CheckReach(weapon, dist); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	// Build context with combatant registry so ResolveAttack can look up combatants
 	combatCtx := dnd5ecombat.WithCombatantLookup(ctx, enc.Registry)
 
@@ -138,75 +155,147 @@ func ResolvePlayerAttack(ctx context.Context, enc *Encounter, input AttackInput)
 
 	playerLog := FormatAttackResult(attacker.GetName(), target.Name(), result, target.GetHitPoints(), target.GetMaxHitPoints())
 
-	// Run monster turns for all live monsters
-	var monsterLines []string
-	for _, m := range enc.Monsters {
-		if !m.IsAlive() {
-			continue
-		}
-		turnLog, err := runMonsterTurn(ctx, enc, m)
-		if err != nil {
-			monsterLines = append(monsterLines, fmt.Sprintf("%s's turn: (error: %v)", m.Name(), err))
-			continue
-		}
-		if turnLog != "" {
-			monsterLines = append(monsterLines, turnLog)
-		}
-	}
-
-	var fullLog string
-	if len(monsterLines) > 0 {
-		fullLog = playerLog + "\n" + strings.Join(monsterLines, "\n")
-	} else {
-		fullLog = playerLog
-	}
-
 	return &AttackOutput{
 		Result:      result,
 		TargetName:  target.Name(),
 		TargetHP:    target.GetHitPoints(),
 		TargetMaxHP: target.GetMaxHitPoints(),
 		TargetDied:  target.GetHitPoints() == 0,
-		CombatLog:   fullLog,
+		CombatLog:   playerLog,
 	}, nil
 }
 
+// RunMonsterTurn runs the turn of the monster with the given ID and returns
+// a formatted log line for the Narrator. Dead or unknown monsters take no turn.
+func RunMonsterTurn(ctx context.Context, enc *Encounter, monsterID string) (string, error) {
+	m, ok := enc.Monsters[monsterID]
+	if !ok {
+		return "", fmt.Errorf("monster %s not found in encounter", monsterID)
+	}
+	if !m.IsAlive() {
+		return "", nil
+	}
+	return runMonsterTurn(ctx, enc, m)
+}
+
 // runMonsterTurn executes a single monster's turn and returns a formatted log line.
 func runMonsterTurn(ctx context.Context, enc *Encounter, m *monster.Monster) (string, error) {
-	// Build PerceptionData: enemies are all live players, treated as adjacent
-	// (simplified — no spatial grid in current architecture)
-	enemies := make([]monster.PerceivedEntity, 0, len(enc.Players))
-	for _, p := range enc.Players {
-		if p.GetHitPoints() > 0 {
-			enemies = append(enemies, monster.PerceivedEntity{
-				Entity:   p,
-				Distance: 1,
-				Adjacent: true,
-				HP:       p.GetHitPoints(),
-				AC:       p.AC(),
-			})
-		}
+	perception := buildPerception(enc, m)
+	if len(perception.Enemies) == 0 {
+		return "", nil // No targets — monster does nothing
 	}
 
-	if len(enemies) == 0 {
-		return "", nil // No targets — monster does nothing
+	roller := enc.Roller
+	if roller == nil {
+		roller = dice.NewRoller()
 	}
 
+	// Monster actions only announce their attacks; collect them to resolve
+	// against the players once the turn is over.
+	var attacks []dnd5eEvents.AttackEvent
+	attackTopic := dnd5eEvents.AttackTopic.On(enc.Bus)
+	subID, err := attackTopic.Subscribe(ctx, func(_ context.Context, ev dnd5eEvents.AttackEvent) error {
+		if ev.AttackerID == m.GetID() {
+			attacks = append(attacks, ev)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("subscribe to attacks: %w", err)
+	}
+	defer func() { _ = attackTopic.Unsubscribe(ctx, subID) }()
+
 	economy := dnd5ecombat.NewActionEconomy()
 	turnResult, err := m.TakeTurn(ctx, &monster.TurnInput{
 		Bus:           enc.Bus,
 		ActionEconomy: economy,
-		Perception: &monster.PerceptionData{
-			Enemies: enemies,
-		},
-		Roller: dice.NewRoller(),
-		Speed:  6,
+		Perception:    perception,
+		Roller:        roller,
+		Speed:         0, // use the monster's own walking speed
 	})
 	if err != nil {
 		return "", err
 	}
+	applyMonsterMovement(enc, m.GetID(), turnResult)
 
-	return FormatMonsterTurn(m.Name(), turnResult, enc.Players), nil
+	turnLog := FormatMonsterTurn(m.Name(), turnResult, enc.Players)
+	for _, ev := range attacks {
+		attackLog, err := resolveMonsterAttack(ctx, enc, m, ev, roller)
+		if err != nil {
+			return "", err
+		}
+		turnLog += attackLog
+	}
+	return turnLog, nil
+}
+
+// buildPerception describes the live players around a monster, closest first.
+// With a grid the distances are real hex distances; without one every player
+// is treated as adjacent.
+func buildPerception(enc *Encounter, m *monster.Monster) *monster.PerceptionData {
+	perception := &monster.PerceptionData{}
+	var myPos GridPos
+	placed := false
+	if enc.Grid != nil {
+		myPos, placed = enc.Grid.Position(m.GetID())
+		perception.MyPosition = myPos.Cube()
+		perception.MyRoom = enc.Grid.RoomID
+	}
+
+	for uid, p := range enc.Players {
+		if p.GetHitPoints() <= 0 {
+			continue
+		}
+		enemy := monster.PerceivedEntity{
+			Entity:   p,
+			Distance: 1,
+			Adjacent: true,
+			HP:       p.GetHitPoints(),
+			AC:       p.AC(),
+		}
+		if placed {
+			pos, ok := enc.Grid.Position(uid)
+			if !ok {
+				continue // not on the grid — out of the fight
+			}
+			enemy.Position = pos.Cube()
+			enemy.Room = enc.Grid.RoomID
+			enemy.Distance = myPos.Distance(pos)
+			enemy.Adjacent = enemy.Distance == 1
+		}
+		perception.Enemies = append(perception.Enemies, enemy)
+	}
+	sort.SliceStable(perception.Enemies, func(i, j int) bool {
+		return perception.Enemies[i].Distance < perception.Enemies[j].Distance
+	})
+
+	if placed {
+		// Other monsters block the path but are not targets.
+		for id, other := range enc.Monsters {
+			if id == m.GetID() || !other.IsAlive() {
+				continue
+			}
+			if pos, ok := enc.Grid.Position(id); ok {
+				perception.BlockedHexes = append(perception.BlockedHexes, pos.Cube())
+			}
+		}
+	}
+	return perception
+}
+
+// applyMonsterMovement moves the monster on the grid to the furthest hex of
+// its path that lies inside the room and is not occupied.
+func applyMonsterMovement(enc *Encounter, monsterID string, result *monster.TurnResult) {
+	if enc.Grid == nil || result == nil {
+		return
+	}
+	for i := len(result.Movement) - 1; i > 0; i-- {
+		dest := gridPosFromCube(result.Movement[i])
+		if enc.Grid.Contains(dest) && !enc.Grid.Occupied(dest, monsterID) {
+			_ = enc.Grid.PlaceMonster(monsterID, dest)
+			return
+		}
+	}
 }
 
 // resolveWeapon determines the weapon to use for an attack.
@@ -279,12 +368,19 @@ func FormatAttackResult(attackerName, targetName string, r *dnd5ecombat.AttackRe
 
 // FormatMonsterTurn formats a monster's turn result for the Narrator system prompt.
 func FormatMonsterTurn(monsterName string, result *monster.TurnResult, players map[string]*dnd5echar.Character) string {
-	if result == nil || len(result.Actions) == 0 {
+	moved := 0
+	if result != nil && len(result.Movement) > 1 {
+		moved = (len(result.Movement) - 1) * FeetPerHex
+	}
+	if result == nil || (len(result.Actions) == 0 && moved == 0) {
 		return fmt.Sprintf("%s takes no action.", monsterName)
 	}
 
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("%s's turn:\n", monsterName))
+	if moved > 0 {
+		sb.WriteString(fmt.Sprintf("  moves %d ft\n", moved))
+	}
 	for _, act := range result.Actions {
 		status := "failed"
 		if act.Success {
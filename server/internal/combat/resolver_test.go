@@ -2,10 +2,16 @@ package combat_test
 
 import (
 	"context"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/KirkDiggler/rpg-toolkit/events"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/abilities"
 	dnd5echar "github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/character"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/classes"
 	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/monster"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/weapons"
 	"github.com/google/uuid"
 	"github.com/rrochlin/an-amazing-adventure/internal/combat"
 	"github.com/rrochlin/an-amazing-adventure/internal/game"
@@ -337,6 +343,868 @@ func TestGame_CombatFields_RoundTrip(t *testing.T) {
 	}
 }
 
+// ---- Turns ----
+
+func TestNextTurn_WrapsAndSkips(t *testing.T) {
+	order := []combat.InitiativeEntry{
+		{CombatantID: "a", IsPlayer: true},
+		{CombatantID: "b", IsPlayer: false},
+		{CombatantID: "c", IsPlayer: true},
+	}
+	down := map[string]bool{"c": true}
+	canAct := func(e combat.InitiativeEntry) bool { return !down[e.CombatantID] }
+
+	next, wrapped := combat.NextTurn(order, 0, canAct)
+	if next != 1 || wrapped {
+		t.Errorf("expected 1 without wrap, got %d (wrapped=%v)", next, wrapped)
+	}
+	next, wrapped = combat.NextTurn(order, 1, canAct)
+	if next != 0 || !wrapped {
+		t.Errorf("expected to skip c and wrap to 0, got %d (wrapped=%v)", next, wrapped)
+	}
+	next, _ = combat.NextTurn(order, 0, func(combat.InitiativeEntry) bool { return false })
+	if next != -1 {
+		t.Errorf("expected -1 when nobody can act, got %d", next)
+	}
+}
+
+// newTurnGame builds a game with two players and a goblin in one room, with
+// the order fighter (user-1) → goblin → rogue (user-2).
+func newTurnGame(t *testing.T) (*game.Game, *monster.Data, string) {
+	t.Helper()
+	g := game.NewGame("sess-1", "user-1")
+	g.SetPlayerCharacter("user-1", game.NewCharacter("Fighter", ""))
+	g.SetPlayerCharacter("user-2", game.NewCharacter("Rogue", ""))
+	room := game.NewArea("Cave", "")
+	_ = g.AddRoom(room)
+	_ = g.PlaceCharacter("user-1", room.ID)
+	_ = g.PlaceCharacter("user-2", room.ID)
+	goblin := combat.NewMonsterByType("goblin").ToData()
+	g.SetRoomMonsters(room.ID, []*monster.Data{goblin})
+	order := []combat.InitiativeEntry{
+		{CombatantID: "user-1", CombatantName: "Fighter", Roll: 18, IsPlayer: true},
+		{CombatantID: goblin.ID, CombatantName: "Goblin", Roll: 12},
+		{CombatantID: "user-2", CombatantName: "Rogue", Roll: 5, IsPlayer: true},
+	}
+	g.StartCombat(room.ID, order, "user-1", time.Now())
+	return g, goblin, room.ID
+}
+
+func TestGame_CheckTurn_RejectsOutOfTurn(t *testing.T) {
+	g, _, _ := newTurnGame(t)
+	if err := g.CheckTurn("user-1"); err != nil {
+		t.Errorf("expected user-1 to act first, got %v", err)
+	}
+	if err := g.CheckTurn("user-2"); err == nil {
+		t.Error("expected out-of-turn error for user-2")
+	}
+	if err := g.CheckTurn("user-outside"); err != nil {
+		t.Errorf("players outside the fight must act freely, got %v", err)
+	}
+}
+
+func TestGame_JoinCombat_LateArrivalWaitsForTheirSlot(t *testing.T) {
+	g, _, roomID := newTurnGame(t)
+	hall := game.NewArea("Hall", "")
+	_ = g.AddRoom(hall)
+	_ = g.ConnectRooms(roomID, hall.ID, "south")
+	g.SetPlayerCharacter("user-3", game.NewCharacter("Cleric", ""))
+	_ = g.PlaceCharacter("user-3", hall.ID)
+
+	late := combat.InitiativeEntry{CombatantID: "user-3", CombatantName: "Cleric", Roll: 12, IsPlayer: true}
+	if g.JoinCombat(late) {
+		t.Fatal("a player outside the fight's room must not join it")
+	}
+	if _, err := g.MoveCharacter("user-3", "north"); err != nil {
+		t.Fatalf("MoveCharacter: %v", err)
+	}
+	if !g.JoinCombat(late) {
+		t.Fatal("expected the arrival to join the fight")
+	}
+	if g.JoinCombat(late) {
+		t.Error("a player must not get a second slot")
+	}
+	if err := g.CheckTurn("user-3"); err == nil {
+		t.Error("expected the late arrival to wait for their slot")
+	}
+	if cur, _ := g.CurrentTurn(); cur.CombatantID != "user-1" {
+		t.Errorf("joining must not move the pointer, current is %s", cur.CombatantID)
+	}
+
+	// Ties with a monster go to the player: fighter, cleric, goblin, rogue.
+	e, _ := g.AdvanceTurn(time.Now())
+	if e.CombatantID != "user-3" {
+		t.Errorf("expected the cleric to act after the fighter, got %s", e.CombatantID)
+	}
+	if err := g.CheckTurn("user-3"); err != nil {
+		t.Errorf("expected the cleric's turn, got %v", err)
+	}
+}
+
+func TestGame_AdvanceTurn_MonsterThenRoundWrap(t *testing.T) {
+	g, _, _ := newTurnGame(t)
+	now := time.Now()
+
+	e, ok := g.AdvanceTurn(now)
+	if !ok || e.IsPlayer {
+		t.Fatalf("expected goblin's turn, got %+v (ok=%v)", e, ok)
+	}
+	e, _ = g.AdvanceTurn(now)
+	if e.CombatantID != "user-2" {
+		t.Errorf("expected user-2 after goblin, got %s", e.CombatantID)
+	}
+	e, _ = g.AdvanceTurn(now)
+	if e.CombatantID != "user-1" || g.Turn.Round != 2 {
+		t.Errorf("expected round 2 starting with user-1, got %s in round %d", e.CombatantID, g.Turn.Round)
+	}
+}
+
+func TestGame_AdvanceTurn_SkipsAbsentPlayer(t *testing.T) {
+	g, _, _ := newTurnGame(t)
+	elsewhere := game.NewArea("Hall", "")
+	_ = g.AddRoom(elsewhere)
+	_ = g.PlaceCharacter("user-2", elsewhere.ID)
+
+	_, _ = g.AdvanceTurn(time.Now()) // goblin
+	e, _ := g.AdvanceTurn(time.Now())
+	if e.CombatantID != "user-1" {
+		t.Errorf("expected user-2 (left the room) to be skipped, got %s", e.CombatantID)
+	}
+}
+
+func TestGame_AdvanceTurn_EndsWhenMonstersDead(t *testing.T) {
+	g, goblin, roomID := newTurnGame(t)
+	goblin.HitPoints = 0
+	g.SetRoomMonsters(roomID, []*monster.Data{goblin})
+
+	if _, ok := g.AdvanceTurn(time.Now()); ok {
+		t.Error("expected combat to end with no live monsters")
+	}
+	if g.CombatActive() || g.TurnView() != nil {
+		t.Error("expected turn state cleared after combat ends")
+	}
+}
+
+func TestGame_TurnExpired(t *testing.T) {
+	g, _, _ := newTurnGame(t)
+	if g.TurnExpired(time.Now()) {
+		t.Error("fresh turn must not be expired")
+	}
+	if !g.TurnExpired(time.Now().Add(combat.TurnTimeout + time.Second)) {
+		t.Error("expected turn to expire after TurnTimeout")
+	}
+}
+
+func TestGame_TurnState_RoundTrip(t *testing.T) {
+	g, _, roomID := newTurnGame(t)
+	_, _ = g.AdvanceTurn(time.Now())
+
+	restored, err := game.FromSaveState(g.ToSaveState(nil, nil))
+	if err != nil {
+		t.Fatalf("FromSaveState: %v", err)
+	}
+	cur, ok := restored.CurrentTurn()
+	if !ok || cur.CombatantName != "Goblin" {
+		t.Errorf("expected goblin's turn after round-trip, got %+v", cur)
+	}
+	if restored.Turn.RoomID != roomID || restored.Turn.Round != 1 {
+		t.Errorf("turn state mismatch after round-trip: %+v", restored.Turn)
+	}
+}
+
+// ---- Grid ----
+
+func TestGrid_PlaceAndDistance(t *testing.T) {
+	g := combat.NewGrid("room-1", 6, 6)
+	if err := g.PlacePlayer("uid-1", combat.GridPos{X: 0, Y: 0}); err != nil {
+		t.Fatalf("PlacePlayer: %v", err)
+	}
+	if err := g.PlaceMonster("m-1", combat.GridPos{X: 0, Y: 0}); err == nil {
+		t.Error("expected error placing on an occupied hex")
+	}
+	if err := g.PlaceMonster("m-1", combat.GridPos{X: 9, Y: 0}); err == nil {
+		t.Error("expected error placing outside the grid")
+	}
+	if err := g.PlaceMonster("m-1", combat.GridPos{X: 0, Y: 3}); err != nil {
+		t.Fatalf("PlaceMonster: %v", err)
+	}
+	if d, ok := g.DistanceBetween("uid-1", "m-1"); !ok || d != 3 {
+		t.Errorf("expected distance 3, got %d (ok=%v)", d, ok)
+	}
+	free, ok := g.NearestFree(combat.GridPos{X: 0, Y: 0})
+	if !ok || free == (combat.GridPos{X: 0, Y: 0}) || free.Distance(combat.GridPos{}) != 1 {
+		t.Errorf("expected a free neighbour of (0,0), got %v", free)
+	}
+}
+
+func TestParseGridPos(t *testing.T) {
+	p, err := combat.ParseGridPos("3,4")
+	if err != nil || p != (combat.GridPos{X: 3, Y: 4}) {
+		t.Errorf("expected (3,4), got %v (err=%v)", p, err)
+	}
+	if _, err := combat.ParseGridPos("north"); err == nil {
+		t.Error("expected error for non-coordinate payload")
+	}
+}
+
+func TestWeaponReach(t *testing.T) {
+	cases := []struct {
+		id           weapons.WeaponID
+		normal, long int
+	}{
+		{weapons.Longsword, 1, 1},
+		{weapons.Glaive, 2, 2},
+		{weapons.Dagger, 4, 12},    // thrown 20/60 ft
+		{weapons.Longbow, 30, 120}, // 150/600 ft
+	}
+	for _, c := range cases {
+		w, err := weapons.GetByID(c.id)
+		if err != nil {
+			t.Fatalf("GetByID(%s): %v", c.id, err)
+		}
+		normal, long := combat.WeaponReach(&w)
+		if normal != c.normal || long != c.long {
+			t.Errorf("%s: expected reach %d/%d, got %d/%d", c.id, c.normal, c.long, normal, long)
+		}
+	}
+}
+
+func TestResolvePlayerAttack_OutOfReach(t *testing.T) {
+	ctx := context.Background()
+	fighter := buildTestFighter(t)
+	goblin := monster.NewGoblin(uuid.NewString())
+
+	enc, err := combat.NewEncounter(ctx,
+		map[string]*dnd5echar.Character{"uid-1": fighter},
+		[]*monster.Monster{goblin},
+	)
+	if err != nil {
+		t.Fatalf("NewEncounter: %v", err)
+	}
+	defer enc.Cleanup(ctx)
+	enc.Grid = combat.NewGrid("room-1", 10, 10)
+	_ = enc.Grid.PlacePlayer("uid-1", combat.GridPos{X: 5, Y: 9})
+	_ = enc.Grid.PlaceMonster(goblin.GetID(), combat.GridPos{X: 5, Y: 0})
+
+	_, err = combat.ResolvePlayerAttack(ctx, enc, combat.AttackInput{
+		AttackerID: "uid-1",
+		TargetID:   goblin.GetID(),
+		WeaponID:   "longsword",
+	})
+	if err == nil || !strings.Contains(err.Error(), "out of reach") {
+		t.Errorf("expected out-of-reach error, got %v", err)
+	}
+
+	_, err = combat.ResolvePlayerAttack(ctx, enc, combat.AttackInput{
+		AttackerID: "uid-1",
+		TargetID:   goblin.GetID(),
+		WeaponID:   "longbow",
+	})
+	if err != nil {
+		t.Errorf("expected longbow to reach 45 ft, got %v", err)
+	}
+}
+
+func TestRunMonsterTurn_ClosesDistance(t *testing.T) {
+	ctx := context.Background()
+	fighter := buildTestFighter(t)
+	goblin := monster.NewGoblin(uuid.NewString())
+
+	enc, err := combat.NewEncounter(ctx,
+		map[string]*dnd5echar.Character{"uid-1": fighter},
+		[]*monster.Monster{goblin},
+	)
+	if err != nil {
+		t.Fatalf("NewEncounter: %v", err)
+	}
+	defer enc.Cleanup(ctx)
+	enc.Grid = combat.NewGrid("room-1", 10, 10)
+	_ = enc.Grid.PlacePlayer("uid-1", combat.GridPos{X: 5, Y: 9})
+	_ = enc.Grid.PlaceMonster(goblin.GetID(), combat.GridPos{X: 5, Y: 0})
+
+	log, err := combat.RunMonsterTurn(ctx, enc, goblin.GetID())
+	if err != nil {
+		t.Fatalf("RunMonsterTurn: %v", err)
+	}
+	dist, _ := enc.Grid.DistanceBetween("uid-1", goblin.GetID())
+	if dist >= 9 {
+		t.Errorf("expected goblin to move closer than 9 hexes, still %d away", dist)
+	}
+	if !strings.Contains(log, "moves") {
+		t.Errorf("expected movement in monster log, got %q", log)
+	}
+}
+
+// ---- Spells ----
+
+// fixedRoller rolls the same value on every die, capped at the die size.
+type fixedRoller int
+
+func (r fixedRoller) Roll(_ context.Context, size int) (int, error) {
+	return min(int(r), size), nil
+}
+
+func (r fixedRoller) RollN(ctx context.Context, count, size int) ([]int, error) {
+	rolls := make([]int, count)
+	for i := range rolls {
+		rolls[i], _ = r.Roll(ctx, size)
+	}
+	return rolls, nil
+}
+
+// buildTestWizard creates a level-1 Wizard character for testing.
+func buildTestWizard(t *testing.T) *dnd5echar.Character {
+	t.Helper()
+	char, err := game.BuildDnDCharacter(context.Background(), game.CharacterCreationData{
+		Name:    "TestWizard",
+		RaceID:  "human",
+		ClassID: "wizard",
+		AbilityScores: map[string]int{
+			"strength": 8, "dexterity": 14, "constitution": 13,
+			"intelligence": 15, "wisdom": 12, "charisma": 10,
+		},
+		SelectedSkills:   []string{"arcana", "history"},
+		SelectedCantrips: []string{"fire-bolt", "ray-of-frost", "light"},
+		SelectedSpells:   []string{"magic-missile", "burning-hands", "thunderwave", "shield", "sleep", "detect-magic"},
+	})
+	if err != nil {
+		t.Fatalf("BuildDnDCharacter: %v", err)
+	}
+	return char
+}
+
+var testWizardSpells = []string{"fire-bolt", "ray-of-frost", "magic-missile", "burning-hands"}
+
+func newSpellEncounter(t *testing.T, caster *dnd5echar.Character) (*combat.Encounter, *monster.Monster) {
+	t.Helper()
+	ctx := context.Background()
+	goblin := monster.NewGoblin(uuid.NewString())
+	enc, err := combat.NewEncounter(ctx,
+		map[string]*dnd5echar.Character{"uid-1": caster},
+		[]*monster.Monster{goblin},
+	)
+	if err != nil {
+		t.Fatalf("NewEncounter: %v", err)
+	}
+	t.Cleanup(func() { enc.Cleanup(ctx) })
+	return enc, goblin
+}
+
+func TestMaxSpellSlots(t *testing.T) {
+	cases := []struct {
+		class string
+		level int
+		want  map[int]int
+	}{
+		{"wizard", 1, map[int]int{1: 2}},
+		{"cleric", 5, map[int]int{1: 4, 2: 3, 3: 2}},
+		{"warlock", 1, map[int]int{1: 1}},
+		{"warlock", 5, map[int]int{3: 2}},
+	}
+	for _, c := range cases {
+		slots := combat.MaxSpellSlots(classes.Class(c.class), c.level)
+		if len(slots) != len(c.want) {
+			t.Errorf("%s %d: expected %v, got %v", c.class, c.level, c.want, slots)
+			continue
+		}
+		for lvl, max := range c.want {
+			if slots[lvl].Max != max {
+				t.Errorf("%s %d: expected %d level-%d slots, got %d", c.class, c.level, max, lvl, slots[lvl].Max)
+			}
+		}
+	}
+	if slots := combat.MaxSpellSlots(classes.Fighter, 5); slots != nil {
+		t.Errorf("expected no slots for a fighter, got %v", slots)
+	}
+}
+
+func TestConsumeSlot(t *testing.T) {
+	slots := combat.MaxSpellSlots(classes.Wizard, 3) // 4×1st, 2×2nd
+	lvl, err := combat.ConsumeSlot(slots, 1, 0)
+	if err != nil || lvl != 1 {
+		t.Fatalf("expected lowest slot (1), got %d, %v", lvl, err)
+	}
+	if _, err := combat.ConsumeSlot(slots, 2, 1); err == nil {
+		t.Error("expected error casting below the spell's level")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := combat.ConsumeSlot(slots, 1, 2); err != nil {
+			t.Fatalf("ConsumeSlot level 2: %v", err)
+		}
+	}
+	if _, err := combat.ConsumeSlot(slots, 1, 2); err == nil {
+		t.Error("expected error once level-2 slots are spent")
+	}
+	if slots[1].Used != 1 || slots[2].Used != 2 {
+		t.Errorf("unexpected slot usage %v", slots)
+	}
+}
+
+func TestRecoverSlots(t *testing.T) {
+	wizard := map[int]dnd5echar.SpellSlotData{1: {Max: 2, Used: 2}}
+	warlock := map[int]dnd5echar.SpellSlotData{1: {Max: 1, Used: 1}}
+	combat.RecoverSlots(classes.Wizard, wizard, false)
+	combat.RecoverSlots(classes.Warlock, warlock, false)
+	if wizard[1].Used != 2 {
+		t.Error("short rest must not restore wizard slots")
+	}
+	if warlock[1].Used != 0 {
+		t.Error("short rest must restore warlock Pact Magic slots")
+	}
+	combat.RecoverSlots(classes.Wizard, wizard, true)
+	if wizard[1].Used != 0 {
+		t.Error("long rest must restore wizard slots")
+	}
+}
+
+func TestCheckCastable(t *testing.T) {
+	if _, err := combat.CheckCastable(classes.Wizard, testWizardSpells, "fire-bolt"); err != nil {
+		t.Errorf("expected known cantrip castable, got %v", err)
+	}
+	if _, err := combat.CheckCastable(classes.Wizard, testWizardSpells, "thunderwave"); err == nil {
+		t.Error("expected unknown spell rejected")
+	}
+	if _, err := combat.CheckCastable(classes.Wizard, nil, "cure-wounds"); err == nil {
+		t.Error("expected other-class spell rejected")
+	}
+	if _, err := combat.CheckCastable(classes.Cleric, nil, "cure-wounds"); err != nil {
+		t.Errorf("expected clerics to prepare any cleric spell, got %v", err)
+	}
+}
+
+func TestResolveSpell_FireBoltCrit(t *testing.T) {
+	ctx := context.Background()
+	enc, goblin := newSpellEncounter(t, buildTestWizard(t))
+
+	out, err := combat.ResolveSpell(ctx, enc, combat.CastInput{
+		CasterID: "uid-1",
+		SpellID:  "fire-bolt",
+		TargetID: goblin.GetID(),
+		Known:    testWizardSpells,
+		Roller:   fixedRoller(20),
+	})
+	if err != nil {
+		t.Fatalf("ResolveSpell: %v", err)
+	}
+	if !out.Critical || out.Damage != 20 || !out.TargetDied {
+		t.Errorf("expected a 20-damage critical kill, got %+v", out)
+	}
+	if out.SlotLevel != 0 {
+		t.Errorf("cantrips must not spend a slot, got level %d", out.SlotLevel)
+	}
+	if !strings.Contains(out.CombatLog, "Fire Bolt") {
+		t.Errorf("expected spell name in log, got %q", out.CombatLog)
+	}
+}
+
+func TestResolveSpell_SpendsSlot(t *testing.T) {
+	ctx := context.Background()
+	enc, goblin := newSpellEncounter(t, buildTestWizard(t))
+	slots := combat.MaxSpellSlots(classes.Wizard, 1)
+
+	out, err := combat.ResolveSpell(ctx, enc, combat.CastInput{
+		CasterID: "uid-1",
+		SpellID:  "magic-missile",
+		TargetID: goblin.GetID(),
+		Known:    testWizardSpells,
+		Slots:    slots,
+		Roller:   fixedRoller(1),
+	})
+	if err != nil {
+		t.Fatalf("ResolveSpell: %v", err)
+	}
+	if out.Damage != 6 { // 3 darts × (1d4 + 1)
+		t.Errorf("expected 6 damage, got %d", out.Damage)
+	}
+	if slots[1].Used != 1 {
+		t.Errorf("expected one slot spent, got %d", slots[1].Used)
+	}
+}
+
+func TestResolveSpell_SaveForHalf(t *testing.T) {
+	ctx := context.Background()
+	enc, goblin := newSpellEncounter(t, buildTestWizard(t))
+
+	out, err := combat.ResolveSpell(ctx, enc, combat.CastInput{
+		CasterID: "uid-1",
+		SpellID:  "burning-hands",
+		TargetID: goblin.GetID(),
+		Known:    testWizardSpells,
+		Slots:    combat.MaxSpellSlots(classes.Wizard, 1),
+		Roller:   fixedRoller(20),
+	})
+	if err != nil {
+		t.Fatalf("ResolveSpell: %v", err)
+	}
+	if !out.Saved || out.Damage != 9 { // 3d6 all sixes, halved
+		t.Errorf("expected a save for 9 damage, got saved=%v damage=%d", out.Saved, out.Damage)
+	}
+}
+
+func TestResolveSpell_Heal(t *testing.T) {
+	ctx := context.Background()
+	cleric, err := game.BuildDnDCharacter(ctx, game.CharacterCreationData{
+		Name:    "TestCleric",
+		RaceID:  "human",
+		ClassID: "cleric",
+		AbilityScores: map[string]int{
+			"strength": 14, "dexterity": 10, "constitution": 13,
+			"intelligence": 8, "wisdom": 15, "charisma": 12,
+		},
+		SelectedSkills:   []string{"medicine", "religion"},
+		SelectedCantrips: []string{"sacred-flame", "toll-the-dead", "light"},
+	})
+	if err != nil {
+		t.Fatalf("BuildDnDCharacter: %v", err)
+	}
+	data := cleric.ToData()
+	data.HitPoints = 1
+	bus := events.NewEventBus()
+	wounded, err := dnd5echar.LoadFromData(ctx, data, bus)
+	if err != nil {
+		t.Fatalf("LoadFromData: %v", err)
+	}
+	enc, _ := newSpellEncounter(t, wounded)
+	enc.PlayerBus = bus
+
+	out, err := combat.ResolveSpell(ctx, enc, combat.CastInput{
+		CasterID: "uid-1",
+		SpellID:  "cure-wounds",
+		Slots:    combat.MaxSpellSlots(classes.Cleric, 1),
+		Roller:   fixedRoller(1),
+	})
+	if err != nil {
+		t.Fatalf("ResolveSpell: %v", err)
+	}
+	if out.Healing < 1 || wounded.GetHitPoints() != 1+out.Healing {
+		t.Errorf("expected HP 1+%d, got %d", out.Healing, wounded.GetHitPoints())
+	}
+}
+
+func TestResolveSpell_OutOfRangeKeepsSlot(t *testing.T) {
+	ctx := context.Background()
+	enc, goblin := newSpellEncounter(t, buildTestWizard(t))
+	enc.Grid = combat.NewGrid("room-1", 10, 10)
+	_ = enc.Grid.PlacePlayer("uid-1", combat.GridPos{X: 5, Y: 9})
+	_ = enc.Grid.PlaceMonster(goblin.GetID(), combat.GridPos{X: 5, Y: 0})
+	slots := combat.MaxSpellSlots(classes.Wizard, 1)
+
+	_, err := combat.ResolveSpell(ctx, enc, combat.CastInput{
+		CasterID: "uid-1",
+		SpellID:  "burning-hands",
+		TargetID: goblin.GetID(),
+		Known:    testWizardSpells,
+		Slots:    slots,
+	})
+	if err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("expected out-of-range error, got %v", err)
+	}
+	if slots[1].Used != 0 {
+		t.Error("a rejected cast must not spend a slot")
+	}
+}
+
+// ---- Leveling ----
+
+func TestLevelForXP(t *testing.T) {
+	cases := map[int]int{0: 1, 299: 1, 300: 2, 899: 2, 900: 3, 355000: 20, 999999: 20}
+	for xp, want := range cases {
+		if got := combat.LevelForXP(xp); got != want {
+			t.Errorf("LevelForXP(%d) = %d, want %d", xp, got, want)
+		}
+	}
+	if combat.XPForLevel(2) != 300 || combat.XPForLevel(21) != 0 {
+		t.Error("unexpected XPForLevel thresholds")
+	}
+}
+
+func TestMonsterXP(t *testing.T) {
+	if got := combat.MonsterXP(monster.NewGoblin("g").ToData()); got != 50 {
+		t.Errorf("expected 50 XP for a CR 1/4 goblin, got %d", got)
+	}
+	if got := combat.MonsterXP(combat.NewMonsterByType("brown_bear").ToData()); got != 200 {
+		t.Errorf("expected 200 XP for a CR 1 brown bear, got %d", got)
+	}
+	if got := combat.MonsterXP(&monster.Data{Name: "Mystery"}); got != 10 {
+		t.Errorf("expected unknown monsters to count as CR 0, got %d", got)
+	}
+}
+
+func TestProficiencyAndASILevels(t *testing.T) {
+	for level, want := range map[int]int{1: 2, 4: 2, 5: 3, 9: 4, 17: 6} {
+		if got := combat.ProficiencyBonus(level); got != want {
+			t.Errorf("ProficiencyBonus(%d) = %d, want %d", level, got, want)
+		}
+	}
+	if !combat.IsASILevel(classes.Wizard, 4) || combat.IsASILevel(classes.Wizard, 6) {
+		t.Error("wizards improve at 4 but not 6")
+	}
+	if !combat.IsASILevel(classes.Fighter, 6) {
+		t.Error("fighters get an extra improvement at 6")
+	}
+}
+
+// ---- Dying ----
+
+// downedFighter returns a test fighter loaded at 0 HP, and the bus it listens on.
+func downedFighter(t *testing.T) (*dnd5echar.Character, events.EventBus) {
+	t.Helper()
+	data := buildTestFighter(t).ToData()
+	data.HitPoints = 0
+	bus := events.NewEventBus()
+	char, err := dnd5echar.LoadFromData(context.Background(), data, bus)
+	if err != nil {
+		t.Fatalf("LoadFromData: %v", err)
+	}
+	return char, bus
+}
+
+func newDyingEncounter(t *testing.T, player *dnd5echar.Character) *combat.Encounter {
+	t.Helper()
+	ctx := context.Background()
+	enc, err := combat.NewEncounter(ctx, map[string]*dnd5echar.Character{"uid-1": player}, nil)
+	if err != nil {
+		t.Fatalf("NewEncounter: %v", err)
+	}
+	t.Cleanup(func() { enc.Cleanup(ctx) })
+	return enc
+}
+
+func TestDamagePlayer_FallsUnconscious(t *testing.T) {
+	fighter := buildTestFighter(t)
+	enc := newDyingEncounter(t, fighter)
+
+	log, err := combat.DamagePlayer(context.Background(), enc, "uid-1", "goblin", fighter.GetHitPoints()+1, "slashing", false)
+	if err != nil {
+		t.Fatalf("DamagePlayer: %v", err)
+	}
+	if fighter.GetHitPoints() != 0 || combat.Vital(fighter) != combat.VitalDying {
+		t.Errorf("expected a dying fighter at 0 HP, got %s at %d HP", combat.Vital(fighter), fighter.GetHitPoints())
+	}
+	if !strings.Contains(log, "falls unconscious") {
+		t.Errorf("expected the knockout in the log, got %q", log)
+	}
+}
+
+func TestDamagePlayer_MassiveDamageKills(t *testing.T) {
+	fighter := buildTestFighter(t)
+	enc := newDyingEncounter(t, fighter)
+
+	amount := fighter.GetHitPoints() + fighter.GetMaxHitPoints()
+	if _, err := combat.DamagePlayer(context.Background(), enc, "uid-1", "ogre", amount, "bludgeoning", false); err != nil {
+		t.Fatalf("DamagePlayer: %v", err)
+	}
+	if !combat.IsDead(fighter) {
+		t.Errorf("expected massive damage to kill outright, got %s", combat.Vital(fighter))
+	}
+}
+
+func TestDamagePlayer_WhileDownCritAddsTwoFailures(t *testing.T) {
+	fighter, _ := downedFighter(t)
+	enc := newDyingEncounter(t, fighter)
+
+	if _, err := combat.DamagePlayer(context.Background(), enc, "uid-1", "goblin", 3, "piercing", true); err != nil {
+		t.Fatalf("DamagePlayer: %v", err)
+	}
+	if got := fighter.GetDeathSaveState().Failures; got != 2 {
+		t.Errorf("expected 2 failures from a critical hit, got %d", got)
+	}
+	if _, err := combat.DamagePlayer(context.Background(), enc, "uid-1", "goblin", 3, "piercing", false); err != nil {
+		t.Fatalf("DamagePlayer: %v", err)
+	}
+	if !combat.IsDead(fighter) {
+		t.Errorf("expected the third failure to kill, got %s", combat.Vital(fighter))
+	}
+}
+
+func TestDeathSave_ThreeSuccessesStabilize(t *testing.T) {
+	ctx := context.Background()
+	fighter, bus := downedFighter(t)
+	for range 3 {
+		if _, err := combat.DeathSave(ctx, fighter, bus, fixedRoller(12)); err != nil {
+			t.Fatalf("DeathSave: %v", err)
+		}
+	}
+	if combat.Vital(fighter) != combat.VitalStable {
+		t.Errorf("expected stable after three successes, got %s", combat.Vital(fighter))
+	}
+	if log, _ := combat.DeathSave(ctx, fighter, bus, fixedRoller(1)); log != "" {
+		t.Errorf("a stable character makes no more saves, got %q", log)
+	}
+}
+
+func TestDeathSave_Natural20Revives(t *testing.T) {
+	fighter, bus := downedFighter(t)
+	log, err := combat.DeathSave(context.Background(), fighter, bus, fixedRoller(20))
+	if err != nil {
+		t.Fatalf("DeathSave: %v", err)
+	}
+	if fighter.GetHitPoints() != 1 || combat.Vital(fighter) != combat.VitalConscious {
+		t.Errorf("expected conscious at 1 HP, got %s at %d HP", combat.Vital(fighter), fighter.GetHitPoints())
+	}
+	if !strings.Contains(log, "regains consciousness") {
+		t.Errorf("expected the revival in the log, got %q", log)
+	}
+}
+
+func TestStabilize(t *testing.T) {
+	ctx := context.Background()
+	patient, _ := downedFighter(t)
+	healer := buildTestFighter(t)
+
+	if log, err := combat.Stabilize(ctx, healer, patient, fixedRoller(2)); err != nil || !strings.Contains(log, "FAILED") {
+		t.Fatalf("expected a failed check, got %q / %v", log, err)
+	}
+	if _, err := combat.Stabilize(ctx, healer, patient, fixedRoller(15)); err != nil {
+		t.Fatalf("Stabilize: %v", err)
+	}
+	if combat.Vital(patient) != combat.VitalStable {
+		t.Errorf("expected stable, got %s", combat.Vital(patient))
+	}
+	if _, err := combat.Stabilize(ctx, healer, healer, fixedRoller(15)); err == nil {
+		t.Error("expected an error stabilizing a conscious character")
+	}
+}
+
+func TestResolveSpell_HealRevivesDying(t *testing.T) {
+	ctx := context.Background()
+	fighter, bus := downedFighter(t)
+	if _, err := fighter.TakeDamageWhileUnconscious(ctx, &dnd5echar.TakeDamageWhileUnconsciousInput{}); err != nil {
+		t.Fatalf("TakeDamageWhileUnconscious: %v", err)
+	}
+	cleric, err := game.BuildDnDCharacter(ctx, game.CharacterCreationData{
+		Name:    "TestCleric",
+		RaceID:  "human",
+		ClassID: "cleric",
+		AbilityScores: map[string]int{
+			"strength": 14, "dexterity": 10, "constitution": 13,
+			"intelligence": 8, "wisdom": 15, "charisma": 12,
+		},
+		SelectedSkills:   []string{"medicine", "religion"},
+		SelectedCantrips: []string{"sacred-flame", "toll-the-dead", "light"},
+	})
+	if err != nil {
+		t.Fatalf("BuildDnDCharacter: %v", err)
+	}
+	enc, err := combat.NewEncounter(ctx, map[string]*dnd5echar.Character{"uid-1": cleric, "uid-2": fighter}, nil)
+	if err != nil {
+		t.Fatalf("NewEncounter: %v", err)
+	}
+	defer enc.Cleanup(ctx)
+	enc.PlayerBus = bus
+
+	out, err := combat.ResolveSpell(ctx, enc, combat.CastInput{
+		CasterID: "uid-1",
+		SpellID:  "cure-wounds",
+		TargetID: "uid-2",
+		Slots:    combat.MaxSpellSlots(classes.Cleric, 1),
+		Roller:   fixedRoller(4),
+	})
+	if err != nil {
+		t.Fatalf("ResolveSpell: %v", err)
+	}
+	if combat.Vital(fighter) != combat.VitalConscious || fighter.GetDeathSaveState().Failures != 0 {
+		t.Errorf("expected healing to revive and clear death saves, got %s with %+v", combat.Vital(fighter), fighter.GetDeathSaveState())
+	}
+	if !strings.Contains(out.CombatLog, "regains consciousness") {
+		t.Errorf("expected the revival in the log, got %q", out.CombatLog)
+	}
+}
+
+func TestRunMonsterTurn_AttackDamagesPlayer(t *testing.T) {
+	ctx := context.Background()
+	fighter := buildTestFighter(t)
+	goblin := monster.NewGoblin(uuid.NewString())
+	enc, err := combat.NewEncounter(ctx,
+		map[string]*dnd5echar.Character{"uid-1": fighter},
+		[]*monster.Monster{goblin},
+	)
+	if err != nil {
+		t.Fatalf("NewEncounter: %v", err)
+	}
+	defer enc.Cleanup(ctx)
+	enc.Roller = fixedRoller(20) // natural 20, max damage dice
+
+	log, err := combat.RunMonsterTurn(ctx, enc, goblin.GetID())
+	if err != nil {
+		t.Fatalf("RunMonsterTurn: %v", err)
+	}
+	// Scimitar crit: 1d6+2 with the die doubled → 6+6+2 = 14, more than a
+	// level-1 fighter's HP but less than twice it.
+	if !strings.Contains(log, "CRITICAL HIT") || !strings.Contains(log, "falls unconscious") {
+		t.Errorf("expected a critical knockout in the log, got %q", log)
+	}
+	if combat.Vital(fighter) != combat.VitalDying {
+		t.Errorf("expected the fighter to be dying, got %s at %d HP", combat.Vital(fighter), fighter.GetHitPoints())
+	}
+}
+
+// ---- Checks ----
+
+func TestRollCheck_SkillAddsProficiency(t *testing.T) {
+	fighter := buildTestFighter(t)
+	res, err := combat.RollCheck(context.Background(), fighter, "Athletics", 15, false, fixedRoller(10))
+	if err != nil {
+		t.Fatalf("RollCheck: %v", err)
+	}
+	strMod := fighter.GetAbilityModifier(abilities.STR)
+	if res.Modifier != strMod+fighter.ProficiencyBonus() {
+		t.Errorf("athletics modifier = %d, want STR %d + proficiency %d", res.Modifier, strMod, fighter.ProficiencyBonus())
+	}
+	if res.Roll != 10 || res.Total != 10+res.Modifier || !res.Success {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if !strings.Contains(res.String(), "Athletics check") || !strings.Contains(res.String(), "SUCCESS") {
+		t.Errorf("unexpected log line %q", res.String())
+	}
+}
+
+func TestRollCheck_UnproficientSkillAndAbility(t *testing.T) {
+	fighter := buildTestFighter(t)
+	ctx := context.Background()
+	dexMod := fighter.GetAbilityModifier(abilities.DEX)
+
+	res, err := combat.RollCheck(ctx, fighter, "sleight of hand", 10, false, fixedRoller(1))
+	if err != nil {
+		t.Fatalf("RollCheck: %v", err)
+	}
+	if res.Modifier != dexMod || res.Success {
+		t.Errorf("sleight of hand: got %+v, want modifier %d and a failure", res, dexMod)
+	}
+
+	res, err = combat.RollCheck(ctx, fighter, "dex", 10, false, fixedRoller(1))
+	if err != nil {
+		t.Fatalf("RollCheck: %v", err)
+	}
+	if res.Check != "Dexterity check" || res.Modifier != dexMod {
+		t.Errorf("dex check: got %+v", res)
+	}
+}
+
+func TestRollCheck_SavingThrow(t *testing.T) {
+	fighter := buildTestFighter(t)
+	ctx := context.Background()
+
+	// Fighters are proficient in Strength and Constitution saves.
+	res, err := combat.RollCheck(ctx, fighter, "strength", 12, true, fixedRoller(5))
+	if err != nil {
+		t.Fatalf("RollCheck: %v", err)
+	}
+	if res.Check != "Strength saving throw" || res.Modifier != fighter.GetSavingThrowModifier(abilities.STR) {
+		t.Errorf("strength save: got %+v", res)
+	}
+	if res.Modifier <= fighter.GetAbilityModifier(abilities.STR) {
+		t.Errorf("expected save proficiency in modifier %d", res.Modifier)
+	}
+
+	if _, err := combat.RollCheck(ctx, fighter, "stealth", 12, true, fixedRoller(5)); err == nil {
+		t.Error("expected an error for a skill saving throw")
+	}
+	if _, err := combat.RollCheck(ctx, fighter, "juggling", 12, false, fixedRoller(5)); err == nil {
+		t.Error("expected an error for an unknown skill")
+	}
+}
+
 // combat_test_attResult is a minimal stand-in for formatting test documentation.
 type combat_test_attResult struct {
 	AttackRoll  int
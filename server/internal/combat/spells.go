@@ -0,0 +1,475 @@
+package combat
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/KirkDiggler/rpg-toolkit/dice"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/abilities"
+	dnd5echar "github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/character"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/classes"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/damage"
+	dnd5eEvents "github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/events"
+)
+
+// SpellKind is how a spell resolves against its target.
+type SpellKind string
+
+const (
+	SpellAttack SpellKind = "attack" // ranged/melee spell attack vs AC
+	SpellSave   SpellKind = "save"   // target saves vs the caster's spell DC
+	SpellAuto   SpellKind = "auto"   // hits automatically (magic missile)
+	SpellHeal   SpellKind = "heal"   // restores hit points to a party member
+)
+
+// SpellDef describes one castable spell. Area spells resolve against the
+// chosen target only — there is no template on the grid.
+type SpellDef struct {
+	ID    string
+	Name  string
+	Level int // 0 = cantrip
+	Kind  SpellKind
+	// DiceCount × DiceSize is the base damage or healing. Cantrips scale the
+	// count at character levels 5, 11 and 17; each slot level above Level
+	// adds UpcastDice more dice.
+	DiceCount  int
+	DiceSize   int
+	UpcastDice int
+	// Bonus is added once per missile for SpellAuto (magic missile: 1d4+1 each).
+	Bonus int
+	// AddModifier adds the caster's spellcasting modifier (healing spells).
+	AddModifier bool
+	DamageType  damage.Type
+	SaveAbility abilities.Ability
+	HalfOnSave  bool
+	// WoundedDieSize replaces DiceSize when the target is already hurt (toll the dead).
+	WoundedDieSize int
+	RangeFeet      int // 5 = touch
+	Classes        []classes.Class
+}
+
+// Spells is the set of spells the combat subsystem can resolve, keyed by the
+// toolkit's kebab-case spell ID. Utility spells may be known by a character
+// but cannot be cast through the "cast" action.
+var Spells = map[string]SpellDef{
+	// Cantrips
+	"fire-bolt":      {ID: "fire-bolt", Name: "Fire Bolt", Kind: SpellAttack, DiceCount: 1, DiceSize: 10, DamageType: damage.Fire, RangeFeet: 120, Classes: []classes.Class{classes.Wizard}},
+	"ray-of-frost":   {ID: "ray-of-frost", Name: "Ray of Frost", Kind: SpellAttack, DiceCount: 1, DiceSize: 8, DamageType: damage.Cold, RangeFeet: 60, Classes: []classes.Class{classes.Wizard}},
+	"shocking-grasp": {ID: "shocking-grasp", Name: "Shocking Grasp", Kind: SpellAttack, DiceCount: 1, DiceSize: 8, DamageType: damage.Lightning, RangeFeet: 5, Classes: []classes.Class{classes.Wizard}},
+	"chill-touch":    {ID: "chill-touch", Name: "Chill Touch", Kind: SpellAttack, DiceCount: 1, DiceSize: 8, DamageType: damage.Necrotic, RangeFeet: 120, Classes: []classes.Class{classes.Wizard, classes.Warlock}},
+	"eldritch-blast": {ID: "eldritch-blast", Name: "Eldritch Blast", Kind: SpellAttack, DiceCount: 1, DiceSize: 10, DamageType: damage.Force, RangeFeet: 120, Classes: []classes.Class{classes.Warlock}},
+	"acid-splash":    {ID: "acid-splash", Name: "Acid Splash", Kind: SpellSave, DiceCount: 1, DiceSize: 6, DamageType: damage.Acid, SaveAbility: abilities.DEX, RangeFeet: 60, Classes: []classes.Class{classes.Wizard}},
+	"poison-spray":   {ID: "poison-spray", Name: "Poison Spray", Kind: SpellSave, DiceCount: 1, DiceSize: 12, DamageType: damage.Poison, SaveAbility: abilities.CON, RangeFeet: 10, Classes: []classes.Class{classes.Wizard, classes.Warlock}},
+	"sacred-flame":   {ID: "sacred-flame", Name: "Sacred Flame", Kind: SpellSave, DiceCount: 1, DiceSize: 8, DamageType: damage.Radiant, SaveAbility: abilities.DEX, RangeFeet: 60, Classes: []classes.Class{classes.Cleric}},
+	"toll-the-dead":  {ID: "toll-the-dead", Name: "Toll the Dead", Kind: SpellSave, DiceCount: 1, DiceSize: 8, WoundedDieSize: 12, DamageType: damage.Necrotic, SaveAbility: abilities.WIS, RangeFeet: 60, Classes: []classes.Class{classes.Cleric, classes.Warlock}},
+
+	// 1st level
+	"magic-missile":  {ID: "magic-missile", Name: "Magic Missile", Level: 1, Kind: SpellAuto, DiceCount: 3, DiceSize: 4, UpcastDice: 1, Bonus: 1, DamageType: damage.Force, RangeFeet: 120, Classes: []classes.Class{classes.Wizard}},
+	"burning-hands":  {ID: "burning-hands", Name: "Burning Hands", Level: 1, Kind: SpellSave, DiceCount: 3, DiceSize: 6, UpcastDice: 1, DamageType: damage.Fire, SaveAbility: abilities.DEX, HalfOnSave: true, RangeFeet: 15, Classes: []classes.Class{classes.Wizard}},
+	"thunderwave":    {ID: "thunderwave", Name: "Thunderwave", Level: 1, Kind: SpellSave, DiceCount: 2, DiceSize: 8, UpcastDice: 1, DamageType: damage.Thunder, SaveAbility: abilities.CON, HalfOnSave: true, RangeFeet: 15, Classes: []classes.Class{classes.Wizard}},
+	"witch-bolt":     {ID: "witch-bolt", Name: "Witch Bolt", Level: 1, Kind: SpellAttack, DiceCount: 1, DiceSize: 12, UpcastDice: 1, DamageType: damage.Lightning, RangeFeet: 30, Classes: []classes.Class{classes.Wizard, classes.Warlock}},
+	"arms-of-hadar":  {ID: "arms-of-hadar", Name: "Arms of Hadar", Level: 1, Kind: SpellSave, DiceCount: 2, DiceSize: 6, UpcastDice: 1, DamageType: damage.Necrotic, SaveAbility: abilities.STR, HalfOnSave: true, RangeFeet: 10, Classes: []classes.Class{classes.Warlock}},
+	"guiding-bolt":   {ID: "guiding-bolt", Name: "Guiding Bolt", Level: 1, Kind: SpellAttack, DiceCount: 4, DiceSize: 6, UpcastDice: 1, DamageType: damage.Radiant, RangeFeet: 120, Classes: []classes.Class{classes.Cleric}},
+	"inflict-wounds": {ID: "inflict-wounds", Name: "Inflict Wounds", Level: 1, Kind: SpellAttack, DiceCount: 3, DiceSize: 10, UpcastDice: 1, DamageType: damage.Necrotic, RangeFeet: 5, Classes: []classes.Class{classes.Cleric}},
+	"cure-wounds":    {ID: "cure-wounds", Name: "Cure Wounds", Level: 1, Kind: SpellHeal, DiceCount: 1, DiceSize: 8, UpcastDice: 1, AddModifier: true, RangeFeet: 5, Classes: []classes.Class{classes.Cleric}},
+	"healing-word":   {ID: "healing-word", Name: "Healing Word", Level: 1, Kind: SpellHeal, DiceCount: 1, DiceSize: 4, UpcastDice: 1, AddModifier: true, RangeFeet: 60, Classes: []classes.Class{classes.Cleric}},
+}
+
+// -------------------------------------------------------------------
+// Spell slots
+// -------------------------------------------------------------------
+
+// fullCasterSlots[level-1][slotLevel-1] is the number of slots a full caster
+// (cleric, wizard, ...) has at each character level (PHB p. 113).
+var fullCasterSlots = [20][]int{
+	{2}, {3}, {4, 2}, {4, 3}, {4, 3, 2},
+	{4, 3, 3}, {4, 3, 3, 1}, {4, 3, 3, 2}, {4, 3, 3, 3, 1}, {4, 3, 3, 3, 2},
+	{4, 3, 3, 3, 2, 1}, {4, 3, 3, 3, 2, 1}, {4, 3, 3, 3, 2, 1, 1}, {4, 3, 3, 3, 2, 1, 1}, {4, 3, 3, 3, 2, 1, 1, 1},
+	{4, 3, 3, 3, 2, 1, 1, 1}, {4, 3, 3, 3, 2, 1, 1, 1, 1}, {4, 3, 3, 3, 3, 1, 1, 1, 1}, {4, 3, 3, 3, 3, 2, 1, 1, 1}, {4, 3, 3, 3, 3, 2, 2, 1, 1},
+}
+
+// pactMagicSlots returns the warlock's slot count and slot level (Pact Magic:
+// every slot is the same level).
+func pactMagicSlots(level int) (count, slotLevel int) {
+	switch {
+	case level >= 17:
+		return 4, 5
+	case level >= 11:
+		return 3, 5
+	case level == 1:
+		return 1, 1
+	default:
+		return 2, min((level+1)/2, 5)
+	}
+}
+
+// MaxSpellSlots returns a fresh slot table for a class at a character level.
+// Non-casters get nil.
+func MaxSpellSlots(class classes.Class, level int) map[int]dnd5echar.SpellSlotData {
+	level = max(1, min(level, 20))
+	switch class {
+	case classes.Warlock:
+		count, slotLevel := pactMagicSlots(level)
+		return map[int]dnd5echar.SpellSlotData{slotLevel: {Max: count}}
+	case classes.Cleric, classes.Wizard, classes.Bard, classes.Druid, classes.Sorcerer:
+		slots := make(map[int]dnd5echar.SpellSlotData)
+		for i, n := range fullCasterSlots[level-1] {
+			slots[i+1] = dnd5echar.SpellSlotData{Max: n}
+		}
+		return slots
+	}
+	return nil
+}
+
+// ConsumeSlot spends one slot of at least minLevel. If want is non-zero that
+// exact slot level is used; otherwise the lowest available one. Returns the
+// slot level spent.
+func ConsumeSlot(slots map[int]dnd5echar.SpellSlotData, minLevel, want int) (int, error) {
+	if want != 0 {
+		if want < minLevel {
+			return 0, fmt.Errorf("a level %d spell cannot be cast with a level %d slot", minLevel, want)
+		}
+		s, ok := slots[want]
+		if !ok || s.Used >= s.Max {
+			return 0, fmt.Errorf("no level %d spell slots left", want)
+		}
+		s.Used++
+		slots[want] = s
+		return want, nil
+	}
+	levels := make([]int, 0, len(slots))
+	for lvl := range slots {
+		levels = append(levels, lvl)
+	}
+	sort.Ints(levels)
+	for _, lvl := range levels {
+		if s := slots[lvl]; lvl >= minLevel && s.Used < s.Max {
+			s.Used++
+			slots[lvl] = s
+			return lvl, nil
+		}
+	}
+	return 0, fmt.Errorf("no spell slots of level %d or higher left", minLevel)
+}
+
+// RecoverSlots restores spent slots after a rest. A long rest restores every
+// slot; a short rest only restores warlock Pact Magic slots.
+func RecoverSlots(class classes.Class, slots map[int]dnd5echar.SpellSlotData, longRest bool) {
+	if !longRest && class != classes.Warlock {
+		return
+	}
+	for lvl, s := range slots {
+		s.Used = 0
+		slots[lvl] = s
+	}
+}
+
+// CheckCastable returns an error if a character of class cannot cast spellID.
+// Cantrips and (for wizards and warlocks) leveled spells must be among
+// known; clerics prepare from their whole class list.
+func CheckCastable(class classes.Class, known []string, spellID string) (SpellDef, error) {
+	spell, ok := Spells[spellID]
+	if !ok {
+		return SpellDef{}, fmt.Errorf("%q cannot be cast in combat", spellID)
+	}
+	if !slices.Contains(spell.Classes, class) {
+		return SpellDef{}, fmt.Errorf("%s is not a %s spell", spell.Name, class)
+	}
+	if spell.Level > 0 && class == classes.Cleric {
+		return spell, nil
+	}
+	if !slices.Contains(known, spellID) {
+		return SpellDef{}, fmt.Errorf("you don't know %s", spell.Name)
+	}
+	return spell, nil
+}
+
+// -------------------------------------------------------------------
+// Resolution
+// -------------------------------------------------------------------
+
+// CastInput is the server-side input for a player casting a spell.
+type CastInput struct {
+	CasterID string // userID of the caster
+	SpellID  string
+	// TargetID is a monsterID for damaging spells and a userID for healing
+	// spells (empty heals the caster).
+	TargetID string
+	// SlotLevel picks the slot to spend; 0 uses the lowest available.
+	SlotLevel int
+	// Known lists the caster's cantrips and known/spellbook spells.
+	Known []string
+	// Slots is the caster's slot table; the spent slot is marked used in place.
+	Slots map[int]dnd5echar.SpellSlotData
+	// Roller is optional; nil uses the crypto roller.
+	Roller dice.Roller
+}
+
+// CastOutput is returned from ResolveSpell.
+type CastOutput struct {
+	Spell       SpellDef
+	SlotLevel   int // 0 for cantrips
+	Hit         bool
+	Critical    bool
+	Saved       bool
+	Damage      int
+	Healing     int
+	TargetName  string
+	TargetHP    int
+	TargetMaxHP int
+	TargetDied  bool
+	// CombatLog is the formatted string for injection into the Narrator prompt.
+	CombatLog string
+}
+
+// ResolveSpell casts a spell. Damaging spells target a monster in the
+// encounter; healing spells target a player (a dying one regains
+// consciousness) and are published on enc.PlayerBus. A slot is spent whenever a leveled spell is cast, even if it
+// misses.
+func ResolveSpell(ctx context.Context, enc *Encounter, in CastInput) (*CastOutput, error) {
+	caster, ok := enc.Players[in.CasterID]
+	if !ok {
+		return nil, fmt.Errorf("caster %s not found in encounter", in.CasterID)
+	}
+	class := caster.ToData().ClassID
+	classData := classes.ClassData[class]
+	if classData == nil || classData.SpellcastingAbility == "" {
+		return nil, fmt.Errorf("%s cannot cast spells", class)
+	}
+	spell, err := CheckCastable(class, in.Known, in.SpellID)
+	if err != nil {
+		return nil, err
+	}
+	mod := caster.GetAbilityModifier(classData.SpellcastingAbility)
+
+	targetID := in.TargetID
+	if spell.Kind == SpellHeal && targetID == "" {
+		targetID = in.CasterID
+	}
+	if err := checkSpellRange(enc, in.CasterID, targetID, spell); err != nil {
+		return nil, err
+	}
+
+	out := &CastOutput{Spell: spell}
+	var heal *dnd5echar.Character
+	var target interface {
+		GetID() string
+		GetHitPoints() int
+		GetMaxHitPoints() int
+	}
+	if spell.Kind == SpellHeal {
+		heal, ok = enc.Players[targetID]
+		if !ok {
+			return nil, fmt.Errorf("party member %s not found", targetID)
+		}
+		if enc.PlayerBus == nil {
+			return nil, fmt.Errorf("healing is unavailable right now")
+		}
+		if IsDead(heal) {
+			return nil, fmt.Errorf("%s is beyond the help of %s", heal.GetName(), spell.Name)
+		}
+		out.TargetName = heal.GetName()
+	} else {
+		m, ok := enc.Monsters[targetID]
+		if !ok {
+			return nil, fmt.Errorf("target monster %s not found in encounter", targetID)
+		}
+		if !m.IsAlive() {
+			return nil, fmt.Errorf("%s is already dead", m.Name())
+		}
+		target = m
+		out.TargetName = m.Name()
+	}
+
+	// Spend the slot last among the checks so a rejected cast costs nothing.
+	if spell.Level > 0 {
+		out.SlotLevel, err = ConsumeSlot(in.Slots, spell.Level, in.SlotLevel)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	roller := in.Roller
+	if roller == nil {
+		roller = dice.NewRoller()
+	}
+	count := spell.DiceCount
+	if spell.Level == 0 {
+		count *= cantripTier(caster.GetLevel())
+	} else {
+		count += spell.UpcastDice * (out.SlotLevel - spell.Level)
+	}
+	dieSize := spell.DiceSize
+	if spell.WoundedDieSize > 0 && target != nil && target.GetHitPoints() < target.GetMaxHitPoints() {
+		dieSize = spell.WoundedDieSize
+	}
+
+	var sb strings.Builder
+	slotNote := ""
+	if out.SlotLevel > 0 {
+		slotNote = fmt.Sprintf(" (level %d slot)", out.SlotLevel)
+	}
+	sb.WriteString(fmt.Sprintf("%s casts %s%s at %s.\n", caster.GetName(), spell.Name, slotNote, out.TargetName))
+
+	switch spell.Kind {
+	case SpellHeal:
+		rolls, err := roller.RollN(ctx, count, dieSize)
+		if err != nil {
+			return nil, fmt.Errorf("roll healing: %w", err)
+		}
+		bonus := 0
+		if spell.AddModifier {
+			bonus = mod
+		}
+		amount := sum(rolls) + bonus
+		if amount < 1 {
+			amount = 1
+		}
+		wasDown := heal.GetHitPoints() == 0
+		if err := dnd5eEvents.HealingReceivedTopic.On(enc.PlayerBus).Publish(ctx, dnd5eEvents.HealingReceivedEvent{
+			TargetID: heal.GetID(),
+			Amount:   amount,
+			Roll:     sum(rolls),
+			Modifier: bonus,
+			Source:   spell.ID,
+		}); err != nil {
+			return nil, fmt.Errorf("publish healing: %w", err)
+		}
+		out.Healing = amount
+		out.TargetHP = heal.GetHitPoints()
+		out.TargetMaxHP = heal.GetMaxHitPoints()
+		sb.WriteString(fmt.Sprintf("Healing: %d (dice: %v + %d)\n", amount, rolls, bonus))
+		sb.WriteString(fmt.Sprintf("%s HP: %d/%d\n", out.TargetName, out.TargetHP, out.TargetMaxHP))
+		if wasDown && out.TargetHP > 0 {
+			heal.ResetDeathSaveState()
+			sb.WriteString(fmt.Sprintf("%s regains consciousness.\n", out.TargetName))
+		}
+		out.CombatLog = sb.String()
+		return out, nil
+
+	case SpellAttack:
+		d20, err := roller.Roll(ctx, 20)
+		if err != nil {
+			return nil, fmt.Errorf("roll spell attack: %w", err)
+		}
+		bonus := caster.ProficiencyBonus() + mod
+		ac := enc.Monsters[targetID].AC()
+		out.Critical = d20 == 20
+		out.Hit = d20 != 1 && (out.Critical || d20+bonus >= ac)
+		sb.WriteString(fmt.Sprintf("Spell attack: %d + %d bonus = %d vs AC %d → ", d20, bonus, d20+bonus, ac))
+		switch {
+		case out.Critical:
+			sb.WriteString("CRITICAL HIT!\n")
+			count *= 2
+		case out.Hit:
+			sb.WriteString("HIT\n")
+		default:
+			sb.WriteString("MISS\n")
+			out.CombatLog = sb.String()
+			return out, nil
+		}
+
+	case SpellSave:
+		dc := 8 + caster.ProficiencyBonus() + mod
+		m := enc.Monsters[targetID]
+		saveMod := m.AbilityScores().Modifier(spell.SaveAbility)
+		d20, err := roller.Roll(ctx, 20)
+		if err != nil {
+			return nil, fmt.Errorf("roll saving throw: %w", err)
+		}
+		out.Saved = d20+saveMod >= dc
+		out.Hit = !out.Saved || spell.HalfOnSave
+		result := "FAILED"
+		if out.Saved {
+			result = "SAVED"
+		}
+		sb.WriteString(fmt.Sprintf("%s %s save: %d + %d = %d vs DC %d → %s\n",
+			m.Name(), strings.ToUpper(string(spell.SaveAbility)), d20, saveMod, d20+saveMod, dc, result))
+		if !out.Hit {
+			out.CombatLog = sb.String()
+			return out, nil
+		}
+
+	case SpellAuto:
+		out.Hit = true
+	}
+
+	rolls, err := roller.RollN(ctx, count, dieSize)
+	if err != nil {
+		return nil, fmt.Errorf("roll damage: %w", err)
+	}
+	amount := sum(rolls) + spell.Bonus*count
+	if out.Saved && spell.HalfOnSave {
+		amount /= 2
+	}
+	if amount > 0 {
+		if err := dnd5eEvents.DamageReceivedTopic.On(enc.Bus).Publish(ctx, dnd5eEvents.DamageReceivedEvent{
+			TargetID:   targetID,
+			SourceID:   caster.GetID(),
+			Amount:     amount,
+			DamageType: spell.DamageType,
+		}); err != nil {
+			return nil, fmt.Errorf("publish damage: %w", err)
+		}
+	}
+	out.Damage = amount
+	out.TargetHP = target.GetHitPoints()
+	out.TargetMaxHP = target.GetMaxHitPoints()
+	out.TargetDied = out.TargetHP == 0
+
+	halfNote := ""
+	if out.Saved {
+		halfNote = ", halved"
+	}
+	sb.WriteString(fmt.Sprintf("Damage: %d %s (dice: %v%s)\n", amount, spell.DamageType, rolls, halfNote))
+	sb.WriteString(fmt.Sprintf("%s HP: %d/%d", out.TargetName, out.TargetHP, out.TargetMaxHP))
+	if out.TargetDied {
+		sb.WriteString(" — DEFEATED")
+	}
+	sb.WriteString("\n")
+	out.CombatLog = sb.String()
+	return out, nil
+}
+
+// checkSpellRange returns an error if the target stands beyond the spell's
+// range. Without a grid (or unplaced combatants) range is not enforced.
+func checkSpellRange(enc *Encounter, casterID, targetID string, spell SpellDef) error {
+	if enc.Grid == nil || casterID == targetID {
+		return nil
+	}
+	dist, placed := enc.Grid.DistanceBetween(casterID, targetID)
+	if !placed {
+		return nil
+	}
+	reach := max(1, spell.RangeFeet/FeetPerHex)
+	if dist > reach {
+		return fmt.Errorf("target is %d ft away — out of range of %s (%d ft)",
+			dist*FeetPerHex, spell.Name, spell.RangeFeet)
+	}
+	return nil
+}
+
+// cantripTier is the cantrip damage dice multiplier at a character level.
+func cantripTier(level int) int {
+	switch {
+	case level >= 17:
+		return 4
+	case level >= 11:
+		return 3
+	case level >= 5:
+		return 2
+	default:
+		return 1
+	}
+}
+
+func sum(rolls []int) int {
+	total := 0
+	for _, r := range rolls {
+		total += r
+	}
+	return total
+}
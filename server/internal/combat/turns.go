@@ -0,0 +1,46 @@
+package combat
+
+import "time"
+
+// TurnTimeout is how long a player's turn may sit idle before it is skipped.
+// The server skips an expired turn when the next frame arrives from anyone
+// in the party (a game action, chat or ooc message).
+const TurnTimeout = 2 * time.Minute
+
+// TurnState is the persisted turn pointer for an active initiative order.
+// It lives alongside Game.InitiativeOrder and is cleared with it.
+type TurnState struct {
+	// RoomID is the room the fight is taking place in.
+	RoomID string `json:"room_id" dynamodbav:"room_id"`
+	// Index points at the combatant in InitiativeOrder whose turn it is.
+	Index int `json:"index" dynamodbav:"index"`
+	// Round starts at 1 and increments each time the order wraps.
+	Round int `json:"round" dynamodbav:"round"`
+	// StartedAt is when the current turn began (unix milliseconds).
+	StartedAt int64 `json:"started_at" dynamodbav:"started_at"`
+	// Moved is how many hexes the current combatant has moved this turn.
+	Moved int `json:"moved,omitempty" dynamodbav:"moved,omitempty"`
+}
+
+// Deadline returns when the current turn times out.
+func (t TurnState) Deadline() time.Time {
+	return time.UnixMilli(t.StartedAt).Add(TurnTimeout)
+}
+
+// NextTurn returns the index of the first combatant after idx for which
+// canAct returns true, and whether the search wrapped past the end of the
+// order (i.e. a new round began). Returns -1 if nobody can act.
+func NextTurn(order []InitiativeEntry, idx int, canAct func(InitiativeEntry) bool) (next int, wrapped bool) {
+	n := len(order)
+	for step := 1; step <= n; step++ {
+		i := idx + step
+		if i >= n {
+			wrapped = true
+			i -= n
+		}
+		if canAct(order[i]) {
+			return i, wrapped
+		}
+	}
+	return -1, wrapped
+}
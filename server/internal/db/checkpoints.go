@@ -0,0 +1,237 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+)
+
+// Checkpoint is a named save point of a session: a copy of its SaveState
+// taken at one moment, stored apart from the live session item.
+// PK: session_id (B), SK: checkpoint_id (S).
+type Checkpoint struct {
+	SessionID         string
+	CheckpointID      string
+	Name              string
+	CreatedBy         string
+	CreatedAt         int64 // Unix ms
+	ConversationCount int   // the session's turn when it was taken
+	// State is empty in ListCheckpoints results.
+	State game.SaveState
+}
+
+// checkpointDB is the DynamoDB form of Checkpoint; the state is stored with
+// the same Binary keys as the sessions table, and like a session item points
+// into the session's log for its narrative and chat history.
+type checkpointDB struct {
+	SessionID         BinaryID     `dynamodbav:"session_id"`
+	CheckpointID      string       `dynamodbav:"checkpoint_id"`
+	Name              string       `dynamodbav:"name"`
+	CreatedBy         BinaryID     `dynamodbav:"created_by"`
+	CreatedAt         int64        `dynamodbav:"created_at"`
+	ConversationCount int          `dynamodbav:"conversation_count"`
+	State             *saveStateDB `dynamodbav:"state,omitempty"`
+}
+
+// ErrCheckpointLimit is returned by PutCheckpoint when the session already
+// keeps as many checkpoints as the limit allows.
+var ErrCheckpointLimit = errors.New("checkpoint limit reached")
+
+// checkpointCountID is the checkpoint_id of the item in each session's
+// partition that counts its checkpoints, so PutCheckpoint can enforce the
+// limit in the same write. ListCheckpoints skips it.
+const checkpointCountID = "#count"
+
+func toCheckpointDB(ctx context.Context, l sessionLog, cp Checkpoint) (checkpointDB, error) {
+	state, err := toLoggedState(ctx, l, cp.State)
+	if err != nil {
+		return checkpointDB{}, err
+	}
+	return checkpointDB{
+		SessionID:         BinaryID(cp.SessionID),
+		CheckpointID:      cp.CheckpointID,
+		Name:              cp.Name,
+		CreatedBy:         BinaryID(cp.CreatedBy),
+		CreatedAt:         cp.CreatedAt,
+		ConversationCount: cp.ConversationCount,
+		State:             &state,
+	}, nil
+}
+
+func fromCheckpointDB(ctx context.Context, l sessionLog, row checkpointDB) (Checkpoint, error) {
+	cp := Checkpoint{
+		SessionID:         string(row.SessionID),
+		CheckpointID:      row.CheckpointID,
+		Name:              row.Name,
+		CreatedBy:         string(row.CreatedBy),
+		CreatedAt:         row.CreatedAt,
+		ConversationCount: row.ConversationCount,
+	}
+	if row.State != nil {
+		state, err := fromLoggedState(ctx, l, *row.State)
+		if err != nil {
+			return Checkpoint{}, err
+		}
+		cp.State = state
+	}
+	return cp, nil
+}
+
+func checkpointItemKey(sessionID, checkpointID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"session_id":    binaryIDVal(sessionID),
+		"checkpoint_id": &types.AttributeValueMemberS{Value: checkpointID},
+	}
+}
+
+// PutCheckpoint writes a new checkpoint unless the session already keeps
+// limit of them (ErrCheckpointLimit). The checkpoint and the session's count
+// are written in one transaction, so concurrent writes cannot pass the limit.
+func (c *Client) PutCheckpoint(ctx context.Context, cp Checkpoint, limit int) error {
+	c.requireCheckpointsTable()
+	row, err := toCheckpointDB(ctx, c, cp)
+	if err != nil {
+		return fmt.Errorf("PutCheckpoint: %w", err)
+	}
+	item, err := attributevalue.MarshalMap(row)
+	if err != nil {
+		return fmt.Errorf("PutCheckpoint marshal: %w", err)
+	}
+	_, err = c.ddb.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Update: &types.Update{
+				TableName:           aws.String(c.checkpointsTable),
+				Key:                 checkpointItemKey(cp.SessionID, checkpointCountID),
+				UpdateExpression:    aws.String("ADD checkpoints :one"),
+				ConditionExpression: aws.String("attribute_not_exists(checkpoints) OR checkpoints < :limit"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":one":   &types.AttributeValueMemberN{Value: "1"},
+					":limit": &types.AttributeValueMemberN{Value: strconv.Itoa(limit)},
+				},
+			}},
+			{Put: &types.Put{
+				TableName:           aws.String(c.checkpointsTable),
+				Item:                item,
+				ConditionExpression: aws.String("attribute_not_exists(checkpoint_id)"),
+			}},
+		},
+	})
+	if err != nil {
+		if firstConditionFailed(err) {
+			return ErrCheckpointLimit
+		}
+		return fmt.Errorf("PutCheckpoint: %w", err)
+	}
+	return nil
+}
+
+// GetCheckpoint loads one checkpoint with its state.
+func (c *Client) GetCheckpoint(ctx context.Context, sessionID, checkpointID string) (Checkpoint, error) {
+	c.requireCheckpointsTable()
+	out, err := c.ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(c.checkpointsTable),
+		Key:       checkpointItemKey(sessionID, checkpointID),
+	})
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("GetCheckpoint: %w", err)
+	}
+	if out.Item == nil {
+		return Checkpoint{}, fmt.Errorf("checkpoint not found: %s", checkpointID)
+	}
+	var row checkpointDB
+	if err := attributevalue.UnmarshalMap(out.Item, &row); err != nil {
+		return Checkpoint{}, fmt.Errorf("GetCheckpoint unmarshal: %w", err)
+	}
+	cp, err := fromCheckpointDB(ctx, c, row)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("GetCheckpoint: %w", err)
+	}
+	return cp, nil
+}
+
+// ListCheckpoints returns a session's checkpoints without their states,
+// paging through the whole partition.
+func (c *Client) ListCheckpoints(ctx context.Context, sessionID string) ([]Checkpoint, error) {
+	c.requireCheckpointsTable()
+	var (
+		checkpoints []Checkpoint
+		startKey    map[string]types.AttributeValue
+	)
+	for {
+		out, err := c.ddb.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(c.checkpointsTable),
+			KeyConditionExpression: aws.String("session_id = :sid"),
+			ProjectionExpression:   aws.String("session_id, checkpoint_id, #n, created_by, created_at, conversation_count"),
+			ExpressionAttributeNames: map[string]string{
+				"#n": "name", // reserved word
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":sid": binaryIDVal(sessionID),
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("ListCheckpoints: %w", err)
+		}
+		for _, item := range out.Items {
+			var row checkpointDB
+			if err := attributevalue.UnmarshalMap(item, &row); err != nil || row.CheckpointID == checkpointCountID {
+				continue
+			}
+			cp, err := fromCheckpointDB(ctx, c, row)
+			if err != nil {
+				continue
+			}
+			checkpoints = append(checkpoints, cp)
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return checkpoints, nil
+		}
+		startKey = out.LastEvaluatedKey
+	}
+}
+
+// DeleteCheckpoint removes one checkpoint and takes it off the session's
+// count. A checkpoint that does not exist is not an error.
+func (c *Client) DeleteCheckpoint(ctx context.Context, sessionID, checkpointID string) error {
+	c.requireCheckpointsTable()
+	_, err := c.ddb.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Delete: &types.Delete{
+				TableName:           aws.String(c.checkpointsTable),
+				Key:                 checkpointItemKey(sessionID, checkpointID),
+				ConditionExpression: aws.String("attribute_exists(checkpoint_id)"),
+			}},
+			{Update: &types.Update{
+				TableName:        aws.String(c.checkpointsTable),
+				Key:              checkpointItemKey(sessionID, checkpointCountID),
+				UpdateExpression: aws.String("ADD checkpoints :minus"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":minus": &types.AttributeValueMemberN{Value: "-1"},
+				},
+			}},
+		},
+	})
+	if err != nil {
+		if firstConditionFailed(err) {
+			return nil // already gone
+		}
+		return fmt.Errorf("DeleteCheckpoint: %w", err)
+	}
+	return nil
+}
+
+// firstConditionFailed reports whether err is a canceled transaction whose
+// first item failed its condition.
+func firstConditionFailed(err error) bool {
+	var canceled *types.TransactionCanceledException
+	return errors.As(err, &canceled) && len(canceled.CancellationReasons) > 0 &&
+		aws.ToString(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed"
+}
@@ -4,15 +4,18 @@ package db
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"time"
 
 	dnd5echar "github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/character"
 	dnd5emonster "github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/monster"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/rrochlin/an-amazing-adventure/internal/combat"
@@ -28,6 +31,9 @@ type Client struct {
 	usersTable       string
 	invitesTable     string
 	membershipsTable string
+	checkpointsTable string
+	sessionLogTable  string
+	leasesTable      string
 }
 
 // New creates a Client from the current AWS environment.
@@ -47,6 +53,9 @@ func New(ctx context.Context) (*Client, error) {
 		usersTable:       os.Getenv("USERS_TABLE"),       // checked at use
 		invitesTable:     os.Getenv("INVITES_TABLE"),     // checked at use
 		membershipsTable: os.Getenv("MEMBERSHIPS_TABLE"), // checked at use
+		checkpointsTable: os.Getenv("CHECKPOINTS_TABLE"), // checked at use
+		sessionLogTable:  os.Getenv("SESSION_LOG_TABLE"), // checked at use
+		leasesTable:      os.Getenv("LEASES_TABLE"),      // checked at use
 	}, nil
 }
 
@@ -93,12 +102,35 @@ func (c *Client) requireMembershipsTable() {
 	}
 }
 
+// requireCheckpointsTable panics with a clear message if CHECKPOINTS_TABLE was not set.
+func (c *Client) requireCheckpointsTable() {
+	if c.checkpointsTable == "" {
+		panic("required env var CHECKPOINTS_TABLE is not set")
+	}
+}
+
+// requireSessionLogTable panics with a clear message if SESSION_LOG_TABLE was not set.
+func (c *Client) requireSessionLogTable() {
+	if c.sessionLogTable == "" {
+		panic("required env var SESSION_LOG_TABLE is not set")
+	}
+}
+
+// requireLeasesTable panics with a clear message if LEASES_TABLE was not set.
+func (c *Client) requireLeasesTable() {
+	if c.leasesTable == "" {
+		panic("required env var LEASES_TABLE is not set")
+	}
+}
+
 // -------------------------------------------------------------------
 // Game sessions
 // -------------------------------------------------------------------
 
 // saveStateDB is a DynamoDB-specific wrapper around game.SaveState that
 // overrides the key fields to marshal as Binary (B), matching the table schema.
+// From v5 the narrative and chat history live in the session log and the item
+// only holds their ranges; see toLoggedState.
 type saveStateDB struct {
 	SessionID            BinaryID                     `dynamodbav:"session_id"`
 	OwnerID              BinaryID                     `dynamodbav:"owner_id,omitempty"`
@@ -113,8 +145,8 @@ type saveStateDB struct {
 	Rooms                []game.Area                  `dynamodbav:"rooms"`
 	Items                []game.Item                  `dynamodbav:"items"`
 	NPCs                 []game.Character             `dynamodbav:"npcs"`
-	Narrative            []game.NarrativeMessage      `dynamodbav:"narrative"`
-	ChatHistory          []game.ChatMessage           `dynamodbav:"chat_history"`
+	Narrative            []game.NarrativeMessage      `dynamodbav:"narrative,omitempty"`    // v1–v4
+	ChatHistory          []game.ChatMessage           `dynamodbav:"chat_history,omitempty"` // v1–v4
 	Ready                bool                         `dynamodbav:"ready"`
 	Title                string                       `dynamodbav:"title,omitempty"`
 	Theme                string                       `dynamodbav:"theme,omitempty"`
@@ -125,12 +157,29 @@ type saveStateDB struct {
 	LegacyCreationParams game.AdventureCreationParams `dynamodbav:"legacy_creation_params,omitempty"` // v1/v2
 
 	// Combat state (v3+) — previously missing from saveStateDB, fixed in v4.
-	RoomMonsters         map[string][]*dnd5emonster.Data `dynamodbav:"room_monsters,omitempty"`
-	PendingCombatContext string                          `dynamodbav:"pending_combat_context,omitempty"`
-	InitiativeOrder      []combat.InitiativeEntry        `dynamodbav:"initiative_order,omitempty"`
+	RoomMonsters         map[string][]*dnd5emonster.Data      `dynamodbav:"room_monsters,omitempty"`
+	PendingCombatContext string                               `dynamodbav:"pending_combat_context,omitempty"`
+	InitiativeOrder      []combat.InitiativeEntry             `dynamodbav:"initiative_order,omitempty"`
+	Turn                 *combat.TurnState                    `dynamodbav:"turn,omitempty"`
+	HideMonsterHP        bool                                 `dynamodbav:"hide_monster_hp,omitempty"`
+	KnownMonsterAC       map[string]bool                      `dynamodbav:"known_monster_ac,omitempty"`
+	Positions            map[string]map[string]combat.GridPos `dynamodbav:"positions,omitempty"`
+	KnownSpells          map[string][]string                  `dynamodbav:"known_spells,omitempty"`
 
 	// Dungeon layout (v4+)
 	DungeonData *game.DungeonData `dynamodbav:"dungeon_data,omitempty"`
+
+	// Session log ranges (v5+). ChatLog covers the ChatHistory loaded with
+	// the session, OlderChat the messages before it.
+	NarrativeLog []game.LogRange `dynamodbav:"narrative_log,omitempty"`
+	ChatLog      []game.LogRange `dynamodbav:"chat_log,omitempty"`
+	OlderChat    []game.LogRange `dynamodbav:"older_chat,omitempty"`
+	// OlderWhispers counts the whispers in OlderChat by user ID.
+	OlderWhispers map[string]int `dynamodbav:"older_whispers,omitempty"`
+
+	// Action rounds
+	RoundMode   bool              `dynamodbav:"round_mode,omitempty"`
+	ActionRound *game.ActionRound `dynamodbav:"action_round,omitempty"`
 }
 
 func toDBState(s game.SaveState) saveStateDB {
@@ -148,8 +197,6 @@ func toDBState(s game.SaveState) saveStateDB {
 		Rooms:                s.Rooms,
 		Items:                s.Items,
 		NPCs:                 s.NPCs,
-		Narrative:            s.Narrative,
-		ChatHistory:          s.ChatHistory,
 		Ready:                s.Ready,
 		Title:                s.Title,
 		Theme:                s.Theme,
@@ -161,7 +208,16 @@ func toDBState(s game.SaveState) saveStateDB {
 		RoomMonsters:         s.RoomMonsters,
 		PendingCombatContext: s.PendingCombatContext,
 		InitiativeOrder:      s.InitiativeOrder,
+		Turn:                 s.Turn,
+		HideMonsterHP:        s.HideMonsterHP,
+		KnownMonsterAC:       s.KnownMonsterAC,
+		Positions:            s.Positions,
+		KnownSpells:          s.KnownSpells,
 		DungeonData:          s.DungeonData,
+		OlderChat:            s.OlderChat,
+		OlderWhispers:        s.OlderWhispers,
+		RoundMode:            s.RoundMode,
+		ActionRound:          s.ActionRound,
 	}
 }
 
@@ -193,16 +249,31 @@ func fromDBState(d saveStateDB) game.SaveState {
 		RoomMonsters:         d.RoomMonsters,
 		PendingCombatContext: d.PendingCombatContext,
 		InitiativeOrder:      d.InitiativeOrder,
+		Turn:                 d.Turn,
+		HideMonsterHP:        d.HideMonsterHP,
+		KnownMonsterAC:       d.KnownMonsterAC,
+		Positions:            d.Positions,
+		KnownSpells:          d.KnownSpells,
 		DungeonData:          d.DungeonData,
+		OlderChat:            d.OlderChat,
+		OlderWhispers:        d.OlderWhispers,
+		RoundMode:            d.RoundMode,
+		ActionRound:          d.ActionRound,
 	}
 }
 
 // PutGame writes a SaveState to DynamoDB using optimistic locking.
 // If the current version in the DB doesn't match state.Version, the write
 // is rejected with a ConditionalCheckFailedException — caller should retry.
+// New narrative and chat messages are appended to the session log first; a
+// rejected write leaves them there unreferenced.
 func (c *Client) PutGame(ctx context.Context, state game.SaveState) error {
 	c.requireSessionsTable()
-	item, err := attributevalue.MarshalMap(toDBState(state))
+	d, err := toLoggedState(ctx, c, state)
+	if err != nil {
+		return fmt.Errorf("put game: %w", err)
+	}
+	item, err := attributevalue.MarshalMap(d)
 	if err != nil {
 		return fmt.Errorf("marshal save state: %w", err)
 	}
@@ -230,7 +301,8 @@ func (c *Client) PutGame(ctx context.Context, state game.SaveState) error {
 	return nil
 }
 
-// GetGame loads and deserialises a full SaveState by session UUID string.
+// GetGame loads and deserialises a full SaveState by session UUID string,
+// with its narrative and the recent chat history from the session log.
 func (c *Client) GetGame(ctx context.Context, sessionID string) (game.SaveState, error) {
 	c.requireSessionsTable()
 	out, err := c.ddb.GetItem(ctx, &dynamodb.GetItemInput{
@@ -247,7 +319,11 @@ func (c *Client) GetGame(ctx context.Context, sessionID string) (game.SaveState,
 	if err := attributevalue.UnmarshalMap(out.Item, &d); err != nil {
 		return game.SaveState{}, fmt.Errorf("unmarshal game: %w", err)
 	}
-	return fromDBState(d), nil
+	s, err := fromLoggedState(ctx, c, d)
+	if err != nil {
+		return game.SaveState{}, fmt.Errorf("get game: %w", err)
+	}
+	return s, nil
 }
 
 // GetGameReady does a projection-only read to check the ready flag cheaply.
@@ -276,7 +352,42 @@ func (c *Client) GetGameReady(ctx context.Context, sessionID string) (bool, erro
 	return partial.Ready, nil
 }
 
-// ListGames returns all SaveState summaries for a given user ID.
+// GetGameAccess does a projection-only read of who may access a session:
+// only SessionID, OwnerID, UserID and Players are filled in. Routes that
+// authorize a member before reading something else of the session use it
+// instead of GetGame, which also loads the narrative and chat.
+func (c *Client) GetGameAccess(ctx context.Context, sessionID string) (game.SaveState, error) {
+	c.requireSessionsTable()
+	out, err := c.ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:            aws.String(c.sessionsTable),
+		Key:                  sessionKey(sessionID),
+		ProjectionExpression: aws.String("session_id, owner_id, user_id, players"),
+	})
+	if err != nil {
+		return game.SaveState{}, fmt.Errorf("get game access: %w", err)
+	}
+	if out.Item == nil {
+		return game.SaveState{}, fmt.Errorf("game not found: %s", sessionID)
+	}
+	var d saveStateDB
+	if err := attributevalue.UnmarshalMap(out.Item, &d); err != nil {
+		return game.SaveState{}, fmt.Errorf("unmarshal game access: %w", err)
+	}
+	return gameAccess(d), nil
+}
+
+// gameAccess returns the access fields of the session item d.
+func gameAccess(d saveStateDB) game.SaveState {
+	return game.SaveState{
+		SessionID: string(d.SessionID),
+		OwnerID:   string(d.OwnerID),
+		UserID:    string(d.UserID),
+		Players:   d.Players,
+	}
+}
+
+// ListGames returns all SaveState summaries for a given user ID. Narrative
+// and chat history are not loaded from the session log.
 func (c *Client) ListGames(ctx context.Context, userID string) ([]game.SaveState, error) {
 	c.requireSessionsTable()
 	out, err := c.ddb.Query(ctx, &dynamodb.QueryInput{
@@ -351,7 +462,8 @@ func (c *Client) ListGamesByOwner(ctx context.Context, userID string) ([]string,
 }
 
 // BatchGetSessions retrieves multiple sessions by their IDs in a single batch request.
-// Sessions not found in DynamoDB are silently skipped.
+// Sessions not found in DynamoDB are silently skipped. Like ListGames it
+// returns summaries, without narrative or chat history.
 func (c *Client) BatchGetSessions(ctx context.Context, sessionIDs []string) ([]game.SaveState, error) {
 	c.requireSessionsTable()
 	if len(sessionIDs) == 0 {
@@ -395,41 +507,154 @@ func (c *Client) BatchGetSessions(ctx context.Context, sessionIDs []string) ([]g
 
 // mutationEntryDB is the DynamoDB wire format for a MutationEntry.
 // SessionID must be Binary (B) to match the mutations table key schema.
+// The state patch is stored as a JSON string: it is opaque to DynamoDB and
+// a string attribute keeps it well clear of the nested-attribute depth limit.
 type mutationEntryDB struct {
-	SessionID string         `dynamodbav:"session_id"` // B via BinaryID marshal below
+	SessionID BinaryID       `dynamodbav:"session_id"`
 	Ts        int64          `dynamodbav:"ts"`
 	Turn      int            `dynamodbav:"turn"`
 	Tool      string         `dynamodbav:"tool"`
 	Input     map[string]any `dynamodbav:"input"`
 	Result    string         `dynamodbav:"result"`
+	Type      string         `dynamodbav:"type,omitempty"`
+	Actor     string         `dynamodbav:"actor,omitempty"`
+	Version   int            `dynamodbav:"version,omitempty"`
+	Seq       int            `dynamodbav:"seq,omitempty"`
+	Patch     string         `dynamodbav:"patch,omitempty"`
 }
 
-// PutMutation writes a single MutationEntry to the mutations table.
-// SessionID is marshaled as Binary (B) to match the table's key schema.
+func toMutationDB(e game.MutationEntry) (mutationEntryDB, error) {
+	row := mutationEntryDB{
+		SessionID: BinaryID(e.SessionID),
+		Ts:        e.Ts,
+		Turn:      e.Turn,
+		Tool:      e.Tool,
+		Input:     e.Input,
+		Result:    e.Result,
+		Type:      string(e.Type),
+		Actor:     e.Actor,
+		Version:   e.Version,
+		Seq:       e.Seq,
+	}
+	if e.Patch != nil {
+		raw, err := json.Marshal(e.Patch)
+		if err != nil {
+			return mutationEntryDB{}, fmt.Errorf("marshal patch: %w", err)
+		}
+		row.Patch = string(raw)
+	}
+	return row, nil
+}
+
+func fromMutationDB(row mutationEntryDB) (game.MutationEntry, error) {
+	e := game.MutationEntry{
+		SessionID: string(row.SessionID),
+		Ts:        row.Ts,
+		Turn:      row.Turn,
+		Tool:      row.Tool,
+		Input:     row.Input,
+		Result:    row.Result,
+		Type:      game.EventType(row.Type),
+		Actor:     row.Actor,
+		Version:   row.Version,
+		Seq:       row.Seq,
+	}
+	if row.Patch != "" {
+		e.Patch = &game.StatePatch{}
+		if err := json.Unmarshal([]byte(row.Patch), e.Patch); err != nil {
+			return game.MutationEntry{}, fmt.Errorf("unmarshal patch: %w", err)
+		}
+	}
+	return e, nil
+}
+
+// maxMutationTsRetries bounds how far PutMutation walks forward looking for
+// a free timestamp.
+const maxMutationTsRetries = 8
+
+// PutMutation writes a single MutationEntry to the mutations table. Entries
+// are never overwritten: when another writer already holds (session_id, ts)
+// the entry moves to the next millisecond. Replay orders events by version
+// and seq, so the shifted timestamp does not matter.
 func (c *Client) PutMutation(ctx context.Context, entry game.MutationEntry) error {
 	c.requireMutationsTable()
-	row := mutationEntryDB{
-		SessionID: entry.SessionID,
-		Ts:        entry.Ts,
-		Turn:      entry.Turn,
-		Tool:      entry.Tool,
-		Input:     entry.Input,
-		Result:    entry.Result,
-	}
-	item, err := attributevalue.MarshalMap(row)
+	row, err := toMutationDB(entry)
 	if err != nil {
-		return fmt.Errorf("marshal mutation entry: %w", err)
+		return err
 	}
-	// Override SessionID key attribute to Binary type
-	item["session_id"] = binaryIDVal(entry.SessionID)
-	_, err = c.ddb.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(c.mutationsTable),
-		Item:      item,
-	})
-	if err != nil {
-		return fmt.Errorf("put mutation: %w", err)
+	for range maxMutationTsRetries {
+		item, err := attributevalue.MarshalMap(row)
+		if err != nil {
+			return fmt.Errorf("marshal mutation entry: %w", err)
+		}
+		_, err = c.ddb.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:           aws.String(c.mutationsTable),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(ts)"),
+		})
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			row.Ts++
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("put mutation: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("put mutation: no free timestamp after %d", entry.Ts)
+}
+
+// ListMutations returns every entry in a session's mutation log, oldest
+// first. Entries that fail to decode are skipped.
+func (c *Client) ListMutations(ctx context.Context, sessionID string) ([]game.MutationEntry, error) {
+	c.requireMutationsTable()
+	var entries []game.MutationEntry
+	var lastKey map[string]types.AttributeValue
+	for {
+		out, err := c.ddb.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(c.mutationsTable),
+			KeyConditionExpression: aws.String("session_id = :sid"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":sid": binaryIDVal(sessionID),
+			},
+			ExclusiveStartKey: lastKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("ListMutations: %w", err)
+		}
+		for _, item := range out.Items {
+			var row mutationEntryDB
+			if err := attributevalue.UnmarshalMap(item, &row); err != nil {
+				continue
+			}
+			e, err := fromMutationDB(row)
+			if err != nil {
+				continue
+			}
+			entries = append(entries, e)
+		}
+		if out.LastEvaluatedKey == nil {
+			break
+		}
+		lastKey = out.LastEvaluatedKey
+	}
+	return entries, nil
+}
+
+// PutEvents writes the events an invocation recorded in events, stamped with
+// the version they were saved in. Call it only after PutGame succeeded.
+// Failures are logged rather than returned: the session itself is already
+// saved.
+func PutEvents(ctx context.Context, store MutationStore, events *game.EventLog, sessionID string, version int) {
+	if events == nil {
+		return
+	}
+	for _, e := range events.Entries(sessionID, version, time.Now()) {
+		if err := store.PutMutation(ctx, e); err != nil {
+			log.Printf("db: put event %s v%d#%d (non-fatal): %v", e.Type, e.Version, e.Seq, err)
+		}
 	}
-	return nil
 }
 
 // -------------------------------------------------------------------
@@ -443,7 +668,6 @@ type Connection struct {
 	UserID       BinaryID `dynamodbav:"user_id"`
 	GameID       string   `dynamodbav:"game_id"`
 	ExpiresAt    int64    `dynamodbav:"expires_at"` // Unix epoch seconds; TTL field
-	Streaming    bool     `dynamodbav:"streaming"`  // true while AI is generating
 }
 
 // PutConnection writes or replaces a connection record.
@@ -617,24 +841,3 @@ func (c *Client) GetConnectionByUserID(ctx context.Context, userID string) (Conn
 	}
 	return conn, nil
 }
-
-// SetStreaming atomically sets the streaming flag on a connection record.
-func (c *Client) SetStreaming(ctx context.Context, connectionID string, streaming bool) error {
-	c.requireConnectionsTable()
-	v, _ := attributevalue.Marshal(connectionID)
-	sv, _ := attributevalue.Marshal(streaming)
-	update := expression.Set(expression.Name("streaming"), expression.Value(streaming))
-	expr, err := expression.NewBuilder().WithUpdate(update).Build()
-	if err != nil {
-		return err
-	}
-	_, err = c.ddb.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-		TableName:                 aws.String(c.connectionsTable),
-		Key:                       map[string]types.AttributeValue{"connection_id": v},
-		UpdateExpression:          expr.Update(),
-		ExpressionAttributeNames:  expr.Names(),
-		ExpressionAttributeValues: expr.Values(),
-	})
-	_ = sv
-	return err
-}
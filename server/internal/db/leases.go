@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrLeaseHeld is returned by AcquireLease while another connection holds a
+// live lease on the session.
+var ErrLeaseHeld = errors.New("streaming lease held")
+
+// Lease is a session's streaming lock. While it is live one connection is
+// running a narrator turn and the rest of the party's chats and actions are
+// refused; ws-game-action also takes it for the moment it saves an action
+// that changes the world. A lease that has expired is stale — its turn
+// crashed or timed out without releasing it — and the next AcquireLease
+// takes it over.
+// PK: session_id (B).
+type Lease struct {
+	SessionID BinaryID `dynamodbav:"session_id"`
+	Holder    string   `dynamodbav:"holder"`     // connection ID running the turn (suffixed "/game_action" for an action)
+	UserID    BinaryID `dynamodbav:"user_id"`    // who started the turn
+	ExpiresAt int64    `dynamodbav:"expires_at"` // Unix epoch seconds; TTL field
+}
+
+// Live reports whether the lease is still held at now.
+func (l Lease) Live(now time.Time) bool {
+	return now.Unix() < l.ExpiresAt
+}
+
+func leaseKey(sessionID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{"session_id": binaryIDVal(sessionID)}
+}
+
+// AcquireLease writes lease unless the session already has a live lease held
+// by another connection (ErrLeaseHeld). A stale lease is taken over, and the
+// holder may acquire again to extend its own.
+func (c *Client) AcquireLease(ctx context.Context, lease Lease) error {
+	c.requireLeasesTable()
+	item, err := attributevalue.MarshalMap(lease)
+	if err != nil {
+		return fmt.Errorf("marshal lease: %w", err)
+	}
+	_, err = c.ddb.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(c.leasesTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(session_id) OR expires_at <= :now OR holder = :holder"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now":    &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+			":holder": &types.AttributeValueMemberS{Value: lease.Holder},
+		},
+	})
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return ErrLeaseHeld
+	}
+	if err != nil {
+		return fmt.Errorf("acquire lease: %w", err)
+	}
+	return nil
+}
+
+// ReleaseLease deletes the session's lease if holder still holds it. A lease
+// that was taken over or force-released in the meantime is left alone.
+func (c *Client) ReleaseLease(ctx context.Context, sessionID, holder string) error {
+	c.requireLeasesTable()
+	_, err := c.ddb.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(c.leasesTable),
+		Key:                 leaseKey(sessionID),
+		ConditionExpression: aws.String("holder = :holder"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":holder": &types.AttributeValueMemberS{Value: holder},
+		},
+	})
+	var ccf *types.ConditionalCheckFailedException
+	if err != nil && !errors.As(err, &ccf) {
+		return fmt.Errorf("release lease: %w", err)
+	}
+	return nil
+}
+
+// GetLease returns the session's live lease, or nil when it has none. TTL
+// deletes lag, so expired leases are filtered here.
+func (c *Client) GetLease(ctx context.Context, sessionID string) (*Lease, error) {
+	c.requireLeasesTable()
+	out, err := c.ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(c.leasesTable),
+		Key:            leaseKey(sessionID),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get lease: %w", err)
+	}
+	if out.Item == nil {
+		return nil, nil
+	}
+	var lease Lease
+	if err := attributevalue.UnmarshalMap(out.Item, &lease); err != nil {
+		return nil, fmt.Errorf("unmarshal lease: %w", err)
+	}
+	if !lease.Live(time.Now()) {
+		return nil, nil
+	}
+	return &lease, nil
+}
+
+// ForceReleaseLease deletes the session's lease whoever holds it. Admin only:
+// a turn still running under it keeps streaming but no longer blocks others.
+func (c *Client) ForceReleaseLease(ctx context.Context, sessionID string) error {
+	c.requireLeasesTable()
+	_, err := c.ddb.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(c.leasesTable),
+		Key:       leaseKey(sessionID),
+	})
+	if err != nil {
+		return fmt.Errorf("force release lease: %w", err)
+	}
+	return nil
+}
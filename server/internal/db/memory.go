@@ -0,0 +1,771 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+)
+
+// Memory is an in-process Store with the same observable behaviour as the
+// DynamoDB tables: PutGame's optimistic lock, conditional deletes and
+// updates, the sparse GSIs and upserting UpdateItem calls. Sessions,
+// mutations and checkpoints are round-tripped through the DynamoDB marshaller
+// on every write and read, so a field missing from saveStateDB is lost here
+// just as it is in DynamoDB, and callers never share maps or pointers with the
+// store.
+//
+// Unlike DynamoDB, index reads are strongly consistent and TTLs never expire
+// (leases still go stale at their ExpiresAt, as the conditions check it).
+type Memory struct {
+	mu          sync.Mutex
+	sessions    map[string]saveStateDB // session_id →
+	mutations   map[mutationKey]mutationEntryDB
+	connections map[string]Connection // connection_id →
+	users       map[string]UserRecord // user_id →
+	invites     map[string]InviteRecord
+	memberships map[membershipKey]MembershipRecord
+	checkpoints map[checkpointKey]checkpointDB
+	cpCounts    map[string]int // session_id → checkpoints kept
+	sessionLog  map[logEntryKey]logEntryDB
+	leases      map[string]Lease // session_id →
+}
+
+type mutationKey struct {
+	sessionID string
+	ts        int64
+}
+
+type membershipKey struct {
+	userID, sessionID string
+}
+
+type checkpointKey struct {
+	sessionID, checkpointID string
+}
+
+type logEntryKey struct {
+	sessionID, logKey string
+}
+
+// NewMemory returns an empty in-memory Store.
+func NewMemory() *Memory {
+	return &Memory{
+		sessions:    make(map[string]saveStateDB),
+		mutations:   make(map[mutationKey]mutationEntryDB),
+		connections: make(map[string]Connection),
+		users:       make(map[string]UserRecord),
+		invites:     make(map[string]InviteRecord),
+		memberships: make(map[membershipKey]MembershipRecord),
+		checkpoints: make(map[checkpointKey]checkpointDB),
+		cpCounts:    make(map[string]int),
+		sessionLog:  make(map[logEntryKey]logEntryDB),
+		leases:      make(map[string]Lease),
+	}
+}
+
+// errConditionFailed mirrors the error DynamoDB returns when a condition
+// expression rejects a write, so callers can errors.As on either Store.
+func errConditionFailed() error {
+	return &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+}
+
+// roundTrip copies v through its DynamoDB wire format.
+func roundTrip[T any](v T) (T, error) {
+	var out T
+	item, err := attributevalue.MarshalMap(v)
+	if err != nil {
+		return out, err
+	}
+	err = attributevalue.UnmarshalMap(item, &out)
+	return out, err
+}
+
+// sortedKeys returns m's keys in order, standing in for DynamoDB's
+// unspecified but stable ordering of items under a hash key.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// -------------------------------------------------------------------
+// Sessions
+// -------------------------------------------------------------------
+
+// PutGame stores state under the same optimistic lock as (*Client).PutGame:
+// version 0 must be a new session, otherwise the stored version must be
+// state.Version-1. New narrative and chat messages go to the session log
+// first, as they do in DynamoDB.
+func (m *Memory) PutGame(ctx context.Context, state game.SaveState) error {
+	d, err := toLoggedState(ctx, m, state)
+	if err != nil {
+		return fmt.Errorf("put game: %w", err)
+	}
+	d, err = roundTrip(d)
+	if err != nil {
+		return fmt.Errorf("marshal save state: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prev, exists := m.sessions[state.SessionID]
+	if state.Version > 0 {
+		if !exists || prev.Version != state.Version-1 {
+			return fmt.Errorf("put game: %w", errConditionFailed())
+		}
+	} else if exists {
+		return fmt.Errorf("put game: %w", errConditionFailed())
+	}
+	m.sessions[state.SessionID] = d
+	return nil
+}
+
+// GetGame returns a copy of the stored session with its narrative and recent
+// chat history.
+func (m *Memory) GetGame(ctx context.Context, sessionID string) (game.SaveState, error) {
+	d, err := m.session(sessionID)
+	if err != nil {
+		return game.SaveState{}, err
+	}
+	s, err := fromLoggedState(ctx, m, d)
+	if err != nil {
+		return game.SaveState{}, fmt.Errorf("get game: %w", err)
+	}
+	return s, nil
+}
+
+// session returns a copy of a stored session item.
+func (m *Memory) session(sessionID string) (saveStateDB, error) {
+	m.mu.Lock()
+	d, ok := m.sessions[sessionID]
+	m.mu.Unlock()
+	if !ok {
+		return saveStateDB{}, fmt.Errorf("game not found: %s", sessionID)
+	}
+	d, err := roundTrip(d)
+	if err != nil {
+		return saveStateDB{}, fmt.Errorf("unmarshal game: %w", err)
+	}
+	return d, nil
+}
+
+// GetGameReady reports the stored ready flag.
+func (m *Memory) GetGameReady(_ context.Context, sessionID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.sessions[sessionID]
+	if !ok {
+		return false, fmt.Errorf("game not found: %s", sessionID)
+	}
+	return d.Ready, nil
+}
+
+// GetGameAccess returns who may access a session: only SessionID, OwnerID,
+// UserID and Players are filled in.
+func (m *Memory) GetGameAccess(_ context.Context, sessionID string) (game.SaveState, error) {
+	d, err := m.session(sessionID)
+	if err != nil {
+		return game.SaveState{}, err
+	}
+	return gameAccess(d), nil
+}
+
+// ownedSessions is the user-sessions-index: sessions whose user_id is userID.
+// Callers must hold m.mu.
+func (m *Memory) ownedSessions(userID string) []saveStateDB {
+	var out []saveStateDB
+	for _, id := range sortedKeys(m.sessions) {
+		if d := m.sessions[id]; userID != "" && string(d.UserID) == userID {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// ListGames returns the sessions owned by userID.
+func (m *Memory) ListGames(_ context.Context, userID string) ([]game.SaveState, error) {
+	m.mu.Lock()
+	owned := m.ownedSessions(userID)
+	m.mu.Unlock()
+	saves := make([]game.SaveState, 0, len(owned))
+	for _, d := range owned {
+		d, err := roundTrip(d)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal game list item: %w", err)
+		}
+		saves = append(saves, fromDBState(d))
+	}
+	return saves, nil
+}
+
+// DeleteGame removes a session, failing the condition unless userID owns it.
+func (m *Memory) DeleteGame(_ context.Context, sessionID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.sessions[sessionID]
+	if !ok || string(d.UserID) != userID {
+		return fmt.Errorf("delete game: %w", errConditionFailed())
+	}
+	delete(m.sessions, sessionID)
+	return nil
+}
+
+// ListGamesByOwner returns the IDs of the sessions owned by userID.
+func (m *Memory) ListGamesByOwner(_ context.Context, userID string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	owned := m.ownedSessions(userID)
+	ids := make([]string, 0, len(owned))
+	for _, d := range owned {
+		ids = append(ids, string(d.SessionID))
+	}
+	return ids, nil
+}
+
+// BatchGetSessions returns the sessions that exist among sessionIDs, without
+// narrative or chat history.
+func (m *Memory) BatchGetSessions(_ context.Context, sessionIDs []string) ([]game.SaveState, error) {
+	var states []game.SaveState
+	for _, id := range sessionIDs {
+		d, err := m.session(id)
+		if err != nil {
+			continue
+		}
+		states = append(states, fromDBState(d))
+	}
+	return states, nil
+}
+
+// CountUserGames returns the number of sessions owned by userID.
+func (m *Memory) CountUserGames(_ context.Context, userID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.ownedSessions(userID)), nil
+}
+
+// -------------------------------------------------------------------
+// Mutation log
+// -------------------------------------------------------------------
+
+// PutMutation stores entry, moving it to the next free millisecond when the
+// session already has an entry at its ts.
+func (m *Memory) PutMutation(_ context.Context, entry game.MutationEntry) error {
+	row, err := toMutationDB(entry)
+	if err != nil {
+		return err
+	}
+	if row, err = roundTrip(row); err != nil {
+		return fmt.Errorf("marshal mutation entry: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for range maxMutationTsRetries {
+		key := mutationKey{entry.SessionID, row.Ts}
+		if _, taken := m.mutations[key]; !taken {
+			m.mutations[key] = row
+			return nil
+		}
+		row.Ts++
+	}
+	return fmt.Errorf("put mutation: no free timestamp after %d", entry.Ts)
+}
+
+// ListMutations returns every entry in a session's mutation log, oldest first.
+func (m *Memory) ListMutations(_ context.Context, sessionID string) ([]game.MutationEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var entries []game.MutationEntry
+	for key, row := range m.mutations {
+		if key.sessionID != sessionID {
+			continue
+		}
+		row, err := roundTrip(row)
+		if err != nil {
+			continue
+		}
+		e, err := fromMutationDB(row)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Ts < entries[j].Ts })
+	return entries, nil
+}
+
+// -------------------------------------------------------------------
+// Session log
+// -------------------------------------------------------------------
+
+func (m *Memory) lastLogSeq(_ context.Context, sessionID, kind string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	last := 0
+	for key := range m.sessionLog {
+		if key.sessionID == sessionID && strings.HasPrefix(key.logKey, kind+"#") {
+			last = max(last, logSeq(key.logKey))
+		}
+	}
+	return last, nil
+}
+
+func (m *Memory) putLogEntry(_ context.Context, row logEntryDB) error {
+	row, err := roundTrip(row)
+	if err != nil {
+		return fmt.Errorf("marshal log entry: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := logEntryKey{string(row.SessionID), row.LogKey}
+	if _, taken := m.sessionLog[key]; taken {
+		return errConditionFailed()
+	}
+	m.sessionLog[key] = row
+	return nil
+}
+
+func (m *Memory) readLogRange(_ context.Context, sessionID, kind string, r game.LogRange) ([]logEntryDB, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var rows []logEntryDB
+	for seq := r.Start; seq < r.End; seq++ {
+		row, ok := m.sessionLog[logEntryKey{sessionID, logKey(kind, seq)}]
+		if !ok {
+			continue
+		}
+		row, err := roundTrip(row)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal log entry: %w", err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (m *Memory) deleteLogEntry(_ context.Context, sessionID, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessionLog, logEntryKey{sessionID, key})
+	return nil
+}
+
+// GetChatPage returns up to limit chat messages of a session ending just
+// before position before; before < 0 pages back from the latest message.
+func (m *Memory) GetChatPage(ctx context.Context, sessionID string, before, limit int) (ChatPage, error) {
+	d, err := m.session(sessionID)
+	if err != nil {
+		return ChatPage{}, err
+	}
+	return chatPage(ctx, m, sessionID, d, before, limit)
+}
+
+// ReadChat returns the chat messages at ranges of a session's log, in order.
+func (m *Memory) ReadChat(ctx context.Context, sessionID string, ranges []game.LogRange) ([]game.ChatMessage, error) {
+	return readChat(ctx, m, sessionID, ranges)
+}
+
+// AppendOOC adds msg to the session's out-of-character chat.
+func (m *Memory) AppendOOC(ctx context.Context, sessionID string, msg game.OOCMessage) (game.OOCMessage, error) {
+	return appendOOC(ctx, m, sessionID, msg)
+}
+
+// RecentOOC returns the session's last game.OOCWindow out-of-character
+// messages, oldest first.
+func (m *Memory) RecentOOC(ctx context.Context, sessionID string) ([]game.OOCMessage, error) {
+	return recentOOC(ctx, m, sessionID)
+}
+
+// -------------------------------------------------------------------
+// WebSocket connections
+// -------------------------------------------------------------------
+
+// PutConnection writes or replaces a connection record.
+func (m *Memory) PutConnection(_ context.Context, conn Connection) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connections[conn.ConnectionID] = conn
+	return nil
+}
+
+// GetConnection retrieves a connection by its connection ID.
+func (m *Memory) GetConnection(_ context.Context, connectionID string) (Connection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	conn, ok := m.connections[connectionID]
+	if !ok {
+		return Connection{}, fmt.Errorf("connection not found: %s", connectionID)
+	}
+	return conn, nil
+}
+
+// DeleteConnection removes a connection record; missing records are not an error.
+func (m *Memory) DeleteConnection(_ context.Context, connectionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.connections, connectionID)
+	return nil
+}
+
+// userConnections is the user-connections-index. Callers must hold m.mu.
+func (m *Memory) userConnections(userID string) []Connection {
+	var out []Connection
+	for _, id := range sortedKeys(m.connections) {
+		if conn := m.connections[id]; userID != "" && string(conn.UserID) == userID {
+			out = append(out, conn)
+		}
+	}
+	return out
+}
+
+// DeleteUserConnections removes every connection record for userID.
+func (m *Memory) DeleteUserConnections(_ context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, conn := range m.userConnections(userID) {
+		delete(m.connections, conn.ConnectionID)
+	}
+	return nil
+}
+
+// DeleteUserConnectionForGame removes userID's connection records for gameID only.
+func (m *Memory) DeleteUserConnectionForGame(_ context.Context, userID, gameID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, conn := range m.userConnections(userID) {
+		if conn.GameID == gameID {
+			delete(m.connections, conn.ConnectionID)
+		}
+	}
+	return nil
+}
+
+// GetConnectionsByGameID returns the connections in gameID, as the sparse
+// game-connections-index does: records without a game_id are never returned.
+func (m *Memory) GetConnectionsByGameID(_ context.Context, gameID string) ([]Connection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	conns := make([]Connection, 0)
+	if gameID == "" {
+		return conns, nil
+	}
+	for _, id := range sortedKeys(m.connections) {
+		if conn := m.connections[id]; conn.GameID == gameID {
+			conns = append(conns, conn)
+		}
+	}
+	return conns, nil
+}
+
+// GetConnectionByUserID returns the first of userID's connections.
+func (m *Memory) GetConnectionByUserID(_ context.Context, userID string) (Connection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	conns := m.userConnections(userID)
+	if len(conns) == 0 {
+		return Connection{}, fmt.Errorf("no active connection for user %s", userID)
+	}
+	return conns[0], nil
+}
+
+// -------------------------------------------------------------------
+// Streaming leases
+// -------------------------------------------------------------------
+
+// AcquireLease writes lease unless another holder's lease is still live.
+func (m *Memory) AcquireLease(_ context.Context, lease Lease) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if prev, ok := m.leases[string(lease.SessionID)]; ok && prev.Live(time.Now()) && prev.Holder != lease.Holder {
+		return ErrLeaseHeld
+	}
+	m.leases[string(lease.SessionID)] = lease
+	return nil
+}
+
+// ReleaseLease deletes the session's lease if holder still holds it.
+func (m *Memory) ReleaseLease(_ context.Context, sessionID, holder string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if prev, ok := m.leases[sessionID]; ok && prev.Holder == holder {
+		delete(m.leases, sessionID)
+	}
+	return nil
+}
+
+// GetLease returns the session's live lease, or nil when it has none.
+func (m *Memory) GetLease(_ context.Context, sessionID string) (*Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	lease, ok := m.leases[sessionID]
+	if !ok || !lease.Live(time.Now()) {
+		return nil, nil
+	}
+	return &lease, nil
+}
+
+// ForceReleaseLease deletes the session's lease whoever holds it.
+func (m *Memory) ForceReleaseLease(_ context.Context, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.leases, sessionID)
+	return nil
+}
+
+// -------------------------------------------------------------------
+// Users
+// -------------------------------------------------------------------
+
+// GetUser returns a copy of the user record, or nil when there is none.
+func (m *Memory) GetUser(_ context.Context, userID string) (*UserRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &u, nil
+}
+
+// PutUser writes a user record unconditionally.
+func (m *Memory) PutUser(_ context.Context, u UserRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[string(u.UserID)] = u
+	return nil
+}
+
+// UpdateUser replaces an existing user record, stamping UpdatedAt.
+func (m *Memory) UpdateUser(_ context.Context, _ string, u UserRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[string(u.UserID)]; !ok {
+		return fmt.Errorf("UpdateUser: %w", errConditionFailed())
+	}
+	u.UpdatedAt = time.Now().UnixMilli()
+	m.users[string(u.UserID)] = u
+	return nil
+}
+
+// UpdateUserTokens adds delta to tokens_used, returning ErrUserNotFound when
+// the record does not exist.
+func (m *Memory) UpdateUserTokens(_ context.Context, userID string, delta int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return fmt.Errorf("UpdateUserTokens: user %s not found: %w", userID, ErrUserNotFound)
+	}
+	u.TokensUsed += delta
+	u.UpdatedAt = time.Now().UnixMilli()
+	m.users[userID] = u
+	return nil
+}
+
+// ListUsers returns every user record.
+func (m *Memory) ListUsers(_ context.Context) ([]UserRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var records []UserRecord
+	for _, id := range sortedKeys(m.users) {
+		records = append(records, m.users[id])
+	}
+	return records, nil
+}
+
+// -------------------------------------------------------------------
+// Invites
+// -------------------------------------------------------------------
+
+// GetInvite returns a copy of the invite, or nil when there is none.
+func (m *Memory) GetInvite(_ context.Context, code string) (*InviteRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inv, ok := m.invites[code]
+	if !ok {
+		return nil, nil
+	}
+	return &inv, nil
+}
+
+// PutInvite writes an invite record.
+func (m *Memory) PutInvite(_ context.Context, inv InviteRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.invites[inv.Code] = inv
+	return nil
+}
+
+// IncrementInviteUses adds one to the invite's uses. Like the ADD update it
+// creates a bare record when the code does not exist.
+func (m *Memory) IncrementInviteUses(_ context.Context, code string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inv, ok := m.invites[code]
+	if !ok {
+		inv = InviteRecord{Code: code}
+	}
+	inv.Uses++
+	m.invites[code] = inv
+	return nil
+}
+
+// -------------------------------------------------------------------
+// Memberships
+// -------------------------------------------------------------------
+
+// PutMembership writes a membership record.
+func (m *Memory) PutMembership(_ context.Context, rec MembershipRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.memberships[membershipKey{string(rec.UserID), string(rec.SessionID)}] = rec
+	return nil
+}
+
+// sortedMemberships returns the membership records matching keep, ordered by
+// user then session. Callers must hold m.mu.
+func (m *Memory) sortedMemberships(keep func(membershipKey) bool) []MembershipRecord {
+	keys := make([]membershipKey, 0)
+	for k := range m.memberships {
+		if keep(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].userID != keys[j].userID {
+			return keys[i].userID < keys[j].userID
+		}
+		return keys[i].sessionID < keys[j].sessionID
+	})
+	records := make([]MembershipRecord, 0, len(keys))
+	for _, k := range keys {
+		records = append(records, m.memberships[k])
+	}
+	return records
+}
+
+// GetMembershipsByUser returns all membership records for a user.
+func (m *Memory) GetMembershipsByUser(_ context.Context, userID string) ([]MembershipRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sortedMemberships(func(k membershipKey) bool { return k.userID == userID }), nil
+}
+
+// GetMemberSessions returns all session IDs the user belongs to.
+func (m *Memory) GetMemberSessions(ctx context.Context, userID string) ([]string, error) {
+	records, err := m.GetMembershipsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(records))
+	for _, r := range records {
+		ids = append(ids, string(r.SessionID))
+	}
+	return ids, nil
+}
+
+// GetSessionMembers returns all membership records for a session.
+func (m *Memory) GetSessionMembers(_ context.Context, sessionID string) ([]MembershipRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sortedMemberships(func(k membershipKey) bool { return k.sessionID == sessionID }), nil
+}
+
+// DeleteMembership removes a single membership record.
+func (m *Memory) DeleteMembership(_ context.Context, userID, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.memberships, membershipKey{userID, sessionID})
+	return nil
+}
+
+// -------------------------------------------------------------------
+// Checkpoints
+// -------------------------------------------------------------------
+
+// PutCheckpoint stores a copy of cp unless the session already keeps limit
+// checkpoints (ErrCheckpointLimit) or one with the same ID.
+func (m *Memory) PutCheckpoint(ctx context.Context, cp Checkpoint, limit int) error {
+	row, err := toCheckpointDB(ctx, m, cp)
+	if err != nil {
+		return fmt.Errorf("PutCheckpoint: %w", err)
+	}
+	row, err = roundTrip(row)
+	if err != nil {
+		return fmt.Errorf("PutCheckpoint marshal: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cpCounts[cp.SessionID] >= limit {
+		return ErrCheckpointLimit
+	}
+	key := checkpointKey{cp.SessionID, cp.CheckpointID}
+	if _, ok := m.checkpoints[key]; ok {
+		return fmt.Errorf("PutCheckpoint: %w", errConditionFailed())
+	}
+	m.checkpoints[key] = row
+	m.cpCounts[cp.SessionID]++
+	return nil
+}
+
+// GetCheckpoint returns a copy of one checkpoint with its state.
+func (m *Memory) GetCheckpoint(ctx context.Context, sessionID, checkpointID string) (Checkpoint, error) {
+	m.mu.Lock()
+	row, ok := m.checkpoints[checkpointKey{sessionID, checkpointID}]
+	m.mu.Unlock()
+	if !ok {
+		return Checkpoint{}, fmt.Errorf("checkpoint not found: %s", checkpointID)
+	}
+	row, err := roundTrip(row)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("GetCheckpoint unmarshal: %w", err)
+	}
+	cp, err := fromCheckpointDB(ctx, m, row)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("GetCheckpoint: %w", err)
+	}
+	return cp, nil
+}
+
+// ListCheckpoints returns a session's checkpoints without their states, in
+// checkpoint_id order like the table's sort key.
+func (m *Memory) ListCheckpoints(ctx context.Context, sessionID string) ([]Checkpoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var checkpoints []Checkpoint
+	for k, row := range m.checkpoints {
+		if k.sessionID != sessionID {
+			continue
+		}
+		row.State = nil
+		cp, err := fromCheckpointDB(ctx, m, row)
+		if err != nil {
+			continue
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	sort.Slice(checkpoints, func(i, j int) bool { return checkpoints[i].CheckpointID < checkpoints[j].CheckpointID })
+	return checkpoints, nil
+}
+
+// DeleteCheckpoint removes one checkpoint and takes it off the session's
+// count.
+func (m *Memory) DeleteCheckpoint(_ context.Context, sessionID, checkpointID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := checkpointKey{sessionID, checkpointID}
+	if _, ok := m.checkpoints[key]; ok {
+		delete(m.checkpoints, key)
+		m.cpCounts[sessionID]--
+	}
+	return nil
+}
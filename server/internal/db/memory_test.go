@@ -0,0 +1,399 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rrochlin/an-amazing-adventure/internal/db"
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+)
+
+func isConditionFailed(err error) bool {
+	var ccf *types.ConditionalCheckFailedException
+	return errors.As(err, &ccf)
+}
+
+func TestMemoryPutGame_OptimisticLock(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+
+	s := game.SaveState{SessionID: "s1", UserID: "u1", Version: 0}
+	if err := store.PutGame(ctx, s); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := store.PutGame(ctx, s); !isConditionFailed(err) {
+		t.Errorf("second create should fail the condition, got %v", err)
+	}
+
+	s.Version = 1
+	if err := store.PutGame(ctx, s); err != nil {
+		t.Fatalf("update to v1: %v", err)
+	}
+	// A writer still holding v0 loses the race.
+	if err := store.PutGame(ctx, s); !isConditionFailed(err) {
+		t.Errorf("stale v1 write should fail the condition, got %v", err)
+	}
+	s.Version = 5
+	if err := store.PutGame(ctx, s); !isConditionFailed(err) {
+		t.Errorf("skipping versions should fail the condition, got %v", err)
+	}
+	if err := store.PutGame(ctx, game.SaveState{SessionID: "new", Version: 3}); !isConditionFailed(err) {
+		t.Errorf("updating a missing session should fail the condition, got %v", err)
+	}
+}
+
+func TestMemoryGetGame_ReturnsCopy(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+	s := game.SaveState{SessionID: "s1", UserID: "u1", Players: map[string]game.Character{"u1": {Name: "Brom"}}}
+	if err := store.PutGame(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	s.Players["u1"] = game.Character{Name: "changed after put"}
+
+	got, err := store.GetGame(ctx, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Players["u1"].Name != "Brom" {
+		t.Errorf("stored state shares memory with the caller: %q", got.Players["u1"].Name)
+	}
+	if _, err := store.GetGame(ctx, "missing"); err == nil {
+		t.Error("expected an error for a missing game")
+	}
+}
+
+func TestMemoryGetGameAccess_OnlyAccessFields(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+	s := game.SaveState{SessionID: "s1", OwnerID: "u1", UserID: "u1", Title: "Crypt",
+		Players:     map[string]game.Character{"u2": {Name: "Lia"}},
+		ChatHistory: []game.ChatMessage{{Type: "player", Content: "hi"}}}
+	if err := store.PutGame(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.GetGameAccess(ctx, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.OwnerID != "u1" || got.UserID != "u1" || got.Players["u2"].Name != "Lia" {
+		t.Errorf("access = %+v", got)
+	}
+	if got.Title != "" || got.ChatHistory != nil {
+		t.Errorf("only the access fields should be read: %+v", got)
+	}
+	if _, err := store.GetGameAccess(ctx, "missing"); err == nil {
+		t.Error("expected an error for a missing game")
+	}
+}
+
+func TestMemoryMutations_KeepsPatchesAndNeverOverwrites(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+
+	full := game.SaveState{SessionID: "s1", Title: "Crypt"}
+	events := []game.MutationEntry{
+		{SessionID: "s1", Ts: 100, Type: game.EventWorldGen, Version: 1, Patch: &game.StatePatch{Full: &full}},
+		{SessionID: "s1", Ts: 100, Type: game.EventTool, Tool: "create_room", Version: 2, Input: map[string]any{"name": "Hall"}},
+		{SessionID: "s2", Ts: 100, Tool: "create_item"},
+	}
+	for _, e := range events {
+		if err := store.PutMutation(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := store.ListMutations(ctx, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected both s1 entries despite the shared ts, got %+v", got)
+	}
+	if got[0].Patch == nil || got[0].Patch.Full == nil || got[0].Patch.Full.Title != "Crypt" {
+		t.Errorf("patch lost in storage: %+v", got[0])
+	}
+	if got[1].Ts != 101 || got[1].Type != game.EventTool || got[1].Input["name"] != "Hall" {
+		t.Errorf("colliding entry = %+v", got[1])
+	}
+}
+
+func TestMemorySessions_UserIndex(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+	for _, s := range []game.SaveState{
+		{SessionID: "a", UserID: "u1"},
+		{SessionID: "b", UserID: "u2"},
+		{SessionID: "c", UserID: "u1"},
+	} {
+		if err := store.PutGame(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids, _ := store.ListGamesByOwner(ctx, "u1")
+	if len(ids) != 2 || ids[0] != "a" || ids[1] != "c" {
+		t.Errorf("ListGamesByOwner = %v", ids)
+	}
+	if n, _ := store.CountUserGames(ctx, "u2"); n != 1 {
+		t.Errorf("CountUserGames = %d, want 1", n)
+	}
+	saves, _ := store.BatchGetSessions(ctx, []string{"b", "missing", "a"})
+	if len(saves) != 2 || saves[0].SessionID != "b" {
+		t.Errorf("BatchGetSessions = %v", saves)
+	}
+
+	if err := store.DeleteGame(ctx, "a", "u2"); !isConditionFailed(err) {
+		t.Errorf("deleting someone else's game should fail the condition, got %v", err)
+	}
+	if err := store.DeleteGame(ctx, "a", "u1"); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := store.CountUserGames(ctx, "u1"); n != 1 {
+		t.Errorf("CountUserGames after delete = %d, want 1", n)
+	}
+}
+
+func TestMemoryConnections_GameIndex(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+	for _, c := range []db.Connection{
+		{ConnectionID: "c1", UserID: "u1", GameID: "g1"},
+		{ConnectionID: "c2", UserID: "u2", GameID: "g1"},
+		{ConnectionID: "c3", UserID: "u1", GameID: "g2"},
+		{ConnectionID: "c4", UserID: "u3"}, // no game yet: not in the index
+	} {
+		if err := store.PutConnection(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conns, _ := store.GetConnectionsByGameID(ctx, "g1")
+	if len(conns) != 2 || conns[0].ConnectionID != "c1" || conns[1].ConnectionID != "c2" {
+		t.Errorf("GetConnectionsByGameID(g1) = %v", conns)
+	}
+	if conns, _ := store.GetConnectionsByGameID(ctx, ""); len(conns) != 0 {
+		t.Errorf("records without a game_id must not be indexed, got %v", conns)
+	}
+
+	if err := store.DeleteUserConnectionForGame(ctx, "u1", "g1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetConnection(ctx, "c1"); err == nil {
+		t.Error("c1 should be gone")
+	}
+	if _, err := store.GetConnection(ctx, "c3"); err != nil {
+		t.Errorf("u1's connection to g2 should survive: %v", err)
+	}
+}
+
+func TestMemoryLeases_ConditionalAcquireAndTakeover(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+	live := time.Now().Add(time.Minute).Unix()
+
+	if err := store.AcquireLease(ctx, db.Lease{SessionID: "s1", Holder: "c1", ExpiresAt: live}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AcquireLease(ctx, db.Lease{SessionID: "s1", Holder: "c2", ExpiresAt: live}); !errors.Is(err, db.ErrLeaseHeld) {
+		t.Errorf("second holder should be refused, got %v", err)
+	}
+	if err := store.AcquireLease(ctx, db.Lease{SessionID: "s1", Holder: "c1", ExpiresAt: live + 10}); err != nil {
+		t.Errorf("holder should be able to extend its lease: %v", err)
+	}
+
+	// Releasing someone else's lease is a no-op.
+	_ = store.ReleaseLease(ctx, "s1", "c2")
+	if l, _ := store.GetLease(ctx, "s1"); l == nil || l.Holder != "c1" {
+		t.Fatalf("GetLease = %+v", l)
+	}
+	_ = store.ReleaseLease(ctx, "s1", "c1")
+	if l, _ := store.GetLease(ctx, "s1"); l != nil {
+		t.Errorf("released lease still live: %+v", l)
+	}
+
+	// A stale lease reads as free and is taken over.
+	_ = store.AcquireLease(ctx, db.Lease{SessionID: "s2", Holder: "c1", ExpiresAt: time.Now().Add(-time.Second).Unix()})
+	if l, _ := store.GetLease(ctx, "s2"); l != nil {
+		t.Errorf("stale lease should not be live: %+v", l)
+	}
+	if err := store.AcquireLease(ctx, db.Lease{SessionID: "s2", Holder: "c2", ExpiresAt: live}); err != nil {
+		t.Errorf("stale lease should be taken over: %v", err)
+	}
+	_ = store.ForceReleaseLease(ctx, "s2")
+	if l, _ := store.GetLease(ctx, "s2"); l != nil {
+		t.Errorf("force-released lease still live: %+v", l)
+	}
+}
+
+func TestMemoryUsers(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+
+	if u, err := store.GetUser(ctx, "u1"); u != nil || err != nil {
+		t.Errorf("missing user = %v, %v; want nil, nil", u, err)
+	}
+	if err := store.UpdateUserTokens(ctx, "u1", 10); !errors.Is(err, db.ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+	if err := store.UpdateUser(ctx, "u1", db.UserRecord{UserID: "u1"}); !isConditionFailed(err) {
+		t.Errorf("UpdateUser on a missing record should fail the condition, got %v", err)
+	}
+
+	if err := store.PutUser(ctx, db.UserRecord{UserID: "u1", TokensUsed: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpdateUserTokens(ctx, "u1", 10); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := store.GetUser(ctx, "u1"); u.TokensUsed != 15 || u.UpdatedAt == 0 {
+		t.Errorf("after UpdateUserTokens: %+v", u)
+	}
+}
+
+func TestMemoryInvitesAndMemberships(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+
+	if err := store.PutInvite(ctx, db.InviteRecord{Code: "ABC123", SessionID: "s1", MaxUses: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.IncrementInviteUses(ctx, "ABC123"); err != nil {
+		t.Fatal(err)
+	}
+	if inv, _ := store.GetInvite(ctx, "ABC123"); inv == nil || inv.Uses != 1 {
+		t.Errorf("invite after increment = %+v", inv)
+	}
+
+	_ = store.PutMembership(ctx, db.MembershipRecord{UserID: "u1", SessionID: "s1", Role: "owner"})
+	_ = store.PutMembership(ctx, db.MembershipRecord{UserID: "u2", SessionID: "s1", Role: "member"})
+	_ = store.PutMembership(ctx, db.MembershipRecord{UserID: "u2", SessionID: "s2", Role: "member"})
+
+	if ids, _ := store.GetMemberSessions(ctx, "u2"); len(ids) != 2 {
+		t.Errorf("GetMemberSessions(u2) = %v", ids)
+	}
+	if members, _ := store.GetSessionMembers(ctx, "s1"); len(members) != 2 {
+		t.Errorf("GetSessionMembers(s1) = %v", members)
+	}
+	_ = store.DeleteMembership(ctx, "u2", "s1")
+	if members, _ := store.GetSessionMembers(ctx, "s1"); len(members) != 1 || members[0].UserID != "u1" {
+		t.Errorf("after delete GetSessionMembers(s1) = %v", members)
+	}
+}
+
+func TestMemoryCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+	state := game.NewGame("s1", "u1").ToSaveState(nil, []game.ChatMessage{{Type: "player", Content: "hi"}})
+
+	if err := store.PutCheckpoint(ctx, db.Checkpoint{SessionID: "s1", CheckpointID: "b", Name: "camp", CreatedBy: "u1", State: state}, 20); err != nil {
+		t.Fatal(err)
+	}
+	_ = store.PutCheckpoint(ctx, db.Checkpoint{SessionID: "s1", CheckpointID: "a", Name: "gate", State: state}, 20)
+	_ = store.PutCheckpoint(ctx, db.Checkpoint{SessionID: "s2", CheckpointID: "c", State: state}, 20)
+
+	cp, err := store.GetCheckpoint(ctx, "s1", "b")
+	if err != nil || cp.Name != "camp" || cp.CreatedBy != "u1" || len(cp.State.ChatHistory) != 1 || cp.State.SessionID != "s1" {
+		t.Fatalf("GetCheckpoint = %+v, %v", cp, err)
+	}
+	list, _ := store.ListCheckpoints(ctx, "s1")
+	if len(list) != 2 || list[0].CheckpointID != "a" {
+		t.Fatalf("ListCheckpoints(s1) = %+v", list)
+	}
+	if list[0].State.SessionID != "" {
+		t.Error("listed checkpoints should not carry their state")
+	}
+	_ = store.DeleteCheckpoint(ctx, "s1", "a")
+	if _, err := store.GetCheckpoint(ctx, "s1", "a"); err == nil {
+		t.Error("deleted checkpoint still readable")
+	}
+
+	// The limit counts what the session keeps: s1 holds one again.
+	if err := store.PutCheckpoint(ctx, db.Checkpoint{SessionID: "s1", CheckpointID: "d", State: state}, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.PutCheckpoint(ctx, db.Checkpoint{SessionID: "s1", CheckpointID: "e", State: state}, 2); !errors.Is(err, db.ErrCheckpointLimit) {
+		t.Errorf("over the limit: err = %v", err)
+	}
+}
+
+func TestMemorySessionLog_PagesChatHistory(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+
+	g := game.NewGame("s1", "u1")
+	narrative := []game.NarrativeMessage{{Role: "assistant", Content: []game.NarrativeBlock{{Type: "text", Text: "You wake."}}}}
+	chat := []game.ChatMessage{{Type: "player", Content: "m0"}}
+	if err := store.PutGame(ctx, g.ToSaveState(narrative, chat)); err != nil {
+		t.Fatal(err)
+	}
+	s, err := store.GetGame(ctx, "s1")
+	if err != nil || len(s.Narrative) != 1 || len(s.ChatHistory) != 1 || s.ChatHistory[0].LogSeq == 0 {
+		t.Fatalf("GetGame after create = %+v, %v", s, err)
+	}
+
+	// Grow past the window so PageChat moves the oldest messages out.
+	g, _ = game.FromSaveState(s)
+	history := s.ChatHistory
+	for i := 1; i <= game.ChatWindow; i++ {
+		history = append(history, game.ChatMessage{Type: "player", Content: fmt.Sprintf("m%d", i)})
+	}
+	grown := g.ToSaveState(s.Narrative, history)
+	grown.Version = s.Version + 1
+	if err := store.PutGame(ctx, grown); err != nil {
+		t.Fatal(err)
+	}
+	s, _ = store.GetGame(ctx, "s1")
+	g, _ = game.FromSaveState(s)
+	history = g.PageChat(s.ChatHistory)
+	next := g.ToSaveState(s.Narrative, history)
+	next.Version = s.Version + 1
+	if err := store.PutGame(ctx, next); err != nil {
+		t.Fatal(err)
+	}
+
+	s, _ = store.GetGame(ctx, "s1")
+	if len(s.ChatHistory) != game.ChatPageSize || s.ChatHistory[0].Content != "m21" {
+		t.Fatalf("recent chat = %d messages starting %+v", len(s.ChatHistory), s.ChatHistory[0])
+	}
+	if len(s.Narrative) != 1 {
+		t.Errorf("narrative = %+v", s.Narrative)
+	}
+
+	page, err := store.GetChatPage(ctx, "s1", -1, 5)
+	if err != nil || page.Total != game.ChatWindow+1 || page.Start != 36 || page.Messages[0].Content != "m36" {
+		t.Fatalf("latest page = %+v, %v", page, err)
+	}
+	page, _ = store.GetChatPage(ctx, "s1", 10, 0)
+	if page.Start != 0 || len(page.Messages) != 10 || page.Messages[9].Content != "m9" {
+		t.Fatalf("older page = %+v", page)
+	}
+}
+
+func TestMemoryOOC_KeepsTheLastWindow(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+
+	if msgs, err := store.RecentOOC(ctx, "s1"); err != nil || len(msgs) != 0 {
+		t.Fatalf("RecentOOC on empty session = %+v, %v", msgs, err)
+	}
+	for i := 1; i <= game.OOCWindow+5; i++ {
+		msg, err := store.AppendOOC(ctx, "s1", game.OOCMessage{UserID: "u1", Content: fmt.Sprintf("o%d", i)})
+		if err != nil || msg.Seq != i {
+			t.Fatalf("AppendOOC #%d = %+v, %v", i, msg, err)
+		}
+	}
+	msgs, err := store.RecentOOC(ctx, "s1")
+	if err != nil || len(msgs) != game.OOCWindow {
+		t.Fatalf("RecentOOC = %d messages, %v", len(msgs), err)
+	}
+	if msgs[0].Content != "o6" || msgs[len(msgs)-1].Content != "o55" {
+		t.Errorf("window = %q … %q", msgs[0].Content, msgs[len(msgs)-1].Content)
+	}
+	if other, _ := store.RecentOOC(ctx, "s2"); len(other) != 0 {
+		t.Errorf("another session's chat leaked: %+v", other)
+	}
+}
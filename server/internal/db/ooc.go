@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+)
+
+// Out-of-character chat is kept in the session log under its own kind
+// ("ooc#0000000042"), apart from the session item, so sending a message
+// never races a narrator turn's save. Only the last game.OOCWindow messages
+// are kept: each append deletes the entry that falls out of the window.
+
+// appendOOC writes msg at the end of the session's out-of-character chat and
+// returns it with its Seq set.
+func appendOOC(ctx context.Context, l sessionLog, sessionID string, msg game.OOCMessage) (game.OOCMessage, error) {
+	msg.Seq = 0
+	if _, err := appendLog(ctx, l, sessionID, logKindOOC, []*int{&msg.Seq}, func(int) logEntryDB {
+		m := msg
+		return logEntryDB{OOC: &m}
+	}); err != nil {
+		return game.OOCMessage{}, err
+	}
+	// Best effort: a missed delete leaves an entry recentOOC never reads.
+	if old := msg.Seq - game.OOCWindow; old > 0 {
+		if err := l.deleteLogEntry(ctx, sessionID, logKey(logKindOOC, old)); err != nil {
+			return msg, fmt.Errorf("prune ooc log: %w", err)
+		}
+	}
+	return msg, nil
+}
+
+// recentOOC returns the session's last game.OOCWindow out-of-character
+// messages, oldest first.
+func recentOOC(ctx context.Context, l sessionLog, sessionID string) ([]game.OOCMessage, error) {
+	last, err := l.lastLogSeq(ctx, sessionID, logKindOOC)
+	if err != nil {
+		return nil, fmt.Errorf("read ooc log: %w", err)
+	}
+	if last == 0 {
+		return nil, nil
+	}
+	rows, err := l.readLogRange(ctx, sessionID, logKindOOC, game.LogRange{Start: max(last-game.OOCWindow+1, 1), End: last + 1})
+	if err != nil {
+		return nil, fmt.Errorf("read ooc log: %w", err)
+	}
+	msgs := make([]game.OOCMessage, 0, len(rows))
+	for _, row := range rows {
+		if row.OOC == nil {
+			continue
+		}
+		m := *row.OOC
+		m.Seq = logSeq(row.LogKey)
+		msgs = append(msgs, m)
+	}
+	return msgs, nil
+}
+
+// -------------------------------------------------------------------
+// DynamoDB
+// -------------------------------------------------------------------
+
+func (c *Client) deleteLogEntry(ctx context.Context, sessionID, key string) error {
+	c.requireSessionLogTable()
+	_, err := c.ddb.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(c.sessionLogTable),
+		Key: map[string]types.AttributeValue{
+			"session_id": binaryIDVal(sessionID),
+			"log_key":    &types.AttributeValueMemberS{Value: key},
+		},
+	})
+	return err
+}
+
+// AppendOOC adds msg to the session's out-of-character chat and returns it
+// with its Seq set.
+func (c *Client) AppendOOC(ctx context.Context, sessionID string, msg game.OOCMessage) (game.OOCMessage, error) {
+	return appendOOC(ctx, c, sessionID, msg)
+}
+
+// RecentOOC returns the session's last game.OOCWindow out-of-character
+// messages, oldest first.
+func (c *Client) RecentOOC(ctx context.Context, sessionID string) ([]game.OOCMessage, error) {
+	return recentOOC(ctx, c, sessionID)
+}
@@ -0,0 +1,369 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+)
+
+// The session log holds every chat message and narrative turn of a session,
+// one item each, so the session item no longer grows with the campaign
+// (SchemaVersion 5). Entries are only ever appended: the session item lists
+// the ranges of entries that make up its current histories, so rewinds,
+// restores and trimmed narrative simply point at different entries.
+// Out-of-character chat lives here too, outside the session item (see ooc.go).
+// PK: session_id (B), SK: log_key (S) — "chat#0000000042".
+const (
+	logKindChat      = "chat"
+	logKindNarrative = "narrative"
+	logKindOOC       = "ooc"
+)
+
+// maxLogSeqRetries bounds how far an append walks forward past entries a
+// concurrent writer took.
+const maxLogSeqRetries = 8
+
+// maxChatPage caps the chat-history page size.
+const maxChatPage = 100
+
+// logEntryDB is one session-log item; exactly one of Chat, Narrative and
+// OOC is set.
+type logEntryDB struct {
+	SessionID BinaryID               `dynamodbav:"session_id"`
+	LogKey    string                 `dynamodbav:"log_key"`
+	Chat      *game.ChatMessage      `dynamodbav:"chat,omitempty"`
+	Narrative *game.NarrativeMessage `dynamodbav:"narrative,omitempty"`
+	OOC       *game.OOCMessage       `dynamodbav:"ooc,omitempty"`
+}
+
+// logKey is zero-padded so the sort key orders entries by seq.
+func logKey(kind string, seq int) string {
+	return fmt.Sprintf("%s#%010d", kind, seq)
+}
+
+// logSeq returns the seq of a log key, or 0 if it is malformed.
+func logSeq(key string) int {
+	_, num, ok := strings.Cut(key, "#")
+	if !ok {
+		return 0
+	}
+	seq, err := strconv.Atoi(num)
+	if err != nil {
+		return 0
+	}
+	return seq
+}
+
+// sessionLog is the storage behind the session log, implemented by *Client
+// and *Memory. putLogEntry fails with a ConditionalCheckFailedException when
+// the key is taken; readLogRange returns the entries of r in seq order.
+// deleteLogEntry is only used on out-of-character chat, whose entries no
+// session item points at.
+type sessionLog interface {
+	lastLogSeq(ctx context.Context, sessionID, kind string) (int, error)
+	putLogEntry(ctx context.Context, row logEntryDB) error
+	readLogRange(ctx context.Context, sessionID, kind string, r game.LogRange) ([]logEntryDB, error)
+	deleteLogEntry(ctx context.Context, sessionID, key string) error
+}
+
+// appendLog writes the entries whose *seqs[i] is 0 to the end of the log,
+// storing the seq each was given, and returns the ranges of all entries in
+// order. Seqs start at 1, so 0 always means "not yet written".
+func appendLog(ctx context.Context, l sessionLog, sessionID, kind string, seqs []*int, row func(i int) logEntryDB) ([]game.LogRange, error) {
+	var ranges []game.LogRange
+	next := 0
+	for i, seq := range seqs {
+		if *seq == 0 {
+			if next == 0 {
+				last, err := l.lastLogSeq(ctx, sessionID, kind)
+				if err != nil {
+					return nil, fmt.Errorf("append %s log: %w", kind, err)
+				}
+				next = last + 1
+			}
+			entry := row(i)
+			entry.SessionID = BinaryID(sessionID)
+			for attempt := 0; ; attempt++ {
+				entry.LogKey = logKey(kind, next)
+				err := l.putLogEntry(ctx, entry)
+				var ccf *types.ConditionalCheckFailedException
+				if errors.As(err, &ccf) && attempt < maxLogSeqRetries {
+					next++
+					continue
+				}
+				if err != nil {
+					return nil, fmt.Errorf("append %s log: %w", kind, err)
+				}
+				break
+			}
+			*seq = next
+			next++
+		}
+		ranges = game.AppendRange(ranges, *seq)
+	}
+	return ranges, nil
+}
+
+// readLog returns the entries of ranges in order. An entry missing from the
+// log is an error: the session item never points at unwritten entries.
+func readLog(ctx context.Context, l sessionLog, sessionID, kind string, ranges []game.LogRange) ([]logEntryDB, error) {
+	var rows []logEntryDB
+	for _, r := range ranges {
+		got, err := l.readLogRange(ctx, sessionID, kind, r)
+		if err != nil {
+			return nil, fmt.Errorf("read %s log: %w", kind, err)
+		}
+		if len(got) != r.End-r.Start {
+			return nil, fmt.Errorf("read %s log: %d of %d entries in [%d,%d)", kind, len(got), r.End-r.Start, r.Start, r.End)
+		}
+		rows = append(rows, got...)
+	}
+	return rows, nil
+}
+
+func readChat(ctx context.Context, l sessionLog, sessionID string, ranges []game.LogRange) ([]game.ChatMessage, error) {
+	rows, err := readLog(ctx, l, sessionID, logKindChat, ranges)
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]game.ChatMessage, 0, len(rows))
+	for _, row := range rows {
+		var m game.ChatMessage
+		if row.Chat != nil {
+			m = *row.Chat
+		}
+		m.LogSeq = logSeq(row.LogKey)
+		msgs = append(msgs, m)
+	}
+	return msgs, nil
+}
+
+func readNarrative(ctx context.Context, l sessionLog, sessionID string, ranges []game.LogRange) ([]game.NarrativeMessage, error) {
+	rows, err := readLog(ctx, l, sessionID, logKindNarrative, ranges)
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]game.NarrativeMessage, 0, len(rows))
+	for _, row := range rows {
+		var m game.NarrativeMessage
+		if row.Narrative != nil {
+			m = *row.Narrative
+		}
+		m.LogSeq = logSeq(row.LogKey)
+		msgs = append(msgs, m)
+	}
+	return msgs, nil
+}
+
+// toLoggedState appends s's new narrative and chat messages to the session
+// log and returns the session item pointing at them. The LogSeq of every
+// newly written message is set in place, so a retried save does not write
+// them again.
+func toLoggedState(ctx context.Context, l sessionLog, s game.SaveState) (saveStateDB, error) {
+	d := toDBState(s)
+
+	narrativeSeqs := make([]*int, len(s.Narrative))
+	for i := range s.Narrative {
+		narrativeSeqs[i] = &s.Narrative[i].LogSeq
+	}
+	narrativeLog, err := appendLog(ctx, l, s.SessionID, logKindNarrative, narrativeSeqs, func(i int) logEntryDB {
+		m := s.Narrative[i]
+		return logEntryDB{Narrative: &m}
+	})
+	if err != nil {
+		return saveStateDB{}, err
+	}
+
+	chatSeqs := make([]*int, len(s.ChatHistory))
+	for i := range s.ChatHistory {
+		chatSeqs[i] = &s.ChatHistory[i].LogSeq
+	}
+	chatLog, err := appendLog(ctx, l, s.SessionID, logKindChat, chatSeqs, func(i int) logEntryDB {
+		m := s.ChatHistory[i]
+		return logEntryDB{Chat: &m}
+	})
+	if err != nil {
+		return saveStateDB{}, err
+	}
+
+	d.NarrativeLog, d.ChatLog = narrativeLog, chatLog
+	return d, nil
+}
+
+// fromLoggedState loads a session item's narrative and recent chat from the
+// session log. Items from before SchemaVersion 5 carry both inline.
+func fromLoggedState(ctx context.Context, l sessionLog, d saveStateDB) (game.SaveState, error) {
+	s := fromDBState(d)
+	if d.Narrative == nil && len(d.NarrativeLog) > 0 {
+		msgs, err := readNarrative(ctx, l, s.SessionID, d.NarrativeLog)
+		if err != nil {
+			return game.SaveState{}, err
+		}
+		s.Narrative = msgs
+	}
+	if d.ChatHistory == nil && len(d.ChatLog) > 0 {
+		msgs, err := readChat(ctx, l, s.SessionID, d.ChatLog)
+		if err != nil {
+			return game.SaveState{}, err
+		}
+		s.ChatHistory = msgs
+	}
+	return s, nil
+}
+
+// ChatPage is a slice of a session's chat history, oldest first. Start is
+// the position of Messages[0] in the whole history and Total its length;
+// pass Start as `before` to fetch the page preceding this one.
+type ChatPage struct {
+	Messages []game.ChatMessage
+	Start    int
+	Total    int
+}
+
+// chatPage returns up to limit messages ending just before position before
+// (before < 0 means the end of the history) of the session item d.
+func chatPage(ctx context.Context, l sessionLog, sessionID string, d saveStateDB, before, limit int) (ChatPage, error) {
+	if limit <= 0 {
+		limit = game.ChatPageSize
+	}
+	limit = min(limit, maxChatPage)
+
+	if d.ChatHistory != nil { // v1–v4: the whole history is inline
+		total := len(d.ChatHistory)
+		from, to := pageBounds(total, before, limit)
+		return ChatPage{Messages: d.ChatHistory[from:to], Start: from, Total: total}, nil
+	}
+	all := append(append([]game.LogRange(nil), d.OlderChat...), d.ChatLog...)
+	total := game.RangeLen(all)
+	from, to := pageBounds(total, before, limit)
+	msgs, err := readChat(ctx, l, sessionID, game.SliceRanges(all, from, to))
+	if err != nil {
+		return ChatPage{}, err
+	}
+	return ChatPage{Messages: msgs, Start: from, Total: total}, nil
+}
+
+func pageBounds(total, before, limit int) (from, to int) {
+	to = total
+	if before >= 0 && before < total {
+		to = before
+	}
+	return max(to-limit, 0), to
+}
+
+// -------------------------------------------------------------------
+// DynamoDB
+// -------------------------------------------------------------------
+
+func (c *Client) lastLogSeq(ctx context.Context, sessionID, kind string) (int, error) {
+	c.requireSessionLogTable()
+	out, err := c.ddb.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(c.sessionLogTable),
+		KeyConditionExpression: aws.String("session_id = :sid AND begins_with(log_key, :kind)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":sid":  binaryIDVal(sessionID),
+			":kind": &types.AttributeValueMemberS{Value: kind + "#"},
+		},
+		ProjectionExpression: aws.String("log_key"),
+		ScanIndexForward:     aws.Bool(false),
+		Limit:                aws.Int32(1),
+		ConsistentRead:       aws.Bool(true),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("last log entry: %w", err)
+	}
+	if len(out.Items) == 0 {
+		return 0, nil
+	}
+	var row logEntryDB
+	if err := attributevalue.UnmarshalMap(out.Items[0], &row); err != nil {
+		return 0, fmt.Errorf("unmarshal log key: %w", err)
+	}
+	return logSeq(row.LogKey), nil
+}
+
+func (c *Client) putLogEntry(ctx context.Context, row logEntryDB) error {
+	c.requireSessionLogTable()
+	item, err := attributevalue.MarshalMap(row)
+	if err != nil {
+		return fmt.Errorf("marshal log entry: %w", err)
+	}
+	_, err = c.ddb.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(c.sessionLogTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(log_key)"),
+	})
+	return err
+}
+
+func (c *Client) readLogRange(ctx context.Context, sessionID, kind string, r game.LogRange) ([]logEntryDB, error) {
+	c.requireSessionLogTable()
+	var (
+		rows     []logEntryDB
+		startKey map[string]types.AttributeValue
+	)
+	for {
+		out, err := c.ddb.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(c.sessionLogTable),
+			KeyConditionExpression: aws.String("session_id = :sid AND log_key BETWEEN :from AND :to"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":sid":  binaryIDVal(sessionID),
+				":from": &types.AttributeValueMemberS{Value: logKey(kind, r.Start)},
+				":to":   &types.AttributeValueMemberS{Value: logKey(kind, r.End-1)},
+			},
+			// The session item is written after its entries, so a strongly
+			// consistent read always finds every entry it points at.
+			ConsistentRead:    aws.Bool(true),
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("query log: %w", err)
+		}
+		for _, item := range out.Items {
+			var row logEntryDB
+			if err := attributevalue.UnmarshalMap(item, &row); err != nil {
+				return nil, fmt.Errorf("unmarshal log entry: %w", err)
+			}
+			rows = append(rows, row)
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return rows, nil
+		}
+		startKey = out.LastEvaluatedKey
+	}
+}
+
+// GetChatPage returns up to limit chat messages of a session ending just
+// before position before; before < 0 pages back from the latest message.
+func (c *Client) GetChatPage(ctx context.Context, sessionID string, before, limit int) (ChatPage, error) {
+	c.requireSessionsTable()
+	out, err := c.ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:            aws.String(c.sessionsTable),
+		Key:                  sessionKey(sessionID),
+		ProjectionExpression: aws.String("chat_history, chat_log, older_chat"),
+	})
+	if err != nil {
+		return ChatPage{}, fmt.Errorf("get chat page: %w", err)
+	}
+	if out.Item == nil {
+		return ChatPage{}, fmt.Errorf("game not found: %s", sessionID)
+	}
+	var d saveStateDB
+	if err := attributevalue.UnmarshalMap(out.Item, &d); err != nil {
+		return ChatPage{}, fmt.Errorf("unmarshal chat page: %w", err)
+	}
+	return chatPage(ctx, c, sessionID, d, before, limit)
+}
+
+// ReadChat returns the chat messages at ranges of a session's log, in order.
+func (c *Client) ReadChat(ctx context.Context, sessionID string, ranges []game.LogRange) ([]game.ChatMessage, error) {
+	return readChat(ctx, c, sessionID, ranges)
+}
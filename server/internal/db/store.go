@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+)
+
+// SessionStore persists game sessions (the sessions table).
+type SessionStore interface {
+	PutGame(ctx context.Context, state game.SaveState) error
+	GetGame(ctx context.Context, sessionID string) (game.SaveState, error)
+	GetGameReady(ctx context.Context, sessionID string) (bool, error)
+	GetGameAccess(ctx context.Context, sessionID string) (game.SaveState, error)
+	ListGames(ctx context.Context, userID string) ([]game.SaveState, error)
+	DeleteGame(ctx context.Context, sessionID, userID string) error
+	ListGamesByOwner(ctx context.Context, userID string) ([]string, error)
+	BatchGetSessions(ctx context.Context, sessionIDs []string) ([]game.SaveState, error)
+	CountUserGames(ctx context.Context, userID string) (int, error)
+}
+
+// MutationStore appends to and reads back the per-session mutation log (the
+// mutations table).
+type MutationStore interface {
+	PutMutation(ctx context.Context, entry game.MutationEntry) error
+	ListMutations(ctx context.Context, sessionID string) ([]game.MutationEntry, error)
+}
+
+// SessionLogStore reads the chat history kept in the session log (the
+// session-log table) and holds out-of-character party chat. PutGame and
+// GetGame write and load the rest of the log themselves.
+type SessionLogStore interface {
+	GetChatPage(ctx context.Context, sessionID string, before, limit int) (ChatPage, error)
+	ReadChat(ctx context.Context, sessionID string, ranges []game.LogRange) ([]game.ChatMessage, error)
+	AppendOOC(ctx context.Context, sessionID string, msg game.OOCMessage) (game.OOCMessage, error)
+	RecentOOC(ctx context.Context, sessionID string) ([]game.OOCMessage, error)
+}
+
+// ConnectionStore tracks live WebSocket connections (the connections table).
+type ConnectionStore interface {
+	PutConnection(ctx context.Context, conn Connection) error
+	GetConnection(ctx context.Context, connectionID string) (Connection, error)
+	DeleteConnection(ctx context.Context, connectionID string) error
+	DeleteUserConnections(ctx context.Context, userID string) error
+	DeleteUserConnectionForGame(ctx context.Context, userID, gameID string) error
+	GetConnectionsByGameID(ctx context.Context, gameID string) ([]Connection, error)
+	GetConnectionByUserID(ctx context.Context, userID string) (Connection, error)
+}
+
+// LeaseStore holds each session's streaming lease (the leases table), the
+// lock that lets one narrator turn run at a time.
+type LeaseStore interface {
+	AcquireLease(ctx context.Context, lease Lease) error
+	ReleaseLease(ctx context.Context, sessionID, holder string) error
+	GetLease(ctx context.Context, sessionID string) (*Lease, error)
+	ForceReleaseLease(ctx context.Context, sessionID string) error
+}
+
+// UserStore holds per-user RBAC and quota records (the users table).
+type UserStore interface {
+	GetUser(ctx context.Context, userID string) (*UserRecord, error)
+	PutUser(ctx context.Context, u UserRecord) error
+	UpdateUser(ctx context.Context, userID string, u UserRecord) error
+	UpdateUserTokens(ctx context.Context, userID string, delta int) error
+	ListUsers(ctx context.Context) ([]UserRecord, error)
+}
+
+// InviteStore holds party invite codes (the invites table).
+type InviteStore interface {
+	GetInvite(ctx context.Context, code string) (*InviteRecord, error)
+	PutInvite(ctx context.Context, inv InviteRecord) error
+	IncrementInviteUses(ctx context.Context, code string) error
+}
+
+// MembershipStore links users to the sessions they belong to (the memberships table).
+type MembershipStore interface {
+	PutMembership(ctx context.Context, m MembershipRecord) error
+	GetMembershipsByUser(ctx context.Context, userID string) ([]MembershipRecord, error)
+	GetMemberSessions(ctx context.Context, userID string) ([]string, error)
+	GetSessionMembers(ctx context.Context, sessionID string) ([]MembershipRecord, error)
+	DeleteMembership(ctx context.Context, userID, sessionID string) error
+}
+
+// CheckpointStore holds named save points of sessions (the checkpoints table).
+type CheckpointStore interface {
+	PutCheckpoint(ctx context.Context, cp Checkpoint, limit int) error
+	GetCheckpoint(ctx context.Context, sessionID, checkpointID string) (Checkpoint, error)
+	ListCheckpoints(ctx context.Context, sessionID string) ([]Checkpoint, error)
+	DeleteCheckpoint(ctx context.Context, sessionID, checkpointID string) error
+}
+
+// Store is everything the handlers persist. *Client implements it against
+// DynamoDB and *Memory in process, for tests and cmd/local-server.
+type Store interface {
+	SessionStore
+	SessionLogStore
+	MutationStore
+	ConnectionStore
+	LeaseStore
+	UserStore
+	InviteStore
+	MembershipStore
+	CheckpointStore
+}
+
+var (
+	_ Store = (*Client)(nil)
+	_ Store = (*Memory)(nil)
+)
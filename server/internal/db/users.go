@@ -22,6 +22,7 @@ type UserRecord struct {
 	GamesLimit  int      `dynamodbav:"games_limit"`  // 0 = unlimited
 	BillingMode string   `dynamodbav:"billing_mode"` // "admin_granted" | "own_key" | "subscription"
 	APIKeyHash  string   `dynamodbav:"api_key_hash,omitempty"`
+	AIProvider  string   `dynamodbav:"ai_provider,omitempty"` // "" = deployment default (AI_PROVIDER)
 	CreatedAt   int64    `dynamodbav:"created_at"`
 	UpdatedAt   int64    `dynamodbav:"updated_at"`
 	Notes       string   `dynamodbav:"notes,omitempty"`
@@ -20,6 +20,7 @@ type Character struct {
 	Friendly    bool      `json:"friendly" dynamodbav:"friendly"`
 	Inventory   []string  `json:"inventory" dynamodbav:"inventory"` // item IDs
 	Equipment   Equipment `json:"equipment" dynamodbav:"equipment"`
+	XP          int       `json:"xp,omitempty" dynamodbav:"xp,omitempty"` // party members only; see Game.AwardXP
 }
 
 // NewCharacter creates a new Character with a server-generated UUID.
@@ -0,0 +1,47 @@
+package game
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/KirkDiggler/rpg-toolkit/dice"
+
+	"github.com/rrochlin/an-amazing-adventure/internal/combat"
+)
+
+// CheckRequest describes an ability check or saving throw called for by the
+// Engineer's request_ability_check tool.
+type CheckRequest struct {
+	// Skill is a skill ("stealth") or ability ("dexterity"); saving throws
+	// need an ability.
+	Skill string
+	DC    int
+	// Save rolls a saving throw instead of an ability check.
+	Save bool
+	// Reason is the in-world reason for the roll, passed on to the narrator.
+	Reason string
+}
+
+// RollCheck rolls an ability check or saving throw for userID with their real
+// modifiers (see combat.RollCheck) and queues the result in
+// PendingCombatContext so the next narrator turn narrates the outcome.
+// roller is optional; nil uses the crypto roller.
+func (g *Game) RollCheck(ctx context.Context, userID string, req CheckRequest, roller dice.Roller) (combat.CheckResult, error) {
+	dnd, ok := g.GetDnDCharacter(userID)
+	if !ok || dnd == nil {
+		return combat.CheckResult{}, fmt.Errorf("player %s has no D&D character", userID)
+	}
+	if combat.IsDead(dnd) {
+		return combat.CheckResult{}, fmt.Errorf("%s is dead", dnd.GetName())
+	}
+	res, err := combat.RollCheck(ctx, dnd, req.Skill, req.DC, req.Save, roller)
+	if err != nil {
+		return combat.CheckResult{}, err
+	}
+	line := res.String()
+	if req.Reason != "" {
+		line += fmt.Sprintf(" (%s)", req.Reason)
+	}
+	g.AppendCombatContext(line)
+	return res, nil
+}
@@ -3,6 +3,7 @@ package game
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/KirkDiggler/rpg-toolkit/events"
 	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/abilities"
@@ -10,11 +11,13 @@ import (
 	dnd5echar "github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/character"
 	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/character/choices"
 	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/classes"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/equipment"
 	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/fightingstyles"
 	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/languages"
 	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/races"
 	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/shared"
 	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/skills"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/spells"
 	"github.com/google/uuid"
 )
 
@@ -26,7 +29,10 @@ type CharacterCreationData struct {
 	Backstory string `json:"backstory,omitempty"` // optional player-written backstory
 	RaceID    string `json:"race_id"`             // e.g. "dwarf" (kebab-case, matches toolkit)
 	SubraceID string `json:"subrace_id"`          // e.g. "hill-dwarf" (empty if none)
-	ClassID   string `json:"class_id"`            // "barbarian" | "fighter" | "monk"
+	ClassID   string `json:"class_id"`            // see SupportedClasses
+	// SubclassID is only needed by classes that pick one at level 1
+	// (cleric: "life-domain", ...). Empty uses defaultSubclass.
+	SubclassID string `json:"subclass_id,omitempty"`
 
 	// Ability scores (standard array or point buy — client resolves before sending).
 	// Keys must be the short form: "str", "dex", "con", "int", "wis", "cha"
@@ -35,14 +41,30 @@ type CharacterCreationData struct {
 	// Skill selections (pick N from class list — N varies by class)
 	SelectedSkills []string `json:"selected_skills"` // e.g. ["athletics", "intimidation"]
 
+	// Spell selections for casters (kebab-case spell IDs, e.g. "fire-bolt").
+	// Cantrips: cleric/wizard 3, warlock 2. Spells: wizard spellbook 6,
+	// warlock 2; clerics prepare from their full list and choose none.
+	SelectedCantrips []string `json:"selected_cantrips,omitempty"`
+	SelectedSpells   []string `json:"selected_spells,omitempty"`
+
 	// World preferences (used by world-gen prompt — optional)
 	ThemeHint   string   `json:"theme_hint,omitempty"`
 	Preferences []string `json:"preferences,omitempty"`
 }
 
-// SupportedClasses lists the only classes with mechanically implemented
-// features in rpg-toolkit as of the current version.
-var SupportedClasses = []string{classes.Barbarian, classes.Fighter, classes.Monk}
+// KnownSpells returns the selected cantrips followed by the selected spells.
+func (d CharacterCreationData) KnownSpells() []string {
+	known := make([]string, 0, len(d.SelectedCantrips)+len(d.SelectedSpells))
+	known = append(known, d.SelectedCantrips...)
+	return append(known, d.SelectedSpells...)
+}
+
+// SupportedClasses lists the classes with mechanically implemented features:
+// martial classes via rpg-toolkit, casters via the combat spell subsystem.
+var SupportedClasses = []string{
+	classes.Barbarian, classes.Fighter, classes.Monk,
+	classes.Cleric, classes.Wizard, classes.Warlock,
+}
 
 // SupportedRaces lists races that can be fully constructed by BuildDnDCharacter.
 // Half-Elf is excluded because rpg-toolkit v0.51.0 records its racial skill choice
@@ -86,7 +108,7 @@ func BuildDnDCharacter(ctx context.Context, input CharacterCreationData) (*dnd5e
 		}
 	}
 	if !validClass {
-		return nil, fmt.Errorf("class %q is not available; supported classes: barbarian, fighter, monk", input.ClassID)
+		return nil, fmt.Errorf("class %q is not available; supported classes: %s", input.ClassID, strings.Join(SupportedClasses, ", "))
 	}
 
 	// Validate race
@@ -124,6 +146,20 @@ func BuildDnDCharacter(ctx context.Context, input CharacterCreationData) (*dnd5e
 		resolvedSkills = append(resolvedSkills, sk)
 	}
 
+	cantrips := make([]spells.Spell, 0, len(input.SelectedCantrips))
+	for _, s := range input.SelectedCantrips {
+		cantrips = append(cantrips, spells.Spell(s))
+	}
+	knownSpells := make([]spells.Spell, 0, len(input.SelectedSpells))
+	for _, s := range input.SelectedSpells {
+		knownSpells = append(knownSpells, spells.Spell(s))
+	}
+
+	subclass := classes.Subclass(input.SubclassID)
+	if subclass == "" {
+		subclass = defaultSubclass(input.ClassID)
+	}
+
 	// Create a fresh event bus for character construction
 	bus := events.NewEventBus()
 	characterID := uuid.NewString()
@@ -149,9 +185,12 @@ func BuildDnDCharacter(ctx context.Context, input CharacterCreationData) (*dnd5e
 	}
 
 	if err := draft.SetClass(&dnd5echar.SetClassInput{
-		ClassID: classes.Class(input.ClassID),
+		ClassID:    classes.Class(input.ClassID),
+		SubclassID: subclass,
 		Choices: dnd5echar.ClassChoices{
 			Skills:        resolvedSkills,
+			Cantrips:      cantrips,
+			Spells:        knownSpells,
 			Equipment:     defaultEquipmentChoices(input.ClassID),
 			FightingStyle: defaultFightingStyle(input.ClassID),
 			Tools:         defaultToolChoices(input.ClassID),
@@ -159,6 +198,11 @@ func BuildDnDCharacter(ctx context.Context, input CharacterCreationData) (*dnd5e
 	}); err != nil {
 		return nil, fmt.Errorf("set class: %w", err)
 	}
+	if focus := defaultFocusChoice(input.ClassID); focus != nil {
+		data := draft.ToData()
+		data.Choices = append(data.Choices, *focus)
+		draft = dnd5echar.LoadDraftFromData(data)
+	}
 
 	// Folk Hero: skills are fixed (Animal Handling + Survival) so no choices needed.
 	if err := draft.SetBackground(&dnd5echar.SetBackgroundInput{
@@ -174,7 +218,12 @@ func BuildDnDCharacter(ctx context.Context, input CharacterCreationData) (*dnd5e
 		return nil, fmt.Errorf("set ability scores: %w", err)
 	}
 
-	char, err := draft.ToCharacter(ctx, characterID, bus)
+	var char *dnd5echar.Character
+	if needsLevel1Subclass(input.ClassID) {
+		char, err = finalizeWithSubclass(ctx, draft, characterID, bus)
+	} else {
+		char, err = draft.ToCharacter(ctx, characterID, bus)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("finalize character: %w", err)
 	}
@@ -286,10 +335,155 @@ func defaultEquipmentChoices(classID string) []dnd5echar.EquipmentChoiceSelectio
 			{ChoiceID: choices.MonkWeaponsPrimary, OptionID: choices.MonkWeaponShortsword},
 			{ChoiceID: choices.MonkPack, OptionID: choices.MonkPackDungeoneer},
 		}
+	case classes.Cleric:
+		return []dnd5echar.EquipmentChoiceSelection{
+			{ChoiceID: choices.ClericWeapons, OptionID: choices.ClericWeaponMace},
+			{ChoiceID: choices.ClericArmor, OptionID: choices.ClericArmorScale},
+			{ChoiceID: choices.ClericSecondaryWeapon, OptionID: choices.ClericSecondaryShortbow},
+			{ChoiceID: choices.ClericPack, OptionID: choices.ClericPackPriest},
+		}
+	case classes.Wizard:
+		return []dnd5echar.EquipmentChoiceSelection{
+			{ChoiceID: choices.WizardWeaponsPrimary, OptionID: choices.WizardWeaponQuarterstaff},
+			{ChoiceID: choices.WizardPack, OptionID: choices.WizardPackScholar},
+		}
+	case classes.Warlock:
+		// The secondary "any simple weapon" option requires a category selection,
+		// as does the arcane focus (a staff doubles as a quarterstaff).
+		return []dnd5echar.EquipmentChoiceSelection{
+			{ChoiceID: choices.WarlockWeaponsPrimary, OptionID: choices.WarlockWeaponCrossbow},
+			{ChoiceID: choices.WarlockFocus, OptionID: choices.WarlockFocusArcane,
+				CategorySelections: []shared.EquipmentID{"quarterstaff"}},
+			{ChoiceID: choices.WarlockPack, OptionID: choices.WarlockPackDungeoneer},
+			{ChoiceID: choices.WarlockWeaponsSecondary, OptionID: choices.WarlockWeaponSecondary,
+				CategorySelections: []shared.EquipmentID{"dagger"}},
+		}
 	}
 	return nil
 }
 
+// defaultFocusChoice returns a pre-recorded spellcasting focus choice for
+// casters whose focus options the toolkit cannot satisfy.
+//
+// TODO(toolkit): "component-pouch", "arcane-focus" and "holy-symbol" are
+// listed in the wizard and cleric equipment requirements but are not in the
+// equipment registry, so SetClass rejects every focus option and
+// ValidateChoices then fails with "... required". Until they are registered
+// upstream, the choice is recorded directly on the draft with a registered
+// stand-in: a quarterstaff for the wizard's staff focus, and a shield bearing
+// the cleric's holy emblem.
+func defaultFocusChoice(classID string) *choices.ChoiceData {
+	focus := choices.ChoiceData{Category: shared.ChoiceEquipment, Source: shared.SourceClass}
+	switch classID {
+	case classes.Wizard:
+		focus.ChoiceID = choices.WizardFocus
+		focus.OptionID = choices.WizardFocusStaff
+		focus.EquipmentSelection = []shared.SelectionID{"quarterstaff"}
+	case classes.Cleric:
+		focus.ChoiceID = choices.ClericHolySymbol
+		focus.OptionID = choices.ClericHolyAmulet
+		focus.EquipmentSelection = []shared.SelectionID{"shield"}
+	default:
+		return nil
+	}
+	return &focus
+}
+
+// defaultSubclass returns the default subclass for classes that choose one
+// at level 1. Clerics default to the Life Domain.
+func defaultSubclass(classID string) classes.Subclass {
+	if classID == classes.Cleric {
+		return classes.LifeDomain
+	}
+	return ""
+}
+
+// needsLevel1Subclass reports whether a class picks its subclass at level 1.
+func needsLevel1Subclass(classID string) bool {
+	data := classes.GetData(classes.Class(classID))
+	return data != nil && data.SubclassLevel == 1
+}
+
+// finalizeWithSubclass assembles a level-1 character from a draft whose
+// class picks a subclass at level 1, then loads it like a persisted one.
+//
+// TODO(toolkit): Draft.ValidateChoices never turns the recorded subclass into
+// a ChoiceClass submission, so ToCharacter fails with "Divine Domain
+// required" for every level-1 cleric. This mirrors ToCharacter for classes
+// without level-1 grants (all casters); switch back once fixed upstream.
+func finalizeWithSubclass(ctx context.Context, draft *dnd5echar.Draft, characterID string, bus events.EventBus) (*dnd5echar.Character, error) {
+	raceData := races.GetData(draft.Race())
+	classData := classes.GetData(draft.Class())
+	if raceData == nil || classData == nil {
+		return nil, fmt.Errorf("unknown race %q or class %q", draft.Race(), draft.Class())
+	}
+	if draft.Subclass() == "" {
+		return nil, fmt.Errorf("%s required", classData.SubclassLabel)
+	}
+
+	scores := make(shared.AbilityScores)
+	for ab, v := range draft.BaseAbilityScores() {
+		scores[ab] = v
+	}
+	for ab, bonus := range raceData.AbilityIncreases {
+		scores[ab] += bonus
+	}
+
+	data := &dnd5echar.Data{
+		ID:               characterID,
+		PlayerID:         draft.PlayerID(),
+		Name:             draft.Name(),
+		Level:            1,
+		ProficiencyBonus: 2,
+		RaceID:           draft.Race(),
+		SubraceID:        draft.Subrace(),
+		ClassID:          draft.Class(),
+		SubclassID:       draft.Subclass(),
+		BackgroundID:     draft.Background(),
+		AbilityScores:    scores,
+		HitPoints:        classData.HitDice + scores.Modifier(abilities.CON),
+		MaxHitPoints:     classData.HitDice + scores.Modifier(abilities.CON),
+		ArmorClass:       10 + scores.Modifier(abilities.DEX),
+		Skills:           make(map[skills.Skill]shared.ProficiencyLevel),
+		SavingThrows:     make(map[abilities.Ability]shared.ProficiencyLevel),
+		Languages:        append([]languages.Language{}, raceData.Languages...),
+		SpellSlots:       map[int]dnd5echar.SpellSlotData{1: {Max: 2}},
+	}
+	for _, sk := range raceData.Skills {
+		data.Skills[sk] = shared.Proficient
+	}
+	for _, ab := range classData.SavingThrows {
+		data.SavingThrows[ab] = shared.Proficient
+	}
+	if grant := races.GetGrants(draft.Race()); grant != nil {
+		data.ArmorProficiencies = append(data.ArmorProficiencies, grant.ArmorProficiencies...)
+		data.WeaponProficiencies = append(data.WeaponProficiencies, grant.WeaponProficiencies...)
+		data.ToolProficiencies = append(data.ToolProficiencies, grant.ToolProficiencies...)
+	}
+	for _, choice := range draft.Choices() {
+		switch choice.Category {
+		case shared.ChoiceSkills:
+			for _, sk := range choice.SkillSelection {
+				data.Skills[sk] = shared.Proficient
+			}
+		case shared.ChoiceLanguages:
+			data.Languages = append(data.Languages, choice.LanguageSelection...)
+		case shared.ChoiceEquipment:
+			for _, id := range choice.EquipmentSelection {
+				eq, err := equipment.GetByID(id)
+				if err != nil {
+					return nil, fmt.Errorf("equipment %q: %w", id, err)
+				}
+				data.Inventory = append(data.Inventory, dnd5echar.InventoryItemData{
+					Type: eq.EquipmentType(), ID: eq.EquipmentID(), Quantity: 1,
+				})
+			}
+		}
+	}
+
+	return dnd5echar.LoadFromData(ctx, data, bus)
+}
+
 // defaultFightingStyle returns the default fighting style for classes that require one.
 func defaultFightingStyle(classID string) fightingstyles.FightingStyle {
 	if classID == classes.Fighter {
@@ -4,6 +4,9 @@ import (
 	"context"
 	"testing"
 
+	dnd5echar "github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/character"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/saves"
+
 	"github.com/rrochlin/an-amazing-adventure/internal/game"
 )
 
@@ -77,17 +80,70 @@ func TestBuildDnDCharacter_Monk(t *testing.T) {
 	}
 }
 
+// casterCreationData returns valid creation data for the supported casters.
+func casterCreationData() []game.CharacterCreationData {
+	return []game.CharacterCreationData{
+		{
+			Name: "Mira", RaceID: "human", ClassID: "cleric",
+			AbilityScores:    standardAbilityScores(),
+			SelectedSkills:   []string{"medicine", "religion"},
+			SelectedCantrips: []string{"sacred-flame", "toll-the-dead", "light"},
+		},
+		{
+			Name: "Elric", RaceID: "elf", SubraceID: "high-elf", ClassID: "wizard",
+			AbilityScores:    standardAbilityScores(),
+			SelectedSkills:   []string{"arcana", "history"},
+			SelectedCantrips: []string{"fire-bolt", "ray-of-frost", "light"},
+			SelectedSpells:   []string{"magic-missile", "burning-hands", "thunderwave", "shield", "sleep", "detect-magic"},
+		},
+		{
+			Name: "Vex", RaceID: "tiefling", ClassID: "warlock",
+			AbilityScores:    standardAbilityScores(),
+			SelectedSkills:   []string{"arcana", "deception"},
+			SelectedCantrips: []string{"eldritch-blast", "chill-touch"},
+			SelectedSpells:   []string{"arms-of-hadar", "hex"},
+		},
+	}
+}
+
+func TestBuildDnDCharacter_Casters(t *testing.T) {
+	ctx := context.Background()
+	for _, in := range casterCreationData() {
+		char, err := game.BuildDnDCharacter(ctx, in)
+		if err != nil {
+			t.Fatalf("BuildDnDCharacter %s: %v", in.ClassID, err)
+		}
+		data := char.ToData()
+		if string(data.ClassID) != in.ClassID {
+			t.Errorf("expected class %s, got %q", in.ClassID, data.ClassID)
+		}
+		if char.GetHitPoints() <= 0 {
+			t.Errorf("%s: expected positive HP, got %d", in.ClassID, char.GetHitPoints())
+		}
+	}
+}
+
+func TestBuildDnDCharacter_ClericDefaultsDomain(t *testing.T) {
+	char, err := game.BuildDnDCharacter(context.Background(), casterCreationData()[0])
+	if err != nil {
+		t.Fatalf("BuildDnDCharacter: %v", err)
+	}
+	if got := char.ToData().SubclassID; got != "life-domain" {
+		t.Errorf("expected life-domain, got %q", got)
+	}
+}
+
 func TestBuildDnDCharacter_InvalidClass(t *testing.T) {
 	ctx := context.Background()
 	_, err := game.BuildDnDCharacter(ctx, game.CharacterCreationData{
 		Name:           "Nobody",
 		RaceID:         "human",
-		ClassID:        "wizard", // not supported
+		ClassID:        "paladin", // not supported
 		AbilityScores:  standardAbilityScores(),
 		SelectedSkills: []string{},
 	})
 	if err == nil {
-		t.Error("expected error for unsupported class wizard")
+		t.Error("expected error for unsupported class paladin")
 	}
 }
 
@@ -254,3 +310,295 @@ func TestBuildDnDCharacter_ToDataRoundTrip(t *testing.T) {
 		t.Error("expected non-empty character context")
 	}
 }
+
+// ── Dungeon lifecycle ─────────────────────────────────────────────────────────
+
+func TestUpdateDungeonState_FailedWhenPartyDown(t *testing.T) {
+	ctx := context.Background()
+	char, err := game.BuildDnDCharacter(ctx, game.CharacterCreationData{
+		Name:           "Grak",
+		RaceID:         "half-orc",
+		ClassID:        "barbarian",
+		AbilityScores:  standardAbilityScores(),
+		SelectedSkills: []string{"athletics", "intimidation"},
+	})
+	if err != nil {
+		t.Fatalf("BuildDnDCharacter: %v", err)
+	}
+	g := game.NewGame("session-1", "user-1")
+	g.DungeonData = &game.DungeonData{BossRoomID: "boss"}
+
+	data := char.ToData()
+	if _, err := g.LoadDnDCharacters(ctx, map[string]*dnd5echar.Data{"user-1": data}); err != nil {
+		t.Fatalf("LoadDnDCharacters: %v", err)
+	}
+	if g.UpdateDungeonState() {
+		t.Fatal("a healthy party must not end the run")
+	}
+
+	data.HitPoints = 0
+	if _, err := g.LoadDnDCharacters(ctx, map[string]*dnd5echar.Data{"user-1": data}); err != nil {
+		t.Fatalf("LoadDnDCharacters: %v", err)
+	}
+	if g.UpdateDungeonState() {
+		t.Fatal("a dying party member may still roll a 20 — the run must not end yet")
+	}
+
+	data.DeathSaveState = &saves.DeathSaveState{Failures: 3, Dead: true}
+	if _, err := g.LoadDnDCharacters(ctx, map[string]*dnd5echar.Data{"user-1": data}); err != nil {
+		t.Fatalf("LoadDnDCharacters: %v", err)
+	}
+	if !g.UpdateDungeonState() {
+		t.Fatal("expected state change when no party member is left standing or dying")
+	}
+	if g.DungeonData.State != game.DungeonStateFailed || g.Outcome().State != "failed" {
+		t.Errorf("expected failed outcome, got %v / %+v", g.DungeonData.State, g.Outcome())
+	}
+	if g.UpdateDungeonState() {
+		t.Error("an ended run must not transition again")
+	}
+}
+
+// ── Spell slots ──────────────────────────────────────────────────────────────
+
+func TestSpellSlots_PersistAndRecover(t *testing.T) {
+	ctx := context.Background()
+	wizard := casterCreationData()[1]
+	char, err := game.BuildDnDCharacter(ctx, wizard)
+	if err != nil {
+		t.Fatalf("BuildDnDCharacter: %v", err)
+	}
+	g := game.NewGame("sess-spells", "user-1")
+	g.SetDnDCharacter("user-1", char)
+	g.SetKnownSpells("user-1", wizard.KnownSpells())
+
+	slots := g.PlayerSpellSlots("user-1")
+	if slots[1].Max != 2 || slots[1].Used != 0 {
+		t.Fatalf("expected two fresh 1st-level slots, got %+v", slots[1])
+	}
+	slots[1] = dnd5echar.SpellSlotData{Max: 2, Used: 1}
+
+	ss := g.ToSaveState(nil, nil)
+	restored, err := game.FromSaveState(ss)
+	if err != nil {
+		t.Fatalf("FromSaveState: %v", err)
+	}
+	if _, err := restored.LoadDnDCharacters(ctx, ss.PlayersData); err != nil {
+		t.Fatalf("LoadDnDCharacters: %v", err)
+	}
+	if got := restored.PlayerSpellSlots("user-1")[1].Used; got != 1 {
+		t.Errorf("expected 1 used slot after reload, got %d", got)
+	}
+	if got := len(restored.KnownSpells["user-1"]); got != 9 {
+		t.Errorf("expected 9 known spells after reload, got %d", got)
+	}
+
+	restored.RecoverSpellSlots(false)
+	if got := restored.PlayerSpellSlots("user-1")[1].Used; got != 1 {
+		t.Errorf("short rest must not restore wizard slots, got %d used", got)
+	}
+	restored.RecoverSpellSlots(true)
+	if got := restored.PlayerSpellSlots("user-1")[1].Used; got != 0 {
+		t.Errorf("long rest must restore all slots, got %d used", got)
+	}
+}
+
+func TestSpellSlots_NonCaster(t *testing.T) {
+	char, err := game.BuildDnDCharacter(context.Background(), game.CharacterCreationData{
+		Name:           "Brom",
+		RaceID:         "dwarf",
+		ClassID:        "fighter",
+		AbilityScores:  standardAbilityScores(),
+		SelectedSkills: []string{"athletics", "perception"},
+	})
+	if err != nil {
+		t.Fatalf("BuildDnDCharacter: %v", err)
+	}
+	g := game.NewGame("sess-fighter", "user-1")
+	g.SetDnDCharacter("user-1", char)
+	if slots := g.PlayerSpellSlots("user-1"); slots != nil {
+		t.Errorf("expected no slots for a fighter, got %v", slots)
+	}
+}
+
+// ── Leveling ─────────────────────────────────────────────────────────────────
+
+func newLevelingGame(t *testing.T, in game.CharacterCreationData) *game.Game {
+	t.Helper()
+	char, err := game.BuildDnDCharacter(context.Background(), in)
+	if err != nil {
+		t.Fatalf("BuildDnDCharacter: %v", err)
+	}
+	g := game.NewGame("sess-lvl", "user-1")
+	hero := game.NewCharacter(in.Name, "")
+	hero.LocationID = "room-1"
+	g.SetPlayerCharacter("user-1", hero)
+	g.SetDnDCharacter("user-1", char)
+	g.SetKnownSpells("user-1", in.KnownSpells())
+	return g
+}
+
+func TestAwardXP_SplitsAmongRoom(t *testing.T) {
+	g := game.NewGame("sess-xp", "user-1")
+	for uid, loc := range map[string]string{"user-1": "room-1", "user-2": "room-1", "user-3": "room-2"} {
+		c := game.NewCharacter(uid, "")
+		c.LocationID = loc
+		g.SetPlayerCharacter(uid, c)
+	}
+	if share := g.AwardXP("room-1", 100); share != 50 {
+		t.Errorf("expected 50 XP each, got %d", share)
+	}
+	if c, _ := g.GetPlayerCharacter("user-3"); c.XP != 0 {
+		t.Errorf("members elsewhere must not share the XP, got %d", c.XP)
+	}
+	restored, err := game.FromSaveState(g.ToSaveState(nil, nil))
+	if err != nil {
+		t.Fatalf("FromSaveState: %v", err)
+	}
+	if c, _ := restored.GetPlayerCharacter("user-2"); c.XP != 50 {
+		t.Errorf("expected XP to persist, got %d", c.XP)
+	}
+}
+
+func TestLevelUp_RequiresXP(t *testing.T) {
+	g := newLevelingGame(t, game.CharacterCreationData{
+		Name: "Brom", RaceID: "dwarf", ClassID: "fighter",
+		AbilityScores:  standardAbilityScores(),
+		SelectedSkills: []string{"athletics", "perception"},
+	})
+	if _, err := g.LevelUp(context.Background(), "user-1", game.LevelUpRequest{}, nil); err == nil {
+		t.Error("expected level up without XP to fail")
+	}
+}
+
+func TestLevelUp_FighterGainsActionSurge(t *testing.T) {
+	ctx := context.Background()
+	g := newLevelingGame(t, game.CharacterCreationData{
+		Name: "Brom", RaceID: "dwarf", ClassID: "fighter",
+		AbilityScores:  standardAbilityScores(),
+		SelectedSkills: []string{"athletics", "perception"},
+	})
+	before, _ := g.GetDnDCharacter("user-1")
+	maxHP := before.GetMaxHitPoints()
+	g.AwardXP("room-1", 300)
+
+	res, err := g.LevelUp(ctx, "user-1", game.LevelUpRequest{HPMethod: "average"}, nil)
+	if err != nil {
+		t.Fatalf("LevelUp: %v", err)
+	}
+	after, _ := g.GetDnDCharacter("user-1")
+	if res.Level != 2 || after.GetLevel() != 2 {
+		t.Errorf("expected level 2, got result %d character %d", res.Level, after.GetLevel())
+	}
+	if after.GetMaxHitPoints() != maxHP+res.HPGained || res.HPGained < 6 {
+		t.Errorf("expected max HP %d + average d10, got %d (+%d)", maxHP, after.GetMaxHitPoints(), res.HPGained)
+	}
+	if len(res.NewFeatures) != 1 || res.NewFeatures[0] != "Action Surge" {
+		t.Errorf("expected Action Surge, got %v", res.NewFeatures)
+	}
+	view := g.BuildGameStateView("user-1", nil)
+	if view.Self.DnD.Level != 2 || view.Self.DnD.CanLevelUp {
+		t.Errorf("expected level 2 in DnDStatsView, got %+v", view.Self.DnD)
+	}
+}
+
+func TestLevelUp_WizardGrowsSlotsAndSpellbook(t *testing.T) {
+	ctx := context.Background()
+	g := newLevelingGame(t, casterCreationData()[1])
+	g.AwardXP("room-1", 900)
+	slots := g.PlayerSpellSlots("user-1")
+	slots[1] = dnd5echar.SpellSlotData{Max: 2, Used: 1}
+
+	if _, err := g.LevelUp(ctx, "user-1", game.LevelUpRequest{Spells: []string{"fireball"}}, nil); err == nil {
+		t.Error("expected a 3rd-level spell to be refused at level 2")
+	}
+	if _, err := g.LevelUp(ctx, "user-1", game.LevelUpRequest{Spells: []string{"guiding-bolt"}}, nil); err == nil {
+		t.Error("expected another class's spell to be refused")
+	}
+	if _, err := g.LevelUp(ctx, "user-1", game.LevelUpRequest{Spells: []string{"chromatic-orb"}}, nil); err != nil {
+		t.Fatalf("LevelUp to 2: %v", err)
+	}
+	if _, err := g.LevelUp(ctx, "user-1", game.LevelUpRequest{}, nil); err != nil {
+		t.Fatalf("LevelUp to 3: %v", err)
+	}
+	slots = g.PlayerSpellSlots("user-1")
+	if slots[1].Max != 4 || slots[1].Used != 1 || slots[2].Max != 2 {
+		t.Errorf("expected 4×1st (1 used) and 2×2nd slots at level 3, got %v", slots)
+	}
+	if got := g.KnownSpells["user-1"]; got[len(got)-1] != "chromatic-orb" {
+		t.Errorf("expected chromatic-orb in the spellbook, got %v", got)
+	}
+}
+
+func TestLevelUp_AbilityScoreImprovement(t *testing.T) {
+	ctx := context.Background()
+	g := newLevelingGame(t, game.CharacterCreationData{
+		Name: "Grak", RaceID: "half-orc", ClassID: "barbarian",
+		AbilityScores:  standardAbilityScores(),
+		SelectedSkills: []string{"athletics", "intimidation"},
+	})
+	g.AwardXP("room-1", 2700)
+	for i := 0; i < 2; i++ {
+		if _, err := g.LevelUp(ctx, "user-1", game.LevelUpRequest{}, nil); err != nil {
+			t.Fatalf("LevelUp: %v", err)
+		}
+	}
+	if _, err := g.LevelUp(ctx, "user-1", game.LevelUpRequest{}, nil); err == nil {
+		t.Error("expected level 4 without an ability choice to fail")
+	}
+	dnd, _ := g.GetDnDCharacter("user-1")
+	str := dnd.ToData().AbilityScores["str"]
+	res, err := g.LevelUp(ctx, "user-1", game.LevelUpRequest{AbilityIncreases: map[string]int{"str": 2}}, nil)
+	if err != nil {
+		t.Fatalf("LevelUp to 4: %v", err)
+	}
+	dnd, _ = g.GetDnDCharacter("user-1")
+	if got := dnd.ToData().AbilityScores["str"]; got != str+2 {
+		t.Errorf("expected STR %d, got %d", str+2, got)
+	}
+	if res.NewFeatures[0] != "Ability Score Improvement" {
+		t.Errorf("expected the improvement listed, got %v", res.NewFeatures)
+	}
+}
+
+// ── Dying ────────────────────────────────────────────────────────────────────
+
+func TestDying_ViewAndLegacyAlive(t *testing.T) {
+	ctx := context.Background()
+	char, err := game.BuildDnDCharacter(ctx, game.CharacterCreationData{
+		Name: "Brom", RaceID: "dwarf", ClassID: "fighter",
+		AbilityScores:  standardAbilityScores(),
+		SelectedSkills: []string{"athletics", "perception"},
+	})
+	if err != nil {
+		t.Fatalf("BuildDnDCharacter: %v", err)
+	}
+	g := game.NewGame("sess-dying", "user-1")
+	g.SetPlayerCharacter("user-1", game.NewCharacter("Brom", ""))
+
+	data := char.ToData()
+	data.HitPoints = 0
+	data.DeathSaveState = &saves.DeathSaveState{Failures: 1}
+	if _, err := g.LoadDnDCharacters(ctx, map[string]*dnd5echar.Data{"user-1": data}); err != nil {
+		t.Fatalf("LoadDnDCharacters: %v", err)
+	}
+	self := g.BuildGameStateView("user-1", nil).Self
+	if self.Vital != "dying" || !self.Alive || self.DeathSaves == nil || self.DeathSaves.Failures != 1 {
+		t.Errorf("expected a living, dying view with 1 failure, got vital=%q alive=%v saves=%+v", self.Vital, self.Alive, self.DeathSaves)
+	}
+	if err := g.CheckConscious("user-1"); err == nil {
+		t.Error("expected an unconscious player to be refused actions")
+	}
+
+	data.DeathSaveState = &saves.DeathSaveState{Failures: 3, Dead: true}
+	if _, err := g.LoadDnDCharacters(ctx, map[string]*dnd5echar.Data{"user-1": data}); err != nil {
+		t.Fatalf("LoadDnDCharacters: %v", err)
+	}
+	if log, _ := g.DeathSave(ctx, "user-1", nil); log != "" {
+		t.Errorf("the dead make no death saves, got %q", log)
+	}
+	saved := g.ToSaveState(nil, nil)
+	if saved.Players["user-1"].Alive {
+		t.Error("expected the legacy Alive flag to follow the character's death")
+	}
+}
@@ -1,6 +1,12 @@
 package game
 
-import "time"
+import (
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/rrochlin/an-amazing-adventure/internal/combat"
+)
 
 // DungeonState represents the current state of a dungeon instance.
 type DungeonState int
@@ -14,6 +20,18 @@ const (
 	DungeonStateFailed
 )
 
+// String returns the wire name of the state ("active", "cleared", "failed").
+func (s DungeonState) String() string {
+	switch s {
+	case DungeonStateCleared:
+		return "cleared"
+	case DungeonStateFailed:
+		return "failed"
+	default:
+		return "active"
+	}
+}
+
 // DungeonRoomType categorises rooms for encounter seeding and narrative framing.
 type DungeonRoomType string
 
@@ -51,6 +69,12 @@ type DungeonRoomData struct {
 	// Coordinates are the 2D map coordinates assigned during world-gen.
 	// X increases east, Y increases south, Z is floor level.
 	Coordinates Coordinates `json:"coordinates" dynamodbav:"coordinates"`
+
+	// Width and Height are the room's combat grid size in hexes (from
+	// environments.ZoneData). Zero for dungeons generated before combat grids —
+	// RoomGridSize falls back to a size by room type.
+	Width  int `json:"width,omitempty" dynamodbav:"width,omitempty"`
+	Height int `json:"height,omitempty" dynamodbav:"height,omitempty"`
 }
 
 // DungeonData is the persistent dungeon layout produced by world-gen.
@@ -76,12 +100,142 @@ type DungeonData struct {
 	// RevealedRooms tracks which rooms players have visited (for fog-of-war).
 	RevealedRooms map[string]bool `json:"revealed_rooms,omitempty" dynamodbav:"revealed_rooms,omitempty"`
 
+	// MemberRevealedRooms tracks fog-of-war per party member (userID → roomID set).
+	// RevealedRooms remains the union across the party.
+	MemberRevealedRooms map[string]map[string]bool `json:"member_revealed_rooms,omitempty" dynamodbav:"member_revealed_rooms,omitempty"`
+
 	// Seed is the RNG seed used for deterministic generation.
 	Seed int64 `json:"seed" dynamodbav:"seed"`
 
 	// State is the dungeon lifecycle stage.
 	State DungeonState `json:"state" dynamodbav:"state"`
 
+	// EpilogueWritten is set once the narrator has closed the story of a
+	// cleared or failed run.
+	EpilogueWritten bool `json:"epilogue_written,omitempty" dynamodbav:"epilogue_written,omitempty"`
+
 	// CreatedAt is when world-gen completed.
 	CreatedAt time.Time `json:"created_at" dynamodbav:"created_at"`
 }
+
+// Reveal marks roomID as visited by userID, updating both the member's own
+// set and the party-wide RevealedRooms. A member's first reveal seeds their
+// set with the shared one, which is what RevealedTo showed them until now.
+func (d *DungeonData) Reveal(userID, roomID string) {
+	if d.RevealedRooms == nil {
+		d.RevealedRooms = make(map[string]bool)
+	}
+	d.RevealedRooms[roomID] = true
+	if userID == "" {
+		return
+	}
+	if d.MemberRevealedRooms == nil {
+		d.MemberRevealedRooms = make(map[string]map[string]bool)
+	}
+	if d.MemberRevealedRooms[userID] == nil {
+		d.MemberRevealedRooms[userID] = maps.Clone(d.RevealedRooms)
+	}
+	d.MemberRevealedRooms[userID][roomID] = true
+}
+
+// RevealedTo returns the set of rooms userID has visited. Members with no
+// per-member record (games saved before it existed) fall back to the shared
+// RevealedRooms set. The returned map must not be modified.
+func (d *DungeonData) RevealedTo(userID string) map[string]bool {
+	if rooms, ok := d.MemberRevealedRooms[userID]; ok {
+		return rooms
+	}
+	return d.RevealedRooms
+}
+
+// DungeonOver reports whether the dungeon run has ended (cleared or failed).
+// Games without DungeonData never end.
+func (g *Game) DungeonOver() bool {
+	return g.DungeonData != nil && g.DungeonData.State != DungeonStateActive
+}
+
+// UpdateDungeonState applies the end-of-run rules to an active dungeon and
+// returns true if the state changed:
+//
+//   - Cleared when the boss room has no live monsters left: they were all
+//     killed, or it never had any and a party member has entered it.
+//   - Failed when the party has D&D characters and every one is at 0 HP.
+//
+// A boss kill that also drops the last party member counts as a clear.
+func (g *Game) UpdateDungeonState() bool {
+	if g.DungeonData == nil || g.DungeonData.State != DungeonStateActive {
+		return false
+	}
+	bossID := g.DungeonData.BossRoomID
+	if bossID != "" && !g.HasLiveMonstersInRoom(bossID) && (len(g.GetRoomMonsters(bossID)) > 0 || g.partyEntered(bossID)) {
+		g.DungeonData.State = DungeonStateCleared
+		g.EndCombat()
+		return true
+	}
+	if g.partyWiped() {
+		g.DungeonData.State = DungeonStateFailed
+		g.EndCombat()
+		return true
+	}
+	return false
+}
+
+// partyEntered reports whether a party member has been in roomID: it is
+// revealed, or someone stands in it.
+func (g *Game) partyEntered(roomID string) bool {
+	if g.DungeonData.RevealedRooms[roomID] {
+		return true
+	}
+	for _, uid := range g.PartyOrder() {
+		if c, ok := g.GetPlayerCharacter(uid); ok && c.LocationID == roomID {
+			return true
+		}
+	}
+	return false
+}
+
+// partyWiped returns true when the party has at least one D&D character and
+// all of them are at 0 HP and no longer dying (stable or dead) — a dying
+// member may still roll a 20 on a death save.
+func (g *Game) partyWiped() bool {
+	if len(g.DnDPlayers) == 0 {
+		return false
+	}
+	for _, c := range g.DnDPlayers {
+		if c == nil {
+			continue
+		}
+		if vital := combat.Vital(c); vital == combat.VitalConscious || vital == combat.VitalDying {
+			return false
+		}
+	}
+	return true
+}
+
+// DungeonOutcome is the payload of the dungeon_ended WebSocket frame and the
+// summary handed to the narrator for the epilogue, which it writes in a
+// turn of its own right after the run ends.
+type DungeonOutcome struct {
+	State   string `json:"state"`   // "cleared" | "failed"
+	Message string `json:"message"` // human-readable summary
+}
+
+// Outcome describes how the run ended. Returns a zero value while the
+// dungeon is still active.
+func (g *Game) Outcome() DungeonOutcome {
+	if !g.DungeonOver() {
+		return DungeonOutcome{}
+	}
+	out := DungeonOutcome{State: g.DungeonData.State.String()}
+	switch g.DungeonData.State {
+	case DungeonStateCleared:
+		bossName := "the dungeon's master"
+		if r, ok := g.DungeonData.Rooms[g.DungeonData.BossRoomID]; ok && r.Name != "" {
+			bossName = "the guardians of " + r.Name
+		}
+		out.Message = fmt.Sprintf("Victory! The party has defeated %s.", bossName)
+	case DungeonStateFailed:
+		out.Message = "The party has fallen. The dungeon claims another band of adventurers."
+	}
+	return out
+}
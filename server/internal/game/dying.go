@@ -0,0 +1,61 @@
+package game
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/KirkDiggler/rpg-toolkit/dice"
+
+	"github.com/rrochlin/an-amazing-adventure/internal/combat"
+)
+
+// DeathSavesView is the client-facing death save tally of a character at 0 HP.
+type DeathSavesView struct {
+	Successes int `json:"successes"`
+	Failures  int `json:"failures"`
+}
+
+// PlayerVital returns userID's state under the dying rules (see
+// combat.Vital), or "" if they have no D&D character.
+func (g *Game) PlayerVital(userID string) string {
+	dnd, ok := g.GetDnDCharacter(userID)
+	if !ok || dnd == nil {
+		return ""
+	}
+	return combat.Vital(dnd)
+}
+
+// CheckConscious returns an error if userID is unconscious or dead.
+func (g *Game) CheckConscious(userID string) error {
+	switch g.PlayerVital(userID) {
+	case combat.VitalDead:
+		return fmt.Errorf("you are dead")
+	case combat.VitalDying, combat.VitalStable:
+		return fmt.Errorf("you are unconscious")
+	}
+	return nil
+}
+
+// DeathSave rolls userID's death saving throw at the start of their turn and
+// returns the combat log line ("" if they are not dying). roller is
+// optional; nil uses the crypto roller.
+func (g *Game) DeathSave(ctx context.Context, userID string, roller dice.Roller) (string, error) {
+	dnd, ok := g.GetDnDCharacter(userID)
+	if !ok || dnd == nil || !combat.IsDying(dnd) {
+		return "", nil
+	}
+	return combat.DeathSave(ctx, dnd, g.Bus, roller)
+}
+
+// syncLegacyVitals ties the legacy Character.Alive flag to the D&D dying
+// rules: a party member is alive until they die, unconscious or not.
+func (g *Game) syncLegacyVitals() {
+	for uid, dnd := range g.DnDPlayers {
+		c, ok := g.Players[uid]
+		if !ok || dnd == nil {
+			continue
+		}
+		c.Alive = !combat.IsDead(dnd)
+		g.Players[uid] = c
+	}
+}
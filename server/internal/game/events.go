@@ -0,0 +1,637 @@
+package game
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// EventType names a kind of state-changing operation recorded in the
+// mutations table.
+type EventType string
+
+const (
+	// EventWorldGen carries the full state world-gen saved; replay starts at
+	// the most recent one.
+	EventWorldGen EventType = "world_gen"
+	// EventSnapshot carries a full state written over a concurrent save (see
+	// EventLog.Snapshot).
+	EventSnapshot EventType = "snapshot"
+	// EventSnapshotLog carries part of the narrative or chat history of the
+	// snapshot event just before it, which holds the rest of the state.
+	EventSnapshotLog EventType = "snapshot_log"
+	// EventRewind carries the full state an owner rewound the session to.
+	EventRewind EventType = "rewind"
+	// EventRestore carries the full state of a checkpoint restored over the
+	// session; EventFork starts a session forked from another's checkpoint.
+	EventRestore EventType = "checkpoint_restore"
+	EventFork    EventType = "fork"
+
+	EventGameCreated   EventType = "game_created"
+	EventJoinCharacter EventType = "join_character"
+	EventInviteCreated EventType = "invite_created"
+	EventMemberJoined  EventType = "member_joined"
+
+	// EventTool is one Engineer DispatchTool call; Tool names the tool.
+	EventTool EventType = "tool"
+	// EventNarratorTurn closes a ws-chat turn: the narrative and chat log
+	// entries plus the turn's bookkeeping (token totals, dungeon state). Its
+	// Turn is the ConversationCount the turn started from.
+	EventNarratorTurn EventType = "narrator_turn"
+	// EventIntent is an intent declared for the pending action round in
+	// round mode; the round's narrator turn consumes it.
+	EventIntent EventType = "intent"
+
+	// Player actions from ws-game-action. Each includes the monster turns
+	// that ran before control returned to a player.
+	EventMove      EventType = "move"
+	EventStep      EventType = "step"
+	EventPickUp    EventType = "pick_up"
+	EventDrop      EventType = "drop"
+	EventEquip     EventType = "equip"
+	EventUnequip   EventType = "unequip"
+	EventAttack    EventType = "attack"
+	EventCast      EventType = "cast"
+	EventStabilize EventType = "stabilize"
+	EventSkip      EventType = "skip"
+	EventLevelUp   EventType = "level_up"
+	EventRoundMode EventType = "round_mode"
+)
+
+// MutationEntry is a durable record written to the mutations table for every
+// state-changing operation: Engineer tool calls, player actions, world-gen and
+// the HTTP routes that edit a session. Together with the world-gen snapshot
+// its patches rebuild the session (see ReplayState).
+//
+// Entries written before events carried patches have an empty Type and no
+// Patch; they remain an audit log of tool calls only.
+type MutationEntry struct {
+	SessionID string         `json:"session_id" dynamodbav:"session_id"`
+	Ts        int64          `json:"ts" dynamodbav:"ts"`     // unix milliseconds — sort key
+	Turn      int            `json:"turn" dynamodbav:"turn"` // g.ConversationCount at dispatch time
+	Tool      string         `json:"tool" dynamodbav:"tool"`
+	Input     map[string]any `json:"input" dynamodbav:"input"`
+	Result    string         `json:"result" dynamodbav:"result"`
+
+	Type    EventType   `json:"type,omitempty" dynamodbav:"type,omitempty"`
+	Actor   string      `json:"actor,omitempty" dynamodbav:"actor,omitempty"`     // userID, "" for the Engineer and world-gen
+	Version int         `json:"version,omitempty" dynamodbav:"version,omitempty"` // SaveState.Version the event was saved in
+	Seq     int         `json:"seq,omitempty" dynamodbav:"seq,omitempty"`         // order within Version
+	Patch   *StatePatch `json:"patch,omitempty" dynamodbav:"-"`                   // stored as JSON by the db package
+}
+
+// -------------------------------------------------------------------
+// State patches
+// -------------------------------------------------------------------
+
+// StatePatch is the change one event made to a SaveState, at the level of
+// whole entities: a room, an item, a player's character data, the monsters
+// of a room. Everything is held as JSON so a patch survives storage and
+// applies exactly, dice rolls included.
+type StatePatch struct {
+	// Full replaces the whole state.
+	Full *SaveState `json:"full,omitempty"`
+	// Fields replaces top-level fields, keyed by JSON name; null clears one.
+	Fields map[string]json.RawMessage `json:"fields,omitempty"`
+	// Entries upserts entities of keyed collections — field → key → value —
+	// where null deletes the entity.
+	Entries map[string]map[string]json.RawMessage `json:"entries,omitempty"`
+	// Append extends the narrative and chat_history logs.
+	Append map[string][]json.RawMessage `json:"append,omitempty"`
+}
+
+// Empty reports whether the patch changes nothing.
+func (p *StatePatch) Empty() bool {
+	return p == nil || (p.Full == nil && len(p.Fields) == 0 && len(p.Entries) == 0 && len(p.Append) == 0)
+}
+
+// keyedArrays are SaveState slices of entities identified by their "id".
+var keyedArrays = map[string]bool{"rooms": true, "items": true, "npcs": true}
+
+// keyedMaps are SaveState maps diffed entry by entry.
+var keyedMaps = map[string]bool{
+	"players":          true,
+	"players_data":     true,
+	"room_monsters":    true,
+	"positions":        true,
+	"known_spells":     true,
+	"known_monster_ac": true,
+}
+
+// appendLogs are SaveState slices that normally only grow.
+var appendLogs = map[string]bool{"narrative": true, "chat_history": true}
+
+// stateDoc is a SaveState broken into the pieces patches address.
+type stateDoc struct {
+	fields  map[string]json.RawMessage
+	entries map[string]map[string]json.RawMessage
+	logs    map[string][]json.RawMessage
+}
+
+func toDoc(s SaveState) (stateDoc, error) {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return stateDoc{}, fmt.Errorf("marshal state: %w", err)
+	}
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &top); err != nil {
+		return stateDoc{}, fmt.Errorf("split state: %w", err)
+	}
+	delete(top, "version") // bookkeeping, set from the event on replay
+
+	doc := stateDoc{
+		fields:  make(map[string]json.RawMessage),
+		entries: make(map[string]map[string]json.RawMessage),
+		logs:    make(map[string][]json.RawMessage),
+	}
+	for name, v := range top {
+		switch {
+		case keyedArrays[name]:
+			var list []json.RawMessage
+			if err := json.Unmarshal(v, &list); err != nil {
+				return stateDoc{}, fmt.Errorf("split %s: %w", name, err)
+			}
+			byID := make(map[string]json.RawMessage, len(list))
+			for _, e := range list {
+				var id struct {
+					ID string `json:"id"`
+				}
+				if err := json.Unmarshal(e, &id); err != nil {
+					return stateDoc{}, fmt.Errorf("split %s: %w", name, err)
+				}
+				byID[id.ID] = e
+			}
+			doc.entries[name] = byID
+		case keyedMaps[name]:
+			var m map[string]json.RawMessage
+			if err := json.Unmarshal(v, &m); err != nil {
+				return stateDoc{}, fmt.Errorf("split %s: %w", name, err)
+			}
+			doc.entries[name] = m
+		case appendLogs[name]:
+			var list []json.RawMessage
+			if err := json.Unmarshal(v, &list); err != nil {
+				return stateDoc{}, fmt.Errorf("split %s: %w", name, err)
+			}
+			doc.logs[name] = list
+		default:
+			doc.fields[name] = v
+		}
+	}
+	return doc, nil
+}
+
+func (d stateDoc) state(version int) (SaveState, error) {
+	top := make(map[string]any, len(d.fields)+len(d.entries)+len(d.logs)+1)
+	for name, v := range d.fields {
+		top[name] = v
+	}
+	for name, m := range d.entries {
+		if !keyedArrays[name] {
+			top[name] = m
+			continue
+		}
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		list := make([]json.RawMessage, 0, len(keys))
+		for _, k := range keys {
+			list = append(list, m[k])
+		}
+		top[name] = list
+	}
+	for name, list := range d.logs {
+		top[name] = list
+	}
+	top["version"] = version
+
+	raw, err := json.Marshal(top)
+	if err != nil {
+		return SaveState{}, fmt.Errorf("join state: %w", err)
+	}
+	var s SaveState
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return SaveState{}, fmt.Errorf("unmarshal state: %w", err)
+	}
+	return s, nil
+}
+
+// diffDocs returns the patch that turns before into after.
+func diffDocs(before, after stateDoc) *StatePatch {
+	p := &StatePatch{}
+	for name := range unionKeys(before.fields, after.fields) {
+		if v, ok := after.fields[name]; !ok {
+			setField(p, name, json.RawMessage("null"))
+		} else if !bytes.Equal(before.fields[name], v) {
+			setField(p, name, v)
+		}
+	}
+	for name := range unionKeys(before.entries, after.entries) {
+		b, a := before.entries[name], after.entries[name]
+		for key := range unionKeys(b, a) {
+			v, ok := a[key]
+			if !ok {
+				v = json.RawMessage("null")
+			} else if sameEntity(name, b[key], v) {
+				continue
+			}
+			if p.Entries == nil {
+				p.Entries = make(map[string]map[string]json.RawMessage)
+			}
+			if p.Entries[name] == nil {
+				p.Entries[name] = make(map[string]json.RawMessage)
+			}
+			p.Entries[name][key] = v
+		}
+	}
+	for name := range unionKeys(before.logs, after.logs) {
+		b, a := before.logs[name], after.logs[name]
+		if isPrefix(b, a) {
+			if len(a) > len(b) {
+				if p.Append == nil {
+					p.Append = make(map[string][]json.RawMessage)
+				}
+				p.Append[name] = a[len(b):]
+			}
+			continue
+		}
+		// Rewritten (history trimming): replace the whole log.
+		raw, _ := json.Marshal(a)
+		setField(p, name, raw)
+	}
+	return p
+}
+
+// sameEntity reports whether two serialised entities are equal. Character
+// data is stamped with updated_at on every save, so that field is ignored.
+func sameEntity(collection string, a, b json.RawMessage) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	if collection != "players_data" || a == nil {
+		return false
+	}
+	var am, bm map[string]json.RawMessage
+	if json.Unmarshal(a, &am) != nil || json.Unmarshal(b, &bm) != nil {
+		return false
+	}
+	delete(am, "updated_at")
+	delete(bm, "updated_at")
+	if len(am) != len(bm) {
+		return false
+	}
+	for k, v := range am {
+		if !bytes.Equal(v, bm[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+func setField(p *StatePatch, name string, v json.RawMessage) {
+	if p.Fields == nil {
+		p.Fields = make(map[string]json.RawMessage)
+	}
+	p.Fields[name] = v
+}
+
+func isPrefix(prefix, list []json.RawMessage) bool {
+	if len(prefix) > len(list) {
+		return false
+	}
+	for i := range prefix {
+		if !bytes.Equal(prefix[i], list[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func unionKeys[V any](a, b map[string]V) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	return keys
+}
+
+// apply applies p to d in place.
+func (d *stateDoc) apply(p *StatePatch) {
+	for name, v := range p.Fields {
+		switch {
+		case appendLogs[name]:
+			var list []json.RawMessage
+			_ = json.Unmarshal(v, &list)
+			d.logs[name] = list
+		case bytes.Equal(v, []byte("null")):
+			delete(d.fields, name)
+		default:
+			d.fields[name] = v
+		}
+	}
+	for name, changes := range p.Entries {
+		m := d.entries[name]
+		if m == nil {
+			m = make(map[string]json.RawMessage)
+			d.entries[name] = m
+		}
+		for key, v := range changes {
+			if bytes.Equal(v, []byte("null")) {
+				delete(m, key)
+			} else {
+				m[key] = v
+			}
+		}
+	}
+	for name, list := range p.Append {
+		d.logs[name] = append(d.logs[name], list...)
+	}
+}
+
+// DiffStates returns the patch that turns before into after. Version is
+// ignored.
+func DiffStates(before, after SaveState) (*StatePatch, error) {
+	b, err := toDoc(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := toDoc(after)
+	if err != nil {
+		return nil, err
+	}
+	return diffDocs(b, a), nil
+}
+
+// ApplyPatch returns s with p applied, at the given version.
+func ApplyPatch(s SaveState, p *StatePatch, version int) (SaveState, error) {
+	if p != nil && p.Full != nil {
+		full := *p.Full
+		full.Version = version
+		return full, nil
+	}
+	d, err := toDoc(s)
+	if err != nil {
+		return SaveState{}, err
+	}
+	if p != nil {
+		d.apply(p)
+	}
+	return d.state(version)
+}
+
+// -------------------------------------------------------------------
+// Recording
+// -------------------------------------------------------------------
+
+// EventLog collects the events of one invocation. Each Record diffs the new
+// state against the state after the previous event, so the patches chain
+// from the state the invocation loaded to the state it saves. Handlers write
+// Entries only once PutGame has succeeded, so a lost optimistic-lock race
+// leaves no events behind.
+type EventLog struct {
+	last      stateDoc
+	narrative []NarrativeMessage
+	history   []ChatMessage
+	entries   []MutationEntry
+}
+
+// NewEventLog starts a log at base, which should be the loaded game
+// re-serialised with ToSaveState so formatting differences never show up as
+// changes.
+func NewEventLog(base SaveState) (*EventLog, error) {
+	doc, err := toDoc(base)
+	if err != nil {
+		return nil, err
+	}
+	return &EventLog{last: doc, narrative: base.Narrative, history: base.ChatHistory}, nil
+}
+
+// Record appends an event whose patch takes the log from its previous state
+// to state.
+func (l *EventLog) Record(e MutationEntry, state SaveState) error {
+	doc, err := toDoc(state)
+	if err != nil {
+		return fmt.Errorf("record %s: %w", e.Type, err)
+	}
+	e.Patch = diffDocs(l.last, doc)
+	l.last, l.narrative, l.history = doc, state.Narrative, state.ChatHistory
+	l.entries = append(l.entries, e)
+	return nil
+}
+
+// snapshotLogChunk is roughly the most narrative or chat JSON one
+// EventSnapshotLog event carries, keeping each mutations item well under
+// DynamoDB's 400 KB limit.
+const snapshotLogChunk = 128 << 10
+
+// Snapshot appends an event carrying the whole of state. World-gen records
+// one, and so does any writer that overwrites a concurrent save: the other
+// writer's events are already in the log, and a snapshot keeps replay from
+// mixing them with patches computed against an older state. The narrative
+// and chat history follow in EventSnapshotLog events, a chunk at a time, so
+// no single event outgrows a mutations item.
+func (l *EventLog) Snapshot(e MutationEntry, state SaveState) error {
+	doc, err := toDoc(state)
+	if err != nil {
+		return fmt.Errorf("snapshot %s: %w", e.Type, err)
+	}
+	full := state
+	full.Narrative, full.ChatHistory = nil, nil
+	e.Patch = &StatePatch{Full: &full}
+	l.last, l.narrative, l.history = doc, state.Narrative, state.ChatHistory
+	l.entries = append(l.entries, e)
+	for _, name := range []string{"narrative", "chat_history"} {
+		for _, chunk := range chunkLog(doc.logs[name]) {
+			l.entries = append(l.entries, MutationEntry{
+				Type:  EventSnapshotLog,
+				Actor: e.Actor,
+				Turn:  e.Turn,
+				Patch: &StatePatch{Append: map[string][]json.RawMessage{name: chunk}},
+			})
+		}
+	}
+	return nil
+}
+
+// chunkLog splits a log into runs of about snapshotLogChunk bytes. A message
+// larger than that gets a run of its own.
+func chunkLog(list []json.RawMessage) [][]json.RawMessage {
+	var chunks [][]json.RawMessage
+	size := 0
+	for _, m := range list {
+		if len(chunks) == 0 || size+len(m) > snapshotLogChunk {
+			chunks = append(chunks, nil)
+			size = 0
+		}
+		chunks[len(chunks)-1] = append(chunks[len(chunks)-1], m)
+		size += len(m)
+	}
+	return chunks
+}
+
+// NewSnapshotLog returns a log holding a single snapshot event of state, for
+// writers that build a session's state wholesale (game creation, world-gen).
+func NewSnapshotLog(e MutationEntry, state SaveState) (*EventLog, error) {
+	l, err := NewEventLog(state)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.Snapshot(e, state); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Len returns the number of events recorded so far.
+func (l *EventLog) Len() int {
+	return len(l.entries)
+}
+
+// Entries returns the recorded events stamped with the session, the version
+// they were saved in and their order, ready for PutMutation.
+func (l *EventLog) Entries(sessionID string, version int, now time.Time) []MutationEntry {
+	out := make([]MutationEntry, len(l.entries))
+	ts := now.UnixMilli()
+	for i, e := range l.entries {
+		e.SessionID = sessionID
+		e.Version = version
+		e.Seq = i
+		e.Ts = ts + int64(i)
+		out[i] = e
+	}
+	return out
+}
+
+// TrackEvents attaches a new EventLog based at g's current state. Call it
+// after LoadDnDCharacters, with the narrative and chat history the session
+// was loaded with.
+func (g *Game) TrackEvents(narrative []NarrativeMessage, history []ChatMessage) error {
+	log, err := NewEventLog(g.ToSaveState(narrative, history))
+	if err != nil {
+		return err
+	}
+	g.Events = log
+	return nil
+}
+
+// RecordEvent records an event against g's current state when an EventLog is
+// attached; otherwise it does nothing. Narrative and chat history are carried
+// over from the previous event, so only world changes are captured.
+func (g *Game) RecordEvent(e MutationEntry) {
+	if g.Events == nil {
+		return
+	}
+	e.Turn = g.ConversationCount
+	if err := g.Events.Record(e, g.ToSaveState(g.Events.narrative, g.Events.history)); err != nil {
+		// A broken patch would make every later replay wrong; drop the log
+		// rather than record a partial history.
+		g.Events = nil
+	}
+}
+
+// -------------------------------------------------------------------
+// Replay
+// -------------------------------------------------------------------
+
+var (
+	// ErrNoSnapshot means a session has no full snapshot to replay from:
+	// it predates typed events.
+	ErrNoSnapshot = errors.New("no snapshot to replay from")
+	// ErrNoSuchTurn means no narrator turn with the requested number was
+	// recorded.
+	ErrNoSuchTurn = errors.New("no such turn")
+)
+
+// ReplayState rebuilds the SaveState a session's events lead to. It starts
+// from the most recent full snapshot (world-gen, an overwrite, a rewind or a
+// restored checkpoint) and applies every later patch in (Version, Seq) order.
+// Entries without a patch — the tool audit log written before events carried
+// patches — are skipped.
+func ReplayState(entries []MutationEntry) (SaveState, error) {
+	events := make([]MutationEntry, 0, len(entries))
+	for _, e := range entries {
+		if e.Patch != nil {
+			events = append(events, e)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Version != events[j].Version {
+			return events[i].Version < events[j].Version
+		}
+		return events[i].Seq < events[j].Seq
+	})
+
+	start := -1
+	for i, e := range events {
+		if e.Patch.Full != nil {
+			start = i
+		}
+	}
+	if start < 0 {
+		return SaveState{}, ErrNoSnapshot
+	}
+
+	var s SaveState
+	for _, e := range events[start:] {
+		next, err := ApplyPatch(s, e.Patch, e.Version)
+		if err != nil {
+			return SaveState{}, fmt.Errorf("replay %s v%d#%d: %w", e.Type, e.Version, e.Seq, err)
+		}
+		s = next
+	}
+	return s, nil
+}
+
+// RewindState rebuilds the state a session was in just before narrator turn
+// `turn` began, when its ConversationCount was turn: every event saved
+// before that turn's narrator_turn event is replayed. A turn played again
+// after an earlier rewind exists twice; the latest one is used, which is the
+// one on the session's current timeline as long as turn is below the
+// current ConversationCount.
+func RewindState(entries []MutationEntry, turn int) (SaveState, error) {
+	cut := -1
+	for _, e := range entries {
+		if e.Type == EventNarratorTurn && e.Turn == turn && e.Version > cut {
+			cut = e.Version
+		}
+	}
+	if cut < 0 {
+		return SaveState{}, ErrNoSuchTurn
+	}
+	before := make([]MutationEntry, 0, len(entries))
+	for _, e := range entries {
+		if e.Version < cut {
+			before = append(before, e)
+		}
+	}
+	return ReplayState(before)
+}
+
+// Replay rebuilds a Game from a session's events and loads its D&D
+// characters. The returned SaveState holds the narrative and chat history,
+// which live outside Game.
+func Replay(ctx context.Context, entries []MutationEntry) (*Game, SaveState, error) {
+	s, err := ReplayState(entries)
+	if err != nil {
+		return nil, SaveState{}, err
+	}
+	g, err := FromSaveState(s)
+	if err != nil {
+		return nil, SaveState{}, err
+	}
+	if s.PlayersData != nil {
+		if _, err := g.LoadDnDCharacters(ctx, s.PlayersData); err != nil {
+			return nil, SaveState{}, fmt.Errorf("load characters: %w", err)
+		}
+	}
+	return g, s, nil
+}
@@ -0,0 +1,302 @@
+package game_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/monster"
+
+	"github.com/rrochlin/an-amazing-adventure/internal/combat"
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+)
+
+// newEventGame builds a two-room world with a D&D hero, a key and a goblin,
+// records its world-gen snapshot and attaches an EventLog.
+func newEventGame(t *testing.T) (*game.Game, game.Area, game.Area, game.Item) {
+	t.Helper()
+	g := newLevelingGame(t, game.CharacterCreationData{
+		Name:           "Grak",
+		RaceID:         "half-orc",
+		ClassID:        "barbarian",
+		AbilityScores:  standardAbilityScores(),
+		SelectedSkills: []string{"athletics", "intimidation"},
+	})
+	hall := game.NewArea("Hall", "")
+	vault := game.NewArea("Vault", "")
+	_ = g.AddRoom(hall)
+	_ = g.AddRoom(vault)
+	_ = g.ConnectRooms(hall.ID, vault.ID, "north")
+	if err := g.PlaceCharacter("user-1", hall.ID); err != nil {
+		t.Fatal(err)
+	}
+	key := game.NewItem("Key", "Brass")
+	_ = g.AddItem(key)
+	_ = g.PlaceItemInRoom(key.ID, hall.ID)
+	g.SetRoomMonsters(vault.ID, []*monster.Data{combat.NewMonsterByType("goblin").ToData()})
+
+	base := g.ToSaveState(nil, nil)
+	log, err := game.NewEventLog(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := log.Snapshot(game.MutationEntry{Type: game.EventWorldGen}, base); err != nil {
+		t.Fatal(err)
+	}
+	g.Events = log
+	return g, hall, vault, key
+}
+
+// ── Recording and replay ──────────────────────────────────────────────────────
+
+func TestReplay_RebuildsFinalState(t *testing.T) {
+	g, _, vault, key := newEventGame(t)
+
+	if err := g.GiveItemToCharacter(key.ID, "user-1"); err != nil {
+		t.Fatal(err)
+	}
+	g.RecordEvent(game.MutationEntry{Type: game.EventPickUp, Actor: "user-1", Input: map[string]any{"item_id": key.ID}})
+	if _, err := g.MoveCharacter("user-1", "north"); err != nil {
+		t.Fatal(err)
+	}
+	g.RecordEvent(game.MutationEntry{Type: game.EventMove, Actor: "user-1", Input: map[string]any{"direction": "north"}})
+	goblins := g.GetRoomMonsters(vault.ID)
+	goblins[0].HitPoints = 1
+	g.SetRoomMonsters(vault.ID, goblins)
+	g.RecordEvent(game.MutationEntry{Type: game.EventAttack, Actor: "user-1"})
+
+	narrative := []game.NarrativeMessage{{Role: "assistant", Content: []game.NarrativeBlock{{Type: "text", Text: "You step into the vault."}}}}
+	history := []game.ChatMessage{{Type: "player", Content: "go north"}}
+	g.ConversationCount++
+	final := g.ToSaveState(narrative, history)
+	if err := g.Events.Record(game.MutationEntry{Type: game.EventNarratorTurn}, final); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := g.Events.Entries("session-1", 4, time.UnixMilli(1000))
+	if len(entries) != 5 || entries[4].Seq != 4 || entries[4].Ts != 1004 || entries[2].Version != 4 {
+		t.Fatalf("entries not stamped in order: %+v", entries)
+	}
+	if entries[1].Turn != 0 || entries[1].Actor != "user-1" {
+		t.Errorf("pick_up entry = %+v", entries[1])
+	}
+
+	replayed, s, err := game.Replay(context.Background(), entries)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if s.Version != 4 {
+		t.Errorf("replayed version = %d", s.Version)
+	}
+	if diff, _ := game.DiffStates(s, final); !diff.Empty() {
+		t.Errorf("replay differs from the saved state: %+v", diff)
+	}
+	if diff, _ := game.DiffStates(replayed.ToSaveState(s.Narrative, s.ChatHistory), final); !diff.Empty() {
+		t.Errorf("replayed game differs from the saved state: %+v", diff)
+	}
+	if _, ok := replayed.GetDnDCharacter("user-1"); !ok {
+		t.Error("replay should load D&D characters")
+	}
+}
+
+func TestRecordEvent_PatchesWholeEntities(t *testing.T) {
+	g, hall, _, key := newEventGame(t)
+	if err := g.GiveItemToCharacter(key.ID, "user-1"); err != nil {
+		t.Fatal(err)
+	}
+	g.RecordEvent(game.MutationEntry{Type: game.EventPickUp, Actor: "user-1"})
+
+	p := g.Events.Entries("s", 1, time.Now())[1].Patch
+	if p.Full != nil || len(p.Append) != 0 {
+		t.Fatalf("expected a partial patch, got %+v", p)
+	}
+	if _, ok := p.Entries["rooms"][hall.ID]; !ok {
+		t.Errorf("hall lost its item; patch entries = %v", p.Entries)
+	}
+	if _, ok := p.Entries["players"]["user-1"]; !ok {
+		t.Errorf("hero gained an item; patch entries = %v", p.Entries)
+	}
+	if _, ok := p.Entries["room_monsters"]; ok {
+		t.Error("untouched monsters must not appear in the patch")
+	}
+	if _, ok := p.Entries["players_data"]; ok {
+		t.Error("re-saving an unchanged character must not appear in the patch")
+	}
+}
+
+func TestRecordEvent_NoLogIsNoop(t *testing.T) {
+	g := newTestGame()
+	g.RecordEvent(game.MutationEntry{Type: game.EventMove})
+	if g.Events != nil {
+		t.Error("RecordEvent must not attach a log")
+	}
+}
+
+func TestEventLog_RewrittenHistoryIsReplaced(t *testing.T) {
+	g := newTestGame()
+	long := []game.ChatMessage{{Type: "player", Content: "a"}, {Type: "narrative", Content: "b"}}
+	log, err := game.NewEventLog(g.ToSaveState(nil, long))
+	if err != nil {
+		t.Fatal(err)
+	}
+	trimmed := []game.ChatMessage{{Type: "narrative", Content: "summary"}}
+	if err := log.Record(game.MutationEntry{Type: game.EventNarratorTurn}, g.ToSaveState(nil, trimmed)); err != nil {
+		t.Fatal(err)
+	}
+	p := log.Entries("s", 1, time.Now())[0].Patch
+	if _, ok := p.Fields["chat_history"]; !ok || len(p.Append) != 0 {
+		t.Fatalf("a trimmed log should be replaced, got %+v", p)
+	}
+	got, err := game.ApplyPatch(g.ToSaveState(nil, long), p, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.ChatHistory) != 1 || got.ChatHistory[0].Content != "summary" {
+		t.Errorf("chat history = %+v", got.ChatHistory)
+	}
+}
+
+// ── ReplayState ───────────────────────────────────────────────────────────────
+
+func TestReplayState_OrdersByVersionAndStartsAtLastSnapshot(t *testing.T) {
+	g := newTestGame()
+	room := game.NewArea("Cell", "")
+	_ = g.AddRoom(room)
+	base := g.ToSaveState(nil, nil)
+
+	log, _ := game.NewEventLog(base)
+	_ = log.Snapshot(game.MutationEntry{Type: game.EventWorldGen}, base)
+	v1 := log.Entries("s", 1, time.Now())
+
+	g.ConversationCount = 7
+	renamed := g.ToSaveState(nil, nil)
+	log2, _ := game.NewEventLog(base)
+	_ = log2.Record(game.MutationEntry{Type: game.EventNarratorTurn}, renamed)
+	v2 := log2.Entries("s", 2, time.Now())
+
+	legacy := game.MutationEntry{SessionID: "s", Tool: "create_room"} // no patch
+	s, err := game.ReplayState(append(append([]game.MutationEntry{legacy}, v2...), v1...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != 2 || s.ConversationCount != 7 || len(s.Rooms) != 1 {
+		t.Errorf("replayed state = v%d count=%d rooms=%d", s.Version, s.ConversationCount, len(s.Rooms))
+	}
+
+	if _, err := game.ReplayState(append(v2, legacy)); err == nil {
+		t.Error("expected an error without a snapshot")
+	}
+}
+
+func TestSnapshot_ChunksNarrativeAndChat(t *testing.T) {
+	g := newTestGame()
+	line := strings.Repeat("The torch gutters. ", 1000)
+	var narrative []game.NarrativeMessage
+	var history []game.ChatMessage
+	for i := 0; i < 40; i++ {
+		narrative = append(narrative, game.NarrativeMessage{Role: "assistant", Content: []game.NarrativeBlock{{Type: "text", Text: line}}})
+		history = append(history, game.ChatMessage{Type: "narrative", Content: line})
+	}
+	base := g.ToSaveState(narrative, history)
+
+	snap, err := game.NewSnapshotLog(game.MutationEntry{Type: game.EventSnapshot}, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := snap.Entries("s", 3, time.Now())
+	if len(entries) < 3 || entries[0].Patch.Full == nil || len(entries[0].Patch.Full.Narrative) != 0 || len(entries[0].Patch.Full.ChatHistory) != 0 {
+		t.Fatalf("expected the snapshot without its logs, then log chunks; got %d entries", len(entries))
+	}
+	for _, e := range entries {
+		raw, _ := json.Marshal(e)
+		if len(raw) > 200<<10 {
+			t.Errorf("%s event #%d is %d bytes", e.Type, e.Seq, len(raw))
+		}
+	}
+
+	s, err := game.ReplayState(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff, _ := game.DiffStates(s, base); !diff.Empty() {
+		t.Errorf("replayed snapshot differs: %+v", diff)
+	}
+}
+
+func TestStatePatch_JSONRoundTrip(t *testing.T) {
+	g, _, _, key := newEventGame(t)
+	_ = g.GiveItemToCharacter(key.ID, "user-1")
+	g.RecordEvent(game.MutationEntry{Type: game.EventPickUp})
+	entries := g.Events.Entries("s", 1, time.Now())
+
+	raw, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []game.MutationEntry
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := game.ReplayState(entries)
+	got, err := game.ReplayState(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff, _ := game.DiffStates(got, want); !diff.Empty() {
+		t.Errorf("decoded events replay differently: %+v", diff)
+	}
+}
+
+// ── RewindState ───────────────────────────────────────────────────────────────
+
+func TestRewindState_StopsBeforeTheTurn(t *testing.T) {
+	g := newTestGame()
+	base := g.ToSaveState(nil, nil)
+	snap, _ := game.NewSnapshotLog(game.MutationEntry{Type: game.EventWorldGen}, base)
+	entries := snap.Entries("s", 0, time.Now())
+
+	// Three narrator turns, each saved as its own version.
+	var history []game.ChatMessage
+	for turn := 0; turn < 3; turn++ {
+		l, _ := game.NewEventLog(g.ToSaveState(nil, history))
+		history = append(history, game.ChatMessage{Type: "player", Content: fmt.Sprintf("turn %d", turn)})
+		g.ConversationCount++
+		_ = l.Record(game.MutationEntry{Type: game.EventNarratorTurn, Turn: turn}, g.ToSaveState(nil, history))
+		entries = append(entries, l.Entries("s", turn+1, time.Now())...)
+	}
+
+	s, err := game.RewindState(entries, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ConversationCount != 1 || len(s.ChatHistory) != 1 || s.Version != 1 {
+		t.Errorf("rewound to count=%d history=%d v%d", s.ConversationCount, len(s.ChatHistory), s.Version)
+	}
+
+	// After rewinding to turn 1 (a snapshot at v4), turn 1 is played again
+	// at v5; rewinding to it again must use the replayed turn.
+	rewound, _ := game.NewSnapshotLog(game.MutationEntry{Type: game.EventRewind}, s)
+	entries = append(entries, rewound.Entries("s", 4, time.Now())...)
+	l, _ := game.NewEventLog(s)
+	again := s
+	again.ConversationCount = 2
+	again.ChatHistory = append(append([]game.ChatMessage{}, s.ChatHistory...), game.ChatMessage{Type: "player", Content: "turn 1, again"})
+	_ = l.Record(game.MutationEntry{Type: game.EventNarratorTurn, Turn: 1}, again)
+	entries = append(entries, l.Entries("s", 5, time.Now())...)
+
+	s, err = game.RewindState(entries, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != 4 || len(s.ChatHistory) != 1 {
+		t.Errorf("second rewind = v%d history=%d", s.Version, len(s.ChatHistory))
+	}
+
+	if _, err := game.RewindState(entries, 9); !errors.Is(err, game.ErrNoSuchTurn) {
+		t.Errorf("unknown turn: err = %v", err)
+	}
+}
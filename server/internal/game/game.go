@@ -2,7 +2,11 @@ package game
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"maps"
+	"sort"
+	"strings"
 
 	"github.com/KirkDiggler/rpg-toolkit/events"
 	dnd5echar "github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/character"
@@ -15,7 +19,11 @@ import (
 // SchemaVersion is incremented whenever SaveState's structure changes
 // in a backward-incompatible way. FromSaveState handles migration from
 // older versions.
-const SchemaVersion = 4
+//
+// v5 moved Narrative and ChatHistory out of the session item into the
+// session log; older items still carry them inline and are migrated by
+// their next save.
+const SchemaVersion = 5
 
 // AdventureCreationParams holds the player-provided setup choices that were
 // used when the game was created. All fields are optional — the AI fills in
@@ -56,12 +64,38 @@ type Game struct {
 	// RoomMonsters maps roomID → serialized monster data for that room.
 	// Monsters are loaded into live *monster.Monster only during combat resolution.
 	RoomMonsters map[string][]*monster.Data // keyed by roomID
-	// PendingCombatContext is set by ws-game-action after resolving combat and consumed
-	// by the next ws-chat call to inject mechanical results into the Narrator prompt.
+	// PendingCombatContext is set by ws-game-action after resolving combat (and by
+	// the Engineer's ability checks) and consumed by the next ws-chat call to
+	// inject mechanical results into the Narrator prompt.
 	PendingCombatContext string
 	// InitiativeOrder is set when a combat encounter begins and cleared when all
 	// monsters in the current room are dead.
 	InitiativeOrder []combat.InitiativeEntry
+	// Turn points at the combatant in InitiativeOrder whose turn it is.
+	// Nil outside of combat; cleared together with InitiativeOrder.
+	Turn *combat.TurnState
+	// HideMonsterHP replaces exact monster hit points with an HP bucket in
+	// every client view. Chosen server-side when the game is created.
+	HideMonsterHP bool
+	// KnownMonsterAC records monsters whose armour class the party has learned
+	// by attacking them. Unknown ACs are omitted from MonsterView.
+	KnownMonsterAC map[string]bool
+	// Positions maps roomID → combatant ID (userID or monsterID) → hex position
+	// on that room's combat grid. See RoomGrid.
+	Positions map[string]map[string]combat.GridPos
+	// SpellSlots maps userID → slot level → max/used. Persisted inside
+	// PlayersData (the toolkit writes but does not reload slots), see
+	// PlayerSpellSlots.
+	SpellSlots map[string]map[int]dnd5echar.SpellSlotData
+	// KnownSpells maps userID → cantrips and known/spellbook spell IDs chosen
+	// at creation. The toolkit character does not keep them.
+	KnownSpells map[string][]string
+	// Bus is the event bus the DnD characters were loaded on. Runtime only;
+	// nil until LoadDnDCharacters runs.
+	Bus events.EventBus
+	// Events, when set, collects the typed events of this invocation for the
+	// mutations table. Runtime only; see EventLog.
+	Events *EventLog
 
 	// DungeonData is the procedurally generated dungeon layout (v4+).
 	// Nil for games created before SchemaVersion 4 (they use the legacy Rooms map).
@@ -70,6 +104,19 @@ type Game struct {
 	// WorldGenLogs holds the ordered log lines emitted by world-gen so clients
 	// that connect after world-gen completes can still replay the terminal output.
 	WorldGenLogs []string
+
+	// OlderChat holds the session-log ranges of the chat messages that come
+	// before the ChatHistory in memory (v5+). See PageChat.
+	OlderChat []LogRange
+	// OlderWhispers counts, per user ID, the whispers among OlderChat, so a
+	// member's view can count only the older messages they may see.
+	OlderWhispers map[string]int
+
+	// RoundMode batches the party's chat into action rounds the narrator
+	// resolves together; ActionRound holds the intents of the pending one.
+	// See SubmitIntent.
+	RoundMode   bool
+	ActionRound *ActionRound
 }
 
 // NewGame creates a blank Game with server-generated IDs.
@@ -145,6 +192,55 @@ func (g *Game) OwnerDnDCharacter() (*dnd5echar.Character, bool) {
 	return g.GetDnDCharacter(g.OwnerID)
 }
 
+// PartyMemberByName returns the userID of the party member whose character
+// is called name (case-insensitive).
+func (g *Game) PartyMemberByName(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	for uid, c := range g.Players {
+		if strings.EqualFold(c.Name, name) {
+			return uid, true
+		}
+	}
+	return "", false
+}
+
+// PartyOrder returns the user IDs of the party members: the owner first, then
+// everyone else by character name. Prompts list the party in this order.
+func (g *Game) PartyOrder() []string {
+	ids := make([]string, 0, len(g.Players))
+	for uid := range g.Players {
+		if uid != g.OwnerID {
+			ids = append(ids, uid)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := g.Players[ids[i]].Name, g.Players[ids[j]].Name
+		if a != b {
+			return a < b
+		}
+		return ids[i] < ids[j]
+	})
+	if _, ok := g.Players[g.OwnerID]; ok {
+		ids = append([]string{g.OwnerID}, ids...)
+	}
+	return ids
+}
+
+// SpeakerInput tags a player's input with their character's name, e.g.
+// "[Brom] I open the door", so a party's narrative history records who said
+// what. Private actions are tagged "[Brom, privately]". Input from a user
+// without a character is returned unchanged.
+func (g *Game) SpeakerInput(userID, input string, private bool) string {
+	c, ok := g.GetPlayerCharacter(userID)
+	if !ok || c.Name == "" {
+		return input
+	}
+	if private {
+		return "[" + c.Name + ", privately] " + input
+	}
+	return "[" + c.Name + "] " + input
+}
+
 // LoadDnDCharacters loads all DnD characters from their persisted Data forms
 // and binds them to a new event bus. Returns the characters and the bus.
 // Must be called at the start of every Lambda invocation that touches game state.
@@ -155,6 +251,13 @@ func (g *Game) LoadDnDCharacters(ctx context.Context, playersData map[string]*dn
 		return nil, err
 	}
 	g.DnDPlayers = players
+	g.Bus = bus
+	g.SpellSlots = make(map[string]map[int]dnd5echar.SpellSlotData)
+	for uid, data := range playersData {
+		if data != nil && len(data.SpellSlots) > 0 {
+			g.SpellSlots[uid] = data.SpellSlots
+		}
+	}
 	return bus, nil
 }
 
@@ -211,6 +314,15 @@ func (g *Game) HasLiveMonstersInRoom(roomID string) bool {
 	return false
 }
 
+// RevealMonsterAC records that the party has learned a monster's armour class
+// (typically by attacking it), so it is shown in MonsterView from now on.
+func (g *Game) RevealMonsterAC(monsterID string) {
+	if g.KnownMonsterAC == nil {
+		g.KnownMonsterAC = make(map[string]bool)
+	}
+	g.KnownMonsterAC[monsterID] = true
+}
+
 // -------------------------------------------------------------------
 // Room operations
 // -------------------------------------------------------------------
@@ -433,21 +545,30 @@ func (g *Game) GiveItemToCharacter(itemID, userID string) error {
 
 // TakeItemFromPlayer moves an item from the owner's inventory into a room.
 func (g *Game) TakeItemFromPlayer(itemID, roomID string) error {
-	owner, ok := g.GetPlayerCharacter(g.OwnerID)
-	if !ok {
+	if _, ok := g.GetPlayerCharacter(g.OwnerID); !ok {
 		return fmt.Errorf("owner character not found")
 	}
-	if !owner.HasItem(itemID) {
-		return fmt.Errorf("player does not have item %s", itemID)
+	return g.TakeItemFromCharacter(itemID, g.OwnerID, roomID)
+}
+
+// TakeItemFromCharacter moves an item from the specified player's inventory
+// into a room.
+func (g *Game) TakeItemFromCharacter(itemID, userID, roomID string) error {
+	player, ok := g.GetPlayerCharacter(userID)
+	if !ok {
+		return fmt.Errorf("player %s not found in game", userID)
 	}
-	if err := owner.RemoveItemID(itemID); err != nil {
-		return err
+	if !player.HasItem(itemID) {
+		return fmt.Errorf("player does not have item %s", itemID)
 	}
-	g.Players[g.OwnerID] = owner
 	room, err := g.GetRoom(roomID)
 	if err != nil {
 		return err
 	}
+	if err := player.RemoveItemID(itemID); err != nil {
+		return err
+	}
+	g.Players[userID] = player
 	if err := room.AddItemID(itemID); err != nil {
 		return err
 	}
@@ -566,8 +687,12 @@ func (g *Game) MoveCharacter(userID, direction string) (Area, error) {
 	if err != nil {
 		return Area{}, err
 	}
+	if err := g.CheckPartySplit(userID, currentRoom.ID, destID); err != nil {
+		return Area{}, err
+	}
 	_ = currentRoom.RemoveOccupant(player.ID)
 	g.Rooms[currentRoom.ID] = currentRoom
+	g.LeaveGrid(userID, currentRoom.ID)
 	_ = destRoom.AddOccupant(player.ID)
 	g.Rooms[destID] = destRoom
 	player.LocationID = destID
@@ -596,6 +721,9 @@ func (g *Game) PlaceCharacter(userID, roomID string) error {
 			_ = old.RemoveOccupant(player.ID)
 			g.Rooms[player.LocationID] = old
 		}
+		if player.LocationID != roomID {
+			g.LeaveGrid(userID, player.LocationID)
+		}
 	}
 	if err := room.AddOccupant(player.ID); err != nil {
 		// Occupant already there — fine
@@ -607,6 +735,88 @@ func (g *Game) PlaceCharacter(userID, roomID string) error {
 	return nil
 }
 
+// -------------------------------------------------------------------
+// Party movement rules
+// -------------------------------------------------------------------
+
+// MaxPartyRooms is the number of distinct rooms the party may be spread
+// across at once. Moves that would split the party further are refused.
+const MaxPartyRooms = 2
+
+// CheckPartySplit applies the party-split rules to a proposed move of userID
+// from fromID into destID:
+//
+//  1. A character may not walk out of a fight while an ally is still in the
+//     room with live monsters.
+//  2. The party may not occupy more than MaxPartyRooms distinct rooms.
+//
+// Members who have not been placed yet (no LocationID) are ignored. A solo
+// party is never restricted.
+func (g *Game) CheckPartySplit(userID, fromID, destID string) error {
+	occupied := map[string]bool{destID: true}
+	alliesBehind := false
+	for uid, c := range g.Players {
+		if uid == userID || c.LocationID == "" {
+			continue
+		}
+		occupied[c.LocationID] = true
+		if c.LocationID == fromID {
+			alliesBehind = true
+		}
+	}
+	if alliesBehind && g.HasLiveMonstersInRoom(fromID) {
+		return fmt.Errorf("you can't leave your allies in the middle of a fight")
+	}
+	if len(occupied) > MaxPartyRooms {
+		return fmt.Errorf("the party can't split across more than %d rooms", MaxPartyRooms)
+	}
+	return nil
+}
+
+// PartyStartRoomID returns the room new party members are placed in: the
+// dungeon entrance when known, otherwise the owner's current room.
+func (g *Game) PartyStartRoomID() string {
+	if g.DungeonData != nil && g.DungeonData.StartRoomID != "" {
+		if _, ok := g.Rooms[g.DungeonData.StartRoomID]; ok {
+			return g.DungeonData.StartRoomID
+		}
+	}
+	owner, _ := g.OwnerCharacter()
+	return owner.LocationID
+}
+
+// EnsureCharacterPlaced puts a party member who has never been placed (e.g.
+// one who joined via invite after world-gen) into PartyStartRoomID and
+// reveals that room to them. It is a no-op for characters with a location.
+func (g *Game) EnsureCharacterPlaced(userID string) error {
+	player, ok := g.GetPlayerCharacter(userID)
+	if !ok {
+		return fmt.Errorf("player %s not found in game", userID)
+	}
+	if player.LocationID != "" {
+		return nil
+	}
+	startID := g.PartyStartRoomID()
+	if startID == "" {
+		return fmt.Errorf("the world has no starting room yet")
+	}
+	if err := g.PlaceCharacter(userID, startID); err != nil {
+		return err
+	}
+	g.RevealRoom(userID, startID)
+	return nil
+}
+
+// RevealRoom marks roomID as visited by userID for fog-of-war. It records
+// the room both in the member's own revealed set and in the shared set.
+// No-op for legacy games without DungeonData.
+func (g *Game) RevealRoom(userID, roomID string) {
+	if g.DungeonData == nil {
+		return
+	}
+	g.DungeonData.Reveal(userID, roomID)
+}
+
 // -------------------------------------------------------------------
 // Persistence helpers
 // -------------------------------------------------------------------
@@ -626,8 +836,8 @@ type SaveState struct {
 	Rooms                []Area                     `json:"rooms" dynamodbav:"rooms"`
 	Items                []Item                     `json:"items" dynamodbav:"items"`
 	NPCs                 []Character                `json:"npcs" dynamodbav:"npcs"`
-	Narrative            []NarrativeMessage         `json:"narrative" dynamodbav:"narrative"`
-	ChatHistory          []ChatMessage              `json:"chat_history" dynamodbav:"chat_history"`
+	Narrative            []NarrativeMessage         `json:"narrative" dynamodbav:"narrative"`       // v5+: stored in the session log
+	ChatHistory          []ChatMessage              `json:"chat_history" dynamodbav:"chat_history"` // v5+: the most recent messages, stored in the session log
 	Ready                bool                       `json:"ready" dynamodbav:"ready"`
 	Title                string                     `json:"title,omitempty" dynamodbav:"title,omitempty"`
 	Theme                string                     `json:"theme,omitempty" dynamodbav:"theme,omitempty"`
@@ -638,21 +848,48 @@ type SaveState struct {
 	LegacyCreationParams AdventureCreationParams    `json:"legacy_creation_params,omitempty" dynamodbav:"legacy_creation_params,omitempty"` // v1/v2 only
 
 	// Combat state (v3+)
-	RoomMonsters         map[string][]*monster.Data `json:"room_monsters,omitempty" dynamodbav:"room_monsters,omitempty"`
-	PendingCombatContext string                     `json:"pending_combat_context,omitempty" dynamodbav:"pending_combat_context,omitempty"`
-	InitiativeOrder      []combat.InitiativeEntry   `json:"initiative_order,omitempty" dynamodbav:"initiative_order,omitempty"`
+	RoomMonsters         map[string][]*monster.Data           `json:"room_monsters,omitempty" dynamodbav:"room_monsters,omitempty"`
+	PendingCombatContext string                               `json:"pending_combat_context,omitempty" dynamodbav:"pending_combat_context,omitempty"`
+	InitiativeOrder      []combat.InitiativeEntry             `json:"initiative_order,omitempty" dynamodbav:"initiative_order,omitempty"`
+	Turn                 *combat.TurnState                    `json:"turn,omitempty" dynamodbav:"turn,omitempty"`
+	HideMonsterHP        bool                                 `json:"hide_monster_hp,omitempty" dynamodbav:"hide_monster_hp,omitempty"`
+	KnownMonsterAC       map[string]bool                      `json:"known_monster_ac,omitempty" dynamodbav:"known_monster_ac,omitempty"`
+	Positions            map[string]map[string]combat.GridPos `json:"positions,omitempty" dynamodbav:"positions,omitempty"`
+	KnownSpells          map[string][]string                  `json:"known_spells,omitempty" dynamodbav:"known_spells,omitempty"`
 
 	// Dungeon layout (v4+)
 	DungeonData *DungeonData `json:"dungeon_data,omitempty" dynamodbav:"dungeon_data,omitempty"`
 
 	// World-gen log replay (v4+). Persisted so late-joining clients can see the terminal output.
 	WorldGenLogs []string `json:"world_gen_logs,omitempty" dynamodbav:"world_gen_logs,omitempty"`
+
+	// Session log (v5+). The chat messages before ChatHistory are not loaded;
+	// OlderChat locates them in the log for the chat-history route.
+	OlderChat     []LogRange     `json:"older_chat,omitempty" dynamodbav:"older_chat,omitempty"`
+	OlderWhispers map[string]int `json:"older_whispers,omitempty" dynamodbav:"older_whispers,omitempty"`
+
+	// Action rounds
+	RoundMode   bool         `json:"round_mode,omitempty" dynamodbav:"round_mode,omitempty"`
+	ActionRound *ActionRound `json:"action_round,omitempty" dynamodbav:"action_round,omitempty"`
 }
 
 // NarrativeMessage stores a single turn of Bedrock conversation history.
 type NarrativeMessage struct {
 	Role    string           `json:"role" dynamodbav:"role"` // "user" | "assistant"
 	Content []NarrativeBlock `json:"content" dynamodbav:"content"`
+	// PrivateTo is the user ID of the player a whisper exchange belongs to:
+	// only the narrator's later private turns for them see it. Empty for the
+	// shared story.
+	PrivateTo string `json:"private_to,omitempty" dynamodbav:"private_to,omitempty"`
+	// LogSeq is the message's position in the session log; 0 until PutGame
+	// has written it. Runtime only.
+	LogSeq int `json:"-" dynamodbav:"-"`
+}
+
+// VisibleTo reports whether the narrator may see the message on a turn for
+// userID; "" is a public turn, which sees only the shared story.
+func (m NarrativeMessage) VisibleTo(userID string) bool {
+	return m.PrivateTo == "" || m.PrivateTo == userID
 }
 
 // NarrativeBlock holds a single content block within a message.
@@ -665,30 +902,48 @@ type NarrativeBlock struct {
 // occurred during a narrator turn. Events are only produced when the player
 // can observe the change (see visibility table in docs/TODO.md).
 type WorldEvent struct {
-	Type    string `json:"type" dynamodbav:"type"`       // "damage","heal","death","revive","item_gained","item_lost","item_appeared","character_arrived","character_departed"
+	Type    string `json:"type" dynamodbav:"type"`       // "damage","heal","death","revive","item_gained","item_lost","item_appeared","character_arrived","character_departed","ability_check"
 	Message string `json:"message" dynamodbav:"message"` // human-readable, player's perspective
 }
 
-// MutationEntry is a durable audit log record written to the mutations table
-// for every DispatchTool call, regardless of player visibility.
-type MutationEntry struct {
-	SessionID string         `json:"session_id" dynamodbav:"session_id"`
-	Ts        int64          `json:"ts" dynamodbav:"ts"`     // unix milliseconds — sort key
-	Turn      int            `json:"turn" dynamodbav:"turn"` // g.ConversationCount at dispatch time
-	Tool      string         `json:"tool" dynamodbav:"tool"`
-	Input     map[string]any `json:"input" dynamodbav:"input"`
-	Result    string         `json:"result" dynamodbav:"result"`
-}
-
 // ChatMessage is the player-facing chat log entry shown in the UI.
 type ChatMessage struct {
 	Type    string       `json:"type" dynamodbav:"type"` // "player" | "narrative"
 	Content string       `json:"content" dynamodbav:"content"`
 	Events  []WorldEvent `json:"events,omitempty" dynamodbav:"events,omitempty"` // non-nil on narrative messages when world events occurred
+	// PrivateTo is the user ID of the only party member who may see the
+	// message: a whispered action or the narrator's private reply to it.
+	// Empty for messages the whole party sees.
+	PrivateTo string `json:"private_to,omitempty" dynamodbav:"private_to,omitempty"`
+	// LogSeq is the message's position in the session log; 0 until PutGame
+	// has written it. Runtime only.
+	LogSeq int `json:"-" dynamodbav:"-"`
+}
+
+// VisibleTo reports whether userID may see the message.
+func (m ChatMessage) VisibleTo(userID string) bool {
+	return m.PrivateTo == "" || m.PrivateTo == userID
+}
+
+// VisibleChat returns the messages of history userID may see, leaving out
+// other members' whispers.
+func VisibleChat(history []ChatMessage, userID string) []ChatMessage {
+	if history == nil {
+		return nil
+	}
+	out := make([]ChatMessage, 0, len(history))
+	for _, m := range history {
+		if m.VisibleTo(userID) {
+			out = append(out, m)
+		}
+	}
+	return out
 }
 
 // ToSaveState serialises the Game to a DynamoDB-ready SaveState.
 func (g *Game) ToSaveState(narrative []NarrativeMessage, history []ChatMessage) SaveState {
+	g.syncLegacyVitals()
+
 	rooms := make([]Area, 0, len(g.Rooms))
 	for _, r := range g.Rooms {
 		rooms = append(rooms, r)
@@ -706,7 +961,11 @@ func (g *Game) ToSaveState(narrative []NarrativeMessage, history []ChatMessage)
 	playersData := make(map[string]*dnd5echar.Data, len(g.DnDPlayers))
 	for uid, char := range g.DnDPlayers {
 		if char != nil {
-			playersData[uid] = char.ToData()
+			data := char.ToData()
+			if slots, ok := g.SpellSlots[uid]; ok {
+				data.SpellSlots = slots
+			}
+			playersData[uid] = data
 		}
 	}
 
@@ -736,15 +995,24 @@ func (g *Game) ToSaveState(narrative []NarrativeMessage, history []ChatMessage)
 		RoomMonsters:         g.RoomMonsters,
 		PendingCombatContext: g.PendingCombatContext,
 		InitiativeOrder:      g.InitiativeOrder,
+		Turn:                 g.Turn,
+		HideMonsterHP:        g.HideMonsterHP,
+		KnownMonsterAC:       g.KnownMonsterAC,
+		Positions:            g.Positions,
+		KnownSpells:          g.KnownSpells,
 		DungeonData:          g.DungeonData,
 		WorldGenLogs:         g.WorldGenLogs,
+		OlderChat:            g.OlderChat,
+		OlderWhispers:        g.OlderWhispers,
+		RoundMode:            g.RoundMode,
+		ActionRound:          g.ActionRound,
 	}
 }
 
 // FromSaveState restores a Game from a SaveState (without loading DnD characters).
 // DnD characters must be loaded separately via g.LoadDnDCharacters() to bind
 // an event bus. This two-step design avoids context/bus passing here.
-// Supports schema versions 1–3. Returns error for unknown future versions.
+// Supports schema versions 1–5. Returns error for unknown future versions.
 func FromSaveState(s SaveState) (*Game, error) {
 	if s.SchemaVersion > SchemaVersion {
 		return nil, fmt.Errorf("unsupported schema version %d (current: %d)", s.SchemaVersion, SchemaVersion)
@@ -784,8 +1052,17 @@ func FromSaveState(s SaveState) (*Game, error) {
 		RoomMonsters:         roomMonsters,
 		PendingCombatContext: s.PendingCombatContext,
 		InitiativeOrder:      s.InitiativeOrder,
+		Turn:                 s.Turn,
+		HideMonsterHP:        s.HideMonsterHP,
+		KnownMonsterAC:       s.KnownMonsterAC,
+		Positions:            s.Positions,
+		KnownSpells:          s.KnownSpells,
 		DungeonData:          s.DungeonData,
 		WorldGenLogs:         s.WorldGenLogs,
+		OlderChat:            append([]LogRange(nil), s.OlderChat...),
+		OlderWhispers:        maps.Clone(s.OlderWhispers),
+		RoundMode:            s.RoundMode,
+		ActionRound:          s.ActionRound,
 	}
 
 	switch {
@@ -844,6 +1121,33 @@ type RoomView struct {
 	Coordinates Coordinates       `json:"coordinates"`
 	Items       []ItemView        `json:"items"`
 	Occupants   []CharacterView   `json:"occupants"`
+	Monsters    []MonsterView     `json:"monsters,omitempty"` // omitted for unexplored stubs and empty rooms
+	// Unexplored marks a "known but unvisited" stub: only ID, Coordinates and
+	// exits back into revealed rooms are populated.
+	Unexplored bool `json:"unexplored,omitempty"`
+}
+
+// HP buckets reported in MonsterView.HPStatus.
+const (
+	MonsterHPUnhurt    = "unhurt"     // full hit points
+	MonsterHPWounded   = "wounded"    // above half
+	MonsterHPBloodied  = "bloodied"   // half or below
+	MonsterHPNearDeath = "near_death" // a quarter or below
+	MonsterHPDead      = "dead"
+)
+
+// MonsterView is the client-facing representation of a monster in a room.
+// HP and MaxHP are nil when the game hides exact hit points; HPStatus is
+// always set. AC is zero until the party has learned it.
+type MonsterView struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	HP         *int     `json:"hp,omitempty"`
+	MaxHP      *int     `json:"max_hp,omitempty"`
+	HPStatus   string   `json:"hp_status"`
+	AC         int      `json:"ac,omitempty"`
+	Alive      bool     `json:"alive"`
+	Conditions []string `json:"conditions,omitempty"`
 }
 
 // ItemView is the client-facing representation of an item.
@@ -877,6 +1181,12 @@ type DnDStatsView struct {
 	Speed       int            `json:"speed"`
 	Proficiency int            `json:"proficiency_bonus"`
 	Abilities   map[string]int `json:"abilities"` // "str","dex","con","int","wis","cha" → score
+	XP          int            `json:"xp"`
+	NextLevelXP int            `json:"next_level_xp,omitempty"` // total XP for the next level; 0 at level 20
+	CanLevelUp  bool           `json:"can_level_up,omitempty"`
+	// Casters only: slot level → max/used, and known cantrips/spells.
+	SpellSlots map[int]dnd5echar.SpellSlotData `json:"spell_slots,omitempty"`
+	Spells     []string                        `json:"spells,omitempty"`
 }
 
 // CharacterView is the client-facing representation of a character.
@@ -890,6 +1200,11 @@ type CharacterView struct {
 	Friendly    bool          `json:"friendly"`
 	Inventory   []ItemView    `json:"inventory"`
 	Equipment   EquipmentView `json:"equipment"`
+	// Vital is the D&D dying-rules state: "conscious", "dying", "stable" or
+	// "dead". Empty for legacy characters.
+	Vital string `json:"vital,omitempty"`
+	// DeathSaves is set while the character is at 0 HP and not dead.
+	DeathSaves *DeathSavesView `json:"death_saves,omitempty"`
 	// D&D 5e stats — populated when character was created via CharacterCreationData
 	DnD *DnDStatsView `json:"dnd,omitempty"`
 }
@@ -903,16 +1218,28 @@ type GameStateView struct {
 	Party       []CharacterView     `json:"party"`
 	Rooms       map[string]RoomView `json:"rooms"`
 	ChatHistory []ChatMessage       `json:"chat_history"`
+	// OlderChat counts the messages before ChatHistory that the caller may
+	// see; page back through them with GET /api/games/{uuid}/chat.
+	OlderChat int       `json:"older_chat,omitempty"`
+	Turn      *TurnView `json:"turn,omitempty"` // nil outside of combat
+	Grid      *GridView `json:"grid,omitempty"` // combat grid of the caller's room, once anyone is placed
+	// ActionRound is nil unless round mode is on; then it lists the pending
+	// intents the whole party can see.
+	ActionRound *ActionRoundView `json:"action_round,omitempty"`
+	// OOCChat is the session's recent out-of-character chat, oldest first.
+	// Only GET /api/games/{uuid} fills it in; new messages arrive as
+	// ooc_message frames.
+	OOCChat []OOCMessage `json:"ooc_chat,omitempty"`
 }
 
 // buildCharacterView constructs a CharacterView for a given legacy character stub.
 func (g *Game) buildCharacterView(c Character) CharacterView {
-	return g.buildCharacterViewWithDnD(c, nil)
+	return g.buildCharacterViewWithDnD("", c, nil)
 }
 
 // buildCharacterViewWithDnD constructs a CharacterView, optionally merging
 // D&D 5e stats from a loaded character.
-func (g *Game) buildCharacterViewWithDnD(c Character, dnd *dnd5echar.Character) CharacterView {
+func (g *Game) buildCharacterViewWithDnD(userID string, c Character, dnd *dnd5echar.Character) CharacterView {
 	resolveItems := func(ids []string) []ItemView {
 		views := make([]ItemView, 0, len(ids))
 		for _, id := range ids {
@@ -969,7 +1296,12 @@ func (g *Game) buildCharacterViewWithDnD(c Character, dnd *dnd5echar.Character)
 		data := dnd.ToData()
 		view.Health = dnd.GetHitPoints()
 		view.MaxHealth = data.MaxHitPoints
-		view.Alive = dnd.GetHitPoints() > 0
+		view.Vital = combat.Vital(dnd)
+		view.Alive = view.Vital != combat.VitalDead
+		if view.Vital == combat.VitalDying || view.Vital == combat.VitalStable {
+			state := dnd.GetDeathSaveState()
+			view.DeathSaves = &DeathSavesView{Successes: state.Successes, Failures: state.Failures}
+		}
 		abilities := map[string]int{
 			"str": data.AbilityScores["str"],
 			"dex": data.AbilityScores["dex"],
@@ -987,7 +1319,11 @@ func (g *Game) buildCharacterViewWithDnD(c Character, dnd *dnd5echar.Character)
 			Speed:       dnd.GetSpeed(),
 			Proficiency: data.ProficiencyBonus,
 			Abilities:   abilities,
+			XP:          c.XP,
+			NextLevelXP: combat.XPForLevel(data.Level + 1),
+			CanLevelUp:  data.Level < combat.MaxLevel && combat.LevelForXP(c.XP) > data.Level,
 		}
+		view.DnD.SpellSlots, view.DnD.Spells = g.spellState(userID, data.ClassID, data.Level)
 	}
 
 	return view
@@ -996,35 +1332,15 @@ func (g *Game) buildCharacterViewWithDnD(c Character, dnd *dnd5echar.Character)
 // BuildGameStateView constructs a full snapshot from the Game for a specific
 // caller. Self is the caller's own character; Party contains all other members.
 // If callerUserID is empty or not found, falls back to the owner's character.
+// Rooms is filtered by the caller's fog-of-war (see VisibleRoomViews) and
+// ChatHistory by who may see each message (see VisibleChat).
 func (g *Game) BuildGameStateView(callerUserID string, history []ChatMessage) GameStateView {
-	toRoomView := func(a Area) RoomView {
-		occupantViews := make([]CharacterView, 0)
-		for _, cid := range a.Occupants {
-			if c, ok := g.NPCs[cid]; ok {
-				occupantViews = append(occupantViews, g.buildCharacterView(c))
-			}
-		}
-		roomType := ""
-		if g.DungeonData != nil {
-			if dr, ok := g.DungeonData.Rooms[a.ID]; ok {
-				roomType = string(dr.Type)
-			}
-		}
-		return RoomView{
-			ID: a.ID, Name: a.Name, Description: a.Description,
-			Type:        roomType,
-			Connections: a.Connections, Coordinates: a.Coordinates,
-			Items:     g.buildItemViews(a.Items),
-			Occupants: occupantViews,
-		}
-	}
-
 	caller, ok := g.GetPlayerCharacter(callerUserID)
 	if !ok {
 		caller, _ = g.OwnerCharacter()
 	}
 	callerDnD, _ := g.GetDnDCharacter(callerUserID)
-	selfView := g.buildCharacterViewWithDnD(caller, callerDnD)
+	selfView := g.buildCharacterViewWithDnD(callerUserID, caller, callerDnD)
 
 	party := make([]CharacterView, 0, len(g.Players)-1)
 	for uid, char := range g.Players {
@@ -1032,17 +1348,12 @@ func (g *Game) BuildGameStateView(callerUserID string, history []ChatMessage) Ga
 			continue
 		}
 		memberDnD, _ := g.GetDnDCharacter(uid)
-		party = append(party, g.buildCharacterViewWithDnD(char, memberDnD))
-	}
-
-	roomViews := make(map[string]RoomView, len(g.Rooms))
-	for id, room := range g.Rooms {
-		roomViews[id] = toRoomView(room)
+		party = append(party, g.buildCharacterViewWithDnD(uid, char, memberDnD))
 	}
 
 	var currentRoom RoomView
 	if r, ok := g.Rooms[caller.LocationID]; ok {
-		currentRoom = toRoomView(r)
+		currentRoom = g.buildRoomView(r)
 	}
 
 	return GameStateView{
@@ -1050,9 +1361,176 @@ func (g *Game) BuildGameStateView(callerUserID string, history []ChatMessage) Ga
 		Player:      selfView, // backward compat
 		Self:        selfView,
 		Party:       party,
-		Rooms:       roomViews,
-		ChatHistory: history,
+		Rooms:       g.VisibleRoomViews(callerUserID),
+		ChatHistory: VisibleChat(history, callerUserID),
+		OlderChat:   g.OlderChatVisibleTo(callerUserID),
+		Turn:        g.TurnView(),
+		Grid:        g.BuildGridView(caller.LocationID),
+		ActionRound: g.ActionRoundView(),
+	}
+}
+
+// buildRoomView constructs the full RoomView for a room the viewer can see.
+func (g *Game) buildRoomView(a Area) RoomView {
+	occupantViews := make([]CharacterView, 0)
+	for _, cid := range a.Occupants {
+		if c, ok := g.NPCs[cid]; ok {
+			occupantViews = append(occupantViews, g.buildCharacterView(c))
+		}
+	}
+	roomType := ""
+	if g.DungeonData != nil {
+		if dr, ok := g.DungeonData.Rooms[a.ID]; ok {
+			roomType = string(dr.Type)
+		}
+	}
+	return RoomView{
+		ID: a.ID, Name: a.Name, Description: a.Description,
+		Type:        roomType,
+		Connections: a.Connections, Coordinates: a.Coordinates,
+		Items:     g.buildItemViews(a.Items),
+		Occupants: occupantViews,
+		Monsters:  g.BuildMonsterViews(a.ID),
+	}
+}
+
+// BuildMonsterViews returns the client-facing views of every monster in a
+// room, honouring HideMonsterHP and KnownMonsterAC. Returns nil if the room
+// has no monsters.
+func (g *Game) BuildMonsterViews(roomID string) []MonsterView {
+	data := g.GetRoomMonsters(roomID)
+	if len(data) == 0 {
+		return nil
+	}
+	views := make([]MonsterView, 0, len(data))
+	for _, m := range data {
+		if m == nil {
+			continue
+		}
+		v := MonsterView{
+			ID:         m.ID,
+			Name:       m.Name,
+			HPStatus:   monsterHPStatus(m.HitPoints, m.MaxHitPoints),
+			Alive:      m.HitPoints > 0,
+			Conditions: monsterConditionIDs(m.Conditions),
+		}
+		if !g.HideMonsterHP {
+			hp, maxHP := m.HitPoints, m.MaxHitPoints
+			v.HP, v.MaxHP = &hp, &maxHP
+		}
+		if g.KnownMonsterAC[m.ID] {
+			v.AC = m.ArmorClass
+		}
+		views = append(views, v)
 	}
+	return views
+}
+
+// monsterHPStatus buckets a monster's hit points for display.
+func monsterHPStatus(hp, maxHP int) string {
+	switch {
+	case hp <= 0:
+		return MonsterHPDead
+	case maxHP <= 0 || hp >= maxHP:
+		return MonsterHPUnhurt
+	case hp*4 <= maxHP:
+		return MonsterHPNearDeath
+	case hp*2 <= maxHP:
+		return MonsterHPBloodied
+	default:
+		return MonsterHPWounded
+	}
+}
+
+// monsterConditionIDs extracts condition IDs (e.g. "poisoned") from the
+// opaque condition JSON blobs stored on monster.Data. Unreadable entries are skipped.
+func monsterConditionIDs(raw []json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(raw))
+	for _, r := range raw {
+		var peek struct {
+			Ref struct {
+				ID string `json:"id"`
+			} `json:"ref"`
+		}
+		if err := json.Unmarshal(r, &peek); err != nil || peek.Ref.ID == "" {
+			continue
+		}
+		ids = append(ids, peek.Ref.ID)
+	}
+	return ids
+}
+
+// buildRoomStub constructs the placeholder for a room the viewer knows exists
+// (it is behind an exit of a revealed room) but has not visited. Only its
+// position and the exits leading back into revealed rooms are exposed.
+func buildRoomStub(a Area, revealed map[string]bool) RoomView {
+	conns := make(map[string]string)
+	for dir, id := range a.Connections {
+		if revealed[id] {
+			conns[dir] = id
+		}
+	}
+	return RoomView{
+		ID:          a.ID,
+		Connections: conns,
+		Coordinates: a.Coordinates,
+		Items:       []ItemView{},
+		Occupants:   []CharacterView{},
+		Unexplored:  true,
+	}
+}
+
+// RevealedRoomIDs returns the set of rooms userID has visited, always
+// including their current room. Returns nil for legacy games without
+// DungeonData, which have no fog-of-war and show every room.
+func (g *Game) RevealedRoomIDs(userID string) map[string]bool {
+	if g.DungeonData == nil {
+		return nil
+	}
+	revealed := make(map[string]bool)
+	for id, ok := range g.DungeonData.RevealedTo(userID) {
+		if ok {
+			revealed[id] = true
+		}
+	}
+	if c, ok := g.GetPlayerCharacter(userID); ok && c.LocationID != "" {
+		revealed[c.LocationID] = true
+	}
+	return revealed
+}
+
+// VisibleRoomViews returns the rooms userID is allowed to see: full views of
+// the rooms they have revealed plus "known but unvisited" stubs for rooms
+// behind their exits. Every room is returned for legacy games.
+func (g *Game) VisibleRoomViews(userID string) map[string]RoomView {
+	revealed := g.RevealedRoomIDs(userID)
+	if revealed == nil {
+		views := make(map[string]RoomView, len(g.Rooms))
+		for id, room := range g.Rooms {
+			views[id] = g.buildRoomView(room)
+		}
+		return views
+	}
+	views := make(map[string]RoomView, len(revealed))
+	for id := range revealed {
+		room, ok := g.Rooms[id]
+		if !ok {
+			continue
+		}
+		views[id] = g.buildRoomView(room)
+		for _, nextID := range room.Connections {
+			if revealed[nextID] {
+				continue
+			}
+			if next, ok := g.Rooms[nextID]; ok {
+				views[nextID] = buildRoomStub(next, revealed)
+			}
+		}
+	}
+	return views
 }
 
 // buildItemViews resolves a list of item IDs into ItemView slices.
@@ -1083,5 +1561,6 @@ type StateDelta struct {
 	Self         *CharacterView      `json:"self,omitempty"`
 	Party        []CharacterView     `json:"party,omitempty"` // updated party member views
 	UpdatedRooms map[string]RoomView `json:"updated_rooms,omitempty"`
-	Events       []WorldEvent        `json:"events,omitempty"` // player-visible world events this turn
+	Monsters     []MonsterView       `json:"monsters,omitempty"` // monsters in the member's current room
+	Events       []WorldEvent        `json:"events,omitempty"`   // player-visible world events this turn
 }
@@ -1,8 +1,13 @@
 package game_test
 
 import (
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/monster"
+
+	"github.com/rrochlin/an-amazing-adventure/internal/combat"
 	"github.com/rrochlin/an-amazing-adventure/internal/game"
 )
 
@@ -339,6 +344,117 @@ func TestMovePlayer(t *testing.T) {
 	}
 }
 
+// newPartyGame builds a three-room line (West ↔ Middle ↔ East) with the owner
+// and one invited member both standing in Middle.
+func newPartyGame(t *testing.T) (*game.Game, game.Area, game.Area, game.Area) {
+	t.Helper()
+	g := newTestGame()
+	g.SetPlayerCharacter("user-2", game.NewCharacter("Sidekick", ""))
+	west := game.NewArea("West", "")
+	middle := game.NewArea("Middle", "")
+	east := game.NewArea("East", "")
+	for _, r := range []game.Area{west, middle, east} {
+		_ = g.AddRoom(r)
+	}
+	_ = g.ConnectRooms(middle.ID, west.ID, "west")
+	_ = g.ConnectRooms(middle.ID, east.ID, "east")
+	if err := g.PlaceCharacter("user-1", middle.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.PlaceCharacter("user-2", middle.ID); err != nil {
+		t.Fatal(err)
+	}
+	return g, west, middle, east
+}
+
+func TestMoveCharacter_PartyMemberMovesIndependently(t *testing.T) {
+	g, _, middle, east := newPartyGame(t)
+
+	dest, err := g.MoveCharacter("user-2", "east")
+	if err != nil {
+		t.Fatalf("MoveCharacter: %v", err)
+	}
+	if dest.ID != east.ID {
+		t.Errorf("expected member in east room, got %s", dest.ID)
+	}
+	owner, _ := g.OwnerCharacter()
+	if owner.LocationID != middle.ID {
+		t.Error("owner must not move when another member moves")
+	}
+}
+
+func TestMoveCharacter_PartySplitLimit(t *testing.T) {
+	g, _, middle, _ := newPartyGame(t)
+	g.SetPlayerCharacter("user-3", game.NewCharacter("Tagalong", ""))
+	_ = g.PlaceCharacter("user-3", middle.ID)
+
+	if _, err := g.MoveCharacter("user-2", "east"); err != nil {
+		t.Fatalf("first split should be allowed: %v", err)
+	}
+	// Owner heading west while user-3 stays in Middle spreads the party over three rooms.
+	if _, err := g.MoveCharacter("user-1", "west"); err == nil {
+		t.Error("expected party-split error when occupying a third room")
+	}
+	// Owner joining the member is fine.
+	if _, err := g.MoveCharacter("user-1", "east"); err != nil {
+		t.Errorf("regrouping must be allowed: %v", err)
+	}
+}
+
+func TestMoveCharacter_CannotAbandonAlliesMidFight(t *testing.T) {
+	g, _, middle, _ := newPartyGame(t)
+	g.SetRoomMonsters(middle.ID, []*monster.Data{{ID: "goblin-1", Name: "Goblin", HitPoints: 7}})
+
+	if _, err := g.MoveCharacter("user-2", "east"); err == nil {
+		t.Error("expected error leaving an ally alone with live monsters")
+	}
+
+	g.SetRoomMonsters(middle.ID, []*monster.Data{{ID: "goblin-1", Name: "Goblin", HitPoints: 0}})
+	if _, err := g.MoveCharacter("user-2", "east"); err != nil {
+		t.Errorf("leaving after the fight should be allowed: %v", err)
+	}
+}
+
+func TestEnsureCharacterPlaced_UsesDungeonStartAndReveals(t *testing.T) {
+	g := newTestGame()
+	start := game.NewArea("Entrance", "")
+	_ = g.AddRoom(start)
+	g.DungeonData = &game.DungeonData{StartRoomID: start.ID}
+	g.SetPlayerCharacter("user-2", game.NewCharacter("Latecomer", ""))
+
+	if err := g.EnsureCharacterPlaced("user-2"); err != nil {
+		t.Fatalf("EnsureCharacterPlaced: %v", err)
+	}
+	member, _ := g.GetPlayerCharacter("user-2")
+	if member.LocationID != start.ID {
+		t.Errorf("expected member placed at entrance, got %q", member.LocationID)
+	}
+	if !g.DungeonData.RevealedTo("user-2")[start.ID] {
+		t.Error("expected entrance revealed to the new member")
+	}
+	if _, ok := g.DungeonData.MemberRevealedRooms["user-1"]; ok {
+		t.Error("member reveal must not create a per-member set for the owner")
+	}
+}
+
+func TestDungeonData_RevealedToFallsBackToShared(t *testing.T) {
+	d := &game.DungeonData{RevealedRooms: map[string]bool{"room-a": true}}
+	if !d.RevealedTo("user-1")["room-a"] {
+		t.Error("members without a per-member record should see the shared set")
+	}
+	d.Reveal("user-1", "room-b")
+	if !d.RevealedRooms["room-b"] {
+		t.Error("Reveal must update the shared set")
+	}
+	if got := d.RevealedTo("user-1"); !got["room-a"] || !got["room-b"] {
+		t.Errorf("a member's first reveal must keep the rooms they already saw, got %v", got)
+	}
+	d.RevealedRooms["room-c"] = true // revealed by someone else
+	if d.RevealedTo("user-1")["room-c"] {
+		t.Error("once recorded, a member's own set must be used")
+	}
+}
+
 func TestNPCLifecycle(t *testing.T) {
 	g := newTestGame()
 	room := game.NewArea("Room", "")
@@ -552,3 +668,385 @@ func TestBuildGameStateView(t *testing.T) {
 		t.Error("expected 1 chat message in view")
 	}
 }
+
+func TestBuildGameStateView_HidesOthersWhispers(t *testing.T) {
+	g, _, _, _ := newPartyGame(t)
+	history := []game.ChatMessage{
+		{Type: "player", Content: "we press on"},
+		{Type: "player", Content: "I pocket the gem", PrivateTo: "user-2"},
+		{Type: "narrative", Content: "Nobody saw.", PrivateTo: "user-2"},
+	}
+	if got := g.BuildGameStateView("user-1", history).ChatHistory; len(got) != 1 {
+		t.Errorf("user-1 should only see the party message, got %+v", got)
+	}
+	if got := g.BuildGameStateView("user-2", history).ChatHistory; len(got) != 3 {
+		t.Errorf("user-2 should see their own whisper, got %+v", got)
+	}
+	if got := g.SpeakerInput("user-2", "I pocket the gem", true); got != "[Sidekick, privately] I pocket the gem" {
+		t.Errorf("SpeakerInput = %q", got)
+	}
+}
+
+// ── Fog-of-war ───────────────────────────────────────────────────────────────
+
+func TestBuildGameStateView_FogOfWar(t *testing.T) {
+	g, west, middle, east := newPartyGame(t)
+	g.DungeonData = &game.DungeonData{StartRoomID: middle.ID}
+	g.RevealRoom("user-1", middle.ID)
+	g.RevealRoom("user-2", middle.ID)
+	if _, err := g.MoveCharacter("user-2", "east"); err != nil {
+		t.Fatal(err)
+	}
+	g.RevealRoom("user-2", east.ID)
+
+	view := g.BuildGameStateView("user-1", nil)
+	if v, ok := view.Rooms[middle.ID]; !ok || v.Unexplored || v.Name != "Middle" {
+		t.Errorf("expected full view of the current room, got %+v", v)
+	}
+	stub, ok := view.Rooms[east.ID]
+	if !ok {
+		t.Fatal("expected a stub for the room behind the east exit")
+	}
+	if !stub.Unexplored || stub.Name != "" || stub.Description != "" {
+		t.Errorf("expected unexplored stub without name/description, got %+v", stub)
+	}
+	if stub.Connections["west"] != middle.ID {
+		t.Errorf("stub should keep the exit back to the revealed room, got %v", stub.Connections)
+	}
+	if _, ok := view.Rooms[west.ID]; !ok {
+		t.Error("expected a stub for the room behind the west exit")
+	}
+
+	// user-2 has visited East; the owner has not.
+	memberView := g.BuildGameStateView("user-2", nil)
+	if memberView.Rooms[east.ID].Unexplored {
+		t.Error("expected East to be fully visible to the member who visited it")
+	}
+}
+
+func TestBuildGameStateView_HidesUnknownRooms(t *testing.T) {
+	g, _, middle, east := newPartyGame(t)
+	beyond := game.NewArea("Boss Lair", "The dragon sleeps")
+	_ = g.AddRoom(beyond)
+	_ = g.ConnectRooms(east.ID, beyond.ID, "east")
+	g.DungeonData = &game.DungeonData{StartRoomID: middle.ID}
+	g.RevealRoom("user-1", middle.ID)
+
+	view := g.BuildGameStateView("user-1", nil)
+	if _, ok := view.Rooms[beyond.ID]; ok {
+		t.Error("rooms two exits away must not be sent to the client")
+	}
+	if view.Rooms[middle.ID].Name != "Middle" {
+		t.Error("revealed rooms must be sent in full")
+	}
+}
+
+func TestBuildGameStateView_LegacyGameShowsAllRooms(t *testing.T) {
+	g, _, _, _ := newPartyGame(t)
+	view := g.BuildGameStateView("user-1", nil)
+	if len(view.Rooms) != 3 {
+		t.Errorf("expected all 3 rooms without DungeonData, got %d", len(view.Rooms))
+	}
+}
+
+// ── Monster views ────────────────────────────────────────────────────────────
+
+func TestBuildGameStateView_RoomMonsters(t *testing.T) {
+	g := newTestGame()
+	room := game.NewArea("Den", "")
+	_ = g.AddRoom(room)
+	_ = g.PlacePlayer(room.ID)
+	g.SetRoomMonsters(room.ID, []*monster.Data{
+		{ID: "wolf-1", Name: "Wolf", HitPoints: 2, MaxHitPoints: 11, ArmorClass: 13},
+		{ID: "wolf-2", Name: "Wolf", HitPoints: 0, MaxHitPoints: 11, ArmorClass: 13},
+	})
+
+	view := g.BuildGameStateView("user-1", nil)
+	ms := view.CurrentRoom.Monsters
+	if len(ms) != 2 {
+		t.Fatalf("expected 2 monsters in current room, got %d", len(ms))
+	}
+	if ms[0].HP == nil || *ms[0].HP != 2 || ms[0].HPStatus != game.MonsterHPNearDeath {
+		t.Errorf("expected exact HP 2 and near_death bucket, got %+v", ms[0])
+	}
+	if ms[0].AC != 0 {
+		t.Error("AC must stay hidden until the party learns it")
+	}
+	if ms[1].Alive || ms[1].HPStatus != game.MonsterHPDead {
+		t.Errorf("expected dead monster, got %+v", ms[1])
+	}
+
+	g.HideMonsterHP = true
+	g.RevealMonsterAC("wolf-1")
+	ms = g.BuildGameStateView("user-1", nil).CurrentRoom.Monsters
+	if ms[0].HP != nil || ms[0].MaxHP != nil {
+		t.Error("exact HP must be omitted when HideMonsterHP is set")
+	}
+	if ms[0].AC != 13 {
+		t.Errorf("expected revealed AC 13, got %d", ms[0].AC)
+	}
+}
+
+// ── Dungeon lifecycle ────────────────────────────────────────────────────────
+
+func TestUpdateDungeonState_ClearedWhenBossRoomDead(t *testing.T) {
+	g := newTestGame()
+	g.DungeonData = &game.DungeonData{
+		BossRoomID: "boss",
+		Rooms:      map[string]*game.DungeonRoomData{"boss": {ID: "boss", Name: "Throne of Bones"}},
+	}
+	g.SetRoomMonsters("boss", []*monster.Data{{ID: "bear", HitPoints: 5}, {ID: "ghoul", HitPoints: 0}})
+
+	if g.UpdateDungeonState() || g.DungeonOver() {
+		t.Fatal("run must stay active while a boss-room monster lives")
+	}
+	g.SetRoomMonsters("boss", []*monster.Data{{ID: "bear", HitPoints: 0}, {ID: "ghoul", HitPoints: 0}})
+	if !g.UpdateDungeonState() {
+		t.Fatal("expected transition once the boss room is cleared")
+	}
+	out := g.Outcome()
+	if out.State != "cleared" || out.Message == "" {
+		t.Errorf("unexpected outcome %+v", out)
+	}
+}
+
+func TestUpdateDungeonState_EmptyBossRoomClearedOnEntry(t *testing.T) {
+	g := newTestGame()
+	g.DungeonData = &game.DungeonData{
+		BossRoomID: "boss",
+		Rooms:      map[string]*game.DungeonRoomData{"boss": {ID: "boss", Name: "Hollow Crypt"}},
+	}
+
+	if g.UpdateDungeonState() || g.DungeonOver() {
+		t.Fatal("an empty boss room must not clear the run before anyone enters it")
+	}
+	g.RevealRoom("user-1", "boss")
+	if !g.UpdateDungeonState() || g.Outcome().State != "cleared" {
+		t.Fatalf("expected a clear once the party enters the empty boss room, got %+v", g.Outcome())
+	}
+}
+
+func TestUpdateDungeonState_LegacyGameNeverEnds(t *testing.T) {
+	g := newTestGame()
+	if g.UpdateDungeonState() || g.DungeonOver() {
+		t.Error("games without DungeonData have no lifecycle")
+	}
+}
+
+// ── Combat grid ──────────────────────────────────────────────────────────────
+
+func TestRoomGridSize(t *testing.T) {
+	if w, h := game.RoomGridSize(&game.DungeonRoomData{Width: 7, Height: 5}); w != 7 || h != 5 {
+		t.Errorf("expected explicit 7x5, got %dx%d", w, h)
+	}
+	if w, h := game.RoomGridSize(&game.DungeonRoomData{Type: game.DungeonRoomTypeCorridor}); w >= h {
+		t.Errorf("expected a corridor to be long and narrow, got %dx%d", w, h)
+	}
+	if w, h := game.RoomGridSize(nil); w == 0 || h == 0 {
+		t.Error("expected a default size for legacy rooms")
+	}
+}
+
+func TestRoomGrid_PlacesAndPersists(t *testing.T) {
+	g, _, middle, _ := newPartyGame(t)
+	g.SetRoomMonsters(middle.ID, []*monster.Data{
+		{ID: "wolf-1", Name: "Wolf", HitPoints: 11, MaxHitPoints: 11},
+		{ID: "wolf-2", Name: "Wolf", HitPoints: 0, MaxHitPoints: 11},
+	})
+
+	grid := g.RoomGrid(middle.ID)
+	p1, ok1 := grid.Position("user-1")
+	p2, ok2 := grid.Position("user-2")
+	w, ok3 := grid.Position("wolf-1")
+	if !ok1 || !ok2 || !ok3 {
+		t.Fatal("expected both players and the live wolf on the grid")
+	}
+	if _, dead := grid.Position("wolf-2"); dead {
+		t.Error("dead monsters must not take a hex")
+	}
+	if p1 == p2 {
+		t.Error("players must not share a hex")
+	}
+	if w.Y >= p1.Y {
+		t.Errorf("expected monsters north of the party, wolf %v player %v", w, p1)
+	}
+
+	restored, err := game.FromSaveState(g.ToSaveState(nil, nil))
+	if err != nil {
+		t.Fatalf("FromSaveState: %v", err)
+	}
+	if got, _ := restored.RoomGrid(middle.ID).Position("user-1"); got != p1 {
+		t.Errorf("expected persisted position %v, got %v", p1, got)
+	}
+}
+
+func TestStepCharacter_MovementBudget(t *testing.T) {
+	g, _, middle, _ := newPartyGame(t)
+	g.SetRoomMonsters(middle.ID, []*monster.Data{{ID: "wolf-1", Name: "Wolf", HitPoints: 11, MaxHitPoints: 11}})
+	g.StartCombat(middle.ID, []combat.InitiativeEntry{
+		{CombatantID: "user-1", CombatantName: "Hero", IsPlayer: true},
+		{CombatantID: "wolf-1", CombatantName: "Wolf"},
+	}, "user-1", time.Now())
+
+	start, _ := g.RoomGrid(middle.ID).Position("user-1")
+	far := combat.GridPos{X: start.X, Y: start.Y - combat.PlayerSpeed - 1}
+	if err := g.StepCharacter("user-1", far); err == nil {
+		t.Error("expected step beyond speed to be refused")
+	}
+	near := combat.GridPos{X: start.X, Y: start.Y - 2}
+	if err := g.StepCharacter("user-1", near); err != nil {
+		t.Fatalf("StepCharacter: %v", err)
+	}
+	if g.Turn.Moved != 2 {
+		t.Errorf("expected 2 hexes spent, got %d", g.Turn.Moved)
+	}
+	if got, _ := g.RoomGrid(middle.ID).Position("user-1"); got != near {
+		t.Errorf("expected player at %v, got %v", near, got)
+	}
+}
+
+func TestMoveCharacter_LeavesGrid(t *testing.T) {
+	g, _, middle, _ := newPartyGame(t)
+	g.RoomGrid(middle.ID)
+	if _, err := g.MoveCharacter("user-2", "east"); err != nil {
+		t.Fatalf("MoveCharacter: %v", err)
+	}
+	if _, ok := g.Positions[middle.ID]["user-2"]; ok {
+		t.Error("expected position in the old room to be cleared")
+	}
+}
+
+// ── Session log ──────────────────────────────────────────────────────────────
+
+func TestSliceRanges(t *testing.T) {
+	var ranges []game.LogRange
+	for _, seq := range []int{1, 2, 3, 7, 8} {
+		ranges = game.AppendRange(ranges, seq)
+	}
+	if len(ranges) != 2 || game.RangeLen(ranges) != 5 {
+		t.Fatalf("ranges = %+v", ranges)
+	}
+	got := game.SliceRanges(ranges, 2, 4)
+	want := []game.LogRange{{Start: 3, End: 4}, {Start: 7, End: 8}}
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("SliceRanges(2, 4) = %+v, want %+v", got, want)
+	}
+}
+
+func TestPageChat_MovesOnlyLoggedMessages(t *testing.T) {
+	g := game.NewGame("s1", "user-1")
+	history := make([]game.ChatMessage, game.ChatWindow+1)
+	for i := range history {
+		history[i].LogSeq = i + 1
+	}
+	history[game.ChatWindow].LogSeq = 0 // not yet written
+
+	if got := g.PageChat(history[:game.ChatWindow]); len(got) != game.ChatWindow {
+		t.Errorf("history within the window should stay put, kept %d", len(got))
+	}
+	got := g.PageChat(history)
+	if len(got) != game.ChatPageSize || got[0].LogSeq != game.ChatWindow+1-game.ChatPageSize+1 {
+		t.Fatalf("kept %d messages starting at seq %d", len(got), got[0].LogSeq)
+	}
+	if len(g.OlderChat) != 1 || game.RangeLen(g.OlderChat) != game.ChatWindow+1-game.ChatPageSize {
+		t.Errorf("OlderChat = %+v", g.OlderChat)
+	}
+}
+
+func TestPageChat_CountsOlderWhispersPerMember(t *testing.T) {
+	g, _, _, _ := newPartyGame(t)
+	history := make([]game.ChatMessage, game.ChatWindow+1)
+	for i := range history {
+		history[i].LogSeq = i + 1
+	}
+	history[0].PrivateTo = "user-2"
+	history[1].PrivateTo = "user-2"
+	history[2].PrivateTo = "user-1"
+
+	g.PageChat(history)
+	older := game.RangeLen(g.OlderChat)
+	if got := g.BuildGameStateView("user-1", nil).OlderChat; got != older-2 {
+		t.Errorf("user-1 OlderChat = %d, want %d", got, older-2)
+	}
+	if got := g.BuildGameStateView("user-2", nil).OlderChat; got != older-1 {
+		t.Errorf("user-2 OlderChat = %d, want %d", got, older-1)
+	}
+	restored, err := game.FromSaveState(g.ToSaveState(nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := restored.OlderChatVisibleTo("user-1"); got != older-2 {
+		t.Errorf("after a save round trip user-1 OlderChat = %d, want %d", got, older-2)
+	}
+}
+
+// ── Action rounds ────────────────────────────────────────────────────────────
+
+func TestActionRound_CollectsIntentsUntilEveryoneDeclares(t *testing.T) {
+	g, _, _, _ := newPartyGame(t)
+	now := time.Now()
+
+	if err := g.SubmitIntent("user-1", "I search", now); !errors.Is(err, game.ErrNotRoundMode) {
+		t.Fatalf("SubmitIntent with round mode off = %v", err)
+	}
+	if err := g.SetRoundMode("user-2", true); err == nil {
+		t.Fatal("only the owner may turn round mode on")
+	}
+	if err := g.SetRoundMode("user-1", true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.SubmitIntent("user-2", "I guard the door", now); err != nil {
+		t.Fatal(err)
+	}
+	if g.RoundReady(now) {
+		t.Fatal("round should wait for Hero")
+	}
+	view := g.ActionRoundView()
+	if len(view.Intents) != 1 || view.Intents[0].Name != "Sidekick" || len(view.WaitingOn) != 1 || view.WaitingOn[0] != "Hero" {
+		t.Errorf("view = %+v", view)
+	}
+	if !g.RoundReady(now.Add(game.RoundTimeout + time.Second)) {
+		t.Error("round should be ready once it times out")
+	}
+	if g.RoundExpired(now) || !g.RoundExpired(now.Add(game.RoundTimeout+time.Second)) {
+		t.Error("RoundExpired should only report a round past its deadline")
+	}
+
+	_ = g.SubmitIntent("user-1", "I search", now)
+	_ = g.SubmitIntent("user-1", "I search the chest", now) // replaces the first
+	if !g.RoundReady(now) {
+		t.Fatal("round should be ready once everyone declared")
+	}
+	intents, err := g.TakeRound()
+	if err != nil || len(intents) != 2 || intents[0].UserID != "user-1" {
+		t.Fatalf("TakeRound = %+v, %v", intents, err)
+	}
+	if got := g.RoundInput(intents); got != "[Hero] I search the chest\n[Sidekick] I guard the door" {
+		t.Errorf("RoundInput = %q", got)
+	}
+	if _, err := g.TakeRound(); !errors.Is(err, game.ErrNoRound) {
+		t.Errorf("second TakeRound = %v", err)
+	}
+
+	_ = g.SubmitIntent("user-2", "I wait", now)
+	_ = g.SetRoundMode("user-1", false)
+	if g.ActionRound != nil || g.ActionRoundView() != nil {
+		t.Error("turning round mode off should drop the pending round")
+	}
+}
+
+func TestSetRoundMode_LegacySaveOwnedByUserID(t *testing.T) {
+	s := game.NewGame("s1", "user-1").ToSaveState(nil, nil)
+	s.OwnerID = "" // saves from before owner_id only carry user_id
+	g, err := game.FromSaveState(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetRoundMode("user-1", true); err != nil {
+		t.Errorf("the user_id owner of a legacy save should change round mode: %v", err)
+	}
+	if err := g.SetRoundMode("", false); err == nil {
+		t.Error("an empty caller must not match a missing owner")
+	}
+}
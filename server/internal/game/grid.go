@@ -0,0 +1,166 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/rrochlin/an-amazing-adventure/internal/combat"
+)
+
+// GridView is the client-facing combat grid for one room.
+type GridView struct {
+	RoomID string `json:"room_id"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	// Positions maps userID (players) or monsterID (monsters) → hex position.
+	Positions map[string]combat.GridPos `json:"positions"`
+}
+
+// RoomGridSize returns a room's combat grid size in hexes. Rooms generated
+// with explicit dimensions use them; older rooms (and legacy games without
+// DungeonData) get a size by room type.
+func RoomGridSize(room *DungeonRoomData) (width, height int) {
+	if room != nil && room.Width > 0 && room.Height > 0 {
+		return room.Width, room.Height
+	}
+	roomType := DungeonRoomTypeChamber
+	if room != nil {
+		roomType = room.Type
+	}
+	switch roomType {
+	case DungeonRoomTypeCorridor:
+		return 4, 10
+	case DungeonRoomTypeBoss:
+		return 14, 12
+	case DungeonRoomTypeEntrance, DungeonRoomTypeJunction, DungeonRoomTypeTreasure:
+		return 8, 8
+	default:
+		return 10, 10
+	}
+}
+
+// dungeonRoom returns the DungeonRoomData for roomID, or nil.
+func (g *Game) dungeonRoom(roomID string) *DungeonRoomData {
+	if g.DungeonData == nil {
+		return nil
+	}
+	return g.DungeonData.Rooms[roomID]
+}
+
+// RoomGrid builds the combat grid for roomID from persisted positions.
+// Players standing in the room and live monsters that have no position yet
+// are placed — players along the south edge, monsters along the north edge —
+// and the result is persisted. Stale entries (players who left, dead
+// monsters) are dropped.
+func (g *Game) RoomGrid(roomID string) *combat.Grid {
+	width, height := RoomGridSize(g.dungeonRoom(roomID))
+	grid := combat.NewGrid(roomID, width, height)
+
+	var playerIDs []string
+	for uid, c := range g.Players {
+		if c.LocationID == roomID {
+			playerIDs = append(playerIDs, uid)
+		}
+	}
+	sort.Strings(playerIDs)
+	var monsterIDs []string
+	for _, m := range g.GetRoomMonsters(roomID) {
+		if m != nil && m.HitPoints > 0 {
+			monsterIDs = append(monsterIDs, m.ID)
+		}
+	}
+
+	// Restore saved positions first so defaults never displace them.
+	saved := g.Positions[roomID]
+	var unplacedPlayers, unplacedMonsters []string
+	for _, uid := range playerIDs {
+		if pos, ok := saved[uid]; !ok || grid.PlacePlayer(uid, pos) != nil {
+			unplacedPlayers = append(unplacedPlayers, uid)
+		}
+	}
+	for _, id := range monsterIDs {
+		if pos, ok := saved[id]; !ok || grid.PlaceMonster(id, pos) != nil {
+			unplacedMonsters = append(unplacedMonsters, id)
+		}
+	}
+
+	for _, uid := range unplacedPlayers {
+		if pos, ok := grid.NearestFree(combat.GridPos{X: width / 2, Y: height - 1}); ok {
+			_ = grid.PlacePlayer(uid, pos)
+		}
+	}
+	for _, id := range unplacedMonsters {
+		if pos, ok := grid.NearestFree(combat.GridPos{X: width / 2, Y: 0}); ok {
+			_ = grid.PlaceMonster(id, pos)
+		}
+	}
+
+	g.SaveRoomGrid(grid)
+	return grid
+}
+
+// SaveRoomGrid persists every position on grid into g.Positions.
+func (g *Game) SaveRoomGrid(grid *combat.Grid) {
+	if g.Positions == nil {
+		g.Positions = make(map[string]map[string]combat.GridPos)
+	}
+	g.Positions[grid.RoomID] = grid.Positions()
+}
+
+// LeaveGrid removes a player's position in roomID (they left the room).
+func (g *Game) LeaveGrid(userID, roomID string) {
+	delete(g.Positions[roomID], userID)
+}
+
+// StepCharacter moves a player to another hex of their current room's grid.
+// During their turn in a fight the total distance moved is capped at
+// combat.PlayerSpeed; outside combat the party repositions freely.
+func (g *Game) StepCharacter(userID string, to combat.GridPos) error {
+	player, ok := g.GetPlayerCharacter(userID)
+	if !ok {
+		return fmt.Errorf("player %s not found in game", userID)
+	}
+	if player.LocationID == "" {
+		return fmt.Errorf("player has no location")
+	}
+	grid := g.RoomGrid(player.LocationID)
+	from, placed := grid.Position(userID)
+	if !placed {
+		return fmt.Errorf("there is no free space in the room")
+	}
+	if !grid.Contains(to) {
+		return fmt.Errorf("position %s is outside the room", to)
+	}
+	if grid.Occupied(to, userID) {
+		return fmt.Errorf("position %s is occupied", to)
+	}
+
+	dist := from.Distance(to)
+	inFight := g.CombatActive() && g.Turn.RoomID == player.LocationID && g.InCombatOrder(userID)
+	if inFight {
+		left := combat.PlayerSpeed - g.Turn.Moved
+		if dist > left {
+			return fmt.Errorf("that is %d ft away — you can move %d more ft this turn",
+				dist*combat.FeetPerHex, left*combat.FeetPerHex)
+		}
+	}
+	if err := grid.PlacePlayer(userID, to); err != nil {
+		return err
+	}
+	if inFight {
+		g.Turn.Moved += dist
+	}
+	g.SaveRoomGrid(grid)
+	return nil
+}
+
+// BuildGridView returns the persisted combat grid for roomID, or nil if
+// nobody has been placed there yet. It does not place anyone.
+func (g *Game) BuildGridView(roomID string) *GridView {
+	positions := g.Positions[roomID]
+	if len(positions) == 0 {
+		return nil
+	}
+	width, height := RoomGridSize(g.dungeonRoom(roomID))
+	return &GridView{RoomID: roomID, Width: width, Height: height, Positions: positions}
+}
@@ -0,0 +1,352 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/KirkDiggler/rpg-toolkit/core"
+	coreResources "github.com/KirkDiggler/rpg-toolkit/core/resources"
+	"github.com/KirkDiggler/rpg-toolkit/dice"
+	"github.com/KirkDiggler/rpg-toolkit/events"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/abilities"
+	dnd5echar "github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/character"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/classes"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/features"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/refs"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/resources"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/spells"
+
+	"github.com/rrochlin/an-amazing-adventure/internal/combat"
+)
+
+// LevelUpRequest holds a member's level-up choices. It is the payload of the
+// "level_up" sub_action and the body of POST /api/games/{uuid}/level-up.
+type LevelUpRequest struct {
+	// HPMethod is "roll" (roll the class hit die) or "average" (the default).
+	HPMethod string `json:"hp_method,omitempty"`
+	// AbilityIncreases is the Ability Score Improvement at ASI levels: +2 to
+	// one ability or +1 to two, keyed "str","dex","con","int","wis","cha".
+	AbilityIncreases map[string]int `json:"ability_increases,omitempty"`
+	// Spells are leveled spells added to a wizard's spellbook (2) or a
+	// warlock's known spells (1).
+	Spells []string `json:"spells,omitempty"`
+}
+
+// LevelUpResult describes what a level up granted.
+type LevelUpResult struct {
+	Level       int      `json:"level"`
+	HPGained    int      `json:"hp_gained"`
+	MaxHP       int      `json:"max_hp"`
+	NewFeatures []string `json:"new_features,omitempty"`
+}
+
+// levelFeatures are the toolkit features each class gains above level 1.
+// TODO(toolkit): classes.GetGrants only covers level 1, so the higher-level
+// grants the toolkit can build live here until it ships them.
+var levelFeatures = map[classes.Class]map[int][]*core.Ref{
+	classes.Fighter:   {2: {refs.Features.ActionSurge()}},
+	classes.Barbarian: {2: {refs.Features.RecklessAttack()}},
+	classes.Monk: {
+		2: {refs.Features.FlurryOfBlows(), refs.Features.PatientDefense(), refs.Features.StepOfTheWind()},
+		3: {refs.Features.DeflectMissiles()},
+	},
+}
+
+// AwardXP splits xp evenly between the party members standing in roomID (the
+// ones who took part in the fight) and returns each member's share.
+func (g *Game) AwardXP(roomID string, xp int) int {
+	var members []string
+	for uid, c := range g.Players {
+		if c.LocationID == roomID {
+			members = append(members, uid)
+		}
+	}
+	if xp <= 0 || len(members) == 0 {
+		return 0
+	}
+	share := xp / len(members)
+	for _, uid := range members {
+		c := g.Players[uid]
+		c.XP += share
+		g.Players[uid] = c
+	}
+	return share
+}
+
+// CanLevelUp reports whether userID has enough XP for their next level.
+func (g *Game) CanLevelUp(userID string) bool {
+	c, ok := g.GetPlayerCharacter(userID)
+	if !ok {
+		return false
+	}
+	dnd, ok := g.GetDnDCharacter(userID)
+	if !ok || dnd == nil || dnd.GetLevel() >= combat.MaxLevel {
+		return false
+	}
+	return combat.LevelForXP(c.XP) > dnd.GetLevel()
+}
+
+// LevelUp advances userID's character one level: hit points (rolled or
+// average), proficiency bonus, class features and resources, an Ability
+// Score Improvement at ASI levels, new spells, and a larger spell slot table.
+// The character is reloaded on g.Bus. roller is optional; nil uses the
+// crypto roller.
+func (g *Game) LevelUp(ctx context.Context, userID string, req LevelUpRequest, roller dice.Roller) (*LevelUpResult, error) {
+	dnd, ok := g.GetDnDCharacter(userID)
+	if !ok || dnd == nil {
+		return nil, fmt.Errorf("player %s has no D&D character", userID)
+	}
+	if !g.CanLevelUp(userID) {
+		c, _ := g.GetPlayerCharacter(userID)
+		return nil, fmt.Errorf("not enough experience — %d/%d XP for level %d",
+			c.XP, combat.XPForLevel(dnd.GetLevel()+1), dnd.GetLevel()+1)
+	}
+
+	data := dnd.ToData()
+	data.AbilityScores = maps.Clone(data.AbilityScores) // ToData shares the live map
+	class := data.ClassID
+	level := data.Level + 1
+	result := &LevelUpResult{Level: level}
+
+	newSpells, err := validateNewSpells(class, level, req.Spells, g.KnownSpells[userID])
+	if err != nil {
+		return nil, err
+	}
+
+	// Hit points: the class hit die (rolled or averaged) + CON, at least 1,
+	// plus the retroactive gain for earlier levels if CON went up.
+	hitDie := 8
+	if cd := classes.GetData(class); cd != nil {
+		hitDie = cd.HitDice
+	}
+	die := hitDie/2 + 1
+	switch req.HPMethod {
+	case "", "average":
+	case "roll":
+		if roller == nil {
+			roller = dice.NewRoller()
+		}
+		if die, err = roller.Roll(ctx, hitDie); err != nil {
+			return nil, fmt.Errorf("roll hit die: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("hp_method must be \"roll\" or \"average\", got %q", req.HPMethod)
+	}
+
+	// Ability Score Improvement — applied before hit points so a CON
+	// increase counts towards them.
+	oldConMod := abilityModifier(data.AbilityScores[abilities.CON])
+	if combat.IsASILevel(class, level) {
+		if err := applyAbilityIncreases(data, req.AbilityIncreases); err != nil {
+			return nil, err
+		}
+		result.NewFeatures = append(result.NewFeatures, "Ability Score Improvement")
+	} else if len(req.AbilityIncreases) > 0 {
+		return nil, fmt.Errorf("no ability score improvement at level %d", level)
+	}
+	conMod := abilityModifier(data.AbilityScores[abilities.CON])
+	result.HPGained = max(1, die+conMod) + (conMod-oldConMod)*data.Level
+	data.MaxHitPoints += result.HPGained
+	data.HitPoints += result.HPGained
+
+	data.Level = level
+	data.ProficiencyBonus = combat.ProficiencyBonus(level)
+	scaleResources(data)
+	if err := scaleFeatures(data); err != nil {
+		return nil, err
+	}
+	for _, ref := range levelFeatures[class][level] {
+		raw, err := newFeature(ref, data)
+		if err != nil {
+			return nil, err
+		}
+		data.Features = append(data.Features, raw)
+		result.NewFeatures = append(result.NewFeatures, featureName(raw))
+	}
+
+	bus := g.Bus
+	if bus == nil {
+		bus = events.NewEventBus()
+		g.Bus = bus
+	}
+	char, err := dnd5echar.LoadFromData(ctx, data, bus)
+	if err != nil {
+		return nil, fmt.Errorf("reload character: %w", err)
+	}
+	_ = dnd.Cleanup(ctx)
+	g.SetDnDCharacter(userID, char)
+	result.MaxHP = data.MaxHitPoints
+
+	g.growSpellSlots(userID, class, level)
+	if len(newSpells) > 0 {
+		g.SetKnownSpells(userID, append(slices.Clone(g.KnownSpells[userID]), newSpells...))
+	}
+	return result, nil
+}
+
+// growSpellSlots replaces userID's slot table with the one for their new
+// level, keeping slots already spent at levels that still exist.
+func (g *Game) growSpellSlots(userID string, class classes.Class, level int) {
+	slots := combat.MaxSpellSlots(class, level)
+	if slots == nil {
+		return
+	}
+	for lvl, s := range slots {
+		s.Used = min(g.SpellSlots[userID][lvl].Used, s.Max)
+		slots[lvl] = s
+	}
+	if g.SpellSlots == nil {
+		g.SpellSlots = make(map[string]map[int]dnd5echar.SpellSlotData)
+	}
+	g.SpellSlots[userID] = slots
+}
+
+// applyAbilityIncreases validates and applies an Ability Score Improvement.
+func applyAbilityIncreases(data *dnd5echar.Data, increases map[string]int) error {
+	total := 0
+	for key, n := range increases {
+		ab := abilities.Ability(key)
+		if _, ok := data.AbilityScores[ab]; !ok {
+			return fmt.Errorf("unknown ability %q", key)
+		}
+		if n < 1 || n > 2 {
+			return fmt.Errorf("ability increases must be +1 or +2, got %+d %s", n, key)
+		}
+		if data.AbilityScores[ab]+n > 20 {
+			return fmt.Errorf("%s cannot go above 20", strings.ToUpper(key))
+		}
+		total += n
+	}
+	if total != 2 {
+		return fmt.Errorf("ability score improvement: choose +2 to one ability or +1 to two")
+	}
+	for key, n := range increases {
+		data.AbilityScores[abilities.Ability(key)] += n
+	}
+	return nil
+}
+
+// validateNewSpells checks the spells learned on reaching level.
+func validateNewSpells(class classes.Class, level int, ids, known []string) ([]string, error) {
+	if len(ids) > combat.SpellsLearnedPerLevel(class) {
+		return nil, fmt.Errorf("a %s learns %d new spells per level", class, combat.SpellsLearnedPerLevel(class))
+	}
+	highest := combat.HighestSlotLevel(class, level)
+	for _, id := range ids {
+		sd := spells.GetData(spells.Spell(id))
+		if sd == nil {
+			return nil, fmt.Errorf("unknown spell %q", id)
+		}
+		if sd.Level < 1 || sd.Level > highest {
+			return nil, fmt.Errorf("%s is level %d — you can learn spells of level 1-%d", sd.Name, sd.Level, highest)
+		}
+		if def, ok := combat.Spells[id]; ok && !slices.Contains(def.Classes, class) {
+			return nil, fmt.Errorf("%s is not a %s spell", sd.Name, class)
+		}
+		if slices.Contains(known, id) {
+			return nil, fmt.Errorf("you already know %s", sd.Name)
+		}
+	}
+	return ids, nil
+}
+
+// scaleResources raises per-level resource pools to the character's level.
+func scaleResources(data *dnd5echar.Data) {
+	grow := func(key coreResources.ResourceKey, maximum int) {
+		r, ok := data.Resources[key]
+		if !ok {
+			return
+		}
+		r.Current += maximum - r.Maximum
+		r.Maximum = maximum
+		data.Resources[key] = r
+	}
+	grow(resources.HitDice, data.Level)
+	grow(resources.RageCharges, combat.RageCharges(data.Level))
+	grow(resources.Ki, data.Level)
+}
+
+// scaleFeatures rebuilds the level-dependent features and conditions the
+// character already has with the new class level.
+func scaleFeatures(data *dnd5echar.Data) error {
+	for i, raw := range data.Features {
+		var peek struct {
+			Ref core.Ref `json:"ref"`
+		}
+		if err := json.Unmarshal(raw, &peek); err != nil {
+			continue
+		}
+		switch peek.Ref.ID {
+		case refs.Features.Rage().ID, refs.Features.SecondWind().ID, refs.Features.DeflectMissiles().ID:
+			rebuilt, err := newFeature(&peek.Ref, data)
+			if err != nil {
+				return err
+			}
+			data.Features[i] = rebuilt
+		}
+	}
+	for i, raw := range data.Conditions {
+		var cond map[string]any
+		if err := json.Unmarshal(raw, &cond); err != nil {
+			continue
+		}
+		if _, ok := cond["monk_level"]; !ok {
+			continue
+		}
+		cond["monk_level"] = data.Level
+		rebuilt, err := json.Marshal(cond)
+		if err != nil {
+			return fmt.Errorf("scale condition: %w", err)
+		}
+		data.Conditions[i] = rebuilt
+	}
+	return nil
+}
+
+// newFeature builds a toolkit feature configured for the character's level.
+func newFeature(ref *core.Ref, data *dnd5echar.Data) (json.RawMessage, error) {
+	var config map[string]int
+	switch ref.ID {
+	case refs.Features.Rage().ID, refs.Features.SecondWind().ID:
+		config = map[string]int{"level": data.Level}
+	case refs.Features.DeflectMissiles().ID:
+		config = map[string]int{
+			"monk_level":   data.Level,
+			"dex_modifier": abilityModifier(data.AbilityScores[abilities.DEX]),
+		}
+	}
+	cfg, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	out, err := features.CreateFromRef(&features.CreateFromRefInput{
+		Ref:         ref.String(),
+		Config:      cfg,
+		CharacterID: data.ID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create %s: %w", ref.ID, err)
+	}
+	return out.Feature.ToJSON()
+}
+
+// featureName returns the display name stored in a feature's JSON.
+func featureName(raw json.RawMessage) string {
+	var f struct {
+		Name string `json:"name"`
+	}
+	_ = json.Unmarshal(raw, &f)
+	return f.Name
+}
+
+// abilityModifier is the 5e modifier for an ability score.
+func abilityModifier(score int) int {
+	if score < 10 {
+		return (score - 11) / 2
+	}
+	return (score - 10) / 2
+}
@@ -0,0 +1,20 @@
+package game
+
+const (
+	// OOCWindow is how many out-of-character messages a session keeps.
+	OOCWindow = 50
+	// MaxOOCLength caps one out-of-character message, in characters.
+	MaxOOCLength = 1000
+)
+
+// OOCMessage is one line of out-of-character party chat: talk between the
+// players that the narrator never sees and that costs no tokens.
+type OOCMessage struct {
+	UserID  string `json:"user_id" dynamodbav:"user_id"`
+	Name    string `json:"name" dynamodbav:"name"` // the sender's character name when sent
+	Content string `json:"content" dynamodbav:"content"`
+	SentAt  int64  `json:"sent_at" dynamodbav:"sent_at"` // unix seconds
+	// Seq is the message's position in the session log, increasing with
+	// every message sent.
+	Seq int `json:"seq" dynamodbav:"-"`
+}
@@ -0,0 +1,207 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RoundTimeout is how long an action round collects intents after the first
+// one. Past it the server resolves the round without the members still
+// missing on the next frame from anyone (chat, ooc, or game action).
+const RoundTimeout = 2 * time.Minute
+
+// Intent is one member's declared action in an action round.
+type Intent struct {
+	UserID  string `json:"user_id" dynamodbav:"user_id"`
+	Content string `json:"content" dynamodbav:"content"`
+}
+
+// ActionRound collects the party's intents while round mode is on. The
+// narrator resolves them together in one turn once every member who can act
+// has declared one, or once the round has timed out.
+type ActionRound struct {
+	Intents []Intent `json:"intents" dynamodbav:"intents"`
+	// StartedAt is when the first intent came in (unix milliseconds).
+	StartedAt int64 `json:"started_at" dynamodbav:"started_at"`
+}
+
+// Deadline returns when the round times out.
+func (r ActionRound) Deadline() time.Time {
+	return time.UnixMilli(r.StartedAt).Add(RoundTimeout)
+}
+
+// ActionRoundView is the client-facing state of round mode: the pending
+// intents, in party order, and who the round is still waiting on. It is the
+// payload of the round_updated WebSocket frame and GameStateView.ActionRound.
+type ActionRoundView struct {
+	Intents   []IntentView `json:"intents"`
+	WaitingOn []string     `json:"waiting_on"`         // names of members yet to declare
+	Deadline  int64        `json:"deadline,omitempty"` // unix ms when the server resolves the round anyway; 0 before the first intent
+	// TimedOut is set on the frame sent when the round ran out of time and
+	// is being resolved without WaitingOn.
+	TimedOut bool `json:"timed_out,omitempty"`
+}
+
+// IntentView is a pending intent with its author's character name.
+type IntentView struct {
+	UserID  string `json:"user_id"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+var (
+	// ErrNotRoundMode is returned by round operations while round mode is off.
+	ErrNotRoundMode = errors.New("round mode is off")
+	// ErrNoRound is returned by TakeRound when no intents are pending.
+	ErrNoRound = errors.New("no intents are pending")
+)
+
+// SetRoundMode turns round mode on or off. Only the owner may change it
+// (FromSaveState fills OwnerID from UserID for saves that predate it).
+// Turning it off drops any pending intents.
+func (g *Game) SetRoundMode(userID string, on bool) error {
+	if userID != g.OwnerID {
+		return fmt.Errorf("only the session owner can change round mode")
+	}
+	g.RoundMode = on
+	if !on {
+		g.ActionRound = nil
+	}
+	return nil
+}
+
+// roundMembers returns the members an action round waits for, in party
+// order: those with a character who are conscious.
+func (g *Game) roundMembers() []string {
+	var ids []string
+	for _, uid := range g.PartyOrder() {
+		if g.CheckConscious(uid) == nil {
+			ids = append(ids, uid)
+		}
+	}
+	return ids
+}
+
+// SubmitIntent records userID's intent for the pending action round,
+// starting one if none is pending. Submitting again replaces the member's
+// earlier intent.
+func (g *Game) SubmitIntent(userID, content string, now time.Time) error {
+	if !g.RoundMode {
+		return ErrNotRoundMode
+	}
+	if _, ok := g.GetPlayerCharacter(userID); !ok {
+		return fmt.Errorf("you have no character in this party")
+	}
+	if err := g.CheckConscious(userID); err != nil {
+		return err
+	}
+	if g.ActionRound == nil {
+		g.ActionRound = &ActionRound{StartedAt: now.UnixMilli()}
+	}
+	for i, in := range g.ActionRound.Intents {
+		if in.UserID == userID {
+			g.ActionRound.Intents[i].Content = content
+			return nil
+		}
+	}
+	g.ActionRound.Intents = append(g.ActionRound.Intents, Intent{UserID: userID, Content: content})
+	return nil
+}
+
+// roundWaitingOn returns the members who can act but have not declared an
+// intent yet, in party order.
+func (g *Game) roundWaitingOn() []string {
+	declared := make(map[string]bool)
+	if g.ActionRound != nil {
+		for _, in := range g.ActionRound.Intents {
+			declared[in.UserID] = true
+		}
+	}
+	var waiting []string
+	for _, uid := range g.roundMembers() {
+		if !declared[uid] {
+			waiting = append(waiting, uid)
+		}
+	}
+	return waiting
+}
+
+// RoundReady reports whether the pending action round can be resolved:
+// everyone who can act has declared an intent, or the round has timed out.
+func (g *Game) RoundReady(now time.Time) bool {
+	if g.ActionRound == nil || len(g.ActionRound.Intents) == 0 {
+		return false
+	}
+	return len(g.roundWaitingOn()) == 0 || now.After(g.ActionRound.Deadline())
+}
+
+// RoundExpired reports whether round mode is on and the pending action
+// round has run past its deadline, so the next frame should resolve it.
+func (g *Game) RoundExpired(now time.Time) bool {
+	return g.RoundMode && g.ActionRound != nil && len(g.ActionRound.Intents) > 0 && now.After(g.ActionRound.Deadline())
+}
+
+// TakeRound ends the pending action round and returns its intents in party
+// order, for the narrator to resolve.
+func (g *Game) TakeRound() ([]Intent, error) {
+	if !g.RoundMode {
+		return nil, ErrNotRoundMode
+	}
+	if g.ActionRound == nil || len(g.ActionRound.Intents) == 0 {
+		return nil, ErrNoRound
+	}
+	intents := g.roundIntents()
+	g.ActionRound = nil
+	return intents, nil
+}
+
+// roundIntents returns the pending intents in party order.
+func (g *Game) roundIntents() []Intent {
+	if g.ActionRound == nil {
+		return nil
+	}
+	byUser := make(map[string]Intent, len(g.ActionRound.Intents))
+	for _, in := range g.ActionRound.Intents {
+		byUser[in.UserID] = in
+	}
+	intents := make([]Intent, 0, len(byUser))
+	for _, uid := range g.PartyOrder() {
+		if in, ok := byUser[uid]; ok {
+			intents = append(intents, in)
+		}
+	}
+	return intents
+}
+
+// RoundInput returns intents as one narrator input, a tagged line per member
+// as SpeakerInput writes them.
+func (g *Game) RoundInput(intents []Intent) string {
+	lines := make([]string, 0, len(intents))
+	for _, in := range intents {
+		lines = append(lines, g.SpeakerInput(in.UserID, in.Content, false))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ActionRoundView returns the state of round mode for clients, or nil while
+// round mode is off.
+func (g *Game) ActionRoundView() *ActionRoundView {
+	if !g.RoundMode {
+		return nil
+	}
+	view := &ActionRoundView{Intents: []IntentView{}, WaitingOn: []string{}}
+	if g.ActionRound != nil {
+		view.Deadline = g.ActionRound.Deadline().UnixMilli()
+	}
+	for _, in := range g.roundIntents() {
+		c, _ := g.GetPlayerCharacter(in.UserID)
+		view.Intents = append(view.Intents, IntentView{UserID: in.UserID, Name: c.Name, Content: in.Content})
+	}
+	for _, uid := range g.roundWaitingOn() {
+		c, _ := g.GetPlayerCharacter(uid)
+		view.WaitingOn = append(view.WaitingOn, c.Name)
+	}
+	return view
+}
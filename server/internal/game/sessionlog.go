@@ -0,0 +1,93 @@
+package game
+
+// LogRange is a run of consecutive entries [Start, End) of one kind in a
+// session's append-only log (the session-log table). Chat history and
+// narrative are stored there as of SchemaVersion 5; the session item only
+// keeps the ranges that make up each history, in order.
+type LogRange struct {
+	Start int `json:"start" dynamodbav:"start"`
+	End   int `json:"end" dynamodbav:"end"`
+}
+
+const (
+	// ChatWindow is the most chat messages a session keeps in ChatHistory.
+	// Past it PageChat moves the older ones out to OlderChat.
+	ChatWindow = 40
+	// ChatPageSize is how many recent messages PageChat leaves in
+	// ChatHistory, and the default page of the chat-history route.
+	ChatPageSize = 20
+)
+
+// AppendRange returns ranges with seq added at the end, extending the last
+// range when seq directly follows it.
+func AppendRange(ranges []LogRange, seq int) []LogRange {
+	if n := len(ranges); n > 0 && ranges[n-1].End == seq {
+		ranges[n-1].End++
+		return ranges
+	}
+	return append(ranges, LogRange{Start: seq, End: seq + 1})
+}
+
+// RangeLen returns how many entries ranges cover.
+func RangeLen(ranges []LogRange) int {
+	n := 0
+	for _, r := range ranges {
+		n += r.End - r.Start
+	}
+	return n
+}
+
+// SliceRanges returns the entries of ranges at positions [from, to), counting
+// from the first entry of the first range.
+func SliceRanges(ranges []LogRange, from, to int) []LogRange {
+	var out []LogRange
+	pos := 0
+	for _, r := range ranges {
+		n := r.End - r.Start
+		lo, hi := max(from-pos, 0), min(to-pos, n)
+		if lo < hi {
+			out = append(out, LogRange{Start: r.Start + lo, End: r.Start + hi})
+		}
+		pos += n
+		if pos >= to {
+			break
+		}
+	}
+	return out
+}
+
+// PageChat keeps ChatHistory bounded: once history holds more than
+// ChatWindow messages, the oldest ones already in the log move to
+// g.OlderChat (whispers counted in g.OlderWhispers) until ChatPageSize
+// remain. It returns what stays in memory.
+// Paging only happens every few turns, so most narrator-turn events still
+// just append to the chat log.
+func (g *Game) PageChat(history []ChatMessage) []ChatMessage {
+	if len(history) <= ChatWindow {
+		return history
+	}
+	n := 0
+	for n < len(history)-ChatPageSize && history[n].LogSeq != 0 {
+		g.OlderChat = AppendRange(g.OlderChat, history[n].LogSeq)
+		if to := history[n].PrivateTo; to != "" {
+			if g.OlderWhispers == nil {
+				g.OlderWhispers = make(map[string]int)
+			}
+			g.OlderWhispers[to]++
+		}
+		n++
+	}
+	return history[n:]
+}
+
+// OlderChatVisibleTo returns how many of the messages in g.OlderChat userID
+// may see: all of them but other members' whispers.
+func (g *Game) OlderChatVisibleTo(userID string) int {
+	n := RangeLen(g.OlderChat)
+	for uid, whispers := range g.OlderWhispers {
+		if uid != userID {
+			n -= whispers
+		}
+	}
+	return n
+}
@@ -0,0 +1,66 @@
+package game
+
+import (
+	dnd5echar "github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/character"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/classes"
+
+	"github.com/rrochlin/an-amazing-adventure/internal/combat"
+)
+
+// PlayerSpellSlots returns userID's spell slot table, filling it from the
+// class table the first time (new characters, or characters saved before
+// slots were tracked). Returns nil for non-casters. The returned map is live:
+// spending a slot in it is persisted on the next save.
+func (g *Game) PlayerSpellSlots(userID string) map[int]dnd5echar.SpellSlotData {
+	if slots, ok := g.SpellSlots[userID]; ok && len(slots) > 0 {
+		return slots
+	}
+	dnd, ok := g.GetDnDCharacter(userID)
+	if !ok || dnd == nil {
+		return nil
+	}
+	slots := combat.MaxSpellSlots(dnd.ToData().ClassID, dnd.GetLevel())
+	if slots == nil {
+		return nil
+	}
+	if g.SpellSlots == nil {
+		g.SpellSlots = make(map[string]map[int]dnd5echar.SpellSlotData)
+	}
+	g.SpellSlots[userID] = slots
+	return slots
+}
+
+// SetKnownSpells records the cantrips and spells a member chose at creation.
+func (g *Game) SetKnownSpells(userID string, spellIDs []string) {
+	if len(spellIDs) == 0 {
+		delete(g.KnownSpells, userID)
+		return
+	}
+	if g.KnownSpells == nil {
+		g.KnownSpells = make(map[string][]string)
+	}
+	g.KnownSpells[userID] = spellIDs
+}
+
+// RecoverSpellSlots restores spent slots for every caster after a rest. A
+// long rest restores all slots; a short rest only warlock Pact Magic slots.
+func (g *Game) RecoverSpellSlots(longRest bool) {
+	for uid, dnd := range g.DnDPlayers {
+		if dnd == nil {
+			continue
+		}
+		if slots := g.PlayerSpellSlots(uid); slots != nil {
+			combat.RecoverSlots(dnd.ToData().ClassID, slots, longRest)
+		}
+	}
+}
+
+// spellState returns the slot table and known spells shown in DnDStatsView.
+// Unlike PlayerSpellSlots it never writes to the game.
+func (g *Game) spellState(userID string, class classes.Class, level int) (map[int]dnd5echar.SpellSlotData, []string) {
+	slots, ok := g.SpellSlots[userID]
+	if !ok || len(slots) == 0 {
+		slots = combat.MaxSpellSlots(class, level)
+	}
+	return slots, g.KnownSpells[userID]
+}
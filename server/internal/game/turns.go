@@ -0,0 +1,213 @@
+package game
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rrochlin/an-amazing-adventure/internal/combat"
+)
+
+// TurnView is the client-facing snapshot of the initiative order. It is the
+// payload of the turn_changed WebSocket frame and GameStateView.Turn.
+type TurnView struct {
+	Active        bool                     `json:"active"` // false once the fight is over
+	RoomID        string                   `json:"room_id,omitempty"`
+	Round         int                      `json:"round,omitempty"`
+	CombatantID   string                   `json:"combatant_id,omitempty"` // userID for players, monsterID for monsters
+	CombatantName string                   `json:"combatant_name,omitempty"`
+	IsPlayer      bool                     `json:"is_player,omitempty"`
+	Deadline      int64                    `json:"deadline,omitempty"` // unix ms when the server skips the turn
+	Order         []combat.InitiativeEntry `json:"order,omitempty"`
+}
+
+// CombatActive reports whether an initiative order with a turn pointer is in play.
+func (g *Game) CombatActive() bool {
+	return g.Turn != nil && len(g.InitiativeOrder) > 0
+}
+
+// StartCombat installs a freshly rolled initiative order for roomID. The
+// combatant identified by firstID (the one who started the fight) acts
+// first; the round then continues from their slot. If firstID is not in the
+// order, the highest roll goes first.
+func (g *Game) StartCombat(roomID string, order []combat.InitiativeEntry, firstID string, now time.Time) {
+	idx := 0
+	for i, e := range order {
+		if e.CombatantID == firstID {
+			idx = i
+			break
+		}
+	}
+	g.InitiativeOrder = order
+	g.Turn = &combat.TurnState{RoomID: roomID, Index: idx, Round: 1, StartedAt: now.UnixMilli()}
+}
+
+// JoinCombat gives a player who arrives at a fight already under way a slot
+// in the initiative order, ranked by their roll the way RollInitiative ranks
+// (ties go to the player). The turn pointer keeps pointing at the same
+// combatant. It reports false if no fight is on, the player is not standing
+// in its room, or they already have a slot.
+func (g *Game) JoinCombat(entry combat.InitiativeEntry) bool {
+	if !g.CombatActive() || g.InCombatOrder(entry.CombatantID) {
+		return false
+	}
+	c, ok := g.GetPlayerCharacter(entry.CombatantID)
+	if !ok || c.LocationID != g.Turn.RoomID {
+		return false
+	}
+	entry.IsPlayer = true
+	at := len(g.InitiativeOrder)
+	for i, e := range g.InitiativeOrder {
+		if entry.Roll > e.Roll || (entry.Roll == e.Roll && !e.IsPlayer) {
+			at = i
+			break
+		}
+	}
+	g.InitiativeOrder = append(g.InitiativeOrder[:at], append([]combat.InitiativeEntry{entry}, g.InitiativeOrder[at:]...)...)
+	if at <= g.Turn.Index {
+		g.Turn.Index++
+	}
+	return true
+}
+
+// EndCombat clears the initiative order and turn pointer.
+func (g *Game) EndCombat() {
+	g.InitiativeOrder = nil
+	g.Turn = nil
+}
+
+// CurrentTurn returns the initiative entry whose turn it is.
+func (g *Game) CurrentTurn() (combat.InitiativeEntry, bool) {
+	if !g.CombatActive() || g.Turn.Index < 0 || g.Turn.Index >= len(g.InitiativeOrder) {
+		return combat.InitiativeEntry{}, false
+	}
+	return g.InitiativeOrder[g.Turn.Index], true
+}
+
+// InCombatOrder reports whether userID has a slot in the active initiative order.
+func (g *Game) InCombatOrder(userID string) bool {
+	if !g.CombatActive() {
+		return false
+	}
+	for _, e := range g.InitiativeOrder {
+		if e.IsPlayer && e.CombatantID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckTurn returns an error if userID is part of the active fight and it is
+// not their turn. Players outside the initiative order act freely; those who
+// walk into the fight's room are given a slot first (see JoinCombat).
+func (g *Game) CheckTurn(userID string) error {
+	if !g.InCombatOrder(userID) {
+		return nil
+	}
+	cur, ok := g.CurrentTurn()
+	if !ok || cur.CombatantID == userID {
+		return nil
+	}
+	return fmt.Errorf("it's not your turn — waiting for %s", cur.CombatantName)
+}
+
+// TurnExpired reports whether the current turn belongs to a player and has
+// run past combat.TurnTimeout.
+func (g *Game) TurnExpired(now time.Time) bool {
+	cur, ok := g.CurrentTurn()
+	if !ok || !cur.IsPlayer {
+		return false
+	}
+	return now.After(g.Turn.Deadline())
+}
+
+// AdvanceTurn moves the pointer to the next combatant able to act and
+// returns them. Players can act while they are in the fight's room with HP
+// left or dying (their turn is a death save); monsters while they are alive. The fight ends (and false is
+// returned) when the room has no live monsters or no player left to fight.
+func (g *Game) AdvanceTurn(now time.Time) (combat.InitiativeEntry, bool) {
+	if !g.CombatActive() {
+		return combat.InitiativeEntry{}, false
+	}
+	if !g.HasLiveMonstersInRoom(g.Turn.RoomID) || !g.anyPlayerCanAct() {
+		g.EndCombat()
+		return combat.InitiativeEntry{}, false
+	}
+	next, wrapped := combat.NextTurn(g.InitiativeOrder, g.Turn.Index, g.canAct)
+	if next < 0 {
+		g.EndCombat()
+		return combat.InitiativeEntry{}, false
+	}
+	if wrapped {
+		g.Turn.Round++
+	}
+	g.Turn.Index = next
+	g.Turn.StartedAt = now.UnixMilli()
+	g.Turn.Moved = 0
+	return g.InitiativeOrder[next], true
+}
+
+// anyPlayerCanAct reports whether at least one player in the order can still act.
+func (g *Game) anyPlayerCanAct() bool {
+	for _, e := range g.InitiativeOrder {
+		if e.IsPlayer && g.canAct(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// canAct reports whether a combatant can take a turn in the current fight.
+func (g *Game) canAct(e combat.InitiativeEntry) bool {
+	if g.Turn == nil {
+		return false
+	}
+	if !e.IsPlayer {
+		for _, m := range g.GetRoomMonsters(g.Turn.RoomID) {
+			if m != nil && m.ID == e.CombatantID {
+				return m.HitPoints > 0
+			}
+		}
+		return false
+	}
+	c, ok := g.GetPlayerCharacter(e.CombatantID)
+	if !ok || c.LocationID != g.Turn.RoomID {
+		return false
+	}
+	if dnd, ok := g.GetDnDCharacter(e.CombatantID); ok && dnd != nil {
+		vital := combat.Vital(dnd)
+		return vital == combat.VitalConscious || vital == combat.VitalDying
+	}
+	return true
+}
+
+// TurnView returns the client-facing turn snapshot, or nil outside of combat.
+func (g *Game) TurnView() *TurnView {
+	cur, ok := g.CurrentTurn()
+	if !ok {
+		return nil
+	}
+	return &TurnView{
+		Active:        true,
+		RoomID:        g.Turn.RoomID,
+		Round:         g.Turn.Round,
+		CombatantID:   cur.CombatantID,
+		CombatantName: cur.CombatantName,
+		IsPlayer:      cur.IsPlayer,
+		Deadline:      g.Turn.Deadline().UnixMilli(),
+		Order:         g.InitiativeOrder,
+	}
+}
+
+// AppendCombatContext adds a block of mechanical results to
+// PendingCombatContext so every action taken between two narrator turns is
+// narrated, not only the last one.
+func (g *Game) AppendCombatContext(log string) {
+	if log == "" {
+		return
+	}
+	if g.PendingCombatContext == "" {
+		g.PendingCombatContext = log
+		return
+	}
+	g.PendingCombatContext += "\n" + log
+}
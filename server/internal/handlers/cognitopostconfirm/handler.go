@@ -0,0 +1,96 @@
+// Package cognitopostconfirm is a Cognito Post Confirmation trigger Lambda.
+// It fires after a user confirms their email address, creating a default
+// restricted UserRecord in the users table. If clientMetadata contains an
+// inviteCode the invite is redeemed and a membership record is written.
+package cognitopostconfirm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rrochlin/an-amazing-adventure/internal/db"
+)
+
+// Handler is the Lambda entry point. It serves each invocation from DynamoDB.
+func Handler(ctx context.Context, event events.CognitoEventUserPoolsPostConfirmation) (events.CognitoEventUserPoolsPostConfirmation, error) {
+	store, err := db.New(ctx)
+	if err != nil {
+		log.Printf("cognito-post-confirm: db init error: %v", err)
+		return event, nil // non-fatal
+	}
+	return handle(ctx, store, event)
+}
+
+// New returns a handler backed by store, so tests and cmd/local-server can run
+// it against db.NewMemory.
+func New(store db.Store) func(context.Context, events.CognitoEventUserPoolsPostConfirmation) (events.CognitoEventUserPoolsPostConfirmation, error) {
+	return func(ctx context.Context, event events.CognitoEventUserPoolsPostConfirmation) (events.CognitoEventUserPoolsPostConfirmation, error) {
+		return handle(ctx, store, event)
+	}
+}
+
+func handle(ctx context.Context, store db.Store, event events.CognitoEventUserPoolsPostConfirmation) (
+	events.CognitoEventUserPoolsPostConfirmation, error,
+) {
+	userID := event.Request.UserAttributes["sub"]
+	if userID == "" {
+		log.Printf("cognito-post-confirm: missing sub in user attributes — skipping")
+		return event, nil // non-fatal — don't block signup
+	}
+
+	now := time.Now().UnixMilli()
+	record := db.UserRecord{
+		UserID:      db.BinaryID(userID),
+		Role:        "restricted",
+		AIEnabled:   false,
+		TokenLimit:  0,
+		TokensUsed:  0,
+		GamesLimit:  1,
+		BillingMode: "admin_granted",
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := store.PutUser(ctx, record); err != nil {
+		// Non-fatal: user can still log in; GetUser returns nil and the system
+		// treats missing records as restricted.
+		log.Printf("cognito-post-confirm: PutUser error (non-fatal): %v", err)
+	}
+
+	// Redeem invite code if the client passed one in signUp clientMetadata
+	if code, ok := event.Request.ClientMetadata["inviteCode"]; ok && code != "" {
+		if err := redeemInvite(ctx, store, userID, code); err != nil {
+			log.Printf("cognito-post-confirm: redeemInvite(%s) error (non-fatal): %v", code, err)
+		}
+	}
+
+	log.Printf("cognito-post-confirm: created restricted user record for %s", userID)
+	return event, nil
+}
+
+func redeemInvite(ctx context.Context, store db.Store, userID, code string) error {
+	invite, err := store.GetInvite(ctx, code)
+	if err != nil {
+		return fmt.Errorf("GetInvite: %w", err)
+	}
+	if invite == nil {
+		return fmt.Errorf("invite %s not found or expired", code)
+	}
+	if invite.MaxUses > 0 && invite.Uses >= invite.MaxUses {
+		return fmt.Errorf("invite %s is full (%d/%d uses)", code, invite.Uses, invite.MaxUses)
+	}
+
+	if err := store.PutMembership(ctx, db.MembershipRecord{
+		UserID:    db.BinaryID(userID),
+		SessionID: invite.SessionID,
+		Role:      "member",
+		JoinedAt:  time.Now().UnixMilli(),
+	}); err != nil {
+		return fmt.Errorf("PutMembership: %w", err)
+	}
+
+	return store.IncrementInviteUses(ctx, code)
+}
@@ -0,0 +1,310 @@
+// Package httpadmin handles admin management API routes:
+//
+//	GET  /api/admin/users           — list all users with Cognito email enrichment
+//	PUT  /api/admin/users/{userId}  — update role, AI access, limits, notes
+//	GET  /api/admin/stats           — aggregate user and token stats
+//	DELETE /api/admin/sessions/{sessionId}/lease — force-release a stuck streaming lease
+//
+// Auth is enforced at two layers:
+//  1. API Gateway JWT authorizer — requires valid Cognito token
+//  2. Lambda-level admin group check — requires cognito:groups to contain "admin"
+package httpadmin
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	cognitoidp "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/rrochlin/an-amazing-adventure/internal/ai"
+	"github.com/rrochlin/an-amazing-adventure/internal/db"
+)
+
+// Handler is the Lambda entry point. It serves each invocation from DynamoDB.
+func Handler(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	store, err := db.New(ctx)
+	if err != nil {
+		log.Printf("http-admin: db init: %v", err)
+		return serverError(), nil
+	}
+	return handle(ctx, store, req)
+}
+
+// New returns a handler backed by store, so tests and cmd/local-server can run
+// it against db.NewMemory.
+func New(store db.Store) func(context.Context, events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	return func(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+		return handle(ctx, store, req)
+	}
+}
+
+func handle(ctx context.Context, store db.Store, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	// Enforce admin group membership — defense in depth beyond API Gateway authorizer
+	if !hasGroup(req.RequestContext.Authorizer.JWT.Claims, "admin") {
+		return jsonResponse(403, map[string]string{"error": "forbidden"}), nil
+	}
+
+	method := req.RequestContext.HTTP.Method
+	path := req.RequestContext.HTTP.Path
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Printf("http-admin: aws config: %v", err)
+		return serverError(), nil
+	}
+	cognitoClient := cognitoidp.NewFromConfig(cfg)
+	userPoolID := os.Getenv("USER_POOL_ID")
+
+	switch {
+	case method == "GET" && path == "/api/admin/users":
+		return handleListUsers(ctx, store, cognitoClient, userPoolID)
+	case method == "PUT" && strings.HasPrefix(path, "/api/admin/users/"):
+		userID := req.PathParameters["userId"]
+		return handleUpdateUser(ctx, req, store, cognitoClient, userPoolID, userID)
+	case method == "GET" && path == "/api/admin/stats":
+		return handleStats(ctx, store)
+	case method == "DELETE" && strings.HasPrefix(path, "/api/admin/sessions/") && strings.HasSuffix(path, "/lease"):
+		return handleReleaseLease(ctx, store, req.PathParameters["sessionId"])
+	default:
+		return jsonResponse(404, map[string]string{"error": "not_found"}), nil
+	}
+}
+
+// AdminUserView is the JSON shape returned to the admin panel per user.
+type AdminUserView struct {
+	UserID      string `json:"user_id"`
+	Email       string `json:"email"`
+	Role        string `json:"role"`
+	AIEnabled   bool   `json:"ai_enabled"`
+	TokenLimit  int    `json:"token_limit"`
+	TokensUsed  int    `json:"tokens_used"`
+	GamesLimit  int    `json:"games_limit"`
+	BillingMode string `json:"billing_mode"`
+	AIProvider  string `json:"ai_provider,omitempty"`
+	Notes       string `json:"notes,omitempty"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+func handleListUsers(
+	ctx context.Context,
+	store db.Store,
+	cognitoClient *cognitoidp.Client,
+	userPoolID string,
+) (events.APIGatewayV2HTTPResponse, error) {
+	records, err := store.ListUsers(ctx)
+	if err != nil {
+		log.Printf("http-admin ListUsers: %v", err)
+		return serverError(), nil
+	}
+
+	views := make([]AdminUserView, 0, len(records))
+	for _, r := range records {
+		email := fetchEmail(ctx, cognitoClient, userPoolID, string(r.UserID))
+		views = append(views, AdminUserView{
+			UserID:      string(r.UserID),
+			Email:       email,
+			Role:        r.Role,
+			AIEnabled:   r.AIEnabled,
+			TokenLimit:  r.TokenLimit,
+			TokensUsed:  r.TokensUsed,
+			GamesLimit:  r.GamesLimit,
+			BillingMode: r.BillingMode,
+			AIProvider:  r.AIProvider,
+			Notes:       r.Notes,
+			CreatedAt:   r.CreatedAt,
+		})
+	}
+	return jsonResponse(200, views), nil
+}
+
+type updateUserRequest struct {
+	Role       string  `json:"role"` // "admin" | "user" | "restricted"
+	AIEnabled  bool    `json:"ai_enabled"`
+	TokenLimit int     `json:"token_limit"` // 0 = unlimited
+	GamesLimit int     `json:"games_limit"` // 0 = unlimited
+	AIProvider *string `json:"ai_provider"` // "" = deployment default; absent keeps the stored provider
+	Notes      string  `json:"notes"`
+}
+
+func handleUpdateUser(
+	ctx context.Context,
+	req events.APIGatewayV2HTTPRequest,
+	store db.Store,
+	cognitoClient *cognitoidp.Client,
+	userPoolID string,
+	userID string,
+) (events.APIGatewayV2HTTPResponse, error) {
+	if userID == "" {
+		return jsonResponse(400, map[string]string{"error": "missing userId"}), nil
+	}
+
+	var body updateUserRequest
+	if err := json.Unmarshal([]byte(req.Body), &body); err != nil {
+		return jsonResponse(400, map[string]string{"error": "invalid_body"}), nil
+	}
+	if body.AIProvider != nil && !ai.KnownProvider(*body.AIProvider) {
+		return jsonResponse(400, map[string]string{"error": "unknown_ai_provider"}), nil
+	}
+
+	existing, err := store.GetUser(ctx, userID)
+	if err != nil || existing == nil {
+		return jsonResponse(404, map[string]string{"error": "user_not_found"}), nil
+	}
+
+	existing.Role = body.Role
+	existing.AIEnabled = body.AIEnabled
+	existing.TokenLimit = body.TokenLimit
+	existing.GamesLimit = body.GamesLimit
+	if body.AIProvider != nil {
+		existing.AIProvider = *body.AIProvider
+	}
+	existing.Notes = body.Notes
+
+	if err := store.UpdateUser(ctx, userID, *existing); err != nil {
+		log.Printf("http-admin UpdateUser %s: %v", userID, err)
+		return serverError(), nil
+	}
+
+	// Sync Cognito group membership to match the new role
+	if err := syncCognitoGroups(ctx, cognitoClient, userPoolID, userID, body.Role); err != nil {
+		// Non-fatal: DB is source of truth; Cognito groups are informational
+		log.Printf("http-admin syncCognitoGroups %s (non-fatal): %v", userID, err)
+	}
+
+	return jsonResponse(200, map[string]string{"status": "ok"}), nil
+}
+
+// syncCognitoGroups ensures the user is in the correct Cognito group for their role:
+//
+//	admin      → [admin, user]
+//	user       → [user]
+//	restricted → [restricted]
+func syncCognitoGroups(
+	ctx context.Context,
+	cognitoClient *cognitoidp.Client,
+	userPoolID, userID, role string,
+) error {
+	allGroups := []string{"admin", "user", "restricted"}
+	targetGroups := map[string]bool{}
+	switch role {
+	case "admin":
+		targetGroups["admin"] = true
+		targetGroups["user"] = true
+	case "user":
+		targetGroups["user"] = true
+	default:
+		targetGroups["restricted"] = true
+	}
+
+	for _, g := range allGroups {
+		g := g
+		if targetGroups[g] {
+			if _, err := cognitoClient.AdminAddUserToGroup(ctx, &cognitoidp.AdminAddUserToGroupInput{
+				UserPoolId: aws.String(userPoolID),
+				Username:   aws.String(userID),
+				GroupName:  aws.String(g),
+			}); err != nil {
+				log.Printf("AdminAddUserToGroup %s → %s: %v", userID, g, err)
+			}
+		} else {
+			if _, err := cognitoClient.AdminRemoveUserFromGroup(ctx, &cognitoidp.AdminRemoveUserFromGroupInput{
+				UserPoolId: aws.String(userPoolID),
+				Username:   aws.String(userID),
+				GroupName:  aws.String(g),
+			}); err != nil {
+				// Non-fatal: user may not be in the group
+				log.Printf("AdminRemoveUserFromGroup %s ← %s (non-fatal): %v", userID, g, err)
+			}
+		}
+	}
+	return nil
+}
+
+type adminStats struct {
+	TotalUsers      int `json:"total_users"`
+	AdminUsers      int `json:"admin_users"`
+	ApprovedUsers   int `json:"approved_users"`
+	RestrictedUsers int `json:"restricted_users"`
+	TotalTokensUsed int `json:"total_tokens_used"`
+}
+
+func handleStats(ctx context.Context, store db.Store) (events.APIGatewayV2HTTPResponse, error) {
+	records, err := store.ListUsers(ctx)
+	if err != nil {
+		return serverError(), nil
+	}
+	stats := adminStats{}
+	for _, r := range records {
+		stats.TotalUsers++
+		stats.TotalTokensUsed += r.TokensUsed
+		switch r.Role {
+		case "admin":
+			stats.AdminUsers++
+		case "user":
+			stats.ApprovedUsers++
+		default:
+			stats.RestrictedUsers++
+		}
+	}
+	return jsonResponse(200, stats), nil
+}
+
+// handleReleaseLease drops a session's streaming lease whoever holds it, for
+// when a turn is wedged and waiting out the lease is not an option. The
+// released holder, if still running, keeps streaming to its own connection.
+func handleReleaseLease(ctx context.Context, store db.Store, sessionID string) (events.APIGatewayV2HTTPResponse, error) {
+	if sessionID == "" {
+		return jsonResponse(400, map[string]string{"error": "missing sessionId"}), nil
+	}
+	lease, err := store.GetLease(ctx, sessionID)
+	if err != nil {
+		log.Printf("http-admin GetLease %s: %v", sessionID, err)
+		return serverError(), nil
+	}
+	if err := store.ForceReleaseLease(ctx, sessionID); err != nil {
+		log.Printf("http-admin ForceReleaseLease %s: %v", sessionID, err)
+		return serverError(), nil
+	}
+	if lease != nil {
+		log.Printf("http-admin: released lease on %s held by conn=%s user=%s", sessionID, lease.Holder, lease.UserID)
+	}
+	return jsonResponse(200, map[string]any{"status": "ok", "released": lease != nil}), nil
+}
+
+func fetchEmail(ctx context.Context, cognitoClient *cognitoidp.Client, userPoolID, userID string) string {
+	out, err := cognitoClient.AdminGetUser(ctx, &cognitoidp.AdminGetUserInput{
+		UserPoolId: aws.String(userPoolID),
+		Username:   aws.String(userID),
+	})
+	if err != nil {
+		return ""
+	}
+	for _, attr := range out.UserAttributes {
+		if aws.ToString(attr.Name) == "email" {
+			return aws.ToString(attr.Value)
+		}
+	}
+	return ""
+}
+
+func hasGroup(claims map[string]string, group string) bool {
+	return strings.Contains(claims["cognito:groups"], group)
+}
+
+func jsonResponse(status int, body any) events.APIGatewayV2HTTPResponse {
+	b, _ := json.Marshal(body)
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode: status,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       string(b),
+	}
+}
+
+func serverError() events.APIGatewayV2HTTPResponse {
+	return jsonResponse(500, map[string]string{"error": "internal_server_error"})
+}
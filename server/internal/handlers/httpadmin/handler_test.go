@@ -0,0 +1,186 @@
+package httpadmin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rrochlin/an-amazing-adventure/internal/db"
+)
+
+func assertPanicsWithEnvAbsent(t *testing.T, envVar string, fn func()) {
+	t.Helper()
+	t.Setenv(envVar, "")
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Errorf("expected panic for missing %s, but handler did not panic", envVar)
+			return
+		}
+		msg := ""
+		switch v := r.(type) {
+		case string:
+			msg = v
+		case error:
+			msg = v.Error()
+		}
+		if !strings.Contains(msg, envVar) {
+			t.Errorf("panic message %q does not mention %s", msg, envVar)
+		}
+	}()
+	fn()
+}
+
+func makeAdminReq(method, path, sub string) events.APIGatewayV2HTTPRequest {
+	claims := map[string]string{}
+	if sub != "" {
+		claims["sub"] = sub
+		claims["cognito:groups"] = "admin"
+	}
+	return events.APIGatewayV2HTTPRequest{
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{
+				Method: method,
+				Path:   path,
+			},
+			Authorizer: &events.APIGatewayV2HTTPRequestContextAuthorizerDescription{
+				JWT: &events.APIGatewayV2HTTPRequestContextAuthorizerJWTDescription{
+					Claims: claims,
+				},
+			},
+		},
+	}
+}
+
+// ---- Auth guard ----
+
+func TestHandlerAdmin_NonAdmin_Forbidden(t *testing.T) {
+	t.Setenv("USERS_TABLE", "test-users")
+	t.Setenv("USER_POOL_ID", "us-west-2_test")
+	req := makeAdminReq("GET", "/api/admin/users", "user-123")
+	// Override claims to remove admin group
+	req.RequestContext.Authorizer.JWT.Claims["cognito:groups"] = "user"
+	resp, err := Handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != 403 {
+		t.Errorf("expected 403 for non-admin, got %d", resp.StatusCode)
+	}
+}
+
+func TestHandlerAdmin_UnknownRoute_404(t *testing.T) {
+	t.Setenv("USERS_TABLE", "test-users")
+	t.Setenv("USER_POOL_ID", "us-west-2_test")
+	req := makeAdminReq("GET", "/api/admin/unknown", "user-123")
+	resp, err := Handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != 404 {
+		t.Errorf("expected 404 for unknown route, got %d", resp.StatusCode)
+	}
+}
+
+func TestReleaseLease_ForceReleasesAnyHolder(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+	_ = store.AcquireLease(ctx, db.Lease{SessionID: "s1", Holder: "conn-1", ExpiresAt: time.Now().Add(time.Minute).Unix()})
+
+	req := makeAdminReq("DELETE", "/api/admin/sessions/s1/lease", "admin-1")
+	req.PathParameters = map[string]string{"sessionId": "s1"}
+	resp, _ := New(store)(ctx, req)
+	if resp.StatusCode != 200 || !strings.Contains(resp.Body, `"released":true`) {
+		t.Fatalf("resp = %d %s", resp.StatusCode, resp.Body)
+	}
+	if l, _ := store.GetLease(ctx, "s1"); l != nil {
+		t.Errorf("lease still held: %+v", l)
+	}
+
+	req.RequestContext.Authorizer.JWT.Claims["cognito:groups"] = "user"
+	if resp, _ := New(store)(ctx, req); resp.StatusCode != 403 {
+		t.Errorf("non-admin: expected 403, got %d", resp.StatusCode)
+	}
+}
+
+func TestUpdateUser_WithoutProviderKeepsIt(t *testing.T) {
+	ctx := context.Background()
+	cognito := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		_, _ = w.Write([]byte("{}"))
+	}))
+	t.Cleanup(cognito.Close)
+	t.Setenv("AWS_REGION", "us-west-2")
+	t.Setenv("AWS_ACCESS_KEY_ID", "local")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "local")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	t.Setenv("AWS_ENDPOINT_URL_COGNITO_IDENTITY_PROVIDER", cognito.URL)
+	store := db.NewMemory()
+	if err := store.PutUser(ctx, db.UserRecord{UserID: "user-1", Role: "user", AIProvider: "openai"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The admin page sends no ai_provider when editing a user's quota.
+	req := makeAdminReq("PUT", "/api/admin/users/user-1", "admin-1")
+	req.PathParameters = map[string]string{"userId": "user-1"}
+	req.Body = `{"role":"user","ai_enabled":true,"token_limit":5000,"games_limit":0,"notes":"raised"}`
+	if resp, _ := New(store)(ctx, req); resp.StatusCode != 200 {
+		t.Fatalf("resp = %d %s", resp.StatusCode, resp.Body)
+	}
+	u, _ := store.GetUser(ctx, "user-1")
+	if u.AIProvider != "openai" || u.TokenLimit != 5000 {
+		t.Errorf("user = %+v, want provider kept and the new limit", u)
+	}
+
+	req.Body = `{"role":"user","ai_enabled":true,"ai_provider":""}`
+	if resp, _ := New(store)(ctx, req); resp.StatusCode != 200 {
+		t.Fatalf("resp = %d %s", resp.StatusCode, resp.Body)
+	}
+	if u, _ := store.GetUser(ctx, "user-1"); u.AIProvider != "" {
+		t.Errorf("an explicit empty ai_provider should reset to the default, got %q", u.AIProvider)
+	}
+}
+
+// ---- Required env var tests ----
+// Each env var listed here must also be present in the Lambda's Terraform config
+// (modules/lambdas/main.tf). If you add a new table or service call to http-admin,
+// add its env var here — the test will fail in CI until Terraform is updated to match.
+//
+// USERS_TABLE:  panics immediately via requireUsersTable() on ListUsers / GetUser.
+// USER_POOL_ID: read via os.Getenv (not require* pattern) — no panic on absence,
+//               but Cognito calls silently fail. Documented here as Terraform guard.
+// LEASES_TABLE: only the lease route uses it; panics via requireLeasesTable() there.
+
+var requiredEnvVars = []string{
+	"USERS_TABLE",
+	"USER_POOL_ID",
+}
+
+func TestAllRequiredEnvVarsPanic(t *testing.T) {
+	req := makeAdminReq("GET", "/api/admin/users", "user-123")
+	for _, env := range requiredEnvVars {
+		env := env
+		t.Run(env, func(t *testing.T) {
+			for _, other := range requiredEnvVars {
+				if other != env {
+					t.Setenv(other, "test-"+other)
+				}
+			}
+
+			if env == "USER_POOL_ID" {
+				// USER_POOL_ID is read via os.Getenv, not the require* panic pattern.
+				// Absence causes silent Cognito failures, not a panic. Documented here
+				// as a Terraform config requirement; enforced by code review.
+				t.Skip("USER_POOL_ID does not use require* panic pattern — verified via Terraform config")
+			}
+
+			assertPanicsWithEnvAbsent(t, env, func() {
+				Handler(context.Background(), req) //nolint:errcheck
+			})
+		})
+	}
+}
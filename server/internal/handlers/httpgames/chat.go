@@ -0,0 +1,75 @@
+package httpgames
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rrochlin/an-amazing-adventure/internal/db"
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+)
+
+// chatPageView is one page of a session's chat history. Start is the
+// position of the first message; request ?before=<start> for the page before.
+// Other members' whispers are left out, so a page may hold fewer messages
+// than asked for; positions still count them.
+type chatPageView struct {
+	Messages []game.ChatMessage `json:"messages"`
+	Start    int                `json:"start"`
+	Total    int                `json:"total"`
+}
+
+func matchesChatPath(path string) bool {
+	// matches /api/games/{uuid}/chat
+	const suffix = "/chat"
+	return len(path) > len(suffix) && path[len(path)-len(suffix):] == suffix
+}
+
+// handleChatHistory serves GET /api/games/{uuid}/chat?before=&limit=, paging
+// back through the chat history kept in the session log. Without before it
+// returns the latest messages; limit defaults to game.ChatPageSize.
+func handleChatHistory(ctx context.Context, store db.Store, req events.APIGatewayV2HTTPRequest, userID string) (events.APIGatewayV2HTTPResponse, error) {
+	sessionID := req.PathParameters["uuid"]
+	if sessionID == "" {
+		rest, _ := strings.CutPrefix(req.RequestContext.HTTP.Path, "/api/games/")
+		sessionID, _ = strings.CutSuffix(rest, "/chat")
+	}
+
+	before, limit := -1, game.ChatPageSize
+	if v := req.QueryStringParameters["before"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return jsonResponse(400, map[string]string{"error": "before must be a non-negative integer"}), nil
+		}
+		before = n
+	}
+	if v := req.QueryStringParameters["limit"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return jsonResponse(400, map[string]string{"error": "limit must be a positive integer"}), nil
+		}
+		limit = n
+	}
+
+	// Only the access fields are read: a page must not cost a full session load.
+	access, err := store.GetGameAccess(ctx, sessionID)
+	if err != nil {
+		return jsonResponse(404, map[string]string{"error": "game not found"}), nil
+	}
+	if !isAuthorizedForSession(access, userID) {
+		return jsonResponse(403, map[string]string{"error": "forbidden"}), nil
+	}
+
+	page, err := store.GetChatPage(ctx, sessionID, before, limit)
+	if err != nil {
+		log.Printf("chat history %s: %v", sessionID, err)
+		return serverError(), nil
+	}
+	messages := game.VisibleChat(page.Messages, userID)
+	if messages == nil {
+		messages = []game.ChatMessage{}
+	}
+	return jsonResponse(200, chatPageView{Messages: messages, Start: page.Start, Total: page.Total}), nil
+}
@@ -0,0 +1,163 @@
+package httpgames
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rrochlin/an-amazing-adventure/internal/db"
+	"github.com/rrochlin/an-amazing-adventure/internal/timeline"
+)
+
+// checkpointView is a checkpoint as listed to clients, without its state.
+type checkpointView struct {
+	CheckpointID      string `json:"checkpoint_id"`
+	Name              string `json:"name"`
+	CreatedBy         string `json:"created_by"`
+	CreatedAt         int64  `json:"created_at"`
+	ConversationCount int    `json:"conversation_count"`
+}
+
+func toCheckpointView(cp db.Checkpoint) checkpointView {
+	return checkpointView{
+		CheckpointID:      cp.CheckpointID,
+		Name:              cp.Name,
+		CreatedBy:         cp.CreatedBy,
+		CreatedAt:         cp.CreatedAt,
+		ConversationCount: cp.ConversationCount,
+	}
+}
+
+// splitCheckpointPath splits /api/games/{uuid}/checkpoints and
+// /api/games/{uuid}/checkpoints/{checkpoint_id}/{restore|fork}.
+func splitCheckpointPath(path string) (sessionID, checkpointID, action string, ok bool) {
+	rest, found := strings.CutPrefix(path, "/api/games/")
+	if !found {
+		return "", "", "", false
+	}
+	parts := strings.Split(rest, "/")
+	switch {
+	case len(parts) == 2 && parts[1] == "checkpoints":
+	case len(parts) == 4 && parts[1] == "checkpoints" && (parts[3] == "restore" || parts[3] == "fork"):
+		checkpointID, action = parts[2], parts[3]
+	default:
+		return "", "", "", false
+	}
+	return parts[0], checkpointID, action, parts[0] != ""
+}
+
+func matchesCheckpointsPath(path string) bool {
+	// matches /api/games/{uuid}/checkpoints and the routes below it
+	_, _, _, ok := splitCheckpointPath(path)
+	return ok
+}
+
+// handleCheckpoints serves every checkpoint route: list and create on the
+// collection, restore and fork on a single checkpoint.
+func handleCheckpoints(ctx context.Context, store db.Store, req events.APIGatewayV2HTTPRequest, userID string) (events.APIGatewayV2HTTPResponse, error) {
+	sessionID, checkpointID, action, _ := splitCheckpointPath(req.RequestContext.HTTP.Path)
+	if p := req.PathParameters["uuid"]; p != "" {
+		sessionID = p
+	}
+	if p := req.PathParameters["checkpoint_id"]; p != "" {
+		checkpointID = p
+	}
+	// An existence check only: listing must not cost a full session load.
+	if _, err := store.GetGameAccess(ctx, sessionID); err != nil {
+		return jsonResponse(404, map[string]string{"error": "game not found"}), nil
+	}
+
+	method := req.RequestContext.HTTP.Method
+	switch {
+	case method == "GET" && action == "":
+		return handleListCheckpoints(ctx, store, sessionID, userID)
+	case method == "POST" && action == "":
+		return handleCreateCheckpoint(ctx, store, req, sessionID, userID)
+	case method == "POST" && action == "restore":
+		return handleRestoreCheckpoint(ctx, store, sessionID, checkpointID, userID)
+	case method == "POST" && action == "fork":
+		return handleForkCheckpoint(ctx, store, sessionID, checkpointID, userID)
+	}
+	return jsonResponse(404, map[string]string{"error": "not found"}), nil
+}
+
+func handleListCheckpoints(ctx context.Context, store db.Store, sessionID, userID string) (events.APIGatewayV2HTTPResponse, error) {
+	checkpoints, err := timeline.ListCheckpoints(ctx, store, sessionID, userID)
+	if err != nil {
+		return checkpointError(sessionID, err), nil
+	}
+	views := make([]checkpointView, 0, len(checkpoints))
+	for _, cp := range checkpoints {
+		views = append(views, toCheckpointView(cp))
+	}
+	return jsonResponse(200, map[string]any{"checkpoints": views}), nil
+}
+
+func handleCreateCheckpoint(ctx context.Context, store db.Store, req events.APIGatewayV2HTTPRequest, sessionID, userID string) (events.APIGatewayV2HTTPResponse, error) {
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal([]byte(req.Body), &body); err != nil {
+		return jsonResponse(400, map[string]string{"error": "invalid body"}), nil
+	}
+	cp, err := timeline.CreateCheckpoint(ctx, store, sessionID, userID, body.Name)
+	if err != nil {
+		return checkpointError(sessionID, err), nil
+	}
+	return jsonResponse(201, toCheckpointView(cp)), nil
+}
+
+// handleRestoreCheckpoint puts the session back to a checkpoint and pushes
+// the restored state to everyone connected. Owner only.
+func handleRestoreCheckpoint(ctx context.Context, store db.Store, sessionID, checkpointID, userID string) (events.APIGatewayV2HTTPResponse, error) {
+	res, err := timeline.RestoreCheckpoint(ctx, store, sessionID, checkpointID, userID)
+	if err != nil {
+		return checkpointError(sessionID, err), nil
+	}
+	timeline.PushState(ctx, store, res.Game, res.State.ChatHistory)
+	return jsonResponse(200, map[string]any{
+		"session_id":         sessionID,
+		"checkpoint_id":      checkpointID,
+		"conversation_count": res.State.ConversationCount,
+		"version":            res.State.Version,
+	}), nil
+}
+
+// handleForkCheckpoint starts a new session from a checkpoint. Owner only;
+// the fork counts against the caller's games limit like any new game.
+func handleForkCheckpoint(ctx context.Context, store db.Store, sessionID, checkpointID, userID string) (events.APIGatewayV2HTTPResponse, error) {
+	if _, denied := checkCanCreateGame(ctx, store, userID); denied != nil {
+		return *denied, nil
+	}
+	res, err := timeline.ForkCheckpoint(ctx, store, sessionID, checkpointID, userID)
+	if err != nil {
+		return checkpointError(sessionID, err), nil
+	}
+	return jsonResponse(201, map[string]any{
+		"session_id":        res.State.SessionID,
+		"source_session_id": sessionID,
+		"checkpoint_id":     checkpointID,
+		"ready":             res.State.Ready,
+	}), nil
+}
+
+// checkpointError maps timeline errors to responses.
+func checkpointError(sessionID string, err error) events.APIGatewayV2HTTPResponse {
+	switch {
+	case errors.Is(err, timeline.ErrNotMember):
+		return jsonResponse(403, map[string]string{"error": "forbidden"})
+	case errors.Is(err, timeline.ErrNotOwner):
+		return jsonResponse(403, map[string]string{"error": err.Error()})
+	case errors.Is(err, timeline.ErrBadName):
+		return jsonResponse(400, map[string]string{"error": err.Error()})
+	case errors.Is(err, timeline.ErrNoCheckpoint):
+		return jsonResponse(404, map[string]string{"error": err.Error()})
+	case errors.Is(err, timeline.ErrTooManyCheckpoints), errors.Is(err, timeline.ErrTurnInProgress):
+		return jsonResponse(409, map[string]string{"error": err.Error()})
+	}
+	log.Printf("http-games: checkpoints of %s: %v", sessionID, err)
+	return serverError()
+}
@@ -0,0 +1,765 @@
+// Package httpgames handles all /api/games* REST routes via API Gateway V2 HTTP API.
+package httpgames
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	awslambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	awslambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/rrochlin/an-amazing-adventure/internal/db"
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+	"github.com/rrochlin/an-amazing-adventure/internal/timeline"
+)
+
+// worldGenPayload is passed to the world-gen Lambda as its event.
+type worldGenPayload struct {
+	SessionID      string                     `json:"session_id"`
+	UserID         string                     `json:"user_id"`
+	CreationParams game.CharacterCreationData `json:"creation_params"`
+	// Legacy fields — preserved for backward-compat with old world-gen code path
+	PlayerName        string   `json:"player_name,omitempty"`
+	PlayerDescription string   `json:"player_description,omitempty"`
+	PlayerAge         string   `json:"player_age,omitempty"`
+	PlayerBackstory   string   `json:"player_backstory,omitempty"`
+	ThemeHint         string   `json:"theme_hint,omitempty"`
+	Preferences       []string `json:"preferences,omitempty"`
+}
+
+// Handler is the Lambda entry point. It serves each invocation from DynamoDB.
+func Handler(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	store, err := db.New(ctx)
+	if err != nil {
+		return serverError(), nil
+	}
+	return handle(ctx, store, req)
+}
+
+// New returns a handler backed by store, so tests and cmd/local-server can run
+// it against db.NewMemory.
+func New(store db.Store) func(context.Context, events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	return func(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+		return handle(ctx, store, req)
+	}
+}
+
+func handle(ctx context.Context, store db.Store, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	// Extract authenticated user ID from Cognito JWT authorizer claims
+	userID := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	if userID == "" {
+		return jsonResponse(401, map[string]string{"error": "unauthorized"}), nil
+	}
+
+	method := req.RequestContext.HTTP.Method
+	path := req.RequestContext.HTTP.Path
+	reqID := req.RequestContext.RequestID
+
+	log.Printf("http-games: %s %s user=%s req=%s", method, path, userID, reqID)
+
+	var resp events.APIGatewayV2HTTPResponse
+	var err error
+	switch {
+	case method == "GET" && path == "/api/games":
+		resp, err = handleListGames(ctx, store, userID)
+	case method == "POST" && path == "/api/games":
+		resp, err = handleCreateGame(ctx, store, req, userID)
+	case matchesCheckpointsPath(path):
+		resp, err = handleCheckpoints(ctx, store, req, userID)
+	case method == "GET" && matchesChatPath(path):
+		resp, err = handleChatHistory(ctx, store, req, userID)
+	case method == "GET" && matchesGamePath(path) && !matchesJoinCharacterPath(path) && !matchesRetryWorldGenPath(path) && !matchesLevelUpPath(path) && !matchesRewindPath(path):
+		resp, err = handleGetGame(ctx, store, req, userID)
+	case method == "DELETE" && matchesGamePath(path):
+		resp, err = handleDeleteGame(ctx, store, req, userID)
+	case method == "POST" && matchesJoinCharacterPath(path):
+		resp, err = handleJoinCharacter(ctx, store, req, userID)
+	case method == "POST" && matchesRetryWorldGenPath(path):
+		resp, err = handleRetryWorldGen(ctx, store, req, userID)
+	case method == "POST" && matchesLevelUpPath(path):
+		resp, err = handleLevelUp(ctx, store, req, userID)
+	case method == "POST" && matchesRewindPath(path):
+		resp, err = handleRewind(ctx, store, req, userID)
+	default:
+		resp, err = jsonResponse(404, map[string]string{"error": "not found"}), nil
+	}
+
+	log.Printf("http-games: %s %s → %d (req=%s)", method, path, resp.StatusCode, reqID)
+	return resp, err
+}
+
+type gameListItem struct {
+	SessionID         string `json:"session_id"`
+	PlayerName        string `json:"player_name"`
+	Ready             bool   `json:"ready"`
+	Title             string `json:"title,omitempty"`
+	Theme             string `json:"theme,omitempty"`
+	QuestGoal         string `json:"quest_goal,omitempty"`
+	ConversationCount int    `json:"conversation_count,omitempty"`
+	TotalTokens       int    `json:"total_tokens,omitempty"`
+	// Outcome is "cleared" or "failed" once the dungeon run has ended; empty while active.
+	Outcome string `json:"outcome,omitempty"`
+}
+
+type userQuotaInfo struct {
+	TokensUsed int    `json:"tokens_used"`
+	TokenLimit int    `json:"token_limit"` // 0 = unlimited
+	AIEnabled  bool   `json:"ai_enabled"`
+	Role       string `json:"role"`
+}
+
+func handleListGames(ctx context.Context, store db.Store, userID string) (events.APIGatewayV2HTTPResponse, error) {
+	// Query 1: sessions the user owns
+	ownedIDs, err := store.ListGamesByOwner(ctx, userID)
+	if err != nil {
+		log.Printf("list games (owned): %v", err)
+		return serverError(), nil
+	}
+
+	// Query 2: sessions the user has joined as a member
+	memberIDs, err := store.GetMemberSessions(ctx, userID)
+	if err != nil {
+		log.Printf("list games (memberships): %v", err)
+		// Non-fatal — fall back to owned only
+		memberIDs = nil
+	}
+
+	// Merge and deduplicate
+	seen := make(map[string]bool, len(ownedIDs))
+	allIDs := make([]string, 0, len(ownedIDs)+len(memberIDs))
+	for _, id := range append(ownedIDs, memberIDs...) {
+		if !seen[id] {
+			seen[id] = true
+			allIDs = append(allIDs, id)
+		}
+	}
+
+	saves, err := store.BatchGetSessions(ctx, allIDs)
+	if err != nil {
+		log.Printf("batch get sessions: %v", err)
+		return serverError(), nil
+	}
+
+	results := make([]gameListItem, 0, len(saves))
+	for _, s := range saves {
+		// Determine player name: prefer PlayersData (v3) → Players map (v2) → legacy Player field.
+		ownerKey := s.OwnerID
+		if ownerKey == "" {
+			ownerKey = s.UserID
+		}
+		playerName := s.Player.Name
+		if s.Players != nil {
+			if pc, ok := s.Players[ownerKey]; ok && pc.Name != "" {
+				playerName = pc.Name
+			}
+		}
+		if s.PlayersData != nil {
+			if pd, ok := s.PlayersData[ownerKey]; ok && pd != nil && pd.Name != "" {
+				playerName = pd.Name
+			}
+		}
+		results = append(results, gameListItem{
+			SessionID:         s.SessionID,
+			PlayerName:        playerName,
+			Ready:             s.Ready,
+			Title:             s.Title,
+			Theme:             s.Theme,
+			QuestGoal:         s.QuestGoal,
+			ConversationCount: s.ConversationCount,
+			TotalTokens:       s.TotalTokens,
+			Outcome:           listOutcome(s),
+		})
+	}
+
+	// Include user quota info so the frontend can display usage bar
+	quota := userQuotaInfo{Role: "restricted"}
+	if ur, err := store.GetUser(ctx, userID); err != nil {
+		log.Printf("list games: GetUser error (quota will show restricted): %v", err)
+	} else if ur != nil {
+		quota = userQuotaInfo{
+			TokensUsed: ur.TokensUsed,
+			TokenLimit: ur.TokenLimit,
+			AIEnabled:  ur.AIEnabled,
+			Role:       ur.Role,
+		}
+	}
+
+	return jsonResponse(200, map[string]any{
+		"games":      results,
+		"user_quota": quota,
+	}), nil
+}
+
+func handleCreateGame(ctx context.Context, store db.Store, req events.APIGatewayV2HTTPRequest, userID string) (events.APIGatewayV2HTTPResponse, error) {
+	var body game.CharacterCreationData
+	if err := json.Unmarshal([]byte(req.Body), &body); err != nil {
+		return jsonResponse(400, map[string]string{"error": "invalid request body"}), nil
+	}
+
+	userRecord, denied := checkCanCreateGame(ctx, store, userID)
+	if denied != nil {
+		return *denied, nil
+	}
+
+	log.Printf("http-games POST: user=%s role=%s ai_enabled=true", userID, userRecord.Role)
+
+	sessionID := game.NewSessionID()
+
+	playerName := body.Name
+	if playerName == "" {
+		playerName = "Adventurer"
+	}
+
+	player := game.NewCharacter(playerName, body.Backstory)
+	g := game.NewGame(sessionID, userID)
+	g.SetPlayerCharacter(userID, player)
+	g.CreationParams = body
+	// Server-side table rule: show monsters as HP buckets instead of exact numbers.
+	g.HideMonsterHP = os.Getenv("HIDE_MONSTER_HP") == "true"
+
+	// Build the full D&D character if we have enough data
+	if body.ClassID != "" && body.RaceID != "" && len(body.AbilityScores) == 6 {
+		dndChar, err := game.BuildDnDCharacter(ctx, body)
+		if err != nil {
+			log.Printf("http-games POST: BuildDnDCharacter error: %v", err)
+			return jsonResponse(400, map[string]string{"error": fmt.Sprintf("character creation failed: %v", err)}), nil
+		}
+		g.SetDnDCharacter(userID, dndChar)
+		g.SetKnownSpells(userID, body.KnownSpells())
+	}
+
+	// Save the initial (not-ready) game record
+	saved := g.ToSaveState(nil, nil)
+	if err := store.PutGame(ctx, saved); err != nil {
+		log.Printf("create game put: %v", err)
+		return serverError(), nil
+	}
+	if createLog, logErr := game.NewSnapshotLog(game.MutationEntry{Type: game.EventGameCreated, Actor: userID}, saved); logErr != nil {
+		log.Printf("http-games POST: record snapshot (non-fatal): %v", logErr)
+	} else {
+		db.PutEvents(ctx, store, createLog, sessionID, saved.Version)
+	}
+
+	// Write owner membership record so the user appears in GetMemberSessions results
+	if err := store.PutMembership(ctx, db.MembershipRecord{
+		UserID:    db.BinaryID(userID),
+		SessionID: db.BinaryID(sessionID),
+		Role:      "owner",
+		JoinedAt:  0, // zero is fine — not currently queried
+	}); err != nil {
+		log.Printf("create game PutMembership (non-fatal): %v", err)
+	}
+
+	log.Printf("http-games POST: invoking world-gen for session %s", sessionID)
+	payload, _ := json.Marshal(worldGenPayload{
+		SessionID:      sessionID,
+		UserID:         userID,
+		CreationParams: body,
+		// Legacy fields for backward-compat
+		PlayerName:  playerName,
+		ThemeHint:   body.ThemeHint,
+		Preferences: body.Preferences,
+	})
+	if err := invokeWorldGen(ctx, payload); err != nil {
+		log.Printf("http-games POST: invoke world-gen FAILED for session %s: %v (game still created)", sessionID, err)
+	} else {
+		log.Printf("http-games POST: world-gen invoked for session %s", sessionID)
+	}
+
+	return jsonResponse(201, map[string]any{
+		"session_id": sessionID,
+		"ready":      false,
+	}), nil
+}
+
+// checkCanCreateGame loads the user record and enforces AI access and the
+// games limit. A missing or unreadable user record is fatal — we do not
+// provision resources for users who don't exist (deleted account, transient
+// DynamoDB error, etc.). On refusal the response to send is returned.
+func checkCanCreateGame(ctx context.Context, store db.Store, userID string) (*db.UserRecord, *events.APIGatewayV2HTTPResponse) {
+	deny := func(resp events.APIGatewayV2HTTPResponse) (*db.UserRecord, *events.APIGatewayV2HTTPResponse) {
+		return nil, &resp
+	}
+	userRecord, err := store.GetUser(ctx, userID)
+	if err != nil {
+		log.Printf("http-games: GetUser error for user=%s: %v", userID, err)
+		return deny(serverError())
+	}
+	if userRecord == nil {
+		log.Printf("http-games: user record not found for user=%s — rejecting game creation", userID)
+		return deny(jsonResponse(403, map[string]string{
+			"error":   "user_not_found",
+			"message": "No user account found. Please sign up or contact support.",
+		}))
+	}
+
+	// Enforce AI access — users without ai_enabled cannot create games.
+	if !userRecord.AIEnabled {
+		log.Printf("http-games: ai_access_not_enabled for user=%s role=%s", userID, userRecord.Role)
+		return deny(jsonResponse(403, map[string]string{
+			"error":   "ai_access_not_enabled",
+			"message": "AI access is not enabled for your account. Contact support to request access.",
+		}))
+	}
+
+	// Enforce games limit
+	if userRecord.GamesLimit > 0 {
+		count, countErr := store.CountUserGames(ctx, userID)
+		if countErr == nil && count >= userRecord.GamesLimit {
+			return deny(jsonResponse(403, map[string]string{
+				"error":   "games_limit_reached",
+				"message": fmt.Sprintf("Game limit of %d reached", userRecord.GamesLimit),
+			}))
+		}
+	}
+	return userRecord, nil
+}
+
+func handleGetGame(ctx context.Context, store db.Store, req events.APIGatewayV2HTTPRequest, userID string) (events.APIGatewayV2HTTPResponse, error) {
+	sessionID := req.PathParameters["uuid"]
+	saveState, err := store.GetGame(ctx, sessionID)
+	if err != nil {
+		return jsonResponse(404, map[string]string{"error": "game not found"}), nil
+	}
+	if !isAuthorizedForSession(saveState, userID) {
+		return jsonResponse(403, map[string]string{"error": "forbidden"}), nil
+	}
+	g, err := game.FromSaveState(saveState)
+	if err != nil {
+		return serverError(), nil
+	}
+
+	// Load DnD characters from SaveState for enriched CharacterView
+	if saveState.PlayersData != nil {
+		bus, loadErr := g.LoadDnDCharacters(ctx, saveState.PlayersData)
+		if loadErr != nil {
+			log.Printf("handleGetGame LoadDnDCharacters (non-fatal): %v", loadErr)
+		} else {
+			_ = bus
+		}
+	}
+
+	stateView := g.BuildGameStateView(userID, saveState.ChatHistory)
+	if stateView.OOCChat, err = store.RecentOOC(ctx, sessionID); err != nil {
+		log.Printf("handleGetGame RecentOOC (non-fatal): %v", err)
+	}
+	return jsonResponse(200, map[string]any{
+		"session_id":            sessionID,
+		"ready":                 saveState.Ready,
+		"state":                 stateView,
+		"title":                 saveState.Title,
+		"theme":                 saveState.Theme,
+		"quest_goal":            saveState.QuestGoal,
+		"total_tokens":          saveState.TotalTokens,
+		"conversation_count":    saveState.ConversationCount,
+		"creation_params":       saveState.CreationParams,
+		"needs_character_reset": g.NeedsCharacterReset,
+		"world_gen_logs":        saveState.WorldGenLogs,
+		"owner_id":              saveState.OwnerID,
+	}), nil
+}
+
+func handleDeleteGame(ctx context.Context, store db.Store, req events.APIGatewayV2HTTPRequest, userID string) (events.APIGatewayV2HTTPResponse, error) {
+	sessionID := req.PathParameters["uuid"]
+	// Load session to verify caller is the owner (not just a member)
+	saveState, err := store.GetGame(ctx, sessionID)
+	if err != nil {
+		return jsonResponse(404, map[string]string{"error": "game not found"}), nil
+	}
+	ownerID := saveState.OwnerID
+	if ownerID == "" {
+		ownerID = saveState.UserID
+	}
+	if ownerID != userID {
+		return jsonResponse(403, map[string]string{"error": "only the session owner can delete a game"}), nil
+	}
+
+	// Delete the session record
+	if err := store.DeleteGame(ctx, sessionID, userID); err != nil {
+		log.Printf("delete game %s: %v", sessionID, err)
+		return jsonResponse(404, map[string]string{"error": "game not found or not owned by user"}), nil
+	}
+
+	// Checkpoints hold full copies of the session; drop them too (best-effort)
+	if checkpoints, cpErr := store.ListCheckpoints(ctx, sessionID); cpErr == nil {
+		for _, cp := range checkpoints {
+			if delErr := store.DeleteCheckpoint(ctx, sessionID, cp.CheckpointID); delErr != nil {
+				log.Printf("delete checkpoint %s of session %s (non-fatal): %v", cp.CheckpointID, sessionID, delErr)
+			}
+		}
+	}
+
+	// Clean up all membership records for this session (best-effort)
+	members, membErr := store.GetSessionMembers(ctx, sessionID)
+	if membErr == nil {
+		for _, m := range members {
+			if delErr := store.DeleteMembership(ctx, string(m.UserID), sessionID); delErr != nil {
+				log.Printf("delete membership for user %s session %s (non-fatal): %v", m.UserID, sessionID, delErr)
+			}
+		}
+	}
+
+	return events.APIGatewayV2HTTPResponse{StatusCode: 204}, nil
+}
+
+// invokeWorldGen fires the world-gen Lambda asynchronously (Event invocation type).
+func invokeWorldGen(ctx context.Context, payload []byte) error {
+	fnName := os.Getenv("WORLD_GEN_ARN")
+	if fnName == "" {
+		log.Printf("invokeWorldGen: WORLD_GEN_ARN not set, skipping")
+		return nil
+	}
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("load AWS config: %w", err)
+	}
+	client := awslambda.NewFromConfig(cfg)
+	out, err := client.Invoke(ctx, &awslambda.InvokeInput{
+		FunctionName:   aws.String(fnName),
+		InvocationType: awslambdatypes.InvocationTypeEvent, // async, no wait
+		Payload:        payload,
+	})
+	if err != nil {
+		return fmt.Errorf("lambda invoke %s: %w", fnName, err)
+	}
+	log.Printf("invokeWorldGen: dispatched to %s status=%d", fnName, out.StatusCode)
+	return nil
+}
+
+func matchesJoinCharacterPath(path string) bool {
+	// matches /api/games/{uuid}/join-character
+	const suffix = "/join-character"
+	return len(path) > len(suffix) && path[len(path)-len(suffix):] == suffix
+}
+
+func matchesRetryWorldGenPath(path string) bool {
+	// matches /api/games/{uuid}/retry-world-gen
+	const suffix = "/retry-world-gen"
+	return len(path) > len(suffix) && path[len(path)-len(suffix):] == suffix
+}
+
+func matchesLevelUpPath(path string) bool {
+	// matches /api/games/{uuid}/level-up
+	const suffix = "/level-up"
+	return len(path) > len(suffix) && path[len(path)-len(suffix):] == suffix
+}
+
+func matchesRewindPath(path string) bool {
+	// matches /api/games/{uuid}/rewind
+	const suffix = "/rewind"
+	return len(path) > len(suffix) && path[len(path)-len(suffix):] == suffix
+}
+
+// handleRetryWorldGen re-invokes world-gen for a session that is stuck in not-ready state.
+// Only the session owner can retry. Only allowed when ready=false.
+func handleRetryWorldGen(ctx context.Context, store db.Store, req events.APIGatewayV2HTTPRequest, userID string) (events.APIGatewayV2HTTPResponse, error) {
+	p := req.RequestContext.HTTP.Path
+	const suffix = "/retry-world-gen"
+	const prefix = "/api/games/"
+	sessionID := ""
+	if len(p) > len(prefix)+len(suffix) {
+		sessionID = p[len(prefix) : len(p)-len(suffix)]
+	}
+	if sessionID == "" {
+		return jsonResponse(400, map[string]string{"error": "missing session id"}), nil
+	}
+
+	saveState, err := store.GetGame(ctx, sessionID)
+	if err != nil {
+		return jsonResponse(404, map[string]string{"error": "game not found"}), nil
+	}
+
+	// Only the owner can trigger a retry.
+	ownerID := saveState.OwnerID
+	if ownerID == "" {
+		ownerID = saveState.UserID
+	}
+	if ownerID != userID {
+		return jsonResponse(403, map[string]string{"error": "only the session owner can retry world generation"}), nil
+	}
+
+	// Refuse if the game is already ready — nothing to retry.
+	if saveState.Ready {
+		return jsonResponse(409, map[string]string{"error": "game is already ready"}), nil
+	}
+
+	// Check the user still has AI access (in case their record changed).
+	userRecord, err := store.GetUser(ctx, userID)
+	if err != nil {
+		log.Printf("handleRetryWorldGen: GetUser error user=%s: %v", userID, err)
+	}
+	if userRecord == nil || !userRecord.AIEnabled {
+		log.Printf("handleRetryWorldGen: ai_access_not_enabled for user=%s (record=%v)", userID, userRecord != nil)
+		return jsonResponse(403, map[string]string{"error": "ai_access_not_enabled"}), nil
+	}
+
+	payload, _ := json.Marshal(worldGenPayload{
+		SessionID:      sessionID,
+		UserID:         userID,
+		CreationParams: saveState.CreationParams,
+		PlayerName:     saveState.Player.Name,
+		ThemeHint:      saveState.CreationParams.ThemeHint,
+		Preferences:    saveState.CreationParams.Preferences,
+	})
+
+	log.Printf("handleRetryWorldGen: invoking world-gen for session %s user=%s", sessionID, userID)
+	if err := invokeWorldGen(ctx, payload); err != nil {
+		log.Printf("handleRetryWorldGen: invoke world-gen FAILED for session %s: %v", sessionID, err)
+		return serverError(), nil
+	}
+
+	return jsonResponse(202, map[string]string{"status": "world generation restarted"}), nil
+}
+
+// handleJoinCharacter updates a member's character stub with real character details.
+// Called by party members after they've been added via invite flow.
+func handleJoinCharacter(ctx context.Context, store db.Store, req events.APIGatewayV2HTTPRequest, userID string) (events.APIGatewayV2HTTPResponse, error) {
+	sessionID := req.PathParameters["uuid"]
+	if sessionID == "" {
+		// Try extracting from path: /api/games/{uuid}/join-character
+		p := req.RequestContext.HTTP.Path
+		const suffix = "/join-character"
+		const prefix = "/api/games/"
+		if len(p) > len(prefix)+len(suffix) {
+			sessionID = p[len(prefix) : len(p)-len(suffix)]
+		}
+	}
+	if sessionID == "" {
+		return jsonResponse(400, map[string]string{"error": "missing session id"}), nil
+	}
+
+	var body game.CharacterCreationData
+	if err := json.Unmarshal([]byte(req.Body), &body); err != nil {
+		return jsonResponse(400, map[string]string{"error": "invalid body"}), nil
+	}
+
+	saveState, err := store.GetGame(ctx, sessionID)
+	if err != nil {
+		return jsonResponse(404, map[string]string{"error": "game not found"}), nil
+	}
+	if !isAuthorizedForSession(saveState, userID) {
+		return jsonResponse(403, map[string]string{"error": "forbidden"}), nil
+	}
+
+	g, err := game.FromSaveState(saveState)
+	if err != nil {
+		return serverError(), nil
+	}
+
+	// Load existing DnD players from SaveState so ToSaveState doesn't lose them
+	if saveState.PlayersData != nil {
+		bus, loadErr := g.LoadDnDCharacters(ctx, saveState.PlayersData)
+		if loadErr != nil {
+			log.Printf("handleJoinCharacter LoadDnDCharacters (non-fatal): %v", loadErr)
+		} else {
+			_ = bus // bus scoped to this invocation
+		}
+	}
+	if trackErr := g.TrackEvents(saveState.Narrative, saveState.ChatHistory); trackErr != nil {
+		log.Printf("handleJoinCharacter TrackEvents (non-fatal): %v", trackErr)
+	}
+
+	playerName := body.Name
+	if playerName == "" {
+		playerName = "Adventurer"
+	}
+	// Legacy stub for room placement
+	char := game.NewCharacter(playerName, body.Backstory)
+	if prev, ok := g.GetPlayerCharacter(userID); ok {
+		// Keep the stub's identity and placement so room occupancy stays consistent.
+		char.ID = prev.ID
+		char.LocationID = prev.LocationID
+	}
+	g.SetPlayerCharacter(userID, char)
+	// Drop late joiners at the party's starting room so they can move on their own.
+	if g.Ready {
+		if placeErr := g.EnsureCharacterPlaced(userID); placeErr != nil {
+			log.Printf("handleJoinCharacter EnsureCharacterPlaced (non-fatal): %v", placeErr)
+		}
+	}
+
+	// Build D&D character if creation data is complete
+	if body.ClassID != "" && body.RaceID != "" && len(body.AbilityScores) == 6 {
+		dndChar, charErr := game.BuildDnDCharacter(ctx, body)
+		if charErr != nil {
+			log.Printf("handleJoinCharacter BuildDnDCharacter: %v", charErr)
+			return jsonResponse(400, map[string]string{"error": fmt.Sprintf("character creation failed: %v", charErr)}), nil
+		}
+		g.SetDnDCharacter(userID, dndChar)
+		g.SetKnownSpells(userID, body.KnownSpells())
+	}
+
+	g.RecordEvent(game.MutationEntry{Type: game.EventJoinCharacter, Actor: userID, Input: map[string]any{"name": playerName, "class_id": body.ClassID, "race_id": body.RaceID}})
+	g.Version++
+
+	updated := g.ToSaveState(saveState.Narrative, saveState.ChatHistory)
+	if err := store.PutGame(ctx, updated); err != nil {
+		log.Printf("handleJoinCharacter PutGame: %v", err)
+		return serverError(), nil
+	}
+	db.PutEvents(ctx, store, g.Events, sessionID, updated.Version)
+
+	return jsonResponse(200, map[string]string{"session_id": sessionID}), nil
+}
+
+// handleLevelUp advances the caller's character one level once they have the
+// XP for it. The body is a game.LevelUpRequest; an empty body takes average HP.
+func handleLevelUp(ctx context.Context, store db.Store, req events.APIGatewayV2HTTPRequest, userID string) (events.APIGatewayV2HTTPResponse, error) {
+	sessionID := req.PathParameters["uuid"]
+	if sessionID == "" {
+		p := req.RequestContext.HTTP.Path
+		const suffix = "/level-up"
+		const prefix = "/api/games/"
+		if len(p) > len(prefix)+len(suffix) {
+			sessionID = p[len(prefix) : len(p)-len(suffix)]
+		}
+	}
+	if sessionID == "" {
+		return jsonResponse(400, map[string]string{"error": "missing session id"}), nil
+	}
+
+	var body game.LevelUpRequest
+	if req.Body != "" {
+		if err := json.Unmarshal([]byte(req.Body), &body); err != nil {
+			return jsonResponse(400, map[string]string{"error": "invalid body"}), nil
+		}
+	}
+
+	saveState, err := store.GetGame(ctx, sessionID)
+	if err != nil {
+		return jsonResponse(404, map[string]string{"error": "game not found"}), nil
+	}
+	if !isAuthorizedForSession(saveState, userID) {
+		return jsonResponse(403, map[string]string{"error": "forbidden"}), nil
+	}
+
+	g, err := game.FromSaveState(saveState)
+	if err != nil {
+		return serverError(), nil
+	}
+	if _, err := g.LoadDnDCharacters(ctx, saveState.PlayersData); err != nil {
+		log.Printf("handleLevelUp LoadDnDCharacters: %v", err)
+		return serverError(), nil
+	}
+	if g.InCombatOrder(userID) {
+		return jsonResponse(409, map[string]string{"error": "you can't level up in the middle of a fight"}), nil
+	}
+	if trackErr := g.TrackEvents(saveState.Narrative, saveState.ChatHistory); trackErr != nil {
+		log.Printf("handleLevelUp TrackEvents (non-fatal): %v", trackErr)
+	}
+
+	result, err := g.LevelUp(ctx, userID, body, nil)
+	if err != nil {
+		return jsonResponse(400, map[string]string{"error": err.Error()}), nil
+	}
+	g.RecordEvent(game.MutationEntry{Type: game.EventLevelUp, Actor: userID, Input: map[string]any{"payload": req.Body}})
+
+	g.Version++
+	updated := g.ToSaveState(saveState.Narrative, saveState.ChatHistory)
+	if err := store.PutGame(ctx, updated); err != nil {
+		log.Printf("handleLevelUp PutGame: %v", err)
+		return serverError(), nil
+	}
+	db.PutEvents(ctx, store, g.Events, sessionID, updated.Version)
+
+	return jsonResponse(200, result), nil
+}
+
+// handleRewind restores the session to how it was before narrator turn
+// body.Turn and pushes the restored state to everyone connected. Owner only.
+func handleRewind(ctx context.Context, store db.Store, req events.APIGatewayV2HTTPRequest, userID string) (events.APIGatewayV2HTTPResponse, error) {
+	sessionID := req.PathParameters["uuid"]
+	if sessionID == "" {
+		p := req.RequestContext.HTTP.Path
+		const suffix = "/rewind"
+		const prefix = "/api/games/"
+		if len(p) > len(prefix)+len(suffix) {
+			sessionID = p[len(prefix) : len(p)-len(suffix)]
+		}
+	}
+	if sessionID == "" {
+		return jsonResponse(400, map[string]string{"error": "missing session id"}), nil
+	}
+
+	var body struct {
+		Turn *int `json:"turn"`
+	}
+	if err := json.Unmarshal([]byte(req.Body), &body); err != nil || body.Turn == nil {
+		return jsonResponse(400, map[string]string{"error": "turn is required"}), nil
+	}
+
+	if _, err := store.GetGame(ctx, sessionID); err != nil {
+		return jsonResponse(404, map[string]string{"error": "game not found"}), nil
+	}
+	res, err := timeline.Rewind(ctx, store, sessionID, userID, *body.Turn)
+	switch {
+	case errors.Is(err, timeline.ErrNotOwner):
+		return jsonResponse(403, map[string]string{"error": err.Error()}), nil
+	case errors.Is(err, timeline.ErrBadTurn):
+		return jsonResponse(400, map[string]string{"error": err.Error()}), nil
+	case errors.Is(err, game.ErrNoSuchTurn):
+		return jsonResponse(404, map[string]string{"error": "no record of that turn"}), nil
+	case errors.Is(err, timeline.ErrTurnInProgress):
+		return jsonResponse(409, map[string]string{"error": err.Error()}), nil
+	case errors.Is(err, game.ErrNoSnapshot):
+		return jsonResponse(409, map[string]string{"error": "this session has no history to rewind"}), nil
+	case err != nil:
+		log.Printf("handleRewind %s: %v", sessionID, err)
+		return serverError(), nil
+	}
+
+	timeline.PushState(ctx, store, res.Game, res.State.ChatHistory)
+	return jsonResponse(200, map[string]any{
+		"session_id":         sessionID,
+		"turn":               *body.Turn,
+		"conversation_count": res.State.ConversationCount,
+		"version":            res.State.Version,
+	}), nil
+}
+
+// listOutcome returns the dungeon outcome shown in the game list, or "" for
+// active and pre-dungeon games.
+func listOutcome(s game.SaveState) string {
+	if s.DungeonData == nil || s.DungeonData.State == game.DungeonStateActive {
+		return ""
+	}
+	return s.DungeonData.State.String()
+}
+
+// isAuthorizedForSession returns true if userID is the owner or a party member.
+func isAuthorizedForSession(ss game.SaveState, userID string) bool {
+	if ss.UserID == userID || ss.OwnerID == userID {
+		return true
+	}
+	if ss.Players != nil {
+		if _, ok := ss.Players[userID]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesGamePath(path string) bool {
+	// matches /api/games/{uuid} — must have a non-empty segment after /api/games/
+	const prefix = "/api/games/"
+	return len(path) > len(prefix) && path[:len(prefix)] == prefix
+}
+
+func jsonResponse(code int, body any) events.APIGatewayV2HTTPResponse {
+	b, _ := json.Marshal(body)
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode: code,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       string(b),
+	}
+}
+
+func serverError() events.APIGatewayV2HTTPResponse {
+	return jsonResponse(500, map[string]string{"error": "internal server error"})
+}
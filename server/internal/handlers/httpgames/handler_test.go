@@ -0,0 +1,630 @@
+package httpgames
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rrochlin/an-amazing-adventure/internal/db"
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+)
+
+func assertPanicsWithEnvAbsent(t *testing.T, envVar string, fn func()) {
+	t.Helper()
+	t.Setenv(envVar, "")
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Errorf("expected panic for missing %s, but handler did not panic", envVar)
+			return
+		}
+		msg := ""
+		switch v := r.(type) {
+		case string:
+			msg = v
+		case error:
+			msg = v.Error()
+		}
+		if !strings.Contains(msg, envVar) {
+			t.Errorf("panic message %q does not mention %s", msg, envVar)
+		}
+	}()
+	fn()
+}
+
+// makeHTTPReq builds a minimal APIGatewayV2HTTPRequest with Cognito sub claim.
+func makeHTTPReq(method, path string, body string, sub string, pathParams map[string]string) events.APIGatewayV2HTTPRequest {
+	claims := map[string]string{}
+	if sub != "" {
+		claims["sub"] = sub
+	}
+	return events.APIGatewayV2HTTPRequest{
+		Body: body,
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{
+				Method: method,
+				Path:   path,
+			},
+			Authorizer: &events.APIGatewayV2HTTPRequestContextAuthorizerDescription{
+				JWT: &events.APIGatewayV2HTTPRequestContextAuthorizerJWTDescription{
+					Claims: claims,
+				},
+			},
+		},
+		PathParameters: pathParams,
+	}
+}
+
+// ---- Auth guard ----
+
+func TestHandlerRejects_NoSub(t *testing.T) {
+	req := makeHTTPReq("GET", "/api/games", "", "", nil)
+	resp, err := Handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != 401 {
+		t.Errorf("expected 401 with no sub, got %d", resp.StatusCode)
+	}
+}
+
+// ---- Route dispatch ----
+
+func TestHandlerUnknownRoute_404(t *testing.T) {
+	t.Setenv("SESSIONS_TABLE", "test-table")
+	t.Setenv("CONNECTIONS_TABLE", "test-connections")
+	t.Setenv("WORLD_GEN_ARN", "")
+	req := makeHTTPReq("GET", "/api/unknown", "", "user-123", nil)
+	resp, err := Handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != 404 {
+		t.Errorf("expected 404 for unknown route, got %d", resp.StatusCode)
+	}
+}
+
+// ---- GET /api/games ----
+
+func TestHandlerListGames_EmptyList(t *testing.T) {
+	t.Setenv("SESSIONS_TABLE", "test-table")
+	t.Setenv("CONNECTIONS_TABLE", "test-connections")
+	t.Setenv("USERS_TABLE", "test-users")
+	t.Setenv("WORLD_GEN_ARN", "")
+	// Without a real DynamoDB table this will error at the DB layer —
+	// we assert the handler routes correctly and returns a structured error.
+	req := makeHTTPReq("GET", "/api/games", "", "user-123", nil)
+	resp, err := Handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected lambda error: %v", err)
+	}
+	// 500 expected because env is missing real DB credentials, not 401/404
+	if resp.StatusCode == 401 || resp.StatusCode == 404 {
+		t.Errorf("expected non-auth error, got %d — routing failed", resp.StatusCode)
+	}
+	// Response must be valid JSON
+	var body map[string]any
+	if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+		t.Errorf("response body is not valid JSON: %s", resp.Body)
+	}
+}
+
+// ---- POST /api/games ----
+
+func TestHandlerCreateGame_EmptyBody_AcceptsAIGenerated(t *testing.T) {
+	// player_name is now optional — empty body should reach the DB layer (not 400)
+	t.Setenv("SESSIONS_TABLE", "test-table")
+	t.Setenv("USERS_TABLE", "test-users")
+	t.Setenv("WORLD_GEN_ARN", "")
+	req := makeHTTPReq("POST", "/api/games", `{}`, "user-123", nil)
+	resp, err := Handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected lambda error: %v", err)
+	}
+	// Should NOT be a 400 (bad request) — will fail at DB layer (500) without real credentials
+	if resp.StatusCode == 400 {
+		t.Errorf("empty body should not return 400 now that player_name is optional, got %d\nbody: %s", resp.StatusCode, resp.Body)
+	}
+}
+
+func TestHandlerCreateGame_WithAllParams(t *testing.T) {
+	// Verify the handler accepts all new optional creation params
+	t.Setenv("SESSIONS_TABLE", "test-table")
+	t.Setenv("USERS_TABLE", "test-users")
+	t.Setenv("WORLD_GEN_ARN", "")
+	body := `{
+		"player_name": "Aria",
+		"player_age": "mid 20s",
+		"player_description": "A nimble rogue with sharp eyes",
+		"player_backstory": "Raised by thieves, seeking redemption",
+		"theme_hint": "gritty noir",
+		"preferences": ["stealth", "mystery"]
+	}`
+	req := makeHTTPReq("POST", "/api/games", body, "user-123", nil)
+	resp, err := Handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected lambda error: %v", err)
+	}
+	// Should reach DB layer, not return 400
+	if resp.StatusCode == 400 {
+		t.Errorf("valid body with all params should not return 400, got %d\nbody: %s", resp.StatusCode, resp.Body)
+	}
+}
+
+func TestHandlerCreateGame_InvalidJSON(t *testing.T) {
+	t.Setenv("SESSIONS_TABLE", "test-table")
+	t.Setenv("USERS_TABLE", "test-users")
+	t.Setenv("WORLD_GEN_ARN", "")
+	req := makeHTTPReq("POST", "/api/games", `not-json`, "user-123", nil)
+	resp, err := Handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected lambda error: %v", err)
+	}
+	if resp.StatusCode != 400 {
+		t.Errorf("expected 400 for invalid JSON, got %d", resp.StatusCode)
+	}
+}
+
+// ---- DELETE /api/games/{uuid} ----
+
+func TestHandlerDeleteGame_MissingUUID(t *testing.T) {
+	t.Setenv("SESSIONS_TABLE", "test-table")
+	req := makeHTTPReq("DELETE", "/api/games/", "user-123", "user-123", map[string]string{})
+	resp, err := Handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected lambda error: %v", err)
+	}
+	// Without uuid in path params, routing should still attempt delete and fail gracefully
+	if resp.StatusCode == 0 {
+		t.Error("expected a non-zero status code")
+	}
+	var body map[string]any
+	if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+		t.Errorf("response is not valid JSON: %s", resp.Body)
+	}
+}
+
+// ---- POST /api/games/{uuid}/level-up ----
+
+func TestHandlerLevelUp_InvalidJSON(t *testing.T) {
+	t.Setenv("SESSIONS_TABLE", "test-table")
+	req := makeHTTPReq("POST", "/api/games/abc-123/level-up", `not-json`, "user-123", map[string]string{"uuid": "abc-123"})
+	resp, err := Handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected lambda error: %v", err)
+	}
+	if resp.StatusCode != 400 {
+		t.Errorf("expected 400 for invalid JSON, got %d", resp.StatusCode)
+	}
+}
+
+func TestMatchesLevelUpPath(t *testing.T) {
+	if !matchesLevelUpPath("/api/games/abc-123/level-up") {
+		t.Error("expected level-up path to match")
+	}
+	if matchesLevelUpPath("/api/games/abc-123") {
+		t.Error("plain game path must not match level-up")
+	}
+}
+
+// ---- Response format helpers ----
+
+func TestJSONResponse_ContentType(t *testing.T) {
+	resp := jsonResponse(200, map[string]string{"foo": "bar"})
+	if resp.Headers["Content-Type"] != "application/json" {
+		t.Errorf("expected Content-Type application/json, got %q", resp.Headers["Content-Type"])
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200, got %d", resp.StatusCode)
+	}
+	var body map[string]string
+	if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+		t.Errorf("body is not valid JSON: %s", resp.Body)
+	}
+	if body["foo"] != "bar" {
+		t.Errorf("expected foo=bar, got %v", body)
+	}
+}
+
+func TestServerError_Returns500(t *testing.T) {
+	resp := serverError()
+	if resp.StatusCode != 500 {
+		t.Errorf("expected 500, got %d", resp.StatusCode)
+	}
+}
+
+func TestMatchesGamePath(t *testing.T) {
+	cases := []struct {
+		path  string
+		match bool
+	}{
+		{"/api/games/abc-123", true},       // UUID segment present — match
+		{"/api/games/abc-123/extra", true}, // deeper path — match
+		{"/api/games/", false},             // trailing slash only — no UUID, no match
+		{"/api/games", false},              // base path — no UUID, no match
+		{"/api/other/uuid", false},         // wrong prefix — no match
+	}
+	for _, c := range cases {
+		got := matchesGamePath(c.path)
+		if got != c.match {
+			t.Errorf("matchesGamePath(%q) = %v, want %v", c.path, got, c.match)
+		}
+	}
+}
+
+// ---- Required env var tests ----
+// http-games requires: SESSIONS_TABLE, USERS_TABLE
+// (CONNECTIONS_TABLE is NOT required — only the rewind route reads connections)
+
+func TestHandlerGames_MissingSESSIONS_TABLE_Panics(t *testing.T) {
+	req := makeHTTPReq("GET", "/api/games", "", "user-sub-123", nil)
+	assertPanicsWithEnvAbsent(t, "SESSIONS_TABLE", func() {
+		Handler(context.Background(), req) //nolint:errcheck
+	})
+}
+
+func TestHandlerGames_NoCONNECTIONS_TABLE_DoesNotPanic(t *testing.T) {
+	// http-games must NOT panic when CONNECTIONS_TABLE is absent —
+	// listing games never uses the connections table.
+	t.Setenv("SESSIONS_TABLE", "test-sessions")
+	t.Setenv("CONNECTIONS_TABLE", "") // explicitly absent
+	req := makeHTTPReq("GET", "/api/games", "", "user-sub-123", nil)
+	// Should reach DynamoDB (and fail with a credential/network error), not panic
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("http-games panicked with CONNECTIONS_TABLE absent: %v", r)
+		}
+	}()
+	Handler(context.Background(), req) //nolint:errcheck
+}
+
+func TestListOutcome(t *testing.T) {
+	cases := []struct {
+		dd   *game.DungeonData
+		want string
+	}{
+		{nil, ""},
+		{&game.DungeonData{State: game.DungeonStateActive}, ""},
+		{&game.DungeonData{State: game.DungeonStateCleared}, "cleared"},
+		{&game.DungeonData{State: game.DungeonStateFailed}, "failed"},
+	}
+	for _, c := range cases {
+		if got := listOutcome(game.SaveState{DungeonData: c.dd}); got != c.want {
+			t.Errorf("listOutcome(%+v) = %q, want %q", c.dd, got, c.want)
+		}
+	}
+}
+
+// ---- Handlers against the in-memory store ----
+
+// seedGame stores a fresh game owned by ownerID with the owner's character.
+func seedGame(t *testing.T, store *db.Memory, sessionID, ownerID string) {
+	t.Helper()
+	g := game.NewGame(sessionID, ownerID)
+	g.SetPlayerCharacter(ownerID, game.NewCharacter("Brom", "a dwarf"))
+	if err := store.PutGame(context.Background(), g.ToSaveState(nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListGames_OwnedAndJoined(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+	seedGame(t, store, "owned", "user-1")
+	seedGame(t, store, "joined", "user-2")
+	seedGame(t, store, "stranger", "user-3")
+	_ = store.PutMembership(ctx, db.MembershipRecord{UserID: "user-1", SessionID: "joined", Role: "member"})
+	_ = store.PutUser(ctx, db.UserRecord{UserID: "user-1", Role: "user", AIEnabled: true, TokensUsed: 42})
+
+	resp, err := New(store)(ctx, makeHTTPReq("GET", "/api/games", "", "user-1", nil))
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("resp = %d %s, err = %v", resp.StatusCode, resp.Body, err)
+	}
+	var body struct {
+		Games []gameListItem `json:"games"`
+		Quota userQuotaInfo  `json:"user_quota"`
+	}
+	if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Games) != 2 {
+		t.Errorf("expected the owned and joined games, got %+v", body.Games)
+	}
+	if body.Quota.TokensUsed != 42 || body.Quota.Role != "user" {
+		t.Errorf("quota = %+v", body.Quota)
+	}
+}
+
+func TestGetGame_OwnerOnlyUnlessMember(t *testing.T) {
+	store := db.NewMemory()
+	seedGame(t, store, "s1", "user-1")
+	params := map[string]string{"uuid": "s1"}
+
+	resp, _ := New(store)(context.Background(), makeHTTPReq("GET", "/api/games/s1", "", "user-1", params))
+	if resp.StatusCode != 200 {
+		t.Errorf("owner: expected 200, got %d %s", resp.StatusCode, resp.Body)
+	}
+	resp, _ = New(store)(context.Background(), makeHTTPReq("GET", "/api/games/s1", "", "user-2", params))
+	if resp.StatusCode != 403 {
+		t.Errorf("stranger: expected 403, got %d", resp.StatusCode)
+	}
+	resp, _ = New(store)(context.Background(), makeHTTPReq("GET", "/api/games/nope", "", "user-1", map[string]string{"uuid": "nope"}))
+	if resp.StatusCode != 404 {
+		t.Errorf("missing game: expected 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestGetGame_IncludesOOCChat(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+	seedGame(t, store, "s1", "user-1")
+	if _, err := store.AppendOOC(ctx, "s1", game.OOCMessage{UserID: "user-1", Name: "Brom", Content: "back in 5"}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, _ := New(store)(ctx, makeHTTPReq("GET", "/api/games/s1", "", "user-1", map[string]string{"uuid": "s1"}))
+	var body struct {
+		State game.GameStateView `json:"state"`
+	}
+	if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.State.OOCChat) != 1 || body.State.OOCChat[0].Content != "back in 5" || body.State.OOCChat[0].Seq != 1 {
+		t.Errorf("ooc_chat = %+v", body.State.OOCChat)
+	}
+}
+
+func TestJoinCharacter_SavesNextVersion(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+	seedGame(t, store, "s1", "user-1")
+
+	req := makeHTTPReq("POST", "/api/games/s1/join-character", `{"name":"Vex"}`, "user-1", map[string]string{"uuid": "s1"})
+	resp, _ := New(store)(ctx, req)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d %s", resp.StatusCode, resp.Body)
+	}
+	saved, err := store.GetGame(ctx, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Version != 1 || saved.Players["user-1"].Name != "Vex" {
+		t.Errorf("saved version %d player %+v", saved.Version, saved.Players["user-1"])
+	}
+}
+
+func TestJoinCharacter_RecordsReplayableEvent(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+	seedGame(t, store, "s1", "user-1")
+	seeded, _ := store.GetGame(ctx, "s1")
+	snap, err := game.NewSnapshotLog(game.MutationEntry{Type: game.EventWorldGen}, seeded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.PutEvents(ctx, store, snap, "s1", seeded.Version)
+
+	req := makeHTTPReq("POST", "/api/games/s1/join-character", `{"name":"Vex"}`, "user-1", map[string]string{"uuid": "s1"})
+	if resp, _ := New(store)(ctx, req); resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d %s", resp.StatusCode, resp.Body)
+	}
+
+	entries, err := store.ListMutations(ctx, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := entries[len(entries)-1]
+	if len(entries) != 2 || last.Type != game.EventJoinCharacter || last.Actor != "user-1" || last.Version != 1 {
+		t.Fatalf("events = %+v", entries)
+	}
+	replayed, err := game.ReplayState(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, _ := store.GetGame(ctx, "s1")
+	if diff, _ := game.DiffStates(replayed, saved); !diff.Empty() || replayed.Version != saved.Version {
+		t.Errorf("replay differs from the saved game: %+v", diff)
+	}
+}
+
+func TestDeleteGame_RemovesSessionAndMemberships(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+	seedGame(t, store, "s1", "user-1")
+	_ = store.PutMembership(ctx, db.MembershipRecord{UserID: "user-1", SessionID: "s1", Role: "owner"})
+	_ = store.PutMembership(ctx, db.MembershipRecord{UserID: "user-2", SessionID: "s1", Role: "member"})
+	params := map[string]string{"uuid": "s1"}
+
+	resp, _ := New(store)(ctx, makeHTTPReq("DELETE", "/api/games/s1", "", "user-2", params))
+	if resp.StatusCode != 403 {
+		t.Errorf("member delete: expected 403, got %d", resp.StatusCode)
+	}
+	resp, _ = New(store)(ctx, makeHTTPReq("DELETE", "/api/games/s1", "", "user-1", params))
+	if resp.StatusCode != 204 {
+		t.Fatalf("owner delete: expected 204, got %d %s", resp.StatusCode, resp.Body)
+	}
+	if _, err := store.GetGame(ctx, "s1"); err == nil {
+		t.Error("session should be deleted")
+	}
+	if members, _ := store.GetSessionMembers(ctx, "s1"); len(members) != 0 {
+		t.Errorf("memberships should be cleaned up, got %v", members)
+	}
+}
+
+func TestRewind_OwnerOnlyAndValidated(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+	seedGame(t, store, "s1", "user-1")
+	seeded, _ := store.GetGame(ctx, "s1")
+	seeded.ConversationCount = 3
+	seeded.Version = 1
+	_ = store.PutGame(ctx, seeded)
+	params := map[string]string{"uuid": "s1"}
+
+	cases := []struct {
+		user, body string
+		want       int
+	}{
+		{"user-1", `{}`, 400},
+		{"user-1", `{"turn":3}`, 400},
+		{"user-2", `{"turn":1}`, 403},
+		{"user-1", `{"turn":1}`, 404}, // no events were recorded for this game
+	}
+	for _, c := range cases {
+		resp, _ := New(store)(ctx, makeHTTPReq("POST", "/api/games/s1/rewind", c.body, c.user, params))
+		if resp.StatusCode != c.want {
+			t.Errorf("%s %s: expected %d, got %d %s", c.user, c.body, c.want, resp.StatusCode, resp.Body)
+		}
+	}
+	if resp, _ := New(store)(ctx, makeHTTPReq("POST", "/api/games/nope/rewind", `{"turn":0}`, "user-1", map[string]string{"uuid": "nope"})); resp.StatusCode != 404 {
+		t.Errorf("missing game: expected 404, got %d", resp.StatusCode)
+	}
+}
+
+// ---- Checkpoints ----
+
+func TestCheckpoints_CreateListRestoreFork(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+	seedGame(t, store, "s1", "user-1")
+	_ = store.PutUser(ctx, db.UserRecord{UserID: "user-1", Role: "user", AIEnabled: true})
+	params := map[string]string{"uuid": "s1"}
+
+	resp, _ := New(store)(ctx, makeHTTPReq("POST", "/api/games/s1/checkpoints", `{"name":"start"}`, "user-1", params))
+	if resp.StatusCode != 201 {
+		t.Fatalf("create: expected 201, got %d %s", resp.StatusCode, resp.Body)
+	}
+	var created checkpointView
+	_ = json.Unmarshal([]byte(resp.Body), &created)
+
+	resp, _ = New(store)(ctx, makeHTTPReq("GET", "/api/games/s1/checkpoints", "", "user-1", params))
+	var listed struct {
+		Checkpoints []checkpointView `json:"checkpoints"`
+	}
+	_ = json.Unmarshal([]byte(resp.Body), &listed)
+	if resp.StatusCode != 200 || len(listed.Checkpoints) != 1 || listed.Checkpoints[0].Name != "start" {
+		t.Fatalf("list: %d %s", resp.StatusCode, resp.Body)
+	}
+	if resp, _ := New(store)(ctx, makeHTTPReq("GET", "/api/games/s1/checkpoints", "", "user-2", params)); resp.StatusCode != 403 {
+		t.Errorf("stranger list: expected 403, got %d", resp.StatusCode)
+	}
+
+	cpParams := map[string]string{"uuid": "s1", "checkpoint_id": created.CheckpointID}
+	resp, _ = New(store)(ctx, makeHTTPReq("POST", "/api/games/s1/checkpoints/"+created.CheckpointID+"/restore", "", "user-1", cpParams))
+	if resp.StatusCode != 200 {
+		t.Errorf("restore: expected 200, got %d %s", resp.StatusCode, resp.Body)
+	}
+	if saved, _ := store.GetGame(ctx, "s1"); saved.Version != 1 {
+		t.Errorf("restore should save the next version, got v%d", saved.Version)
+	}
+
+	resp, _ = New(store)(ctx, makeHTTPReq("POST", "/api/games/s1/checkpoints/"+created.CheckpointID+"/fork", "", "user-1", cpParams))
+	if resp.StatusCode != 201 {
+		t.Fatalf("fork: expected 201, got %d %s", resp.StatusCode, resp.Body)
+	}
+	var forked struct {
+		SessionID string `json:"session_id"`
+	}
+	_ = json.Unmarshal([]byte(resp.Body), &forked)
+	if _, err := store.GetGame(ctx, forked.SessionID); err != nil || forked.SessionID == "s1" {
+		t.Errorf("fork %q not saved: %v", forked.SessionID, err)
+	}
+
+	resp, _ = New(store)(ctx, makeHTTPReq("POST", "/api/games/s1/checkpoints/nope/restore", "", "user-1", map[string]string{"uuid": "s1", "checkpoint_id": "nope"}))
+	if resp.StatusCode != 404 {
+		t.Errorf("missing checkpoint: expected 404, got %d", resp.StatusCode)
+	}
+
+	resp, _ = New(store)(ctx, makeHTTPReq("DELETE", "/api/games/s1", "", "user-1", params))
+	if resp.StatusCode != 204 {
+		t.Fatalf("delete: %d", resp.StatusCode)
+	}
+	if left, _ := store.ListCheckpoints(ctx, "s1"); len(left) != 0 {
+		t.Errorf("deleting a game should drop its checkpoints, %d left", len(left))
+	}
+}
+
+func TestSplitCheckpointPath(t *testing.T) {
+	cases := []struct {
+		path, session, checkpoint, action string
+		ok                                bool
+	}{
+		{"/api/games/s1/checkpoints", "s1", "", "", true},
+		{"/api/games/s1/checkpoints/c1/restore", "s1", "c1", "restore", true},
+		{"/api/games/s1/checkpoints/c1/fork", "s1", "c1", "fork", true},
+		{"/api/games/s1/checkpoints/c1", "", "", "", false},
+		{"/api/games/s1/checkpoints/c1/delete", "", "", "", false},
+		{"/api/games/s1", "", "", "", false},
+		{"/api/games//checkpoints", "", "", "", false},
+	}
+	for _, c := range cases {
+		s, cp, a, ok := splitCheckpointPath(c.path)
+		if ok != c.ok || (ok && (s != c.session || cp != c.checkpoint || a != c.action)) {
+			t.Errorf("splitCheckpointPath(%q) = %q %q %q %v", c.path, s, cp, a, ok)
+		}
+	}
+}
+
+func mustGetGame(t *testing.T, store *db.Memory, sessionID string) game.SaveState {
+	t.Helper()
+	s, err := store.GetGame(context.Background(), sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestChatHistory_PagesAndAuthorizes(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+	g := game.NewGame("s1", "user-1")
+	var history []game.ChatMessage
+	for i := range 5 {
+		history = append(history, game.ChatMessage{Type: "player", Content: strconv.Itoa(i)})
+	}
+	if err := store.PutGame(ctx, g.ToSaveState(nil, history)); err != nil {
+		t.Fatal(err)
+	}
+	params := map[string]string{"uuid": "s1"}
+
+	req := makeHTTPReq("GET", "/api/games/s1/chat", "", "user-1", params)
+	req.QueryStringParameters = map[string]string{"before": "4", "limit": "2"}
+	resp, _ := New(store)(ctx, req)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d %s", resp.StatusCode, resp.Body)
+	}
+	var page chatPageView
+	if err := json.Unmarshal([]byte(resp.Body), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Start != 2 || page.Total != 5 || len(page.Messages) != 2 || page.Messages[0].Content != "2" {
+		t.Errorf("page = %+v", page)
+	}
+
+	// Whispers only reach the member they were for; positions still count them.
+	g, _ = game.FromSaveState(mustGetGame(t, store, "s1"))
+	whisper := g.ToSaveState(nil, append(mustGetGame(t, store, "s1").ChatHistory,
+		game.ChatMessage{Type: "player", Content: "psst", PrivateTo: "user-2"}))
+	whisper.Version++
+	if err := store.PutGame(ctx, whisper); err != nil {
+		t.Fatal(err)
+	}
+	req.QueryStringParameters = nil
+	resp, _ = New(store)(ctx, req)
+	_ = json.Unmarshal([]byte(resp.Body), &page)
+	if page.Total != 6 || len(page.Messages) != 5 {
+		t.Errorf("owner's page should skip user-2's whisper, got %+v", page)
+	}
+
+	req.QueryStringParameters = map[string]string{"limit": "-1"}
+	if resp, _ := New(store)(ctx, req); resp.StatusCode != 400 {
+		t.Errorf("bad limit: expected 400, got %d", resp.StatusCode)
+	}
+	resp, _ = New(store)(ctx, makeHTTPReq("GET", "/api/games/s1/chat", "", "user-2", params))
+	if resp.StatusCode != 403 {
+		t.Errorf("stranger: expected 403, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,534 @@
+// Package wschat handles the WebSocket "chat" route.
+//
+// Turn flow:
+//  0. RBAC check     — verify AI access is enabled and token quota not exceeded
+//  1. NarrateStream  — streams pure narrative prose (no tools) to the client
+//  2. narrative_end  — signals streaming is complete
+//  3. EngineerScan   — infers world mutations from the narrative, executes them
+//     (skipped once the dungeon is cleared or failed — the narrator only
+//     writes the epilogue and the world no longer changes)
+//  4. PutGame        — persists updated game state; new chat and narrative
+//     messages are appended to the session log
+//  5. PutEvents      — writes the turn's typed events to the mutations table
+//     (best-effort, only once the save has succeeded)
+//  6. UpdateTokens   — increments per-user token counter (best-effort)
+//  7. SendDelta      — sends state delta (player, room, world events)
+//
+// A private chat ("private": true) is a whisper: the narrator's reply streams
+// to the sender alone, both messages are stored visible only to them (in the
+// chat and in the narrator's history, which later public turns do not see),
+// and only they see the turn's world events. The rest of the party still gets a state
+// delta, so a world change shows up without its story.
+//
+// In round mode a chat declares the sender's intent for the pending action
+// round instead: it is saved, the party gets a round_updated frame, and the
+// narrator is not called. Once everyone who can act has declared the same
+// flow runs once for the whole round, with every intent in one narrator
+// input. A round past game.RoundTimeout is resolved by the next frame from
+// anyone: a chat here, or an ooc or game_action frame, which ws-ooc and
+// ws-game-action forward here as wsutil.ResolveExpiredRound. The party is
+// then sent a round_updated frame marked timed_out before the narration.
+//
+// The turn that clears or fails the run, here or in ws-game-action, is
+// followed by an epilogue turn (wsutil.WriteEpilogue): the narrator closes
+// the story for the whole party with no player input. It is written once.
+package wschat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rrochlin/an-amazing-adventure/internal/ai"
+	"github.com/rrochlin/an-amazing-adventure/internal/db"
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+	"github.com/rrochlin/an-amazing-adventure/internal/wsutil"
+)
+
+// streamLease is how long a turn holds the session's streaming lease. It
+// just outlasts the Lambda's 29s timeout, so a live turn never loses the
+// lease and one that died without releasing it blocks the party for hardly
+// longer than a turn could have run.
+const streamLease = 30 * time.Second
+
+type chatRequest struct {
+	Action  string `json:"action"`
+	Content string `json:"content"`
+	Private bool   `json:"private,omitempty"` // whisper to the narrator
+	Resolve bool   `json:"resolve,omitempty"` // round mode: resolve a timed-out round without an intent
+	// Epilogue asks for the epilogue of a finished run (wsutil.WriteEpilogue).
+	Epilogue bool `json:"epilogue,omitempty"`
+	// Forwarded marks the resolve (wsutil.ResolveExpiredRound) sent on the
+	// sender's behalf when their ooc or game_action frame arrives after the
+	// round's deadline, and the epilogue sent after the turn that ended the
+	// run. Refusals are not reported to the sender, who never asked for it.
+	Forwarded bool `json:"forwarded,omitempty"`
+}
+
+// Handler is the Lambda entry point. It serves each invocation from DynamoDB.
+func Handler(ctx context.Context, req events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	store, err := db.New(ctx)
+	if err != nil {
+		log.Printf("ws-chat: db init: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+	}
+	return handle(ctx, store, req)
+}
+
+// New returns a handler backed by store, so tests and cmd/local-server can run
+// it against db.NewMemory.
+func New(store db.Store) func(context.Context, events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return func(ctx context.Context, req events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return handle(ctx, store, req)
+	}
+}
+
+func handle(ctx context.Context, store db.Store, req events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	connID := req.RequestContext.ConnectionID
+	reqID := req.RequestContext.RequestID
+	log.Printf("ws-chat: conn=%s req=%s", connID, reqID)
+
+	// Parse message
+	var msg chatRequest
+	if err := json.Unmarshal([]byte(req.Body), &msg); err != nil {
+		log.Printf("ws-chat: bad body conn=%s: %v", connID, err)
+		return events.APIGatewayProxyResponse{StatusCode: 400}, nil
+	}
+	if msg.Content == "" && !msg.Resolve && !msg.Epilogue {
+		return events.APIGatewayProxyResponse{StatusCode: 400}, nil
+	}
+
+	// Load connection record
+	conn, err := store.GetConnection(ctx, connID)
+	if err != nil {
+		log.Printf("ws-chat: get connection: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 410}, nil // Gone
+	}
+
+	// One narrator turn per session: take the streaming lease or bounce. A
+	// lease left behind by a crashed turn is stale after streamLease and
+	// taken over here.
+	lease := db.Lease{
+		SessionID: db.BinaryID(conn.GameID),
+		Holder:    connID,
+		UserID:    conn.UserID,
+		ExpiresAt: time.Now().Add(streamLease).Unix(),
+	}
+	if err := store.AcquireLease(ctx, lease); err != nil {
+		if !errors.Is(err, db.ErrLeaseHeld) {
+			log.Printf("ws-chat: acquire lease: %v", err)
+			return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+		}
+		if msg.Forwarded {
+			return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+		}
+		ws, _ := wsutil.New(ctx)
+		_ = ws.Send(ctx, connID, wsutil.Frame{Type: wsutil.FrameStreamingBlocked})
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	}
+	// A fight's idle turn is skipped by ws-game-action, and the epilogue of a
+	// run this turn ended is written by another ws-chat turn, once the lease
+	// is released, so neither is turned away by this very turn.
+	var turnExpired, writeEpilogue bool
+	defer func() {
+		if err := store.ReleaseLease(ctx, conn.GameID, connID); err != nil {
+			log.Printf("ws-chat: release lease: %v", err)
+		}
+		if turnExpired {
+			if err := wsutil.Forward(ctx, "GAME_ACTION_ARN", connID, wsutil.SkipExpiredTurn); err != nil {
+				log.Printf("ws-chat: forward expired turn (non-fatal): %v", err)
+			}
+		}
+		if writeEpilogue {
+			if err := wsutil.Forward(ctx, "CHAT_ARN", connID, wsutil.WriteEpilogue); err != nil {
+				log.Printf("ws-chat: forward epilogue (non-fatal): %v", err)
+			}
+		}
+	}()
+
+	// Set up WebSocket sender early so we can send error frames during RBAC check
+	ws, err := wsutil.New(ctx)
+	if err != nil {
+		log.Printf("ws-chat: ws sender init: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+	}
+	// refuse reports why the chat was turned away, unless it was forwarded.
+	refuse := func(message string) {
+		if !msg.Forwarded {
+			_ = ws.SendError(ctx, connID, message)
+		}
+	}
+
+	// RBAC + quota check — must pass before loading game or calling Bedrock
+	userID := string(conn.UserID)
+	log.Printf("ws-chat: conn=%s user=%s game=%s", connID, userID, conn.GameID)
+	userRecord, err := store.GetUser(ctx, userID)
+	if err != nil {
+		log.Printf("ws-chat: GetUser error user=%s: %v", userID, err)
+		_ = ws.SendError(ctx, connID, "internal_error")
+		return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+	}
+	if userRecord == nil {
+		log.Printf("ws-chat: user record not found for user=%s — rejecting chat", userID)
+		refuse("user_not_found")
+		return events.APIGatewayProxyResponse{StatusCode: 403}, nil
+	}
+	if !userRecord.AIEnabled {
+		log.Printf("ws-chat: ai_access_not_enabled for user=%s role=%s", userID, userRecord.Role)
+		refuse("ai_access_not_enabled")
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	}
+	if userRecord.TokenLimit > 0 && userRecord.TokensUsed >= userRecord.TokenLimit {
+		refuse("quota_exceeded")
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	}
+
+	// Load game state
+	saveState, err := store.GetGame(ctx, conn.GameID)
+	if err != nil {
+		log.Printf("ws-chat: get game %s: %v", conn.GameID, err)
+		return events.APIGatewayProxyResponse{StatusCode: 404}, nil
+	}
+
+	g, err := game.FromSaveState(saveState)
+	if err != nil {
+		log.Printf("ws-chat: load game: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+	}
+
+	// Load D&D characters for this invocation (binds a fresh event bus)
+	if saveState.PlayersData != nil {
+		if _, loadErr := g.LoadDnDCharacters(ctx, saveState.PlayersData); loadErr != nil {
+			log.Printf("ws-chat: LoadDnDCharacters (non-fatal): %v", loadErr)
+		}
+	}
+	if trackErr := g.TrackEvents(saveState.Narrative, saveState.ChatHistory); trackErr != nil {
+		log.Printf("ws-chat: track events (non-fatal): %v", trackErr)
+	}
+	turnExpired = g.TurnExpired(time.Now())
+
+	// The epilogue is written once, and only for a run that is over.
+	if msg.Epilogue && (!g.DungeonOver() || g.DungeonData.EpilogueWritten) {
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	}
+
+	// Round mode: collect the intent, and only go on to narrate once the
+	// round is ready. A chat that cannot be an intent still resolves a round
+	// that has run out of time.
+	var intents []game.Intent
+	if g.RoundMode && !msg.Epilogue {
+		now := time.Now()
+		if msg.Private {
+			_ = ws.SendError(ctx, connID, "Private actions are not available in round mode.")
+			if !g.RoundExpired(now) {
+				return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+			}
+			msg.Private, msg.Resolve = false, true
+		}
+		if !msg.Resolve {
+			if err := g.SubmitIntent(userID, msg.Content, now); err != nil {
+				_ = ws.SendError(ctx, connID, err.Error())
+				if !g.RoundExpired(now) {
+					return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+				}
+			}
+		}
+		if !g.RoundReady(now) {
+			if msg.Resolve {
+				refuse(roundNotReady(g))
+				return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+			}
+			return saveIntent(ctx, store, ws, g, saveState, connID, userID, msg.Content)
+		}
+		// Tell the party a round that ran out of time goes ahead without
+		// the members still missing.
+		if view := g.ActionRoundView(); len(view.WaitingOn) > 0 {
+			view.TimedOut = true
+			_ = ws.BroadcastAndCleanStale(ctx, partyConnIDs(ctx, store, conn.GameID, connID), wsutil.Frame{Type: wsutil.FrameRoundUpdated, Payload: view}, store)
+		}
+		intents, _ = g.TakeRound()
+	} else if msg.Resolve {
+		refuse("Round mode is off.")
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	}
+	// actorID is who the Engineer treats as acting: the sender, or in a
+	// round the first member (in party order) who declared an intent.
+	input, actorID := msg.Content, userID
+	if intents != nil {
+		input, actorID = g.RoundInput(intents), intents[0].UserID
+	}
+
+	// Set up the AI client on the user's provider (or the deployment default)
+	aiClient, err := ai.NewFor(ctx, userRecord.AIProvider)
+	if err != nil {
+		log.Printf("ws-chat: ai init: %v", err)
+		_ = ws.SendError(ctx, connID, "AI unavailable")
+		return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+	}
+
+	// Capture pre-turn state for delta calculation
+	preTurnOwner, _ := g.OwnerCharacter()
+	preTurnPlayerLoc := preTurnOwner.LocationID
+
+	// Build connection ID list for broadcast (refreshed now the lease is held)
+	allGameConns, _ := store.GetConnectionsByGameID(ctx, conn.GameID)
+	allConnIDs := make([]string, 0, len(allGameConns))
+	for _, gc := range allGameConns {
+		allConnIDs = append(allConnIDs, gc.ConnectionID)
+	}
+	if len(allConnIDs) == 0 {
+		allConnIDs = []string{connID} // fallback to sender only
+	}
+	// A whisper's narration goes to the sender only.
+	narrationConnIDs := allConnIDs
+	privateTo := ""
+	if msg.Private {
+		narrationConnIDs = []string{connID}
+		privateTo = userID
+	}
+
+	// Step 1: Stream narrator prose — broadcast each chunk to the party (or
+	// just the sender, for a whisper).
+	onChunk := func(chunk string) {
+		payload := map[string]any{"content": chunk}
+		if msg.Private {
+			payload["private"] = true
+		}
+		chunkFrame := wsutil.Frame{Type: wsutil.FrameNarrativeChunk, Payload: payload}
+		stale, _ := ws.Broadcast(ctx, narrationConnIDs, chunkFrame)
+		for _, s := range stale {
+			_ = store.DeleteConnection(ctx, s)
+		}
+	}
+	var narratorResult ai.NarratorResult
+	switch {
+	case msg.Epilogue:
+		narratorResult, err = aiClient.NarrateEpilogue(ctx, g, saveState.Narrative, onChunk)
+	case intents != nil:
+		narratorResult, err = aiClient.NarrateRound(ctx, g, intents, saveState.Narrative, onChunk)
+	default:
+		narratorResult, err = aiClient.NarrateStream(ctx, g, userID, msg.Private, saveState.Narrative, msg.Content, onChunk)
+	}
+	if err != nil {
+		log.Printf("ws-chat: narrator error: %v", err)
+		_ = ws.SendError(ctx, connID, "Narrator error — please try again")
+		return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+	}
+
+	// Step 2: Signal streaming complete to everyone who got the prose.
+	staleEnds, _ := ws.Broadcast(ctx, narrationConnIDs, wsutil.Frame{Type: wsutil.FrameNarrativeEnd})
+	for _, s := range staleEnds {
+		_ = store.DeleteConnection(ctx, s)
+	}
+
+	// Step 3: Engineer infers world mutations from the narrative and executes them.
+	// Runs after narrative_end so the client never waits on the Engineer for prose.
+	var engineerResult ai.EngineerResult
+	if g.DungeonOver() {
+		log.Printf("ws-chat: dungeon %s — skipping engineer scan", g.DungeonData.State)
+		g.DungeonData.EpilogueWritten = true
+	} else {
+		engineerResult, err = aiClient.EngineerScan(ctx, g, actorID, narratorResult.Narrative)
+		if err != nil {
+			// Non-fatal: log the error but continue — game state may be partially mutated,
+			// but the narrative has already been delivered successfully.
+			log.Printf("ws-chat: engineer scan error: %v", err)
+		}
+	}
+	dungeonEnded := g.UpdateDungeonState()
+
+	// Append chat history — attach world events to the narrative message so they
+	// survive reconnection/reload.
+	history := saveState.ChatHistory
+	if !msg.Epilogue {
+		history = append(history, game.ChatMessage{Type: "player", Content: input, PrivateTo: privateTo})
+	}
+	history = append(history, game.ChatMessage{
+		Type:      "narrative",
+		Content:   narratorResult.Narrative,
+		Events:    engineerResult.Events,
+		PrivateTo: privateTo,
+	})
+	history = g.PageChat(history)
+
+	// Update stats (include both Narrator and Engineer token usage).
+	turn := g.ConversationCount // this turn's number; rewinding to it undoes the turn
+	g.ConversationCount++
+	g.TotalTokens += narratorResult.Tokens.Total() + engineerResult.Tokens.Total()
+
+	// Step 4: Persist updated game state with optimistic locking retry.
+	g.Version++
+	saved := g.ToSaveState(narratorResult.NewMessages, history)
+	if g.Events != nil {
+		turnEvent := game.MutationEntry{
+			Type:  game.EventNarratorTurn,
+			Actor: userID,
+			Turn:  turn,
+			Input: map[string]any{"content": input},
+		}
+		if msg.Private {
+			turnEvent.Input["private"] = true
+		}
+		if intents != nil {
+			turnEvent.Input["round"] = true
+		}
+		if msg.Epilogue {
+			turnEvent.Input["epilogue"] = true
+		}
+		if recErr := g.Events.Record(turnEvent, saved); recErr != nil {
+			log.Printf("ws-chat: record turn (non-fatal): %v", recErr)
+			g.Events = nil
+		}
+	}
+	for attempt := 0; attempt < 3; attempt++ {
+		if err := store.PutGame(ctx, saved); err != nil {
+			log.Printf("ws-chat: put game attempt %d: %v", attempt+1, err)
+			if attempt == 2 {
+				_ = ws.SendError(ctx, connID, "Failed to save game state")
+				return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+			}
+			// Reload and re-apply on conflict
+			fresh, loadErr := store.GetGame(ctx, conn.GameID)
+			if loadErr == nil {
+				saved.Version = fresh.Version + 1
+			}
+			// This save replaces whatever the other writer stored, so the turn's
+			// patches no longer chain from the saved state; close with a snapshot.
+			if g.Events != nil {
+				if snapErr := g.Events.Snapshot(game.MutationEntry{Type: game.EventSnapshot, Actor: userID, Turn: turn}, saved); snapErr != nil {
+					g.Events = nil
+				}
+			}
+			continue
+		}
+		break
+	}
+
+	// Step 5: Record the turn's events — best-effort, non-fatal. Without an
+	// event log fall back to the Engineer's plain audit entries.
+	if g.Events != nil {
+		db.PutEvents(ctx, store, g.Events, conn.GameID, saved.Version)
+	} else {
+		for _, m := range engineerResult.Mutations {
+			if err := store.PutMutation(ctx, m); err != nil {
+				log.Printf("ws-chat: put mutation (tool=%s): %v", m.Tool, err)
+			}
+		}
+	}
+
+	// Step 6: Account for token usage — best-effort, non-fatal. A round's
+	// tokens are shared between the members who declared intents.
+	totalTokenDelta := narratorResult.Tokens.Total() + engineerResult.Tokens.Total()
+	payers := []string{userID}
+	if intents != nil {
+		payers = payers[:0]
+		for _, in := range intents {
+			payers = append(payers, in.UserID)
+		}
+	}
+	for i, payer := range payers {
+		share := totalTokenDelta / len(payers)
+		if i == 0 {
+			share += totalTokenDelta % len(payers)
+		}
+		if accountErr := store.UpdateUserTokens(ctx, payer, share); accountErr != nil {
+			log.Printf("ws-chat: UpdateUserTokens (non-fatal): %v", accountErr)
+		}
+	}
+
+	// Step 7: Send per-member state delta — each party member gets their own
+	// perspective (their own character's location and inventory).
+	postTurnOwner, _ := g.OwnerCharacter()
+	postTurnOwnerLoc := postTurnOwner.LocationID
+	// Refresh connection list for delta fanout (some may have disconnected)
+	freshConns, _ := store.GetConnectionsByGameID(ctx, conn.GameID)
+	for _, gc := range freshConns {
+		memberUID := string(gc.UserID)
+		memberView := g.BuildGameStateView(memberUID, nil)
+		memberEvents := engineerResult.Events
+		if privateTo != "" && memberUID != privateTo {
+			memberEvents = nil
+		}
+		delta := game.StateDelta{
+			Events: memberEvents,
+			Player: &memberView.Player,
+			Self:   &memberView.Self,
+			// The narrator may have created or changed rooms; memberView.Rooms is
+			// already limited to what this member's fog-of-war allows.
+			UpdatedRooms: memberView.Rooms,
+			Monsters:     memberView.CurrentRoom.Monsters,
+		}
+		if postTurnOwnerLoc != preTurnPlayerLoc || true { // always send current room
+			delta.CurrentRoom = &memberView.CurrentRoom
+		}
+		if sendErr := ws.SendDelta(ctx, gc.ConnectionID, delta); sendErr != nil {
+			log.Printf("ws-chat: send delta to %s: %v", gc.ConnectionID, sendErr)
+			_ = store.DeleteConnection(ctx, gc.ConnectionID)
+		}
+	}
+
+	// The round is over; show the party an empty one.
+	if intents != nil {
+		roundFrame := wsutil.Frame{Type: wsutil.FrameRoundUpdated, Payload: g.ActionRoundView()}
+		_ = ws.BroadcastAndCleanStale(ctx, allConnIDs, roundFrame, store)
+	}
+
+	if dungeonEnded {
+		outcomeFrame := wsutil.Frame{Type: wsutil.FrameDungeonEnded, Payload: g.Outcome()}
+		stale, _ := ws.Broadcast(ctx, allConnIDs, outcomeFrame)
+		for _, s := range stale {
+			_ = store.DeleteConnection(ctx, s)
+		}
+		writeEpilogue = true
+	}
+
+	log.Printf("ws-chat: complete conn=%s user=%s game=%s turns=%d tokens=%d", connID, userID, conn.GameID, g.ConversationCount, g.TotalTokens)
+	return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+}
+
+// saveIntent persists an intent declared in round mode and shows the party
+// the updated round. The session's streaming lease is held, so no narrator
+// turn can save over it.
+func saveIntent(ctx context.Context, store db.Store, ws *wsutil.Sender, g *game.Game, saveState game.SaveState, connID, userID, content string) (events.APIGatewayProxyResponse, error) {
+	g.RecordEvent(game.MutationEntry{Type: game.EventIntent, Actor: userID, Input: map[string]any{"content": content}})
+	g.Version++
+	saved := g.ToSaveState(saveState.Narrative, saveState.ChatHistory)
+	if err := store.PutGame(ctx, saved); err != nil {
+		log.Printf("ws-chat: put intent: %v", err)
+		_ = ws.SendError(ctx, connID, "Failed to save game state")
+		return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+	}
+	db.PutEvents(ctx, store, g.Events, g.ID, saved.Version)
+
+	_ = ws.BroadcastAndCleanStale(ctx, partyConnIDs(ctx, store, g.ID, connID), wsutil.Frame{Type: wsutil.FrameRoundUpdated, Payload: g.ActionRoundView()}, store)
+	return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+}
+
+// partyConnIDs returns the connections open on the session, or just the
+// sender's if none are found.
+func partyConnIDs(ctx context.Context, store db.Store, gameID, connID string) []string {
+	conns, _ := store.GetConnectionsByGameID(ctx, gameID)
+	connIDs := make([]string, 0, len(conns))
+	for _, c := range conns {
+		connIDs = append(connIDs, c.ConnectionID)
+	}
+	if len(connIDs) == 0 {
+		connIDs = []string{connID}
+	}
+	return connIDs
+}
+
+// roundNotReady explains why a resolve request was refused.
+func roundNotReady(g *game.Game) string {
+	view := g.ActionRoundView()
+	if len(view.Intents) == 0 {
+		return "No one has declared an action this round yet."
+	}
+	wait := time.Until(time.UnixMilli(view.Deadline)).Round(time.Second)
+	return fmt.Sprintf("The round is still waiting on %s (it times out in %s).", strings.Join(view.WaitingOn, ", "), wait)
+}
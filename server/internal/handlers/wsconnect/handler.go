@@ -0,0 +1,109 @@
+// Package wsconnect handles the API Gateway WebSocket $connect route.
+// It verifies the Cognito JWT from the ?token= query param against the user
+// pool's JWKS, enforces one-connection-per-user, and writes a connection
+// record to DynamoDB.
+package wsconnect
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rrochlin/an-amazing-adventure/internal/auth"
+	"github.com/rrochlin/an-amazing-adventure/internal/db"
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+)
+
+// Handler is the Lambda entry point. It serves each invocation from DynamoDB
+// and verifies tokens against the user pool named by USER_POOL_ID and
+// USER_POOL_CLIENT_ID (JWKS_URL overrides where the keys are fetched from).
+func Handler(ctx context.Context, req events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	store, err := db.New(ctx)
+	if err != nil {
+		log.Printf("ws-connect: db init: %v", err)
+		return reject(500, "internal error"), nil
+	}
+	tokens, err := auth.VerifierFromEnv()
+	if err != nil {
+		log.Printf("ws-connect: token verifier init: %v", err)
+		return reject(500, "internal error"), nil
+	}
+	return handle(ctx, store, tokens, req)
+}
+
+// New returns a handler backed by store that accepts tokens verified by
+// tokens, so tests and cmd/local-server can run it against db.NewMemory and
+// their own key sets.
+func New(store db.Store, tokens auth.TokenVerifier) func(context.Context, events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return func(ctx context.Context, req events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return handle(ctx, store, tokens, req)
+	}
+}
+
+func handle(ctx context.Context, store db.Store, tokens auth.TokenVerifier, req events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	token := req.QueryStringParameters["token"]
+	gameID := req.QueryStringParameters["gameId"]
+	if token == "" {
+		return reject(401, "missing token"), nil
+	}
+	if gameID == "" {
+		return reject(400, "missing gameId"), nil
+	}
+
+	claims, err := tokens.Verify(ctx, token)
+	if err != nil {
+		log.Printf("ws-connect: invalid token: %v", err)
+		return reject(401, "invalid token"), nil
+	}
+	userID := claims.Sub
+
+	// Authorize: caller must be owner or a party member of the session.
+	saveState, err := store.GetGame(ctx, gameID)
+	if err != nil {
+		log.Printf("ws-connect: get game %s: %v", gameID, err)
+		return reject(404, "game not found"), nil
+	}
+	if !isAuthorizedForSession(saveState, userID) {
+		log.Printf("ws-connect: user %s not authorized for game %s", userID, gameID)
+		return reject(403, "forbidden"), nil
+	}
+
+	// Scoped cleanup: remove any stale connection for this (user, game) pair only.
+	// This allows a user to maintain connections to multiple different sessions.
+	if err := store.DeleteUserConnectionForGame(ctx, userID, gameID); err != nil {
+		log.Printf("ws-connect: cleanup stale connection (non-fatal): %v", err)
+	}
+
+	conn := db.Connection{
+		ConnectionID: req.RequestContext.ConnectionID,
+		UserID:       db.BinaryID(userID),
+		GameID:       gameID,
+		ExpiresAt:    time.Now().Add(24 * time.Hour).Unix(),
+	}
+	if err := store.PutConnection(ctx, conn); err != nil {
+		log.Printf("ws-connect: put connection: %v", err)
+		return reject(500, "internal error"), nil
+	}
+
+	log.Printf("ws-connect: user %s connected (%s), game %s", userID, req.RequestContext.ConnectionID, gameID)
+	return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+}
+
+// isAuthorizedForSession returns true if userID is the owner or a party member
+// of the given session.
+func isAuthorizedForSession(ss game.SaveState, userID string) bool {
+	if ss.UserID == userID || ss.OwnerID == userID {
+		return true
+	}
+	if ss.Players != nil {
+		if _, ok := ss.Players[userID]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func reject(code int, msg string) events.APIGatewayProxyResponse {
+	return events.APIGatewayProxyResponse{StatusCode: code, Body: msg}
+}
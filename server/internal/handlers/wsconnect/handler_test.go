@@ -0,0 +1,226 @@
+package wsconnect
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rrochlin/an-amazing-adventure/internal/auth"
+	"github.com/rrochlin/an-amazing-adventure/internal/db"
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+)
+
+// assertPanicsWithEnvAbsent runs fn with the given env var unset and asserts
+// that it panics with a message containing the var name. This catches missing
+// env var configuration before deployment.
+func assertPanicsWithEnvAbsent(t *testing.T, envVar string, fn func()) {
+	t.Helper()
+	t.Setenv(envVar, "") // unset for this test; restored after
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Errorf("expected panic for missing %s, but handler did not panic", envVar)
+			return
+		}
+		msg := ""
+		switch v := r.(type) {
+		case string:
+			msg = v
+		case error:
+			msg = v.Error()
+		}
+		if !strings.Contains(msg, envVar) {
+			t.Errorf("panic message %q does not mention %s", msg, envVar)
+		}
+	}()
+	fn()
+}
+
+func makeWSReq(connID string, queryParams map[string]string) events.APIGatewayWebsocketProxyRequest {
+	return events.APIGatewayWebsocketProxyRequest{
+		QueryStringParameters: queryParams,
+		RequestContext: events.APIGatewayWebsocketProxyRequestContext{
+			ConnectionID: connID,
+		},
+	}
+}
+
+// testPool points the verifier at a local JWKS endpoint and returns a
+// function that issues access tokens for sub signed by the pool's key.
+func testPool(t *testing.T) func(sub string) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(auth.EncodeJWKS(map[string]*rsa.PublicKey{"test-kid": &key.PublicKey}))
+	}))
+	t.Cleanup(jwks.Close)
+	t.Setenv("USER_POOL_ID", "us-west-2_test")
+	t.Setenv("USER_POOL_CLIENT_ID", "test-client")
+	t.Setenv("JWKS_URL", jwks.URL)
+
+	return func(sub string) string {
+		tok, err := auth.SignRS256(key, "test-kid", map[string]any{
+			"sub":       sub,
+			"iss":       "https://cognito-idp.us-west-2.amazonaws.com/us-west-2_test",
+			"token_use": "access",
+			"client_id": "test-client",
+			"exp":       time.Now().Add(time.Hour).Unix(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+}
+
+func TestHandlerConnect_MissingToken(t *testing.T) {
+	testPool(t)
+	req := makeWSReq("conn-1", map[string]string{})
+	resp, err := Handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != 401 {
+		t.Errorf("expected 401 for missing token, got %d", resp.StatusCode)
+	}
+}
+
+func TestHandlerConnect_MalformedToken(t *testing.T) {
+	testPool(t)
+	req := makeWSReq("conn-1", map[string]string{"token": "not-a-jwt", "gameId": "game-uuid"})
+	resp, err := Handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != 401 {
+		t.Errorf("expected 401 for malformed token, got %d", resp.StatusCode)
+	}
+}
+
+func TestHandlerConnect_ExpiredToken(t *testing.T) {
+	testPool(t)
+	// A real JWT structure but with exp in the past
+	// Header: {"alg":"HS256","typ":"JWT"}
+	// Payload: {"sub":"user-123","exp":1000000000}  (year 2001 - definitely expired)
+	expiredToken := "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9.eyJzdWIiOiJ1c2VyLTEyMyIsImV4cCI6MTAwMDAwMDAwMH0.signature"
+	req := makeWSReq("conn-1", map[string]string{"token": expiredToken, "gameId": "game-uuid"})
+	resp, err := Handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != 401 {
+		t.Errorf("expected 401 for expired token, got %d", resp.StatusCode)
+	}
+}
+
+// TestHandlerConnect_UnsignedTokenRejected covers the forgery ws-connect used
+// to accept: a well-formed, unexpired token whose signature is never checked.
+func TestHandlerConnect_UnsignedTokenRejected(t *testing.T) {
+	testPool(t)
+	// Payload: {"sub":"user-abc","exp":9999999999}
+	forged := "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9.eyJzdWIiOiJ1c2VyLWFiYyIsImV4cCI6OTk5OTk5OTk5OX0.signature"
+	req := makeWSReq("conn-1", map[string]string{"token": forged, "gameId": "game-uuid"})
+	resp, err := Handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != 401 {
+		t.Errorf("expected 401 for an unsigned token, got %d", resp.StatusCode)
+	}
+}
+
+func TestHandlerConnect_MissingPoolConfig(t *testing.T) {
+	t.Setenv("USER_POOL_ID", "")
+	t.Setenv("USER_POOL_CLIENT_ID", "")
+	req := makeWSReq("conn-1", map[string]string{"token": "a.b.c", "gameId": "game-uuid"})
+	resp, err := Handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != 500 {
+		t.Errorf("expected 500 without a user pool to verify against, got %d", resp.StatusCode)
+	}
+}
+
+func TestHandlerConnect_ValidToken_ReachesDB(t *testing.T) {
+	sign := testPool(t)
+	t.Setenv("CONNECTIONS_TABLE", "test-connections")
+	t.Setenv("SESSIONS_TABLE", "test-sessions")
+	req := makeWSReq("conn-123", map[string]string{
+		"token":  sign("user-abc"),
+		"gameId": "game-uuid",
+	})
+	resp, err := Handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected lambda error: %v", err)
+	}
+	// Token verifies; will fail at DynamoDB with no real credentials
+	// Should be 404/500 (DB failure), not 401 (auth failure)
+	if resp.StatusCode == 401 {
+		t.Errorf("expected to pass JWT verification, got 401")
+	}
+}
+
+// ---- Required env var tests ----
+// ws-connect now reads the sessions table (for auth) before writing to connections.
+// Both SESSIONS_TABLE and CONNECTIONS_TABLE must be set.
+
+func TestHandlerConnect_MissingSESSIONS_TABLE_Panics(t *testing.T) {
+	sign := testPool(t)
+	t.Setenv("CONNECTIONS_TABLE", "test-connections")
+	req := makeWSReq("conn-1", map[string]string{"token": sign("user-abc"), "gameId": "g"})
+	assertPanicsWithEnvAbsent(t, "SESSIONS_TABLE", func() {
+		Handler(context.Background(), req) //nolint:errcheck
+	})
+}
+
+// TestHandlerConnect_MissingGameId verifies we reject connections without a gameId.
+func TestHandlerConnect_MissingGameId(t *testing.T) {
+	sign := testPool(t)
+	req := makeWSReq("conn-1", map[string]string{"token": sign("user-abc")}) // no gameId
+	resp, err := Handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != 400 {
+		t.Errorf("expected 400 for missing gameId, got %d", resp.StatusCode)
+	}
+}
+
+// ---- Memory store ----
+
+func TestConnect_VerifiedSubMustBelongToSession(t *testing.T) {
+	sign := testPool(t)
+	tokens, err := auth.VerifierFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	store := db.NewMemory()
+	if err := store.PutGame(ctx, game.SaveState{SessionID: "g1", UserID: "owner", OwnerID: "owner"}); err != nil {
+		t.Fatal(err)
+	}
+	h := New(store, tokens)
+
+	resp, _ := h(ctx, makeWSReq("c-owner", map[string]string{"token": sign("owner"), "gameId": "g1"}))
+	if resp.StatusCode != 200 {
+		t.Fatalf("owner connect = %d %s", resp.StatusCode, resp.Body)
+	}
+	if conn, err := store.GetConnection(ctx, "c-owner"); err != nil || string(conn.UserID) != "owner" {
+		t.Errorf("connection record = %+v, %v", conn, err)
+	}
+
+	resp, _ = h(ctx, makeWSReq("c-stranger", map[string]string{"token": sign("stranger"), "gameId": "g1"}))
+	if resp.StatusCode != 403 {
+		t.Errorf("stranger connect = %d, want 403", resp.StatusCode)
+	}
+}
@@ -0,0 +1,41 @@
+// Package wsdisconnect handles the API Gateway WebSocket $disconnect route.
+// It deletes the connection record from DynamoDB.
+package wsdisconnect
+
+import (
+	"context"
+	"log"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rrochlin/an-amazing-adventure/internal/db"
+)
+
+// Handler is the Lambda entry point. It serves each invocation from DynamoDB.
+func Handler(ctx context.Context, req events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	store, err := db.New(ctx)
+	if err != nil {
+		log.Printf("ws-disconnect: db init: %v", err)
+		// Always return 200 — API GW ignores disconnect errors
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	}
+	return handle(ctx, store, req)
+}
+
+// New returns a handler backed by store, so tests and cmd/local-server can run
+// it against db.NewMemory.
+func New(store db.Store) func(context.Context, events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return func(ctx context.Context, req events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return handle(ctx, store, req)
+	}
+}
+
+func handle(ctx context.Context, store db.Store, req events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	connID := req.RequestContext.ConnectionID
+	log.Printf("ws-disconnect: %s", connID)
+
+	if err := store.DeleteConnection(ctx, connID); err != nil {
+		log.Printf("ws-disconnect: delete connection %s: %v", connID, err)
+	}
+
+	return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+}
@@ -0,0 +1,991 @@
+// Package wsgameaction handles direct player actions that mutate game state without AI:
+// move, step, pick_up, drop, equip, unequip, attack, cast, skip, level_up.
+// The session owner can also rewind to an earlier narrator turn and turn
+// round mode on or off (see package wschat).
+//
+// During combat the initiative order is enforced: members in the fight may
+// only move, step, pick up, drop, attack, or cast on their own turn. Stepping across
+// the room's hex grid spends movement; everything else ends the turn.
+// Monsters act at their own initiative slots as the pointer passes them.
+// Members who walk into a fight under way roll initiative and get a slot. A
+// turn left idle past combat.TurnTimeout is skipped when the next frame from
+// anyone in the party arrives (ws-chat and ws-ooc forward a skip here). An
+// action round past game.RoundTimeout is handed to ws-chat the same way, as
+// is the epilogue of a run an action clears or fails.
+// Defeated monsters award XP by challenge rating to the members in the room.
+package wsgameaction
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	rpgevents "github.com/KirkDiggler/rpg-toolkit/events"
+	dnd5echar "github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/character"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/monster"
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/monster/actions"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rrochlin/an-amazing-adventure/internal/combat"
+	"github.com/rrochlin/an-amazing-adventure/internal/db"
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+	"github.com/rrochlin/an-amazing-adventure/internal/timeline"
+	"github.com/rrochlin/an-amazing-adventure/internal/wsutil"
+)
+
+// actionLease is how long a world-changing action holds the session's lease.
+// It matches the Lambda's 10s timeout, so an action that died without
+// releasing it blocks the party for no longer than the action could have run.
+const actionLease = 10 * time.Second
+
+// actionHolderSuffix marks the lease holder as this route rather than a
+// narrator turn on the same connection, which must not be able to re-enter it.
+const actionHolderSuffix = "/game_action"
+
+type actionRequest struct {
+	Action    string `json:"action"`
+	SubAction string `json:"sub_action"` // "move" | "pick_up" | "drop" | "equip" | "unequip" | "attack" | "cast" | "skip" | "step" | "level_up" | "rewind" | "round_mode"
+	Payload   string `json:"payload"`    // direction, item name, target monster ID, (cast) target ID, (level_up) JSON choices, (rewind) turn number, or (round_mode) "on"/"off"
+	// WeaponID is optional — used only for "attack" sub_action.
+	// If empty the character's equipped main-hand weapon is used.
+	WeaponID string `json:"weapon_id,omitempty"`
+	// SpellID is required for "cast" (kebab-case, e.g. "fire-bolt").
+	// SlotLevel optionally upcasts; 0 spends the lowest available slot.
+	SpellID   string `json:"spell_id,omitempty"`
+	SlotLevel int    `json:"slot_level,omitempty"`
+	// Forwarded marks the "skip" (wsutil.SkipExpiredTurn) sent on the sender's
+	// behalf when their frame arrives after the current turn's deadline. A
+	// refusal (the turn already moved on, or a narrator turn holds the
+	// lease) is not reported to the sender, who never asked to skip.
+	Forwarded bool `json:"forwarded,omitempty"`
+}
+
+// Handler is the Lambda entry point. It serves each invocation from DynamoDB.
+func Handler(ctx context.Context, req events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	store, err := db.New(ctx)
+	if err != nil {
+		log.Printf("ws-game-action: db init conn=%s: %v", req.RequestContext.ConnectionID, err)
+		return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+	}
+	return handle(ctx, store, req)
+}
+
+// New returns a handler backed by store, so tests and cmd/local-server can run
+// it against db.NewMemory.
+func New(store db.Store) func(context.Context, events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return func(ctx context.Context, req events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return handle(ctx, store, req)
+	}
+}
+
+func handle(ctx context.Context, store db.Store, req events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	connID := req.RequestContext.ConnectionID
+	reqID := req.RequestContext.RequestID
+
+	var msg actionRequest
+	if err := json.Unmarshal([]byte(req.Body), &msg); err != nil {
+		log.Printf("ws-game-action: bad body conn=%s: %v", connID, err)
+		return events.APIGatewayProxyResponse{StatusCode: 400}, nil
+	}
+
+	log.Printf("ws-game-action: conn=%s req=%s action=%s payload=%q", connID, reqID, msg.SubAction, msg.Payload)
+
+	conn, err := store.GetConnection(ctx, connID)
+	if err != nil {
+		log.Printf("ws-game-action: get connection conn=%s: %v", connID, err)
+		return events.APIGatewayProxyResponse{StatusCode: 410}, nil
+	}
+	userID := string(conn.UserID)
+
+	// A timed-out action round is resolved by ws-chat, and the epilogue of a
+	// run this action ended is written there, once this action has released
+	// the lease (deferred first, so it runs last).
+	var chatFollowUp string
+	defer func() {
+		if chatFollowUp != "" {
+			if err := wsutil.Forward(ctx, "CHAT_ARN", connID, chatFollowUp); err != nil {
+				log.Printf("ws-game-action: forward to ws-chat (non-fatal): %v", err)
+			}
+		}
+	}()
+
+	// Actions wait while a narrator turn holds the session's streaming lease;
+	// the turn's save would otherwise race this one. A world-changing action
+	// holds the lease itself until it has saved, so a narrator turn cannot
+	// start on the world it is about to change. Rewind checks the lease on
+	// its own (see timeline.Rewind).
+	holder := connID + actionHolderSuffix
+	holding := false
+	takeLease := func() (blocked bool, err error) {
+		err = store.AcquireLease(ctx, db.Lease{
+			SessionID: db.BinaryID(conn.GameID),
+			Holder:    holder,
+			UserID:    conn.UserID,
+			ExpiresAt: time.Now().Add(actionLease).Unix(),
+		})
+		switch {
+		case errors.Is(err, db.ErrLeaseHeld):
+			return true, nil
+		case err != nil:
+			return false, err
+		}
+		holding = true
+		return false, nil
+	}
+	defer func() {
+		if !holding {
+			return
+		}
+		if err := store.ReleaseLease(ctx, conn.GameID, holder); err != nil {
+			log.Printf("ws-game-action: release lease: %v", err)
+		}
+	}()
+	blocked := false
+	if changesWorld(msg.SubAction) && msg.SubAction != "rewind" {
+		if blocked, err = takeLease(); err != nil {
+			log.Printf("ws-game-action: acquire lease: %v", err)
+			return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+		}
+	} else {
+		lease, err := store.GetLease(ctx, conn.GameID)
+		if err != nil {
+			log.Printf("ws-game-action: get lease: %v", err)
+			return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+		}
+		blocked = lease != nil
+	}
+	if blocked {
+		if msg.Forwarded {
+			return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+		}
+		ws, _ := wsutil.New(ctx)
+		_ = ws.Send(ctx, connID, wsutil.Frame{Type: wsutil.FrameStreamingBlocked})
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	}
+
+	if msg.SubAction == "rewind" {
+		return handleRewind(ctx, store, conn.GameID, connID, userID, msg.Payload)
+	}
+
+	saveState, err := store.GetGame(ctx, conn.GameID)
+	if err != nil {
+		return events.APIGatewayProxyResponse{StatusCode: 404}, nil
+	}
+
+	g, err := game.FromSaveState(saveState)
+	if err != nil {
+		return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+	}
+
+	// Load D&D characters for this invocation (binds a fresh event bus)
+	if saveState.PlayersData != nil {
+		if _, loadErr := g.LoadDnDCharacters(ctx, saveState.PlayersData); loadErr != nil {
+			log.Printf("ws-game-action: LoadDnDCharacters (non-fatal): %v", loadErr)
+		}
+	}
+	if trackErr := g.TrackEvents(saveState.Narrative, saveState.ChatHistory); trackErr != nil {
+		log.Printf("ws-game-action: track events (non-fatal): %v", trackErr)
+	}
+
+	ws, err := wsutil.New(ctx)
+	if err != nil {
+		return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+	}
+
+	// A finished run is frozen: only changes to the caller's own gear remain allowed.
+	if g.DungeonOver() && changesWorld(msg.SubAction) {
+		_ = ws.SendError(ctx, connID, fmt.Sprintf("The adventure is over (%s).", g.DungeonData.State))
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	}
+
+	// A turn left idle past its deadline is skipped by the first frame anyone
+	// else in the party sends; this frame is then handled on the new turn and
+	// saved with the skip. The skip changes the world, so an action that does
+	// not hold the lease takes it here; if a narrator turn has it, the idle
+	// turn is left for a later frame.
+	turnBefore := g.TurnView()
+	now := time.Now()
+	skipped := false
+	if cur, ok := g.CurrentTurn(); ok && cur.CombatantID != userID && msg.SubAction != "skip" && g.TurnExpired(now) {
+		if !holding {
+			if _, err := takeLease(); err != nil {
+				log.Printf("ws-game-action: acquire lease: %v", err)
+				return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+			}
+		}
+		if holding {
+			log.Printf("ws-game-action: %s skipped timed-out turn of %s", userID, cur.CombatantName)
+			if advErr := advanceCombat(ctx, g, now); advErr != nil {
+				log.Printf("ws-game-action: advance combat (non-fatal): %v", advErr)
+			}
+			g.RecordEvent(game.MutationEntry{Type: game.EventType("skip"), Actor: userID, Input: map[string]any{"payload": ""}})
+			skipped = true
+		}
+	}
+	if !msg.Forwarded && g.RoundExpired(now) {
+		chatFollowUp = wsutil.ResolveExpiredRound
+	}
+
+	// Enforce initiative: in a fight, turn-taking actions wait for your slot.
+	// Someone standing in the fight's room without one joins it first. The
+	// unconscious and the dead take no actions of their own.
+	joinFight(g, userID)
+	var actionErr error
+	if onTurnOnly(msg.SubAction) {
+		if actionErr = g.CheckTurn(userID); actionErr == nil {
+			actionErr = g.CheckConscious(userID)
+		}
+	}
+
+	// Execute the action
+	var notice *moveNotice
+	if actionErr == nil {
+		notice, actionErr = runAction(ctx, g, userID, msg, now)
+	}
+	if actionErr != nil {
+		log.Printf("ws-game-action: %s: %v", msg.SubAction, actionErr)
+		if !msg.Forwarded {
+			_ = ws.SendError(ctx, connID, actionErr.Error())
+		}
+		// A refused action still saves the idle turn it skipped.
+		if !skipped {
+			return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+		}
+	}
+
+	// The acting combatant's turn is over — run monsters up to the next player.
+	if actionErr == nil && takesTurn(msg.SubAction) && g.CombatActive() {
+		if cur, ok := g.CurrentTurn(); ok && (cur.CombatantID == userID || msg.SubAction == "skip") {
+			if advErr := advanceCombat(ctx, g, now); advErr != nil {
+				log.Printf("ws-game-action: advance combat (non-fatal): %v", advErr)
+			}
+		}
+	}
+
+	dungeonEnded := g.UpdateDungeonState()
+	if actionErr == nil {
+		g.RecordEvent(game.MutationEntry{
+			Type:  game.EventType(msg.SubAction),
+			Actor: userID,
+			Input: actionInput(msg),
+		})
+	}
+
+	// Persist
+	g.Version++
+	saved := g.ToSaveState(saveState.Narrative, saveState.ChatHistory)
+	if err := store.PutGame(ctx, saved); err != nil {
+		log.Printf("ws-game-action: put game: %v", err)
+		_ = ws.SendError(ctx, connID, "Failed to save game state")
+		return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+	}
+	db.PutEvents(ctx, store, g.Events, conn.GameID, saved.Version)
+
+	// Broadcast per-member state update to all connected party members
+	allConns, _ := store.GetConnectionsByGameID(ctx, conn.GameID)
+	if len(allConns) == 0 {
+		// Fallback: send only to the requesting connection
+		stateView := g.BuildGameStateView(userID, saveState.ChatHistory)
+		_ = ws.SendFullState(ctx, connID, stateView)
+	} else {
+		for _, gc := range allConns {
+			memberUID := string(gc.UserID)
+			memberView := g.BuildGameStateView(memberUID, saveState.ChatHistory)
+			if sendErr := ws.SendFullState(ctx, gc.ConnectionID, memberView); sendErr != nil {
+				log.Printf("ws-game-action: send state to %s: %v", gc.ConnectionID, sendErr)
+				_ = store.DeleteConnection(ctx, gc.ConnectionID)
+			}
+		}
+	}
+
+	targets := []string{connID}
+	if len(allConns) > 0 {
+		targets = targets[:0]
+		for _, gc := range allConns {
+			targets = append(targets, gc.ConnectionID)
+		}
+	}
+
+	if turnAfter := g.TurnView(); turnChanged(turnBefore, turnAfter) {
+		payload := turnAfter
+		if payload == nil {
+			payload = &game.TurnView{Active: false}
+		}
+		stale, _ := ws.Broadcast(ctx, targets, wsutil.Frame{Type: wsutil.FrameTurnChanged, Payload: payload})
+		for _, s := range stale {
+			_ = store.DeleteConnection(ctx, s)
+		}
+	}
+
+	if dungeonEnded {
+		stale, _ := ws.Broadcast(ctx, targets, wsutil.Frame{Type: wsutil.FrameDungeonEnded, Payload: g.Outcome()})
+		for _, s := range stale {
+			_ = store.DeleteConnection(ctx, s)
+		}
+		chatFollowUp = wsutil.WriteEpilogue
+	}
+
+	// Tell the members on either side of the door that someone came or went.
+	if notice != nil {
+		for _, gc := range allConns {
+			ev := notice.eventFor(g, string(gc.UserID))
+			if ev == nil {
+				continue
+			}
+			delta := game.StateDelta{Events: []game.WorldEvent{*ev}}
+			if sendErr := ws.SendDelta(ctx, gc.ConnectionID, delta); sendErr != nil {
+				log.Printf("ws-game-action: send move event to %s: %v", gc.ConnectionID, sendErr)
+			}
+		}
+	}
+
+	return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+}
+
+// runAction applies one sub_action to g for userID. A move returns the notice
+// for the members on either side of the door.
+func runAction(ctx context.Context, g *game.Game, userID string, msg actionRequest, now time.Time) (*moveNotice, error) {
+	var actionErr error
+	var notice *moveNotice
+	switch msg.SubAction {
+	case "move":
+		notice, actionErr = handleMove(g, userID, msg.Payload)
+		if actionErr == nil {
+			joinFight(g, userID)
+		}
+	case "pick_up":
+		item, findErr := g.GetItemByName(msg.Payload)
+		if findErr != nil {
+			actionErr = findErr
+			break
+		}
+		player, _ := g.GetPlayerCharacter(userID)
+		currentRoom, roomErr := g.GetRoom(player.LocationID)
+		if roomErr != nil {
+			actionErr = roomErr
+			break
+		}
+		if !currentRoom.HasItem(item.ID) {
+			actionErr = fmt.Errorf("item %q is not in this room", msg.Payload)
+			break
+		}
+		_ = currentRoom.RemoveItemID(item.ID)
+		g.UpdateRoom(currentRoom)
+		actionErr = g.GiveItemToCharacter(item.ID, userID)
+	case "drop":
+		item, findErr := g.GetItemByName(msg.Payload)
+		if findErr != nil {
+			actionErr = findErr
+			break
+		}
+		player, _ := g.GetPlayerCharacter(userID)
+		if !player.HasItem(item.ID) {
+			actionErr = fmt.Errorf("you don't have %q", msg.Payload)
+			break
+		}
+		room, roomErr := g.GetRoom(player.LocationID)
+		if roomErr != nil {
+			actionErr = roomErr
+			break
+		}
+		actionErr = g.TakeItemFromPlayer(item.ID, room.ID)
+	case "equip":
+		item, findErr := g.GetItemByName(msg.Payload)
+		if findErr != nil {
+			actionErr = findErr
+			break
+		}
+		player, _ := g.GetPlayerCharacter(userID)
+		if equipErr := player.EquipItem(item); equipErr != nil {
+			actionErr = equipErr
+			break
+		}
+		g.SetPlayerCharacter(userID, player)
+	case "unequip":
+		// Payload is the slot name (e.g. "head", "chest")
+		slot := game.EquipmentSlot(msg.Payload)
+		player, _ := g.GetPlayerCharacter(userID)
+		if _, unequipErr := player.UnequipItem(slot); unequipErr != nil {
+			actionErr = unequipErr
+			break
+		}
+		g.SetPlayerCharacter(userID, player)
+	case "attack":
+		// Payload is the target monster ID. WeaponID is optional.
+		actionErr = handleAttack(ctx, g, userID, msg.Payload, msg.WeaponID, now)
+	case "cast":
+		// Payload is the target: a monster ID, or a party member's userID for
+		// healing (empty heals yourself).
+		actionErr = handleCast(ctx, g, userID, msg.SpellID, msg.Payload, msg.SlotLevel, now)
+	case "stabilize":
+		// Payload is the dying party member's userID.
+		actionErr = handleStabilize(ctx, g, userID, msg.Payload)
+	case "skip":
+		actionErr = handleSkip(g, userID, now)
+	case "step":
+		// Payload is the destination hex as "x,y".
+		actionErr = handleStep(g, userID, msg.Payload)
+	case "level_up":
+		// Payload is an optional JSON game.LevelUpRequest; empty takes average HP.
+		actionErr = handleLevelUp(ctx, g, userID, msg.Payload)
+	case "round_mode":
+		// Payload is "on" or "off"; owner only.
+		actionErr = handleRoundMode(g, userID, msg.Payload)
+	default:
+		actionErr = fmt.Errorf("unknown sub_action: %s", msg.SubAction)
+	}
+	return notice, actionErr
+}
+
+// handleRewind restores the session to how it was before the narrator turn
+// named by payload and sends every member the restored state. Owner only.
+func handleRewind(ctx context.Context, store db.Store, sessionID, connID, userID, payload string) (events.APIGatewayProxyResponse, error) {
+	ws, err := wsutil.New(ctx)
+	if err != nil {
+		return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+	}
+	turn, err := strconv.Atoi(strings.TrimSpace(payload))
+	if err != nil {
+		_ = ws.SendError(ctx, connID, "rewind needs a turn number")
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	}
+	res, err := timeline.Rewind(ctx, store, sessionID, userID, turn)
+	switch {
+	case errors.Is(err, game.ErrNoSuchTurn):
+		_ = ws.SendError(ctx, connID, "There is no record of that turn.")
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	case errors.Is(err, game.ErrNoSnapshot):
+		_ = ws.SendError(ctx, connID, "This adventure has no history to rewind.")
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	case errors.Is(err, timeline.ErrNotOwner), errors.Is(err, timeline.ErrBadTurn), errors.Is(err, timeline.ErrTurnInProgress):
+		_ = ws.SendError(ctx, connID, err.Error())
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	case err != nil:
+		log.Printf("ws-game-action: rewind %s to turn %d: %v", sessionID, turn, err)
+		_ = ws.SendError(ctx, connID, "Failed to rewind")
+		return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+	}
+	timeline.PushState(ctx, store, res.Game, res.State.ChatHistory)
+	return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+}
+
+// actionInput is the request as recorded on the action's event.
+func actionInput(msg actionRequest) map[string]any {
+	input := map[string]any{"payload": msg.Payload}
+	if msg.WeaponID != "" {
+		input["weapon_id"] = msg.WeaponID
+	}
+	if msg.SpellID != "" {
+		input["spell_id"] = msg.SpellID
+	}
+	if msg.SlotLevel != 0 {
+		input["slot_level"] = msg.SlotLevel
+	}
+	return input
+}
+
+// changesWorld reports whether a sub_action alters the shared world (as
+// opposed to the caller's own equipment). Such actions are refused once the
+// dungeon has been cleared or failed.
+func changesWorld(subAction string) bool {
+	switch subAction {
+	case "equip", "unequip", "level_up":
+		return false
+	default:
+		return true
+	}
+}
+
+// takesTurn reports whether a sub_action uses a combatant's turn. Equipment
+// changes are free; everything that acts on the world ends your turn.
+func takesTurn(subAction string) bool {
+	switch subAction {
+	case "move", "pick_up", "drop", "attack", "cast", "stabilize", "skip":
+		return true
+	default:
+		return false
+	}
+}
+
+// onTurnOnly reports whether a sub_action may only be used on the caller's own
+// turn while they are in a fight.
+func onTurnOnly(subAction string) bool {
+	return subAction == "step" || (takesTurn(subAction) && subAction != "skip")
+}
+
+// turnChanged reports whether the initiative pointer moved between two snapshots.
+func turnChanged(before, after *game.TurnView) bool {
+	if before == nil || after == nil {
+		return before != after
+	}
+	return before.Round != after.Round || before.CombatantID != after.CombatantID
+}
+
+// handleRoundMode turns round mode on or off for the session.
+func handleRoundMode(g *game.Game, userID, payload string) error {
+	switch strings.TrimSpace(payload) {
+	case "on":
+		return g.SetRoundMode(userID, true)
+	case "off":
+		return g.SetRoundMode(userID, false)
+	default:
+		return fmt.Errorf("round_mode needs \"on\" or \"off\"")
+	}
+}
+
+// handleSkip ends the current player's turn without acting. Players may
+// always skip their own turn; anyone in the party may skip a turn that has
+// run past combat.TurnTimeout (an AFK member), which the server also does
+// itself on the next frame anyone sends.
+func handleSkip(g *game.Game, userID string, now time.Time) error {
+	cur, ok := g.CurrentTurn()
+	if !ok {
+		return fmt.Errorf("skip: no fight in progress")
+	}
+	if cur.CombatantID == userID {
+		return nil
+	}
+	if !g.TurnExpired(now) {
+		return fmt.Errorf("skip: it's %s's turn — it can be skipped after %s", cur.CombatantName, g.Turn.Deadline().Format(time.Kitchen))
+	}
+	log.Printf("ws-game-action: %s skipped timed-out turn of %s", userID, cur.CombatantName)
+	return nil
+}
+
+// joinFight gives userID a slot in the fight under way if they are standing
+// in its room without one, rolling their initiative as the fight's opening
+// roll would have.
+func joinFight(g *game.Game, userID string) {
+	if !g.CombatActive() || g.InCombatOrder(userID) {
+		return
+	}
+	dndChar, hasDnD := g.GetDnDCharacter(userID)
+	if !hasDnD || dndChar == nil {
+		return
+	}
+	roll := combat.RollInitiative(map[string]*dnd5echar.Character{userID: dndChar}, nil)
+	if len(roll) == 1 && g.JoinCombat(roll[0]) {
+		log.Printf("ws-game-action: %s joined the fight in %s (initiative %d)", userID, g.Turn.RoomID, roll[0].Roll)
+	}
+}
+
+// advanceCombat ends the current turn and runs monster turns at their own
+// initiative slots until a player is up or the fight is over. Dying players
+// roll their death saves as their turns come round. Monster turn results and
+// death saves are appended to PendingCombatContext for the Narrator.
+func advanceCombat(ctx context.Context, g *game.Game, now time.Time) error {
+	var enc *combat.Encounter
+	var roomID string
+	var monsterData []*monster.Data
+	defer func() {
+		if enc != nil {
+			g.SetRoomMonsters(roomID, encounterMonsterData(monsterData, enc))
+			g.SaveRoomGrid(enc.Grid) // monsters may have moved
+			enc.Cleanup(ctx)
+		}
+	}()
+	for {
+		entry, ok := g.AdvanceTurn(now)
+		if !ok {
+			return nil
+		}
+		if entry.IsPlayer {
+			// A dying player's turn is spent on a death save.
+			if g.PlayerVital(entry.CombatantID) != combat.VitalDying {
+				return nil
+			}
+			saveLog, err := g.DeathSave(ctx, entry.CombatantID, nil)
+			if err != nil {
+				return fmt.Errorf("death save: %w", err)
+			}
+			g.AppendCombatContext(saveLog)
+			if g.PlayerVital(entry.CombatantID) == combat.VitalConscious {
+				return nil // a natural 20: back up in time to act this turn
+			}
+			continue
+		}
+		if enc == nil {
+			roomID = g.Turn.RoomID
+			var err error
+			enc, monsterData, err = buildRoomEncounter(ctx, g, roomID, "")
+			if err != nil {
+				return fmt.Errorf("monster turn: %w", err)
+			}
+		}
+		turnLog, err := combat.RunMonsterTurn(ctx, enc, entry.CombatantID)
+		if err != nil {
+			turnLog = fmt.Sprintf("%s's turn: (error: %v)", entry.CombatantName, err)
+		}
+		g.AppendCombatContext(turnLog)
+	}
+}
+
+// buildRoomEncounter loads the persisted monsters of roomID into live
+// monsters and builds an Encounter with every DnD player standing in the
+// room. includeUserID, if set, is always added (e.g. the attacker). The
+// original monster data list is returned for encounterMonsterData. A room
+// without monsters yields an empty encounter (healing spells still work).
+func buildRoomEncounter(ctx context.Context, g *game.Game, roomID, includeUserID string) (*combat.Encounter, []*monster.Data, error) {
+	monsterDataList := g.GetRoomMonsters(roomID)
+
+	// Rebuild live *monster.Monster objects from persisted data.
+	// Use a temporary bus — the Encounter builds its own canonical bus below.
+	tempBus := rpgevents.NewEventBus()
+	liveMonsters := make([]*monster.Monster, 0, len(monsterDataList))
+	for _, data := range monsterDataList {
+		if data == nil {
+			continue
+		}
+		m, err := monster.LoadFromData(ctx, data, tempBus)
+		if err != nil {
+			log.Printf("buildRoomEncounter: skip monster %s (load error: %v)", data.ID, err)
+			continue
+		}
+		if err := actions.LoadMonsterActions(m, data.Actions); err != nil {
+			log.Printf("buildRoomEncounter: skip monster %s (action load error: %v)", data.ID, err)
+			continue
+		}
+		liveMonsters = append(liveMonsters, m)
+	}
+
+	// Build encounter player map: all DnD players currently in this room
+	encounterPlayers := make(map[string]*dnd5echar.Character)
+	for uid, char := range g.Players {
+		if char.LocationID == roomID {
+			if c, hasDnD := g.GetDnDCharacter(uid); hasDnD && c != nil {
+				encounterPlayers[uid] = c
+			}
+		}
+	}
+	if includeUserID != "" {
+		if c, hasDnD := g.GetDnDCharacter(includeUserID); hasDnD && c != nil {
+			encounterPlayers[includeUserID] = c
+		}
+	}
+
+	enc, err := combat.NewEncounter(ctx, encounterPlayers, liveMonsters)
+	if err != nil {
+		return nil, nil, fmt.Errorf("build encounter: %w", err)
+	}
+	enc.Grid = g.RoomGrid(roomID)
+	enc.PlayerBus = g.Bus
+	return enc, monsterDataList, nil
+}
+
+// encounterMonsterData rebuilds the room's monster data list from the
+// encounter (which has current HP after combat), keeping the original order
+// and any monsters that could not be loaded.
+func encounterMonsterData(orig []*monster.Data, enc *combat.Encounter) []*monster.Data {
+	updated := make([]*monster.Data, 0, len(orig))
+	for _, d := range orig {
+		if d == nil {
+			continue
+		}
+		if m, inEnc := enc.Monsters[d.ID]; inEnc {
+			updated = append(updated, m.ToData())
+		} else {
+			updated = append(updated, d)
+		}
+	}
+	return updated
+}
+
+// moveNotice records a completed party-member move so the fanout can notify
+// the other members standing in the rooms on either side of the exit.
+type moveNotice struct {
+	UserID     string
+	Name       string
+	Direction  string
+	FromRoomID string
+	ToRoomID   string
+}
+
+// eventFor returns the WorldEvent a given party member should see for this
+// move, or nil when they are the mover or in neither affected room.
+func (n *moveNotice) eventFor(g *game.Game, memberUID string) *game.WorldEvent {
+	if memberUID == n.UserID {
+		return nil
+	}
+	member, ok := g.GetPlayerCharacter(memberUID)
+	if !ok {
+		return nil
+	}
+	switch member.LocationID {
+	case n.FromRoomID:
+		return &game.WorldEvent{Type: "character_departed", Message: fmt.Sprintf("%s leaves to the %s.", n.Name, n.Direction)}
+	case n.ToRoomID:
+		msg := fmt.Sprintf("%s arrives.", n.Name)
+		if from, ok := game.OppositeDirection[n.Direction]; ok {
+			msg = fmt.Sprintf("%s arrives from the %s.", n.Name, from)
+		}
+		return &game.WorldEvent{Type: "character_arrived", Message: msg}
+	}
+	return nil
+}
+
+// handleMove moves the calling member's own character through an exit,
+// subject to the party-split rules, and reveals the destination to them.
+// Members who joined after world-gen are placed at the entrance first.
+func handleMove(g *game.Game, userID, direction string) (*moveNotice, error) {
+	if err := g.EnsureCharacterPlaced(userID); err != nil {
+		return nil, fmt.Errorf("move: %w", err)
+	}
+	player, _ := g.GetPlayerCharacter(userID)
+	fromID := player.LocationID
+	dest, err := g.MoveCharacter(userID, direction)
+	if err != nil {
+		return nil, err
+	}
+	// Persist fog-of-war: mark the destination room as revealed to this member.
+	g.RevealRoom(userID, dest.ID)
+	// Take a spot on the destination's combat grid.
+	g.RoomGrid(dest.ID)
+	return &moveNotice{
+		UserID:     userID,
+		Name:       player.Name,
+		Direction:  direction,
+		FromRoomID: fromID,
+		ToRoomID:   dest.ID,
+	}, nil
+}
+
+// handleStep moves the player to another hex of their room's combat grid.
+func handleStep(g *game.Game, userID, payload string) error {
+	to, err := combat.ParseGridPos(payload)
+	if err != nil {
+		return fmt.Errorf("step: %w", err)
+	}
+	if err := g.StepCharacter(userID, to); err != nil {
+		return fmt.Errorf("step: %w", err)
+	}
+	return nil
+}
+
+// handleAttack resolves a player's attack against a monster using the rpg-toolkit
+// combat engine. It updates the monster's HP in g.RoomMonsters and appends the
+// result to g.PendingCombatContext so the next ws-chat call can inject it into
+// the Narrator system prompt. The first attack in a room rolls initiative and
+// the attacker acts first.
+func handleAttack(ctx context.Context, g *game.Game, userID, targetMonsterID, weaponID string, now time.Time) error {
+	if targetMonsterID == "" {
+		return fmt.Errorf("attack: target monster ID is required")
+	}
+
+	// Get attacker's current room
+	player, ok := g.GetPlayerCharacter(userID)
+	if !ok {
+		return fmt.Errorf("attack: player %s not found in game", userID)
+	}
+	roomID := player.LocationID
+	if roomID == "" {
+		return fmt.Errorf("attack: player has no location")
+	}
+	if g.CombatActive() && g.Turn.RoomID != roomID {
+		return fmt.Errorf("attack: the party is already fighting in another room")
+	}
+
+	// Get the attacking player's DnD character
+	dndChar, hasDnD := g.GetDnDCharacter(userID)
+	if !hasDnD || dndChar == nil {
+		return fmt.Errorf("attack: player %s has no D&D character", userID)
+	}
+
+	enc, monsterDataList, err := buildRoomEncounter(ctx, g, roomID, userID)
+	if err != nil {
+		return fmt.Errorf("attack: %w", err)
+	}
+	defer enc.Cleanup(ctx)
+
+	// Verify target exists and is alive
+	targetMonster, found := enc.Monsters[targetMonsterID]
+	if !found {
+		return fmt.Errorf("attack: monster %s not found or already defeated", targetMonsterID)
+	}
+	if !targetMonster.IsAlive() {
+		return fmt.Errorf("attack: %s is already defeated", targetMonster.Name())
+	}
+
+	// Roll initiative if this is the first attack in this encounter
+	if !g.CombatActive() {
+		liveMonsters := make([]*monster.Monster, 0, len(enc.Monsters))
+		for _, m := range enc.Monsters {
+			liveMonsters = append(liveMonsters, m)
+		}
+		g.StartCombat(roomID, combat.RollInitiative(enc.Players, liveMonsters), userID, now)
+	}
+
+	// Resolve the attack — monsters respond at their own initiative slots.
+	out, err := combat.ResolvePlayerAttack(ctx, enc, combat.AttackInput{
+		AttackerID: userID,
+		TargetID:   targetMonsterID,
+		WeaponID:   weaponID,
+	})
+	if err != nil {
+		return fmt.Errorf("attack: resolve: %w", err)
+	}
+	// The attack roll was made against the target's AC — the party now knows it.
+	g.RevealMonsterAC(targetMonsterID)
+
+	// Persist updated monster HP back to game state.
+	g.SetRoomMonsters(roomID, encounterMonsterData(monsterDataList, enc))
+
+	// Queue the result for the Narrator
+	combatLog := out.CombatLog
+	if out.TargetDied {
+		combatLog += awardKillXP(g, roomID, targetMonster.ToData())
+	}
+	g.AppendCombatContext(combatLog)
+
+	// End the fight if all monsters in room are now defeated
+	if !g.HasLiveMonstersInRoom(roomID) {
+		g.EndCombat()
+	}
+
+	return nil
+}
+
+// handleCast resolves a spell cast by the calling player. Damaging spells
+// target a monster in the caller's room and start a fight like an attack
+// does; healing spells target a party member in the room. Spell slots are
+// spent from the caller's persisted slot table.
+func handleCast(ctx context.Context, g *game.Game, userID, spellID, targetID string, slotLevel int, now time.Time) error {
+	spell, ok := combat.Spells[spellID]
+	if !ok {
+		return fmt.Errorf("cast: unknown or non-combat spell %q", spellID)
+	}
+	player, ok := g.GetPlayerCharacter(userID)
+	if !ok {
+		return fmt.Errorf("cast: player %s not found in game", userID)
+	}
+	roomID := player.LocationID
+	if roomID == "" {
+		return fmt.Errorf("cast: player has no location")
+	}
+	if g.CombatActive() && g.Turn.RoomID != roomID {
+		return fmt.Errorf("cast: the party is already fighting in another room")
+	}
+	if dndChar, hasDnD := g.GetDnDCharacter(userID); !hasDnD || dndChar == nil {
+		return fmt.Errorf("cast: player %s has no D&D character", userID)
+	}
+	heal := spell.Kind == combat.SpellHeal
+	if !heal && targetID == "" {
+		return fmt.Errorf("cast: target monster ID is required")
+	}
+
+	enc, monsterDataList, err := buildRoomEncounter(ctx, g, roomID, userID)
+	if err != nil {
+		return fmt.Errorf("cast: %w", err)
+	}
+	defer enc.Cleanup(ctx)
+
+	// Casting at a monster starts the fight, the caster acting first.
+	if !heal && !g.CombatActive() {
+		if m, found := enc.Monsters[targetID]; found && m.IsAlive() {
+			liveMonsters := make([]*monster.Monster, 0, len(enc.Monsters))
+			for _, lm := range enc.Monsters {
+				liveMonsters = append(liveMonsters, lm)
+			}
+			g.StartCombat(roomID, combat.RollInitiative(enc.Players, liveMonsters), userID, now)
+		}
+	}
+
+	out, err := combat.ResolveSpell(ctx, enc, combat.CastInput{
+		CasterID:  userID,
+		SpellID:   spellID,
+		TargetID:  targetID,
+		SlotLevel: slotLevel,
+		Known:     g.KnownSpells[userID],
+		Slots:     g.PlayerSpellSlots(userID),
+	})
+	if err != nil {
+		return fmt.Errorf("cast: %w", err)
+	}
+	if spell.Kind == combat.SpellAttack {
+		g.RevealMonsterAC(targetID)
+	}
+
+	combatLog := out.CombatLog
+	if !heal {
+		g.SetRoomMonsters(roomID, encounterMonsterData(monsterDataList, enc))
+		if out.TargetDied {
+			combatLog += awardKillXP(g, roomID, enc.Monsters[targetID].ToData())
+		}
+	}
+	g.AppendCombatContext(combatLog)
+
+	if g.CombatActive() && g.Turn.RoomID == roomID && !g.HasLiveMonstersInRoom(roomID) {
+		g.EndCombat()
+	}
+	return nil
+}
+
+// handleStabilize has the calling player tend to a dying party member in
+// the same room (adjacent to them when positions are known) with a DC 10
+// Wisdom (Medicine) check.
+func handleStabilize(ctx context.Context, g *game.Game, userID, targetUserID string) error {
+	if targetUserID == "" {
+		return fmt.Errorf("stabilize: target party member is required")
+	}
+	healer, ok := g.GetPlayerCharacter(userID)
+	if !ok {
+		return fmt.Errorf("stabilize: player %s not found in game", userID)
+	}
+	patient, ok := g.GetPlayerCharacter(targetUserID)
+	if !ok {
+		return fmt.Errorf("stabilize: party member %s not found", targetUserID)
+	}
+	if patient.LocationID != healer.LocationID {
+		return fmt.Errorf("stabilize: %s is not in this room", patient.Name)
+	}
+	healerDnD, hasDnD := g.GetDnDCharacter(userID)
+	patientDnD, patientHasDnD := g.GetDnDCharacter(targetUserID)
+	if !hasDnD || healerDnD == nil || !patientHasDnD || patientDnD == nil {
+		return fmt.Errorf("stabilize: both characters need D&D stats")
+	}
+	grid := g.RoomGrid(healer.LocationID)
+	if dist, placed := grid.DistanceBetween(userID, targetUserID); placed && dist > 1 {
+		return fmt.Errorf("stabilize: %s is %d ft away — move next to them first", patient.Name, dist*combat.FeetPerHex)
+	}
+	stabilizeLog, err := combat.Stabilize(ctx, healerDnD, patientDnD, nil)
+	if err != nil {
+		return fmt.Errorf("stabilize: %w", err)
+	}
+	g.AppendCombatContext(stabilizeLog)
+	return nil
+}
+
+// awardKillXP shares a defeated monster's XP (by challenge rating) among the
+// party members in the room and returns a line for the combat log.
+func awardKillXP(g *game.Game, roomID string, m *monster.Data) string {
+	xp := combat.MonsterXP(m)
+	share := g.AwardXP(roomID, xp)
+	if share == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s defeated — %d XP (%d each).\n", m.Name, xp, share)
+}
+
+// handleLevelUp advances the calling player one level. Members in an active
+// fight must finish it first.
+func handleLevelUp(ctx context.Context, g *game.Game, userID, payload string) error {
+	var req game.LevelUpRequest
+	if payload != "" {
+		if err := json.Unmarshal([]byte(payload), &req); err != nil {
+			return fmt.Errorf("level_up: invalid payload: %w", err)
+		}
+	}
+	if g.InCombatOrder(userID) {
+		return fmt.Errorf("level_up: you can't level up in the middle of a fight")
+	}
+	res, err := g.LevelUp(ctx, userID, req, nil)
+	if err != nil {
+		return fmt.Errorf("level_up: %w", err)
+	}
+	player, _ := g.GetPlayerCharacter(userID)
+	g.AppendCombatContext(fmt.Sprintf("%s reached level %d (+%d HP).", player.Name, res.Level, res.HPGained))
+	return nil
+}
@@ -0,0 +1,394 @@
+package wsgameaction
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/monster"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rrochlin/an-amazing-adventure/internal/combat"
+	"github.com/rrochlin/an-amazing-adventure/internal/db"
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+	"github.com/rrochlin/an-amazing-adventure/internal/wsutil"
+)
+
+func assertPanicsWithEnvAbsent(t *testing.T, envVar string, fn func()) {
+	t.Helper()
+	t.Setenv(envVar, "")
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Errorf("expected panic for missing %s, but handler did not panic", envVar)
+			return
+		}
+		msg := ""
+		switch v := r.(type) {
+		case string:
+			msg = v
+		case error:
+			msg = v.Error()
+		}
+		if !strings.Contains(msg, envVar) {
+			t.Errorf("panic message %q does not mention %s", msg, envVar)
+		}
+	}()
+	fn()
+}
+
+func makeActionReq(connID, body string) events.APIGatewayWebsocketProxyRequest {
+	return events.APIGatewayWebsocketProxyRequest{
+		Body: body,
+		RequestContext: events.APIGatewayWebsocketProxyRequestContext{
+			ConnectionID: connID,
+		},
+	}
+}
+
+func TestHandlerAction_InvalidJSON(t *testing.T) {
+	req := makeActionReq("conn-1", "bad-json")
+	resp, err := Handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected lambda error: %v", err)
+	}
+	if resp.StatusCode != 400 {
+		t.Errorf("expected 400 for invalid JSON, got %d", resp.StatusCode)
+	}
+}
+
+func TestHandlerAction_ValidBody_ReachesDB(t *testing.T) {
+	t.Setenv("CONNECTIONS_TABLE", "test-connections")
+	t.Setenv("SESSIONS_TABLE", "test-sessions")
+	t.Setenv("WEBSOCKET_API_ENDPOINT", "https://test.execute-api.us-west-2.amazonaws.com/prod")
+
+	body, _ := json.Marshal(actionRequest{
+		Action:    "game_action",
+		SubAction: "move",
+		Payload:   "north",
+	})
+	req := makeActionReq("conn-abc", string(body))
+	resp, err := Handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected lambda error: %v", err)
+	}
+	// Should fail at DB layer (410 Gone or 500), not at parse layer (400)
+	if resp.StatusCode == 400 {
+		t.Errorf("routing/parse failure — expected to reach DB layer, got 400")
+	}
+}
+
+func TestActionRequest_SubActions(t *testing.T) {
+	cases := []struct {
+		subAction string
+		payload   string
+	}{
+		{"move", "north"},
+		{"pick_up", "Rusty Dagger"},
+		{"drop", "Heavy Shield"},
+		{"equip", "Iron Helm"},
+		{"unequip", "head"},
+		{"skip", ""},
+		{"step", "3,4"},
+	}
+	for _, c := range cases {
+		body, _ := json.Marshal(actionRequest{
+			Action:    "game_action",
+			SubAction: c.subAction,
+			Payload:   c.payload,
+		})
+		var parsed actionRequest
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			t.Errorf("failed to parse action %q: %v", c.subAction, err)
+		}
+		if parsed.SubAction != c.subAction {
+			t.Errorf("expected sub_action=%q, got %q", c.subAction, parsed.SubAction)
+		}
+		if parsed.Payload != c.payload {
+			t.Errorf("expected payload=%q, got %q", c.payload, parsed.Payload)
+		}
+	}
+}
+
+// ---- Required env var tests ----
+// ws-game-action calls GetConnection first, so CONNECTIONS_TABLE panics immediately.
+// SESSIONS_TABLE is required later (GetGame) but unreachable without real DynamoDB.
+// Both vars are set in Terraform — see modules/lambdas/main.tf.
+
+func TestHandlerAction_MissingCONNECTIONS_TABLE_Panics(t *testing.T) {
+	t.Setenv("SESSIONS_TABLE", "test-sessions")
+	t.Setenv("WEBSOCKET_API_ENDPOINT", "https://test.execute-api.us-west-2.amazonaws.com/prod")
+	body, _ := json.Marshal(actionRequest{Action: "game_action", SubAction: "move", Payload: "north"})
+	assertPanicsWithEnvAbsent(t, "CONNECTIONS_TABLE", func() {
+		Handler(context.Background(), makeActionReq("conn-1", string(body))) //nolint:errcheck
+	})
+}
+
+func TestHandlerAction_Equip_ReachesDB(t *testing.T) {
+	t.Setenv("CONNECTIONS_TABLE", "test-connections")
+	t.Setenv("SESSIONS_TABLE", "test-sessions")
+	t.Setenv("WEBSOCKET_API_ENDPOINT", "https://test.execute-api.us-west-2.amazonaws.com/prod")
+
+	body, _ := json.Marshal(actionRequest{
+		Action:    "game_action",
+		SubAction: "equip",
+		Payload:   "Iron Helm",
+	})
+	req := makeActionReq("conn-equip", string(body))
+	resp, err := Handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected lambda error: %v", err)
+	}
+	if resp.StatusCode == 400 {
+		t.Errorf("parse failure — expected to reach DB layer, got 400")
+	}
+}
+
+func TestHandlerAction_Unequip_ReachesDB(t *testing.T) {
+	t.Setenv("CONNECTIONS_TABLE", "test-connections")
+	t.Setenv("SESSIONS_TABLE", "test-sessions")
+	t.Setenv("WEBSOCKET_API_ENDPOINT", "https://test.execute-api.us-west-2.amazonaws.com/prod")
+
+	body, _ := json.Marshal(actionRequest{
+		Action:    "game_action",
+		SubAction: "unequip",
+		Payload:   "head",
+	})
+	req := makeActionReq("conn-unequip", string(body))
+	resp, err := Handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected lambda error: %v", err)
+	}
+	if resp.StatusCode == 400 {
+		t.Errorf("parse failure — expected to reach DB layer, got 400")
+	}
+}
+
+// ---- Move notices ----
+
+func TestMoveNotice_EventsForAffectedRooms(t *testing.T) {
+	g := game.NewGame("session-1", "owner")
+	from := game.NewArea("Hall", "")
+	to := game.NewArea("Crypt", "")
+	elsewhere := game.NewArea("Well", "")
+	for _, r := range []game.Area{from, to, elsewhere} {
+		_ = g.AddRoom(r)
+	}
+	for uid, roomID := range map[string]string{"owner": to.ID, "stayer": from.ID, "mover": to.ID, "far": elsewhere.ID} {
+		g.SetPlayerCharacter(uid, game.NewCharacter(uid, ""))
+		if err := g.PlaceCharacter(uid, roomID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n := &moveNotice{UserID: "mover", Name: "Mira", Direction: "north", FromRoomID: from.ID, ToRoomID: to.ID}
+
+	if ev := n.eventFor(g, "stayer"); ev == nil || ev.Type != "character_departed" {
+		t.Errorf("expected character_departed for member left behind, got %+v", ev)
+	}
+	ev := n.eventFor(g, "owner")
+	if ev == nil || ev.Type != "character_arrived" {
+		t.Fatalf("expected character_arrived for member in destination, got %+v", ev)
+	}
+	if !strings.Contains(ev.Message, "from the south") {
+		t.Errorf("expected arrival from the opposite direction, got %q", ev.Message)
+	}
+	if ev := n.eventFor(g, "mover"); ev != nil {
+		t.Errorf("mover must not be notified of their own move, got %+v", ev)
+	}
+	if ev := n.eventFor(g, "far"); ev != nil {
+		t.Errorf("members in unrelated rooms must not be notified, got %+v", ev)
+	}
+}
+
+func TestHandleCast_RefusedInAnotherRoomDuringAFight(t *testing.T) {
+	g := game.NewGame("session-1", "owner")
+	hall := game.NewArea("Hall", "")
+	crypt := game.NewArea("Crypt", "")
+	for _, r := range []game.Area{hall, crypt} {
+		_ = g.AddRoom(r)
+	}
+	for uid, roomID := range map[string]string{"owner": hall.ID, "wizard": crypt.ID} {
+		g.SetPlayerCharacter(uid, game.NewCharacter(uid, ""))
+		if err := g.PlaceCharacter(uid, roomID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	g.StartCombat(hall.ID, []combat.InitiativeEntry{{CombatantID: "owner", CombatantName: "owner", Roll: 12, IsPlayer: true}}, "owner", now)
+
+	// The wizard is not in the fight; a bolt in the crypt must not open a
+	// second one with no initiative of its own.
+	err := handleCast(context.Background(), g, "wizard", "fire-bolt", "monster-1", 0, now)
+	if err == nil || !strings.Contains(err.Error(), "another room") {
+		t.Fatalf("handleCast = %v, want the other-room refusal", err)
+	}
+	if g.Turn.RoomID != hall.ID {
+		t.Errorf("the fight moved to %q", g.Turn.RoomID)
+	}
+}
+
+func TestChangesWorld(t *testing.T) {
+	for _, sub := range []string{"move", "pick_up", "drop", "attack", "cast", "stabilize"} {
+		if !changesWorld(sub) {
+			t.Errorf("expected %q to be refused after the run ends", sub)
+		}
+	}
+	for _, sub := range []string{"equip", "unequip", "level_up"} {
+		if changesWorld(sub) {
+			t.Errorf("expected %q to stay allowed after the run ends", sub)
+		}
+	}
+}
+
+func TestTakesTurn(t *testing.T) {
+	for _, sub := range []string{"move", "pick_up", "drop", "attack", "cast", "stabilize", "skip"} {
+		if !takesTurn(sub) {
+			t.Errorf("expected %q to take a turn", sub)
+		}
+	}
+	for _, sub := range []string{"equip", "unequip", "level_up"} {
+		if takesTurn(sub) {
+			t.Errorf("expected %q to be a free action", sub)
+		}
+	}
+}
+
+func TestTurnChanged(t *testing.T) {
+	a := &game.TurnView{Active: true, Round: 1, CombatantID: "u1"}
+	b := &game.TurnView{Active: true, Round: 1, CombatantID: "m1"}
+	if turnChanged(a, a) || turnChanged(nil, nil) {
+		t.Error("identical snapshots must not count as a change")
+	}
+	if !turnChanged(a, b) || !turnChanged(a, nil) || !turnChanged(nil, a) {
+		t.Error("expected a change when combatant moves or combat starts/ends")
+	}
+}
+
+func TestOnTurnOnly(t *testing.T) {
+	if !onTurnOnly("step") || !onTurnOnly("attack") {
+		t.Error("expected step and attack to wait for the caller's turn")
+	}
+	if onTurnOnly("skip") || onTurnOnly("equip") {
+		t.Error("skip and equip must be usable out of turn")
+	}
+}
+
+// fakeManagementAPI points wsutil at a local server that records the frame
+// types posted to each connection.
+func fakeManagementAPI(t *testing.T) func(connID string) []wsutil.FrameType {
+	t.Helper()
+	var mu sync.Mutex
+	frames := make(map[string][]wsutil.FrameType)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		connID, _ := strings.CutPrefix(r.URL.Path, "/@connections/")
+		body, _ := io.ReadAll(r.Body)
+		var f wsutil.Frame
+		_ = json.Unmarshal(body, &f)
+		mu.Lock()
+		frames[connID] = append(frames[connID], f.Type)
+		mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("AWS_REGION", "us-west-2")
+	t.Setenv("AWS_ACCESS_KEY_ID", "local")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "local")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	t.Setenv("WEBSOCKET_API_ENDPOINT", srv.URL)
+	return func(connID string) []wsutil.FrameType {
+		mu.Lock()
+		defer mu.Unlock()
+		return frames[connID]
+	}
+}
+
+func TestHandlerAction_MoveHoldsTheLeaseWhileItSaves(t *testing.T) {
+	ctx := context.Background()
+	framesFor := fakeManagementAPI(t)
+	store := db.NewMemory()
+	g := game.NewGame("s1", "user-1")
+	g.SetPlayerCharacter("user-1", game.NewCharacter("Brom", "a dwarf"))
+	hall := game.NewArea("Hall", "")
+	vault := game.NewArea("Vault", "")
+	_ = g.AddRoom(hall)
+	_ = g.AddRoom(vault)
+	_ = g.ConnectRooms(hall.ID, vault.ID, "north")
+	_ = g.PlaceCharacter("user-1", hall.ID)
+	if err := store.PutGame(ctx, g.ToSaveState(nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+	_ = store.PutConnection(ctx, db.Connection{ConnectionID: "conn-1", UserID: db.BinaryID("user-1"), GameID: "s1"})
+	move := makeActionReq("conn-1", `{"action":"game_action","sub_action":"move","payload":"north"}`)
+
+	// A narrator turn is streaming: the move waits.
+	narrator := db.Lease{SessionID: db.BinaryID("s1"), Holder: "conn-1", ExpiresAt: time.Now().Add(time.Minute).Unix()}
+	_ = store.AcquireLease(ctx, narrator)
+	if resp, _ := New(store)(ctx, move); resp.StatusCode != 200 {
+		t.Fatalf("blocked move: status %d", resp.StatusCode)
+	}
+	if got := framesFor("conn-1"); len(got) != 1 || got[0] != wsutil.FrameStreamingBlocked {
+		t.Errorf("blocked move frames = %v", got)
+	}
+	if saved, _ := store.GetGame(ctx, "s1"); saved.Players["user-1"].LocationID != hall.ID {
+		t.Error("a move must not save under a narrator turn, even from the same connection")
+	}
+
+	_ = store.ReleaseLease(ctx, "s1", "conn-1")
+	if resp, _ := New(store)(ctx, move); resp.StatusCode != 200 {
+		t.Fatalf("move: status %d", resp.StatusCode)
+	}
+	if saved, _ := store.GetGame(ctx, "s1"); saved.Players["user-1"].LocationID != vault.ID {
+		t.Error("expected the move to be saved")
+	}
+	if lease, _ := store.GetLease(ctx, "s1"); lease != nil {
+		t.Errorf("lease left behind: %+v", lease)
+	}
+}
+
+func TestHandlerAction_SkipsAnIdleTurnInTheSameSave(t *testing.T) {
+	ctx := context.Background()
+	framesFor := fakeManagementAPI(t)
+	store := db.NewMemory()
+	g := game.NewGame("s1", "user-1")
+	hall := game.NewArea("Hall", "")
+	_ = g.AddRoom(hall)
+	for _, uid := range []string{"user-1", "user-2"} {
+		g.SetPlayerCharacter(uid, game.NewCharacter(uid, ""))
+		_ = g.PlaceCharacter(uid, hall.ID)
+		_ = store.PutConnection(ctx, db.Connection{ConnectionID: "conn-" + uid, UserID: db.BinaryID(uid), GameID: "s1"})
+	}
+	g.SetRoomMonsters(hall.ID, []*monster.Data{{ID: "wolf-1", Name: "Wolf", HitPoints: 11, MaxHitPoints: 11}})
+	order := []combat.InitiativeEntry{
+		{CombatantID: "user-2", CombatantName: "user-2", Roll: 15, IsPlayer: true},
+		{CombatantID: "user-1", CombatantName: "user-1", Roll: 9, IsPlayer: true},
+	}
+	g.StartCombat(hall.ID, order, "user-2", time.Now().Add(-combat.TurnTimeout-time.Second))
+	start := g.ToSaveState(nil, nil)
+	if err := store.PutGame(ctx, start); err != nil {
+		t.Fatal(err)
+	}
+
+	// user-2 let their turn lapse; user-1's attack skips it, then is refused
+	// (there is no such target), and the skip is still saved.
+	resp, _ := New(store)(ctx, makeActionReq("conn-user-1", `{"action":"game_action","sub_action":"attack","payload":"nobody"}`))
+	if resp.StatusCode != 200 {
+		t.Fatalf("attack: status %d", resp.StatusCode)
+	}
+	saved, _ := store.GetGame(ctx, "s1")
+	if saved.Version != start.Version+1 {
+		t.Errorf("expected one save, version went %d → %d", start.Version, saved.Version)
+	}
+	if saved.Turn == nil || saved.InitiativeOrder[saved.Turn.Index].CombatantID != "user-1" {
+		t.Errorf("expected user-1's turn after the skip, got %+v", saved.Turn)
+	}
+	if lease, _ := store.GetLease(ctx, "s1"); lease != nil {
+		t.Errorf("lease left behind: %+v", lease)
+	}
+	if got := framesFor("conn-user-1"); len(got) == 0 || got[0] != wsutil.FrameError {
+		t.Errorf("expected the refusal first, got %v", got)
+	}
+}
@@ -0,0 +1,127 @@
+// Package wsooc handles the WebSocket "ooc" route: out-of-character chat
+// between party members. Messages are relayed to every connection in the
+// session as ooc_message frames. The narrator never sees them, so they cost
+// no tokens and need no streaming lease — players can talk while a turn
+// streams. The last game.OOCWindow messages are kept in the session log and
+// come back with the game state (GET /api/games/{uuid}) on reconnect.
+package wsooc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rrochlin/an-amazing-adventure/internal/db"
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+	"github.com/rrochlin/an-amazing-adventure/internal/wsutil"
+)
+
+type oocRequest struct {
+	Action  string `json:"action"`
+	Content string `json:"content"`
+}
+
+// Handler is the Lambda entry point. It serves each invocation from DynamoDB.
+func Handler(ctx context.Context, req events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	store, err := db.New(ctx)
+	if err != nil {
+		log.Printf("ws-ooc: db init: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+	}
+	return handle(ctx, store, req)
+}
+
+// New returns a handler backed by store, so tests and cmd/local-server can run
+// it against db.NewMemory.
+func New(store db.Store) func(context.Context, events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return func(ctx context.Context, req events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return handle(ctx, store, req)
+	}
+}
+
+func handle(ctx context.Context, store db.Store, req events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	connID := req.RequestContext.ConnectionID
+
+	var msg oocRequest
+	if err := json.Unmarshal([]byte(req.Body), &msg); err != nil {
+		log.Printf("ws-ooc: bad body conn=%s: %v", connID, err)
+		return events.APIGatewayProxyResponse{StatusCode: 400}, nil
+	}
+
+	conn, err := store.GetConnection(ctx, connID)
+	if err != nil {
+		log.Printf("ws-ooc: get connection conn=%s: %v", connID, err)
+		return events.APIGatewayProxyResponse{StatusCode: 410}, nil
+	}
+	userID := string(conn.UserID)
+
+	ws, err := wsutil.New(ctx)
+	if err != nil {
+		return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+	}
+
+	content := strings.TrimSpace(msg.Content)
+	if content == "" {
+		_ = ws.SendError(ctx, connID, "Message is empty.")
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	}
+	if utf8.RuneCountInString(content) > game.MaxOOCLength {
+		_ = ws.SendError(ctx, connID, fmt.Sprintf("Message is too long (at most %d characters).", game.MaxOOCLength))
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	}
+
+	// Sign the message with the sender's character name, as the party knows them.
+	saveState, err := store.GetGame(ctx, conn.GameID)
+	if err != nil {
+		return events.APIGatewayProxyResponse{StatusCode: 404}, nil
+	}
+	g, err := game.FromSaveState(saveState)
+	if err != nil {
+		return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+	}
+	char, _ := g.GetPlayerCharacter(userID)
+
+	// Any frame moves a fight on past an idle turn; ws-game-action runs monsters.
+	// Likewise a timed-out action round; ws-chat narrates it.
+	now := time.Now()
+	if g.TurnExpired(now) {
+		if err := wsutil.Forward(ctx, "GAME_ACTION_ARN", connID, wsutil.SkipExpiredTurn); err != nil {
+			log.Printf("ws-ooc: forward expired turn (non-fatal): %v", err)
+		}
+	}
+	if g.RoundExpired(now) {
+		if err := wsutil.Forward(ctx, "CHAT_ARN", connID, wsutil.ResolveExpiredRound); err != nil {
+			log.Printf("ws-ooc: forward expired round (non-fatal): %v", err)
+		}
+	}
+
+	sent, err := store.AppendOOC(ctx, conn.GameID, game.OOCMessage{
+		UserID:  userID,
+		Name:    char.Name,
+		Content: content,
+		SentAt:  now.Unix(),
+	})
+	if err != nil {
+		log.Printf("ws-ooc: append: %v", err)
+		if sent.Seq == 0 {
+			_ = ws.SendError(ctx, connID, "Failed to send message.")
+			return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+		}
+	}
+
+	allConns, _ := store.GetConnectionsByGameID(ctx, conn.GameID)
+	connIDs := make([]string, 0, len(allConns))
+	for _, c := range allConns {
+		connIDs = append(connIDs, c.ConnectionID)
+	}
+	if len(connIDs) == 0 {
+		connIDs = []string{connID}
+	}
+	_ = ws.BroadcastAndCleanStale(ctx, connIDs, wsutil.Frame{Type: wsutil.FrameOOCMessage, Payload: sent}, store)
+	return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+}
@@ -0,0 +1,132 @@
+package wsooc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rrochlin/an-amazing-adventure/internal/db"
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+	"github.com/rrochlin/an-amazing-adventure/internal/wsutil"
+)
+
+// posted records the frames handlers post to the Management API, by connection.
+type posted struct {
+	mu     sync.Mutex
+	frames map[string][]wsutil.Frame
+}
+
+func fakeManagementAPI(t *testing.T) *posted {
+	t.Helper()
+	p := &posted{frames: make(map[string][]wsutil.Frame)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		connID, _ := strings.CutPrefix(r.URL.Path, "/@connections/")
+		body, _ := io.ReadAll(r.Body)
+		var f wsutil.Frame
+		_ = json.Unmarshal(body, &f)
+		p.mu.Lock()
+		p.frames[connID] = append(p.frames[connID], f)
+		p.mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("AWS_REGION", "us-west-2")
+	t.Setenv("AWS_ACCESS_KEY_ID", "local")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "local")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	t.Setenv("WEBSOCKET_API_ENDPOINT", srv.URL)
+	return p
+}
+
+func makeOOCReq(connID, content string) events.APIGatewayWebsocketProxyRequest {
+	body, _ := json.Marshal(oocRequest{Action: "ooc", Content: content})
+	return events.APIGatewayWebsocketProxyRequest{
+		Body:           string(body),
+		RequestContext: events.APIGatewayWebsocketProxyRequestContext{ConnectionID: connID},
+	}
+}
+
+func seedParty(t *testing.T, store *db.Memory) {
+	t.Helper()
+	ctx := context.Background()
+	g := game.NewGame("s1", "user-1")
+	g.SetPlayerCharacter("user-1", game.NewCharacter("Brom", "a dwarf"))
+	g.SetPlayerCharacter("user-2", game.NewCharacter("Lia", "an elf"))
+	if err := store.PutGame(ctx, g.ToSaveState(nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []db.Connection{
+		{ConnectionID: "conn-1", UserID: "user-1", GameID: "s1"},
+		{ConnectionID: "conn-2", UserID: "user-2", GameID: "s1"},
+	} {
+		if err := store.PutConnection(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestHandle_InvalidJSON(t *testing.T) {
+	req := events.APIGatewayWebsocketProxyRequest{Body: "bad-json"}
+	resp, _ := New(db.NewMemory())(context.Background(), req)
+	if resp.StatusCode != 400 {
+		t.Errorf("expected 400 for invalid JSON, got %d", resp.StatusCode)
+	}
+}
+
+func TestHandle_RelaysToPartyAndKeepsHistory(t *testing.T) {
+	ctx := context.Background()
+	frames := fakeManagementAPI(t)
+	store := db.NewMemory()
+	seedParty(t, store)
+	// A narrator turn in progress does not hold up party chat.
+	if err := store.AcquireLease(ctx, db.Lease{SessionID: "s1", Holder: "conn-2", ExpiresAt: 1 << 40}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := New(store)(ctx, makeOOCReq("conn-1", "  brb, pizza  "))
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("resp = %d, err = %v", resp.StatusCode, err)
+	}
+	for _, connID := range []string{"conn-1", "conn-2"} {
+		got := frames.frames[connID]
+		if len(got) != 1 || got[0].Type != wsutil.FrameOOCMessage {
+			t.Fatalf("%s frames = %+v", connID, got)
+		}
+		payload, _ := got[0].Payload.(map[string]any)
+		if payload["content"] != "brb, pizza" || payload["name"] != "Brom" || payload["user_id"] != "user-1" {
+			t.Errorf("%s payload = %v", connID, payload)
+		}
+	}
+
+	msgs, err := store.RecentOOC(ctx, "s1")
+	if err != nil || len(msgs) != 1 || msgs[0].Content != "brb, pizza" {
+		t.Errorf("RecentOOC = %+v, %v", msgs, err)
+	}
+}
+
+func TestHandle_RejectsEmptyAndOverlongMessages(t *testing.T) {
+	ctx := context.Background()
+	frames := fakeManagementAPI(t)
+	store := db.NewMemory()
+	seedParty(t, store)
+
+	for _, content := range []string{"   ", strings.Repeat("a", game.MaxOOCLength+1)} {
+		if resp, _ := New(store)(ctx, makeOOCReq("conn-1", content)); resp.StatusCode != 200 {
+			t.Errorf("expected 200 with an error frame, got %d", resp.StatusCode)
+		}
+	}
+	if got := frames.frames["conn-1"]; len(got) != 2 || got[0].Type != wsutil.FrameError || got[1].Type != wsutil.FrameError {
+		t.Errorf("sender frames = %+v", got)
+	}
+	if got := frames.frames["conn-2"]; len(got) != 0 {
+		t.Errorf("party should not see rejected messages: %+v", got)
+	}
+	if msgs, _ := store.RecentOOC(ctx, "s1"); len(msgs) != 0 {
+		t.Errorf("rejected messages were stored: %+v", msgs)
+	}
+}
@@ -0,0 +1,211 @@
+package timeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/rrochlin/an-amazing-adventure/internal/db"
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+)
+
+// MaxCheckpoints caps the checkpoints one session keeps; each holds a full
+// copy of the session. The store enforces it in the same write as the
+// checkpoint (see db.Store.PutCheckpoint).
+const MaxCheckpoints = 20
+
+// maxCheckpointName is the longest checkpoint name accepted, in runes.
+const maxCheckpointName = 80
+
+var (
+	// ErrNotMember means the caller is neither the owner nor in the party.
+	ErrNotMember = errors.New("not a member of this session")
+	// ErrBadName means the checkpoint name is empty or too long.
+	ErrBadName = fmt.Errorf("checkpoint name must be 1-%d characters", maxCheckpointName)
+	// ErrTooManyCheckpoints means the session already has MaxCheckpoints.
+	ErrTooManyCheckpoints = fmt.Errorf("a session can keep at most %d checkpoints", MaxCheckpoints)
+	// ErrNoCheckpoint means the checkpoint does not exist in the session.
+	ErrNoCheckpoint = errors.New("checkpoint not found")
+)
+
+// CreateCheckpoint saves the session's current state as a checkpoint named
+// name. Any party member can take one.
+func CreateCheckpoint(ctx context.Context, store db.Store, sessionID, userID, name string) (db.Checkpoint, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > maxCheckpointName {
+		return db.Checkpoint{}, ErrBadName
+	}
+	current, err := store.GetGame(ctx, sessionID)
+	if err != nil {
+		return db.Checkpoint{}, fmt.Errorf("get game: %w", err)
+	}
+	if !isMember(current, userID) {
+		return db.Checkpoint{}, ErrNotMember
+	}
+
+	cp := db.Checkpoint{
+		SessionID:         sessionID,
+		CheckpointID:      uuid.NewString(),
+		Name:              name,
+		CreatedBy:         userID,
+		CreatedAt:         time.Now().UnixMilli(),
+		ConversationCount: current.ConversationCount,
+		State:             current,
+	}
+	if err := store.PutCheckpoint(ctx, cp, MaxCheckpoints); err != nil {
+		if errors.Is(err, db.ErrCheckpointLimit) {
+			return db.Checkpoint{}, ErrTooManyCheckpoints
+		}
+		return db.Checkpoint{}, fmt.Errorf("put checkpoint: %w", err)
+	}
+	return cp, nil
+}
+
+// ListCheckpoints returns the session's checkpoints, oldest first, without
+// their states.
+func ListCheckpoints(ctx context.Context, store db.Store, sessionID, userID string) ([]db.Checkpoint, error) {
+	access, err := store.GetGameAccess(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("get game: %w", err)
+	}
+	if !isMember(access, userID) {
+		return nil, ErrNotMember
+	}
+	checkpoints, err := store.ListCheckpoints(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("list checkpoints: %w", err)
+	}
+	sort.SliceStable(checkpoints, func(i, j int) bool {
+		return checkpoints[i].CreatedAt < checkpoints[j].CreatedAt
+	})
+	return checkpoints, nil
+}
+
+// RestoreCheckpoint puts the session back to a checkpoint and saves that as
+// its next version. As with Rewind, token totals, the invite code and members
+// who joined since are kept. The checkpoint itself stays, so it can be
+// restored again.
+//
+// Errors: ErrNotOwner, ErrTurnInProgress, ErrNoCheckpoint.
+func RestoreCheckpoint(ctx context.Context, store db.Store, sessionID, checkpointID, userID string) (Result, error) {
+	current, err := store.GetGame(ctx, sessionID)
+	if err != nil {
+		return Result{}, fmt.Errorf("get game: %w", err)
+	}
+	if err := checkOwner(current, userID); err != nil {
+		return Result{}, err
+	}
+	if err := checkIdle(ctx, store, sessionID); err != nil {
+		return Result{}, err
+	}
+	cp, err := getCheckpoint(ctx, store, sessionID, checkpointID)
+	if err != nil {
+		return Result{}, err
+	}
+	return replace(ctx, store, current, cp.State, game.MutationEntry{
+		Type:  game.EventRestore,
+		Actor: userID,
+		Turn:  cp.ConversationCount,
+		Input: map[string]any{"checkpoint_id": cp.CheckpointID, "name": cp.Name},
+	})
+}
+
+// ForkCheckpoint starts a new session, owned by the caller, from a
+// checkpoint. The party at the checkpoint comes along as members; the source
+// session is left untouched. The fork gets no invite code and starts its
+// token count at zero.
+//
+// Errors: ErrNotOwner, ErrNoCheckpoint.
+func ForkCheckpoint(ctx context.Context, store db.Store, sessionID, checkpointID, userID string) (Result, error) {
+	current, err := store.GetGame(ctx, sessionID)
+	if err != nil {
+		return Result{}, fmt.Errorf("get game: %w", err)
+	}
+	if err := checkOwner(current, userID); err != nil {
+		return Result{}, err
+	}
+	cp, err := getCheckpoint(ctx, store, sessionID, checkpointID)
+	if err != nil {
+		return Result{}, err
+	}
+
+	fork := cp.State
+	if err := detachLog(ctx, store, sessionID, &fork); err != nil {
+		return Result{}, err
+	}
+	fork.SessionID = game.NewSessionID()
+	fork.Version = 0
+	fork.OwnerID = userID
+	fork.UserID = userID
+	fork.InviteCode = ""
+	fork.TotalTokens = 0
+	if err := store.PutGame(ctx, fork); err != nil {
+		return Result{}, fmt.Errorf("put game: %w", err)
+	}
+	recordSnapshot(ctx, store, game.MutationEntry{
+		Type:  game.EventFork,
+		Actor: userID,
+		Turn:  fork.ConversationCount,
+		Input: map[string]any{"source_session_id": sessionID, "checkpoint_id": cp.CheckpointID, "name": cp.Name},
+	}, fork)
+
+	joinedAt := time.Now().UnixMilli()
+	members := []db.MembershipRecord{{UserID: db.BinaryID(userID), SessionID: db.BinaryID(fork.SessionID), Role: "owner", JoinedAt: joinedAt}}
+	for uid := range fork.Players {
+		if uid != userID {
+			members = append(members, db.MembershipRecord{UserID: db.BinaryID(uid), SessionID: db.BinaryID(fork.SessionID), Role: "member", JoinedAt: joinedAt})
+		}
+	}
+	for _, m := range members {
+		if err := store.PutMembership(ctx, m); err != nil {
+			log.Printf("timeline: fork %s membership for %s (non-fatal): %v", fork.SessionID, m.UserID, err)
+		}
+	}
+	return load(ctx, fork)
+}
+
+// detachLog pulls the whole chat history of s, a state of sessionID, into
+// memory and marks every message unwritten, so saving s under a new session
+// copies its narrative and chat into that session's own log.
+func detachLog(ctx context.Context, store db.Store, sessionID string, s *game.SaveState) error {
+	older, err := store.ReadChat(ctx, sessionID, s.OlderChat)
+	if err != nil {
+		return fmt.Errorf("read chat: %w", err)
+	}
+	history := append(older, s.ChatHistory...)
+	for i := range history {
+		history[i].LogSeq = 0
+	}
+	narrative := append([]game.NarrativeMessage(nil), s.Narrative...)
+	for i := range narrative {
+		narrative[i].LogSeq = 0
+	}
+	s.ChatHistory, s.Narrative, s.OlderChat, s.OlderWhispers = history, narrative, nil, nil
+	return nil
+}
+
+func getCheckpoint(ctx context.Context, store db.Store, sessionID, checkpointID string) (db.Checkpoint, error) {
+	cp, err := store.GetCheckpoint(ctx, sessionID, checkpointID)
+	if err != nil {
+		// Both stores report a missing item as a plain error; a checkpoint
+		// that cannot be read is as good as missing to the caller.
+		log.Printf("timeline: get checkpoint %s/%s: %v", sessionID, checkpointID, err)
+		return db.Checkpoint{}, ErrNoCheckpoint
+	}
+	return cp, nil
+}
+
+// isMember reports whether userID owns s or has a character in it.
+func isMember(s game.SaveState, userID string) bool {
+	if s.UserID == userID || s.OwnerID == userID {
+		return true
+	}
+	_, ok := s.Players[userID]
+	return ok
+}
@@ -0,0 +1,174 @@
+package timeline_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rrochlin/an-amazing-adventure/internal/db"
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+	"github.com/rrochlin/an-amazing-adventure/internal/timeline"
+)
+
+// ---- Checkpoints ----
+
+func TestCheckpoint_RestoreBringsTheSessionBack(t *testing.T) {
+	ctx := context.Background()
+	s, lair := newSession(t)
+	s.narratorTurn("I look around", func(*game.Game) {})
+
+	cp, err := timeline.CreateCheckpoint(ctx, s.store, "s1", "owner", "  before the boss door ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Name != "before the boss door" || cp.ConversationCount != 1 {
+		t.Errorf("checkpoint = %+v", cp)
+	}
+
+	s.narratorTurn("I open the door", func(g *game.Game) {
+		goblins := g.GetRoomMonsters(lair)
+		goblins[0].HitPoints = 1
+		g.SetRoomMonsters(lair, goblins)
+	})
+
+	res, err := timeline.RestoreCheckpoint(ctx, s.store, "s1", cp.CheckpointID, "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, _ := s.store.GetGame(ctx, "s1")
+	if saved.Version != 3 || saved.ConversationCount != 1 || len(saved.ChatHistory) != 1 {
+		t.Errorf("restored v%d count=%d history=%d", saved.Version, saved.ConversationCount, len(saved.ChatHistory))
+	}
+	if saved.RoomMonsters[lair][0].HitPoints == 1 {
+		t.Error("the goblin should be back at full health")
+	}
+	if saved.TotalTokens != 200 {
+		t.Errorf("tokens already spent must be kept, got %d", saved.TotalTokens)
+	}
+	if res.Game == nil || res.State.Version != 3 {
+		t.Error("the restored game should be loaded")
+	}
+
+	entries, _ := s.store.ListMutations(ctx, "s1")
+	if last := lastSnapshot(entries); last.Type != game.EventRestore || last.Patch.Full == nil {
+		t.Errorf("expected a restore snapshot, got %+v", last)
+	}
+	if _, err := s.store.GetCheckpoint(ctx, "s1", cp.CheckpointID); err != nil {
+		t.Error("a restored checkpoint must be kept")
+	}
+}
+
+func TestCheckpoint_ForkStartsANewSession(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newSession(t)
+	s.g.SetPlayerCharacter("member", game.NewCharacter("Vex", "a rogue"))
+	s.narratorTurn("we set off", func(*game.Game) {})
+	cp, err := timeline.CreateCheckpoint(ctx, s.store, "s1", "member", "camp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.narratorTurn("we press on", func(*game.Game) {})
+
+	if _, err := timeline.ForkCheckpoint(ctx, s.store, "s1", cp.CheckpointID, "member"); !errors.Is(err, timeline.ErrNotOwner) {
+		t.Errorf("member fork: err = %v", err)
+	}
+	res, err := timeline.ForkCheckpoint(ctx, s.store, "s1", cp.CheckpointID, "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	forkID := res.State.SessionID
+	if forkID == "" || forkID == "s1" {
+		t.Fatalf("fork session id = %q", forkID)
+	}
+	fork, err := s.store.GetGame(ctx, forkID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork.Version != 0 || fork.ConversationCount != 1 || fork.TotalTokens != 0 || fork.OwnerID != "owner" {
+		t.Errorf("fork = v%d count=%d tokens=%d owner=%s", fork.Version, fork.ConversationCount, fork.TotalTokens, fork.OwnerID)
+	}
+	if _, ok := fork.Players["member"]; !ok {
+		t.Error("the party should come along")
+	}
+	if members, _ := s.store.GetSessionMembers(ctx, forkID); len(members) != 2 {
+		t.Errorf("fork memberships = %+v", members)
+	}
+	if src, _ := s.store.GetGame(ctx, "s1"); src.ConversationCount != 2 {
+		t.Error("the source session must be untouched")
+	}
+
+	entries, _ := s.store.ListMutations(ctx, forkID)
+	if len(entries) == 0 || entries[0].Type != game.EventFork || entries[0].Patch.Full == nil || lastSnapshot(entries).Type != game.EventFork {
+		t.Fatalf("fork events = %+v", entries)
+	}
+	if replayed, err := game.ReplayState(entries); err != nil || replayed.SessionID != forkID {
+		t.Errorf("fork should replay from its own snapshot: %v", err)
+	}
+}
+
+func TestCheckpoint_Refusals(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newSession(t)
+
+	if _, err := timeline.CreateCheckpoint(ctx, s.store, "s1", "owner", " "); !errors.Is(err, timeline.ErrBadName) {
+		t.Errorf("blank name: err = %v", err)
+	}
+	if _, err := timeline.CreateCheckpoint(ctx, s.store, "s1", "owner", strings.Repeat("x", 81)); !errors.Is(err, timeline.ErrBadName) {
+		t.Errorf("long name: err = %v", err)
+	}
+	if _, err := timeline.CreateCheckpoint(ctx, s.store, "s1", "stranger", "mine"); !errors.Is(err, timeline.ErrNotMember) {
+		t.Errorf("stranger: err = %v", err)
+	}
+	if _, err := timeline.ListCheckpoints(ctx, s.store, "s1", "stranger"); !errors.Is(err, timeline.ErrNotMember) {
+		t.Errorf("stranger list: err = %v", err)
+	}
+	for range timeline.MaxCheckpoints {
+		if _, err := timeline.CreateCheckpoint(ctx, s.store, "s1", "owner", "again"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := timeline.CreateCheckpoint(ctx, s.store, "s1", "owner", "one too many"); !errors.Is(err, timeline.ErrTooManyCheckpoints) {
+		t.Errorf("over the cap: err = %v", err)
+	}
+
+	if _, err := timeline.RestoreCheckpoint(ctx, s.store, "s1", "nope", "owner"); !errors.Is(err, timeline.ErrNoCheckpoint) {
+		t.Errorf("missing checkpoint: err = %v", err)
+	}
+	list, _ := timeline.ListCheckpoints(ctx, s.store, "s1", "owner")
+	_ = s.store.AcquireLease(ctx, db.Lease{SessionID: "s1", Holder: "c1", ExpiresAt: time.Now().Add(time.Minute).Unix()})
+	if _, err := timeline.RestoreCheckpoint(ctx, s.store, "s1", list[0].CheckpointID, "owner"); !errors.Is(err, timeline.ErrTurnInProgress) {
+		t.Errorf("streaming: err = %v", err)
+	}
+}
+
+func TestCheckpoint_ConcurrentCreatesKeepTheCap(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newSession(t)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	created := 0
+	for range timeline.MaxCheckpoints + 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := timeline.CreateCheckpoint(ctx, s.store, "s1", "owner", "rush"); err == nil {
+				mu.Lock()
+				created++
+				mu.Unlock()
+			} else if !errors.Is(err, timeline.ErrTooManyCheckpoints) {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if created != timeline.MaxCheckpoints {
+		t.Errorf("created %d checkpoints, want %d", created, timeline.MaxCheckpoints)
+	}
+	if list, _ := timeline.ListCheckpoints(ctx, s.store, "s1", "owner"); len(list) != timeline.MaxCheckpoints {
+		t.Errorf("listed %d checkpoints, want %d", len(list), timeline.MaxCheckpoints)
+	}
+}
@@ -0,0 +1,188 @@
+// Package timeline moves a session through its own history: rewinding to
+// an earlier narrator turn, rebuilt from the typed events in the mutations
+// table, and named checkpoints that can be restored or forked into a new
+// session. The HTTP and WebSocket entry points share it.
+package timeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	dnd5echar "github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/character"
+
+	"github.com/rrochlin/an-amazing-adventure/internal/db"
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+	"github.com/rrochlin/an-amazing-adventure/internal/wsutil"
+)
+
+var (
+	// ErrNotOwner means the caller does not own the session.
+	ErrNotOwner = errors.New("only the session owner can do that")
+	// ErrTurnInProgress means a narrator turn is streaming; rewinding under
+	// it would be overwritten when the turn saves.
+	ErrTurnInProgress = errors.New("a narrator turn is in progress")
+	// ErrBadTurn means the requested turn is not an earlier turn of the
+	// session.
+	ErrBadTurn = errors.New("turn must be an earlier turn of this session")
+)
+
+// Result is a rewound session, saved and loaded.
+type Result struct {
+	State game.SaveState
+	Game  *game.Game
+}
+
+// Rewind restores sessionID to how it was just before narrator turn `turn`
+// (a ConversationCount) and saves that as the session's next version. World
+// state, narrative, chat history and monsters all go back; token totals, the
+// invite code and party members who joined since are kept. The restored
+// state is recorded as a rewind snapshot, so later replays start from it.
+//
+// Errors: ErrNotOwner, ErrTurnInProgress, ErrBadTurn, game.ErrNoSuchTurn and
+// game.ErrNoSnapshot (the session predates typed events).
+func Rewind(ctx context.Context, store db.Store, sessionID, userID string, turn int) (Result, error) {
+	current, err := store.GetGame(ctx, sessionID)
+	if err != nil {
+		return Result{}, fmt.Errorf("get game: %w", err)
+	}
+	if err := checkOwner(current, userID); err != nil {
+		return Result{}, err
+	}
+	if turn < 0 || turn >= current.ConversationCount {
+		return Result{}, ErrBadTurn
+	}
+	if err := checkIdle(ctx, store, sessionID); err != nil {
+		return Result{}, err
+	}
+
+	entries, err := store.ListMutations(ctx, sessionID)
+	if err != nil {
+		return Result{}, fmt.Errorf("list events: %w", err)
+	}
+	restored, err := game.RewindState(entries, turn)
+	if err != nil {
+		return Result{}, err
+	}
+	return replace(ctx, store, current, restored, game.MutationEntry{
+		Type:  game.EventRewind,
+		Actor: userID,
+		Turn:  turn,
+		Input: map[string]any{"turn": turn},
+	})
+}
+
+// checkOwner returns ErrNotOwner unless userID owns s.
+func checkOwner(s game.SaveState, userID string) error {
+	ownerID := s.OwnerID
+	if ownerID == "" {
+		ownerID = s.UserID
+	}
+	if ownerID != userID {
+		return ErrNotOwner
+	}
+	return nil
+}
+
+// checkIdle returns ErrTurnInProgress while the session's streaming lease
+// is held by a narrator turn.
+func checkIdle(ctx context.Context, store db.Store, sessionID string) error {
+	lease, err := store.GetLease(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("get lease: %w", err)
+	}
+	if lease != nil {
+		return ErrTurnInProgress
+	}
+	return nil
+}
+
+// replace saves restored over current as the session's next version, records
+// it as a snapshot event and loads it.
+func replace(ctx context.Context, store db.Store, current, restored game.SaveState, event game.MutationEntry) (Result, error) {
+	carryOver(&restored, current)
+	restored.SessionID = current.SessionID
+	restored.Version = current.Version + 1
+	if err := store.PutGame(ctx, restored); err != nil {
+		return Result{}, fmt.Errorf("put game: %w", err)
+	}
+	recordSnapshot(ctx, store, event, restored)
+	return load(ctx, restored)
+}
+
+// recordSnapshot writes event carrying the whole of s, so later replays and
+// rewinds start from it.
+func recordSnapshot(ctx context.Context, store db.Store, event game.MutationEntry, s game.SaveState) {
+	snap, err := game.NewSnapshotLog(event, s)
+	if err != nil {
+		log.Printf("timeline: record %s of %s (non-fatal): %v", event.Type, s.SessionID, err)
+		return
+	}
+	db.PutEvents(ctx, store, snap, s.SessionID, s.Version)
+}
+
+func load(ctx context.Context, s game.SaveState) (Result, error) {
+	g, err := game.FromSaveState(s)
+	if err != nil {
+		return Result{}, fmt.Errorf("load game: %w", err)
+	}
+	if s.PlayersData != nil {
+		if _, loadErr := g.LoadDnDCharacters(ctx, s.PlayersData); loadErr != nil {
+			log.Printf("timeline: LoadDnDCharacters (non-fatal): %v", loadErr)
+		}
+	}
+	return Result{State: s, Game: g}, nil
+}
+
+// carryOver keeps what a rewind must not undo: tokens already spent, the
+// invite code, and members who joined after the turn being rewound to.
+func carryOver(restored *game.SaveState, current game.SaveState) {
+	restored.TotalTokens = current.TotalTokens
+	restored.InviteCode = current.InviteCode
+	restored.PartySize = current.PartySize
+	for uid, c := range current.Players {
+		if _, ok := restored.Players[uid]; ok {
+			continue
+		}
+		if restored.Players == nil {
+			restored.Players = make(map[string]game.Character)
+		}
+		restored.Players[uid] = c
+		if d, ok := current.PlayersData[uid]; ok {
+			if restored.PlayersData == nil {
+				restored.PlayersData = make(map[string]*dnd5echar.Data)
+			}
+			restored.PlayersData[uid] = d
+		}
+		if spells, ok := current.KnownSpells[uid]; ok {
+			if restored.KnownSpells == nil {
+				restored.KnownSpells = make(map[string][]string)
+			}
+			restored.KnownSpells[uid] = spells
+		}
+	}
+}
+
+// PushState sends every connected party member a full game_state_update of
+// g from their own perspective. Gone connections are removed. Without a
+// WebSocket endpoint (e.g. unit tests) nothing is sent.
+func PushState(ctx context.Context, store db.Store, g *game.Game, history []game.ChatMessage) {
+	ws, err := wsutil.New(ctx)
+	if err != nil {
+		log.Printf("timeline: push state skipped: %v", err)
+		return
+	}
+	conns, err := store.GetConnectionsByGameID(ctx, g.ID)
+	if err != nil {
+		log.Printf("timeline: get connections for %s: %v", g.ID, err)
+		return
+	}
+	for _, c := range conns {
+		view := g.BuildGameStateView(string(c.UserID), history)
+		if sendErr := ws.SendFullState(ctx, c.ConnectionID, view); sendErr != nil {
+			log.Printf("timeline: send state to %s: %v", c.ConnectionID, sendErr)
+			_ = store.DeleteConnection(ctx, c.ConnectionID)
+		}
+	}
+}
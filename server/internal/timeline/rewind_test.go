@@ -0,0 +1,172 @@
+package timeline_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/KirkDiggler/rpg-toolkit/rulebooks/dnd5e/monster"
+
+	"github.com/rrochlin/an-amazing-adventure/internal/combat"
+	"github.com/rrochlin/an-amazing-adventure/internal/db"
+	"github.com/rrochlin/an-amazing-adventure/internal/game"
+	"github.com/rrochlin/an-amazing-adventure/internal/timeline"
+)
+
+// session is a game saved to a store one invocation at a time, recording
+// events the way the handlers do.
+type session struct {
+	t         *testing.T
+	store     *db.Memory
+	g         *game.Game
+	narrative []game.NarrativeMessage
+	history   []game.ChatMessage
+}
+
+func newSession(t *testing.T) (*session, string) {
+	t.Helper()
+	ctx := context.Background()
+	g := game.NewGame("s1", "owner")
+	g.SetPlayerCharacter("owner", game.NewCharacter("Brom", "a dwarf"))
+	lair := game.NewArea("Lair", "")
+	_ = g.AddRoom(lair)
+	g.SetRoomMonsters(lair.ID, []*monster.Data{combat.NewMonsterByType("goblin").ToData()})
+
+	store := db.NewMemory()
+	base := g.ToSaveState(nil, nil)
+	if err := store.PutGame(ctx, base); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := game.NewSnapshotLog(game.MutationEntry{Type: game.EventWorldGen}, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.PutEvents(ctx, store, snap, "s1", base.Version)
+	return &session{t: t, store: store, g: g}, lair.ID
+}
+
+// narratorTurn plays one ws-chat turn: change runs against the world, then
+// the turn's narrative and chat entries are appended and saved.
+func (s *session) narratorTurn(say string, change func(g *game.Game)) {
+	s.t.Helper()
+	ctx := context.Background()
+	if err := s.g.TrackEvents(s.narrative, s.history); err != nil {
+		s.t.Fatal(err)
+	}
+	turn := s.g.ConversationCount
+	change(s.g)
+	s.g.RecordEvent(game.MutationEntry{Type: game.EventTool, Tool: "update_monster"})
+	s.history = append(s.history, game.ChatMessage{Type: "player", Content: say})
+	s.narrative = append(s.narrative, game.NarrativeMessage{Role: "assistant", Content: []game.NarrativeBlock{{Type: "text", Text: say}}})
+	s.g.ConversationCount++
+	s.g.TotalTokens += 100
+	s.g.Version++
+	saved := s.g.ToSaveState(s.narrative, s.history)
+	if err := s.g.Events.Record(game.MutationEntry{Type: game.EventNarratorTurn, Actor: "owner", Turn: turn}, saved); err != nil {
+		s.t.Fatal(err)
+	}
+	if err := s.store.PutGame(ctx, saved); err != nil {
+		s.t.Fatal(err)
+	}
+	db.PutEvents(ctx, s.store, s.g.Events, "s1", saved.Version)
+}
+
+// lastSnapshot returns the last event in entries that is not one of the
+// narrative and chat chunks following a snapshot.
+func lastSnapshot(entries []game.MutationEntry) game.MutationEntry {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Type != game.EventSnapshotLog {
+			return entries[i]
+		}
+	}
+	return game.MutationEntry{}
+}
+
+// ---- Rewind ----
+
+func TestRewind_RestoresTheTurnAndKeepsTheParty(t *testing.T) {
+	ctx := context.Background()
+	s, lair := newSession(t)
+	s.narratorTurn("I look around", func(*game.Game) {})
+	s.narratorTurn("I stab the goblin", func(g *game.Game) {
+		goblins := g.GetRoomMonsters(lair)
+		goblins[0].HitPoints = 1
+		g.SetRoomMonsters(lair, goblins)
+		// A member joins mid-adventure.
+		g.SetPlayerCharacter("member", game.NewCharacter("Vex", "a rogue"))
+	})
+
+	res, err := timeline.Rewind(ctx, s.store, "s1", "owner", 1)
+	if err != nil {
+		t.Fatalf("Rewind: %v", err)
+	}
+	saved, _ := s.store.GetGame(ctx, "s1")
+	if saved.Version != 3 || res.State.Version != 3 {
+		t.Errorf("rewind should save the next version, got v%d", saved.Version)
+	}
+	if saved.ConversationCount != 1 || len(saved.ChatHistory) != 1 || len(saved.Narrative) != 1 {
+		t.Errorf("restored count=%d history=%d narrative=%d", saved.ConversationCount, len(saved.ChatHistory), len(saved.Narrative))
+	}
+	if hp := saved.RoomMonsters[lair][0].HitPoints; hp == 1 {
+		t.Error("the goblin should be back at full health")
+	}
+	if saved.TotalTokens != 200 {
+		t.Errorf("tokens already spent must be kept, got %d", saved.TotalTokens)
+	}
+	if _, ok := saved.Players["member"]; !ok {
+		t.Error("a member who joined since must keep their character")
+	}
+	if res.Game == nil || res.Game.ConversationCount != 1 {
+		t.Error("the rewound game should be loaded")
+	}
+
+	entries, _ := s.store.ListMutations(ctx, "s1")
+	last := lastSnapshot(entries)
+	if last.Type != game.EventRewind || last.Patch == nil || last.Patch.Full == nil || last.Version != 3 {
+		t.Fatalf("expected a rewind snapshot, got %+v", last)
+	}
+	replayed, err := game.ReplayState(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff, _ := game.DiffStates(replayed, saved); !diff.Empty() {
+		t.Errorf("replay after a rewind differs from the saved game: %+v", diff)
+	}
+}
+
+func TestRewind_Refusals(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newSession(t)
+	s.narratorTurn("hello", func(*game.Game) {})
+
+	if _, err := timeline.Rewind(ctx, s.store, "s1", "member", 0); !errors.Is(err, timeline.ErrNotOwner) {
+		t.Errorf("member: err = %v", err)
+	}
+	for _, turn := range []int{-1, 1, 5} {
+		if _, err := timeline.Rewind(ctx, s.store, "s1", "owner", turn); !errors.Is(err, timeline.ErrBadTurn) {
+			t.Errorf("turn %d: err = %v", turn, err)
+		}
+	}
+
+	_ = s.store.AcquireLease(ctx, db.Lease{SessionID: "s1", Holder: "c1", ExpiresAt: time.Now().Add(time.Minute).Unix()})
+	if _, err := timeline.Rewind(ctx, s.store, "s1", "owner", 0); !errors.Is(err, timeline.ErrTurnInProgress) {
+		t.Errorf("streaming: err = %v", err)
+	}
+	saved, _ := s.store.GetGame(ctx, "s1")
+	if saved.Version != 1 {
+		t.Errorf("a refused rewind must not save, got v%d", saved.Version)
+	}
+}
+
+func TestRewind_LegacySessionHasNoHistory(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+	g := game.NewGame("old", "owner")
+	g.ConversationCount = 4
+	_ = store.PutGame(ctx, g.ToSaveState(nil, nil))
+
+	if _, err := timeline.Rewind(ctx, store, "old", "owner", 2); !errors.Is(err, game.ErrNoSuchTurn) {
+		t.Errorf("err = %v", err)
+	}
+}
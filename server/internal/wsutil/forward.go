@@ -0,0 +1,64 @@
+package wsutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	awslambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	awslambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// SkipExpiredTurn is the game_action frame that skips a combat turn which has
+// run past combat.TurnTimeout. ws-game-action skips such a turn itself when
+// a frame arrives late; ws-chat and ws-ooc forward this there.
+const SkipExpiredTurn = `{"action":"game_action","sub_action":"skip","forwarded":true}`
+
+// ResolveExpiredRound is the chat frame that resolves an action round which
+// has run past game.RoundTimeout. ws-chat resolves a late round itself;
+// ws-ooc and ws-game-action forward this there.
+const ResolveExpiredRound = `{"action":"chat","resolve":true,"forwarded":true}`
+
+// WriteEpilogue is the chat frame that has the narrator close the story once
+// the run is cleared or failed. The route that sends dungeon_ended forwards
+// it to ws-chat (ws-chat to itself) once it has released the lease.
+const WriteEpilogue = `{"action":"chat","epilogue":true,"forwarded":true}`
+
+// Forward hands body to the WebSocket route Lambda named by the environment
+// variable fnEnv, as if connectionID had sent it. The invocation is
+// asynchronous (Event), so the caller never waits on the other route. An
+// unset variable skips the forward, the way http-games skips world-gen.
+func Forward(ctx context.Context, fnEnv, connectionID, body string) error {
+	fnName := os.Getenv(fnEnv)
+	if fnName == "" {
+		log.Printf("wsutil: %s not set, not forwarding", fnEnv)
+		return nil
+	}
+	payload, err := json.Marshal(events.APIGatewayWebsocketProxyRequest{
+		Body: body,
+		RequestContext: events.APIGatewayWebsocketProxyRequestContext{
+			ConnectionID: connectionID,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("marshal forwarded frame: %w", err)
+	}
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("load AWS config: %w", err)
+	}
+	_, err = awslambda.NewFromConfig(cfg).Invoke(ctx, &awslambda.InvokeInput{
+		FunctionName:   aws.String(fnName),
+		InvocationType: awslambdatypes.InvocationTypeEvent,
+		Payload:        payload,
+	})
+	if err != nil {
+		return fmt.Errorf("lambda invoke %s: %w", fnName, err)
+	}
+	return nil
+}
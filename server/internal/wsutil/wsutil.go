@@ -31,6 +31,19 @@ const (
 	// it is running, before the game is marked ready.
 	FrameWorldGenLog   FrameType = "world_gen_log"
 	FrameWorldGenReady FrameType = "world_gen_ready"
+	// FrameDungeonEnded is sent once when the dungeon run is cleared or failed.
+	// It carries only the outcome: the sender then forwards
+	// WriteEpilogue to ws-chat, the only route that calls the model, and the
+	// epilogue streams in as narrative chunks.
+	FrameDungeonEnded FrameType = "dungeon_ended"
+	// FrameTurnChanged is sent to the party whenever the initiative pointer
+	// moves, including when a fight starts or ends.
+	FrameTurnChanged FrameType = "turn_changed"
+	// FrameOOCMessage relays one line of out-of-character party chat.
+	FrameOOCMessage FrameType = "ooc_message"
+	// FrameRoundUpdated is sent to the party when the pending action round
+	// changes in round mode: an intent came in or the round was resolved.
+	FrameRoundUpdated FrameType = "round_updated"
 )
 
 // Frame is the JSON envelope sent to the client over WebSocket.